@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/codegangsta/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// serverConfig holds the options of the server command. It can be loaded
+// from a YAML or JSON file, whose keys are the names of the flags of the
+// server command.
+type serverConfig struct {
+	Debug bool `yaml:"debug"`
+
+	DockerSocket     string `yaml:"docker-socket"`
+	DockerHost       string `yaml:"docker-host"`
+	DockerTLSCA      string `yaml:"docker-tls-ca"`
+	DockerTLSCert    string `yaml:"docker-tls-cert"`
+	DockerTLSKey     string `yaml:"docker-tls-key"`
+	DockerAPIVersion string `yaml:"docker-api-version"`
+
+	Address    string `yaml:"address"`
+	Port       int    `yaml:"port"`
+	UnixSocket string `yaml:"unix-socket"`
+
+	TLS       bool   `yaml:"tls"`
+	TLSCert   string `yaml:"tlscert"`
+	TLSKey    string `yaml:"tlskey"`
+	TLSVerify bool   `yaml:"tlsverify"`
+	TLSCACert string `yaml:"tlscacert"`
+
+	AuthTokenFile     string        `yaml:"auth-token-file"`
+	EncryptionKeyFile string        `yaml:"encryption-key-file"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown-timeout"`
+
+	ReconcileWorkers int `yaml:"reconcile-workers"`
+}
+
+// loadServerConfig returns the configuration of the server command. Flags set
+// on the command line take precedence over the config file passed with the
+// --config flag, which in turn takes precedence over the defaults of the
+// flags.
+func loadServerConfig(c *cli.Context) (serverConfig, error) {
+	flags := serverConfigFromFlags(c)
+
+	path := c.String("config")
+	if path == "" {
+		return flags, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return serverConfig{}, fmt.Errorf("unable to read config file: %s", err)
+	}
+
+	// The config file is applied over the defaults, so that it only needs
+	// to contain the options it changes. JSON is a subset of YAML, so both
+	// formats are parsed by the YAML decoder.
+	config := flags
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return serverConfig{}, fmt.Errorf("invalid config file %s: %s", path, err)
+	}
+
+	// Then flags set on the command line are applied over the config file.
+	configValue := reflect.ValueOf(&config).Elem()
+	flagsValue := reflect.ValueOf(flags)
+	for i := 0; i < configValue.NumField(); i++ {
+		if c.IsSet(configValue.Type().Field(i).Tag.Get("yaml")) {
+			configValue.Field(i).Set(flagsValue.Field(i))
+		}
+	}
+
+	return config, nil
+}
+
+// serverConfigFromFlags returns the configuration of the server command from
+// its flags alone.
+func serverConfigFromFlags(c *cli.Context) serverConfig {
+	return serverConfig{
+		Debug:             c.Bool("debug"),
+		DockerSocket:      c.String("docker-socket"),
+		DockerHost:        c.String("docker-host"),
+		DockerTLSCA:       c.String("docker-tls-ca"),
+		DockerTLSCert:     c.String("docker-tls-cert"),
+		DockerTLSKey:      c.String("docker-tls-key"),
+		DockerAPIVersion:  c.String("docker-api-version"),
+		Address:           c.String("address"),
+		Port:              c.Int("port"),
+		UnixSocket:        c.String("unix-socket"),
+		TLS:               c.Bool("tls"),
+		TLSCert:           c.String("tlscert"),
+		TLSKey:            c.String("tlskey"),
+		TLSVerify:         c.Bool("tlsverify"),
+		TLSCACert:         c.String("tlscacert"),
+		AuthTokenFile:     c.String("auth-token-file"),
+		EncryptionKeyFile: c.String("encryption-key-file"),
+		ShutdownTimeout:   c.Duration("shutdown-timeout"),
+		ReconcileWorkers:  c.Int("reconcile-workers"),
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/stretchr/testify/require"
+)
+
+// newServerContext returns the context of the server command run with the
+// provided arguments.
+func newServerContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet(cmdServer.Name, flag.ContinueOnError)
+	for _, f := range cmdServer.Flags {
+		f.Apply(set)
+	}
+	require.NoError(t, set.Parse(args))
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stacks-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`port: 8080
+docker-tls-cert: /etc/stacks/docker-cert.pem
+tlscert: /etc/stacks/cert.pem
+encryption-key-file: /etc/stacks/key
+shutdown-timeout: 10s
+`), 0600))
+
+	testcases := []struct {
+		doc      string
+		args     []string
+		expected func(*serverConfig)
+	}{
+		{
+			doc:      "defaults",
+			expected: func(*serverConfig) {},
+		},
+		{
+			doc:  "file only",
+			args: []string{"--config", path},
+			expected: func(c *serverConfig) {
+				c.Port = 8080
+				c.DockerTLSCert = "/etc/stacks/docker-cert.pem"
+				c.TLSCert = "/etc/stacks/cert.pem"
+				c.EncryptionKeyFile = "/etc/stacks/key"
+				c.ShutdownTimeout = 10 * time.Second
+			},
+		},
+		{
+			doc:  "flags only",
+			args: []string{"--port", "9090", "--tlscert", "/tmp/cert.pem", "--debug"},
+			expected: func(c *serverConfig) {
+				c.Port = 9090
+				c.TLSCert = "/tmp/cert.pem"
+				c.Debug = true
+			},
+		},
+		{
+			doc:  "flags override file",
+			args: []string{"--config", path, "--port", "9090", "--docker-tls-cert", "/tmp/docker-cert.pem"},
+			expected: func(c *serverConfig) {
+				c.Port = 9090
+				c.DockerTLSCert = "/tmp/docker-cert.pem"
+				c.TLSCert = "/etc/stacks/cert.pem"
+				c.EncryptionKeyFile = "/etc/stacks/key"
+				c.ShutdownTimeout = 10 * time.Second
+			},
+		},
+		{
+			doc:  "flags set to their defaults override file",
+			args: []string{"--config", path, "--port", "2375"},
+			expected: func(c *serverConfig) {
+				c.DockerTLSCert = "/etc/stacks/docker-cert.pem"
+				c.TLSCert = "/etc/stacks/cert.pem"
+				c.EncryptionKeyFile = "/etc/stacks/key"
+				c.ShutdownTimeout = 10 * time.Second
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.doc, func(t *testing.T) {
+			expected := serverConfigFromFlags(newServerContext(t))
+			tc.expected(&expected)
+
+			config, err := loadServerConfig(newServerContext(t, tc.args...))
+			require.NoError(t, err)
+			require.Equal(t, expected, config)
+		})
+	}
+}
+
+func TestLoadServerConfigInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stacks-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("tls-cert: /etc/stacks/cert.pem\n"), 0600))
+
+	_, err = loadServerConfig(newServerContext(t, "--config", path))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tls-cert")
+
+	_, err = loadServerConfig(newServerContext(t, "--config", filepath.Join(dir, "missing.yml")))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unable to read config file")
+}
+
+func TestReadEncryptionKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stacks-key")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, []byte("MDEyMzQ1Njc4OWFiY2RlZg==\n"), 0600))
+	key, err := readEncryptionKey(path)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123456789abcdef"), key)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not base64"), 0600))
+	_, err = readEncryptionKey(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid encryption key file "+path)
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/sirupsen/logrus"
@@ -14,6 +19,10 @@ var cmdServer = cli.Command{
 	Usage:  "Starts the Standalone Stacks API server and reconciler",
 	Action: RunStandaloneServer,
 	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "config",
+			Usage: "Path to a YAML or JSON file setting any of the options below, keyed by their names",
+		},
 		cli.BoolFlag{
 			Name:  "debug",
 			Usage: "Enable debug logging",
@@ -23,24 +32,151 @@ var cmdServer = cli.Command{
 			Usage: "Path to the Docker socket (default: /var/run/docker.sock)",
 			Value: "/var/run/docker.sock",
 		},
+		cli.StringFlag{
+			Name:   "docker-host",
+			Usage:  "Address of the Docker engine, such as tcp://host:2376; overrides --docker-socket",
+			EnvVar: "DOCKER_HOST",
+		},
+		cli.StringFlag{
+			Name:  "docker-tls-ca",
+			Usage: "Trust the Docker engine certificate only if signed by this CA",
+		},
+		cli.StringFlag{
+			Name:  "docker-tls-cert",
+			Usage: "Path to the TLS certificate presented to the Docker engine",
+		},
+		cli.StringFlag{
+			Name:  "docker-tls-key",
+			Usage: "Path to the TLS key presented to the Docker engine",
+		},
+		cli.StringFlag{
+			Name:   "docker-api-version",
+			Usage:  "Docker API version to use, instead of negotiating it with the Docker engine",
+			EnvVar: "DOCKER_API_VERSION",
+		},
+		cli.StringFlag{
+			Name:  "address",
+			Usage: "Address on which to expose the stacks API (default: 0.0.0.0)",
+			Value: "0.0.0.0",
+		},
 		cli.IntFlag{
 			Name:  "port",
-			Usage: "Port on which to expose the stacks API (default: 2375)",
+			Usage: "Port on which to expose the stacks API, or 0 to disable TCP (default: 2375)",
 			Value: 2375,
 		},
+		cli.StringFlag{
+			Name:  "unix-socket",
+			Usage: "Path to a unix socket on which to expose the stacks API",
+		},
+		cli.BoolFlag{
+			Name:  "tls",
+			Usage: "Use TLS; implied by --tlsverify",
+		},
+		cli.StringFlag{
+			Name:  "tlscert",
+			Usage: "Path to the TLS certificate file",
+		},
+		cli.StringFlag{
+			Name:  "tlskey",
+			Usage: "Path to the TLS key file",
+		},
+		cli.BoolFlag{
+			Name:  "tlsverify",
+			Usage: "Use TLS and verify the client certificates",
+		},
+		cli.StringFlag{
+			Name:  "tlscacert",
+			Usage: "Trust client certificates signed by this CA only",
+		},
+		cli.StringFlag{
+			Name:  "auth-token-file",
+			Usage: "Path to a file containing the bearer token required from clients",
+		},
+		cli.StringFlag{
+			Name:  "encryption-key-file",
+			Usage: "Path to a file containing the base64-encoded AES key with which stacks holding sensitive values are encrypted (default: a random key)",
+		},
+		cli.DurationFlag{
+			Name:  "shutdown-timeout",
+			Usage: "Time given to in-flight requests to complete on shutdown (default: 30s)",
+			Value: 30 * time.Second,
+		},
+		cli.IntFlag{
+			Name:  "reconcile-workers",
+			Usage: "Number of objects of different stacks reconciled concurrently (default: 4)",
+			Value: 4,
+		},
 	},
 }
 
 // RunStandaloneServer parses CLI arguments and runs the StandaloneServer
 // method from the standalone package.
 func RunStandaloneServer(c *cli.Context) error {
+	config, err := loadServerConfig(c)
+	if err != nil {
+		return err
+	}
+
+	var authToken string
+	if path := config.AuthTokenFile; path != "" {
+		token, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read auth token file: %s", err)
+		}
+		authToken = strings.TrimSpace(string(token))
+		if authToken == "" {
+			return fmt.Errorf("auth token file %s is empty", path)
+		}
+	}
+
+	var encryptionKey []byte
+	if path := config.EncryptionKeyFile; path != "" {
+		encryptionKey, err = readEncryptionKey(path)
+		if err != nil {
+			return err
+		}
+	}
+
 	return standalone.Server(standalone.ServerOptions{
-		Debug:            c.Bool("debug"),
-		DockerSocketPath: c.String("docker-socket"),
-		ServerPort:       c.Int("port"),
+		Debug:             config.Debug,
+		DockerSocketPath:  config.DockerSocket,
+		DockerHost:        config.DockerHost,
+		DockerTLSCAFile:   config.DockerTLSCA,
+		DockerTLSCertFile: config.DockerTLSCert,
+		DockerTLSKeyFile:  config.DockerTLSKey,
+		DockerAPIVersion:  config.DockerAPIVersion,
+		ServerAddress:     config.Address,
+		ServerPort:        config.Port,
+		UnixSocketPath:    config.UnixSocket,
+		TLS:               config.TLS,
+		TLSCertFile:       config.TLSCert,
+		TLSKeyFile:        config.TLSKey,
+		TLSVerify:         config.TLSVerify,
+		TLSCAFile:         config.TLSCACert,
+		AuthToken:         authToken,
+		EncryptionKey:     encryptionKey,
+		ShutdownTimeout:   config.ShutdownTimeout,
+		ReconcileWorkers:  config.ReconcileWorkers,
 	})
 }
 
+// readEncryptionKey reads the base64-encoded encryption key of the stacks
+// from a file.
+func readEncryptionKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption key file: %s", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key file %s: %s", path, err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("encryption key file %s is empty", path)
+	}
+	return key, nil
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "Stacks Standalone Controller"
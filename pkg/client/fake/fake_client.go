@@ -1,13 +1,18 @@
 package fake
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"sync"
 
 	"github.com/docker/docker/errdefs"
 
 	"github.com/docker/stacks/pkg/compose/loader"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/mergepatch"
 	"github.com/docker/stacks/pkg/types"
 )
 
@@ -50,9 +55,15 @@ func (c *StackClient) ParseComposeInput(_ context.Context, input types.ComposeIn
 }
 
 // StackCreate creates a new stack.
-func (c *StackClient) StackCreate(_ context.Context, stack types.StackCreate, _ types.StackCreateOptions) (types.StackCreateResponse, error) {
+func (c *StackClient) StackCreate(_ context.Context, stack types.StackCreate, options types.StackCreateOptions) (types.StackCreateResponse, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	warnings, err := stackWarnings(stack.Spec, options.Strict)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	stack.Spec.MarkSensitive(options.SensitiveProperties)
+
 	newStack := types.Stack{
 		ID: fmt.Sprintf("%d", c.idx),
 		Metadata: types.Metadata{
@@ -63,7 +74,8 @@ func (c *StackClient) StackCreate(_ context.Context, stack types.StackCreate, _
 	c.idx++
 	c.stacks[newStack.ID] = newStack
 	return types.StackCreateResponse{
-		ID: newStack.ID,
+		ID:       newStack.ID,
+		Warnings: warnings,
 	}, nil
 }
 
@@ -76,7 +88,7 @@ func (c *StackClient) StackInspect(_ context.Context, id string) (types.Stack, e
 		return types.Stack{}, errdefs.NotFound(fmt.Errorf("stack not found"))
 	}
 
-	return stack, nil
+	return stack.Redacted(), nil
 }
 
 // StackList lists all stacks.
@@ -86,29 +98,45 @@ func (c *StackClient) StackList(_ context.Context, _ types.StackListOptions) ([]
 
 	allStacks := []types.Stack{}
 	for _, stack := range c.stacks {
-		allStacks = append(allStacks, stack)
+		allStacks = append(allStacks, stack.Redacted())
 	}
 
 	return allStacks, nil
 }
 
 // StackUpdate updates a stack.
-func (c *StackClient) StackUpdate(_ context.Context, id string, version types.Version, spec types.StackSpec, _ types.StackUpdateOptions) error {
+func (c *StackClient) StackUpdate(_ context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) (types.StackUpdateResponse, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	stack, ok := c.stacks[id]
 	if !ok {
-		return errdefs.NotFound(fmt.Errorf("stack not found"))
+		return types.StackUpdateResponse{}, errdefs.NotFound(fmt.Errorf("stack not found"))
 	}
 
 	if version.Index != stack.Version.Index {
-		return fmt.Errorf("update out of sequence")
+		return types.StackUpdateResponse{}, fmt.Errorf("update out of sequence")
 	}
 
-	stack.Spec = spec
+	warnings, err := stackWarnings(spec, options.Strict)
+	if err != nil {
+		return types.StackUpdateResponse{}, err
+	}
+	spec.MarkSensitive(options.SensitiveProperties)
+
+	stack.Spec = spec.WithSensitiveValues(stack.Spec)
 	stack.Version.Index++
 	c.stacks[id] = stack
-	return nil
+	return types.StackUpdateResponse{Warnings: warnings}, nil
+}
+
+// stackWarnings returns the warnings about a stack spec, or an error in
+// strict mode if there are any.
+func stackWarnings(spec types.StackSpec, strict bool) ([]types.Warning, error) {
+	warnings := loader.Warnings(spec.Services)
+	if strict && len(warnings) > 0 {
+		return nil, &types.WarningsError{Warnings: warnings}
+	}
+	return warnings, nil
 }
 
 // StackDelete deletes a stack.
@@ -118,3 +146,167 @@ func (c *StackClient) StackDelete(_ context.Context, id string) error {
 	delete(c.stacks, id)
 	return nil
 }
+
+// StackPatch applies a JSON merge patch to the spec of a stack.
+func (c *StackClient) StackPatch(_ context.Context, id string, patch []byte, options types.StackPatchOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	if options.Version != nil && options.Version.Index != stack.Version.Index {
+		return errdefs.Conflict(fmt.Errorf("update out of sequence"))
+	}
+
+	spec, err := mergepatch.ApplyStackSpec(stack.Spec, patch)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	stack.Spec = spec
+	stack.Version.Index++
+	c.stacks[id] = stack
+	return nil
+}
+
+// StackSetImage sets the image of a service of a stack.
+func (c *StackClient) StackSetImage(_ context.Context, id string, service string, image string, options types.StackPatchOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	if options.Version != nil && options.Version.Index != stack.Version.Index {
+		return errdefs.Conflict(fmt.Errorf("update out of sequence"))
+	}
+
+	services := make([]composetypes.ServiceConfig, len(stack.Spec.Services))
+	copy(services, stack.Spec.Services)
+	for i := range services {
+		if services[i].Name != service {
+			continue
+		}
+		services[i].Image = image
+		stack.Spec.Services = services
+		stack.Version.Index++
+		c.stacks[id] = stack
+		return nil
+	}
+
+	return errdefs.NotFound(fmt.Errorf("service not found"))
+}
+
+// StackRedeploy bumps the version of a stack, as the services of the fake
+// client never run.
+func (c *StackClient) StackRedeploy(_ context.Context, id string, options types.StackRedeployOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	for _, name := range options.Services {
+		found := false
+		for _, service := range stack.Spec.Services {
+			if service.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errdefs.InvalidParameter(fmt.Errorf("service not found"))
+		}
+	}
+
+	stack.Version.Index++
+	c.stacks[id] = stack
+	return nil
+}
+
+// StackPause pauses the reconciliation of a stack.
+func (c *StackClient) StackPause(_ context.Context, id string) error {
+	return c.setPaused(id, true)
+}
+
+// StackResume resumes the reconciliation of a stack.
+func (c *StackClient) StackResume(_ context.Context, id string) error {
+	return c.setPaused(id, false)
+}
+
+func (c *StackClient) setPaused(id string, paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	if stack.Paused != paused {
+		stack.Paused = paused
+		stack.Version.Index++
+		c.stacks[id] = stack
+	}
+	return nil
+}
+
+// StackScale sets the number of replicas of a service of a stack.
+func (c *StackClient) StackScale(_ context.Context, id string, service string, options types.StackScaleOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	services := make([]composetypes.ServiceConfig, len(stack.Spec.Services))
+	copy(services, stack.Spec.Services)
+	for i := range services {
+		if services[i].Name != service {
+			continue
+		}
+		if services[i].Deploy.Mode == "global" {
+			return errdefs.InvalidParameter(fmt.Errorf("service is a global service"))
+		}
+		replicas := options.Replicas
+		services[i].Deploy.Replicas = &replicas
+		stack.Spec.Services = services
+		stack.Version.Index++
+		c.stacks[id] = stack
+		return nil
+	}
+
+	return errdefs.NotFound(fmt.Errorf("service not found"))
+}
+
+// StackWait returns the status of a stack immediately, as there is nothing
+// to wait for in the fake client.
+func (c *StackClient) StackWait(_ context.Context, id string, options types.StackWaitOptions) (types.StackStatus, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		if options.Condition == types.StackWaitConditionDeleted {
+			return types.StackStatus{Phase: types.StackPhaseDeleted}, nil
+		}
+		return types.StackStatus{}, errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	return stack.Status, nil
+}
+
+// StackLogs returns an empty log stream, as the services of the fake client
+// never run.
+func (c *StackClient) StackLogs(_ context.Context, id string, _ types.StackLogsOptions) (io.ReadCloser, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.stacks[id]; !ok {
+		return nil, errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	return ioutil.NopCloser(&bytes.Buffer{}), nil
+}
@@ -46,9 +46,10 @@ func TestFakeStackClientUpdateOutOfSequence(t *testing.T) {
 
 	stackSpec := stack.Spec
 	stackSpec.Services[0].Image = "newimage"
-	require.NoError(c.StackUpdate(ctx, resp.ID, stack.Version, stackSpec, types.StackUpdateOptions{}))
+	_, err = c.StackUpdate(ctx, resp.ID, stack.Version, stackSpec, types.StackUpdateOptions{})
+	require.NoError(err)
 
-	err = c.StackUpdate(ctx, resp.ID, stack.Version, stackSpec, types.StackUpdateOptions{})
+	_, err = c.StackUpdate(ctx, resp.ID, stack.Version, stackSpec, types.StackUpdateOptions{})
 	require.Error(err)
 	require.Contains(err.Error(), "update out of sequence")
 }
@@ -81,7 +82,8 @@ func TestFakeStackClientCRUD(t *testing.T) {
 	// Update
 	stackSpec := stack.Spec
 	stackSpec.Services[0].Image = "newimage"
-	require.NoError(c.StackUpdate(ctx, resp.ID, stack.Version, stackSpec, types.StackUpdateOptions{}))
+	_, err = c.StackUpdate(ctx, resp.ID, stack.Version, stackSpec, types.StackUpdateOptions{})
+	require.NoError(err)
 	stack, err = c.StackInspect(ctx, resp.ID)
 	require.NoError(err)
 	require.True(reflect.DeepEqual(stackSpec, stack.Spec))
@@ -97,3 +99,61 @@ func TestFakeStackClientCRUD(t *testing.T) {
 	require.NoError(err)
 	require.Len(stacks, 0)
 }
+
+func TestFakeStackClientSensitiveProperties(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	c := NewStackClient()
+
+	create := stackCreate
+	create.Spec.PropertyValues = []string{"PASSWORD=s3cret", "USER=admin"}
+	resp, err := c.StackCreate(ctx, create, types.StackCreateOptions{
+		SensitiveProperties: []string{"PASSWORD"},
+	})
+	require.NoError(err)
+
+	stack, err := c.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal([]string{"PASSWORD=" + types.RedactedValue, "USER=admin"}, stack.Spec.PropertyValues)
+	require.True(stack.Spec.Properties["PASSWORD"].Sensitive)
+
+	stacks, err := c.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal(stack.Spec.PropertyValues, stacks[0].Spec.PropertyValues)
+
+	// updating the stack with the redacted value keeps the actual value.
+	spec := stack.Spec
+	spec.PropertyValues = []string{"PASSWORD=" + types.RedactedValue, "USER=root"}
+	_, err = c.StackUpdate(ctx, resp.ID, stack.Version, spec, types.StackUpdateOptions{})
+	require.NoError(err)
+	require.Equal([]string{"PASSWORD=s3cret", "USER=root"}, c.stacks[resp.ID].Spec.PropertyValues)
+}
+
+func TestFakeStackClientSecretData(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	c := NewStackClient()
+
+	create := stackCreate
+	create.Spec.Secrets = map[string]composeTypes.SecretConfig{
+		"password": {File: "./password.txt", Data: []byte("s3cret")},
+	}
+	resp, err := c.StackCreate(ctx, create, types.StackCreateOptions{})
+	require.NoError(err)
+
+	stack, err := c.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Nil(stack.Spec.Secrets["password"].Data)
+	require.Equal("./password.txt", stack.Spec.Secrets["password"].File)
+
+	stacks, err := c.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Nil(stacks[0].Spec.Secrets["password"].Data)
+
+	// updating the stack without the content of the secret keeps it.
+	_, err = c.StackUpdate(ctx, resp.ID, stack.Version, stack.Spec, types.StackUpdateOptions{})
+	require.NoError(err)
+	require.Equal([]byte("s3cret"), c.stacks[resp.ID].Spec.Secrets["password"].Data)
+}
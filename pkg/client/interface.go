@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"io"
 
 	"github.com/docker/stacks/pkg/types"
 )
@@ -12,6 +13,14 @@ type StackAPIClient interface {
 	StackCreate(ctx context.Context, stack types.StackCreate, options types.StackCreateOptions) (types.StackCreateResponse, error)
 	StackInspect(ctx context.Context, id string) (types.Stack, error)
 	StackList(ctx context.Context, options types.StackListOptions) ([]types.Stack, error)
-	StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error
+	StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) (types.StackUpdateResponse, error)
+	StackPatch(ctx context.Context, id string, patch []byte, options types.StackPatchOptions) error
+	StackSetImage(ctx context.Context, id string, service string, image string, options types.StackPatchOptions) error
 	StackDelete(ctx context.Context, id string) error
+	StackRedeploy(ctx context.Context, id string, options types.StackRedeployOptions) error
+	StackPause(ctx context.Context, id string) error
+	StackResume(ctx context.Context, id string) error
+	StackScale(ctx context.Context, id string, service string, options types.StackScaleOptions) error
+	StackWait(ctx context.Context, id string, options types.StackWaitOptions) (types.StackStatus, error)
+	StackLogs(ctx context.Context, id string, options types.StackLogsOptions) (io.ReadCloser, error)
 }
@@ -25,3 +25,40 @@ func (cli *Client) ParseComposeInput(ctx context.Context, input types.ComposeInp
 	ensureReaderClosed(resp)
 	return &response, err
 }
+
+// composeError returns the error of the compose files of an error response
+// of the parsecompose operation, if any. It can be retrieved from the errors
+// of the client with errors.Cause.
+func composeError(body []byte) (*types.ComposeError, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false
+	}
+	if _, ok := fields["file_index"]; !ok {
+		return nil, false
+	}
+	var composeErr types.ComposeError
+	if err := json.Unmarshal(body, &composeErr); err != nil {
+		return nil, false
+	}
+	return &composeErr, true
+}
+
+// variablesError returns the variables of an error response about the
+// variables of a stack, as returned by the parsecompose, create and update
+// operations, if any. It can be retrieved from the errors of the client with
+// errors.Cause.
+func variablesError(body []byte) (*types.VariablesError, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false
+	}
+	if _, ok := fields["variables"]; !ok {
+		return nil, false
+	}
+	var variablesErr types.VariablesError
+	if err := json.Unmarshal(body, &variablesErr); err != nil {
+		return nil, false
+	}
+	return &variablesErr, true
+}
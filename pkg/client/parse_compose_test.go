@@ -3,11 +3,13 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"testing"
 
 	"github.com/docker/stacks/pkg/types"
+	"github.com/pkg/errors"
 	"gotest.tools/assert"
 )
 
@@ -37,3 +39,37 @@ func TestParseComposeInputEmpty(t *testing.T) {
 	_, err = cli.ParseComposeInput(ctx, types.ComposeInput{})
 	assert.NilError(t, err)
 }
+
+func TestParseComposeInputComposeError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			body, err := json.Marshal(&types.ComposeError{
+				FileIndex: 1,
+				File:      "docker-compose.override.yml",
+				Line:      4,
+				Column:    5,
+				Path:      "services.web.ports.0",
+				Message:   "services.web.ports.0 must be a string or number",
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+				Header:     header,
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.ParseComposeInput(ctx, types.ComposeInput{})
+	assert.ErrorContains(t, err, "docker-compose.override.yml:4:5: services.web.ports.0 must be a string or number")
+	composeErr, ok := errors.Cause(err).(*types.ComposeError)
+	assert.Assert(t, ok)
+	assert.Equal(t, 1, composeErr.FileIndex)
+	assert.Equal(t, "services.web.ports.0", composeErr.Path)
+}
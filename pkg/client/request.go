@@ -69,6 +69,11 @@ func (cli *Client) putRaw(ctx context.Context, path string, query url.Values, bo
 	return cli.sendRequest(ctx, "PUT", path, query, body, headers)
 }
 
+// patchRaw sends an http request to the docker API using the method PATCH.
+func (cli *Client) patchRaw(ctx context.Context, path string, query url.Values, body io.Reader, headers map[string][]string) (serverResponse, error) {
+	return cli.sendRequest(ctx, "PATCH", path, query, body, headers)
+}
+
 // delete sends an http request to the docker API using the method DELETE.
 func (cli *Client) delete(ctx context.Context, path string, query url.Values, headers map[string][]string) (serverResponse, error) {
 	return cli.sendRequest(ctx, "DELETE", path, query, nil, headers)
@@ -93,7 +98,7 @@ func encodeBody(obj interface{}, headers headers) (io.Reader, headers, error) {
 }
 
 func (cli *Client) buildRequest(method, path string, body io.Reader, headers headers) (*http.Request, error) {
-	expectedPayload := (method == "POST" || method == "PUT")
+	expectedPayload := (method == "POST" || method == "PUT" || method == "PATCH")
 	if expectedPayload && body == nil {
 		body = bytes.NewReader([]byte{})
 	}
@@ -234,6 +239,12 @@ func (cli *Client) checkResponseErr(serverResp serverResponse) error {
 			return errors.Wrap(err, "Error reading JSON")
 		}
 		errorMessage = strings.TrimSpace(errorResponse.Message)
+		if composeErr, ok := composeError(body); ok {
+			return errors.Wrap(composeErr, "Error response from daemon")
+		}
+		if variablesErr, ok := variablesError(body); ok {
+			return errors.Wrap(variablesErr, "Error response from daemon")
+		}
 	} else {
 		errorMessage = strings.TrimSpace(string(body))
 	}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"net/url"
 
 	"github.com/docker/stacks/pkg/types"
 )
@@ -17,8 +18,16 @@ func (cli *Client) StackCreate(ctx context.Context, stack types.StackCreate, opt
 		headers["X-Registry-Auth"] = []string{options.EncodedRegistryAuth}
 	}
 
+	query := url.Values{}
+	if options.Strict {
+		query.Set("strict", "1")
+	}
+	for _, name := range options.SensitiveProperties {
+		query.Add("sensitive", name)
+	}
+
 	var response types.StackCreateResponse
-	resp, err := cli.post(ctx, "/stacks", nil, stack, headers)
+	resp, err := cli.post(ctx, "/stacks", query, stack, headers)
 	if err != nil {
 		return response, err
 	}
@@ -5,11 +5,17 @@ import (
 	"context"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/pkg/errors"
+
+	controllerRouter "github.com/docker/stacks/pkg/controller/router"
 	"github.com/docker/stacks/pkg/types"
 
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
 )
 
 func TestCreateStackServerError(t *testing.T) {
@@ -38,3 +44,60 @@ func TestCreateStackEmpty(t *testing.T) {
 	_, err = cli.StackCreate(ctx, types.StackCreate{}, types.StackCreateOptions{})
 	assert.NilError(t, err)
 }
+
+// variablesBackend is a stacks API backend failing to create stacks because
+// of their variables.
+type variablesBackend struct {
+	controllerRouter.Backend
+	err *types.VariablesError
+}
+
+func (b variablesBackend) StackWarnings(types.StackSpec) []types.Warning {
+	return nil
+}
+
+func (b variablesBackend) CreateStack(types.StackCreate) (types.StackCreateResponse, error) {
+	return types.StackCreateResponse{}, b.err
+}
+
+func TestCreateStackVariablesError(t *testing.T) {
+	ctx := context.Background()
+	variablesErr := &types.VariablesError{
+		Variables: []types.VariableError{
+			{
+				Variable: "PASSWORD",
+				Message:  "required variable PASSWORD is missing a value",
+				Locations: []types.VariableLocation{
+					{Path: "services.db.environment.PASSWORD"},
+				},
+			},
+			{Variable: "TAG", Message: "required variable TAG is missing a value"},
+		},
+	}
+
+	// the error goes through the route of the API server, and back to the
+	// client.
+	var route httputils.APIFunc
+	for _, r := range controllerRouter.NewRouter(variablesBackend{err: variablesErr}).Routes() {
+		if r.Method() == http.MethodPost && r.Path() == "/stacks" {
+			route = r.Handler()
+		}
+	}
+	assert.Assert(t, route != nil)
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			w := httptest.NewRecorder()
+			if err := route(req.Context(), w, req, map[string]string{}); err != nil {
+				httputils.MakeErrorHandler(err)(w, req)
+			}
+			return w.Result(), nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackCreate(ctx, types.StackCreate{}, types.StackCreateOptions{})
+	assert.ErrorContains(t, err, "stack has 2 invalid variable(s)")
+	got, ok := errors.Cause(err).(*types.VariablesError)
+	assert.Assert(t, ok)
+	assert.Check(t, is.DeepEqual(variablesErr, got))
+}
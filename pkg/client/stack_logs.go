@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackLogs returns the logs of the services of a Stack in an
+// io.ReadCloser, multiplexed with the stdcopy format. It's up to the caller
+// to close the stream.
+func (cli *Client) StackLogs(ctx context.Context, id string, options types.StackLogsOptions) (io.ReadCloser, error) {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	for _, service := range options.Services {
+		query.Add("service", service)
+	}
+	if options.Follow {
+		query.Set("follow", "1")
+	}
+	if options.Tail != "" {
+		query.Set("tail", options.Tail)
+	}
+	if options.Since != "" {
+		query.Set("since", options.Since)
+	}
+	if options.Timestamps {
+		query.Set("timestamps", "1")
+	}
+
+	resp, err := cli.get(ctx, "/stacks/"+id+"/logs", query, headers)
+	if err != nil {
+		return nil, wrapResponseError(err, resp, "stack", id)
+	}
+	return resp.body, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackLogsServerError(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackLogs(ctx, id, types.StackLogsOptions{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackLogs(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			query := req.URL.Query()
+			if val := query["service"]; !reflect.DeepEqual(val, []string{"web", "db"}) {
+				return nil, fmt.Errorf("unexpected service parameter: %v", val)
+			}
+			if val := query.Get("follow"); val != "1" {
+				return nil, fmt.Errorf("unexpected follow parameter: %s", val)
+			}
+			if val := query.Get("tail"); val != "10" {
+				return nil, fmt.Errorf("unexpected tail parameter: %s", val)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("logs")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	body, err := cli.StackLogs(ctx, id, types.StackLogsOptions{
+		Services: []string{"web", "db"},
+		Follow:   true,
+		Tail:     "10",
+	})
+	assert.NilError(t, err)
+	defer body.Close()
+	logs, err := ioutil.ReadAll(body)
+	assert.NilError(t, err)
+	assert.Equal(t, string(logs), "logs")
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackPatch applies a JSON merge patch (RFC 7386) to the spec of an existing
+// Stack
+func (cli *Client) StackPatch(ctx context.Context, id string, patch []byte, options types.StackPatchOptions) error {
+
+	headers := map[string][]string{
+		"version":      {cli.settings.Version},
+		"Content-Type": {"application/merge-patch+json"},
+	}
+
+	query := url.Values{}
+	if options.Version != nil {
+		query.Set("version", strconv.FormatUint(options.Version.Index, 10))
+	}
+
+	resp, err := cli.patchRaw(ctx, "/stacks/"+id, query, bytes.NewReader(patch), headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
+
+// StackSetImage sets the image of a service within an existing Stack
+func (cli *Client) StackSetImage(ctx context.Context, id string, service string, image string, options types.StackPatchOptions) error {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	query.Set("image", image)
+	if options.Version != nil {
+		query.Set("version", strconv.FormatUint(options.Version.Index, 10))
+	}
+
+	resp, err := cli.post(ctx, "/stacks/"+id+"/services/"+service+"/image", query, nil, headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
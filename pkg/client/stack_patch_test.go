@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackPatchServerError(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackPatch(ctx, id, []byte(`{}`), types.StackPatchOptions{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackPatch(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method != "PATCH" {
+				return nil, fmt.Errorf("unexpected method: %s", req.Method)
+			}
+			if val := req.Header.Get("Content-Type"); val != "application/merge-patch+json" {
+				return nil, fmt.Errorf("unexpected content type: %s", val)
+			}
+			if val := req.URL.Query().Get("version"); val != "123" {
+				return nil, fmt.Errorf("unexpected version parameter: %s", val)
+			}
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			if string(body) != `{"collection":"test"}` {
+				return nil, fmt.Errorf("unexpected body: %s", body)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackPatch(ctx, id, []byte(`{"collection":"test"}`), types.StackPatchOptions{
+		Version: &types.Version{Index: 123},
+	})
+	assert.NilError(t, err)
+}
+
+func TestStackSetImage(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/stacks/dummy/services/web/image" {
+				return nil, fmt.Errorf("unexpected path: %s", req.URL.Path)
+			}
+			query := req.URL.Query()
+			if val := query.Get("image"); val != "nginx:1.15" {
+				return nil, fmt.Errorf("unexpected image parameter: %s", val)
+			}
+			if _, ok := query["version"]; ok {
+				return nil, fmt.Errorf("unexpected version parameter")
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackSetImage(ctx, id, "web", "nginx:1.15", types.StackPatchOptions{})
+	assert.NilError(t, err)
+}
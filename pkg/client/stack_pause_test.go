@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestStackPauseServerError(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackPause(ctx, id)
+	assert.ErrorContains(t, err, "Server error")
+	err = cli.StackResume(ctx, id)
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackPauseResume(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	var paths []string
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodPost {
+				return nil, fmt.Errorf("unexpected method: %s", req.Method)
+			}
+			paths = append(paths, req.URL.Path)
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	assert.NilError(t, cli.StackPause(ctx, id))
+	assert.NilError(t, cli.StackResume(ctx, id))
+	assert.DeepEqual(t, []string{"/stacks/dummy/pause", "/stacks/dummy/resume"}, paths)
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackRedeploy forces the services of a Stack to be redeployed, even if
+// their spec hasn't changed
+func (cli *Client) StackRedeploy(ctx context.Context, id string, options types.StackRedeployOptions) error {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	for _, service := range options.Services {
+		query.Add("service", service)
+	}
+
+	resp, err := cli.post(ctx, "/stacks/"+id+"/redeploy", query, nil, headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackRedeployServerError(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackRedeploy(ctx, id, types.StackRedeployOptions{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackRedeploy(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/stacks/dummy/redeploy" {
+				return nil, fmt.Errorf("unexpected path: %s", req.URL.Path)
+			}
+			services := req.URL.Query()["service"]
+			if len(services) != 2 || services[0] != "web" || services[1] != "db" {
+				return nil, fmt.Errorf("unexpected service parameters: %v", services)
+			}
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackRedeploy(ctx, id, types.StackRedeployOptions{
+		Services: []string{"web", "db"},
+	})
+	assert.NilError(t, err)
+}
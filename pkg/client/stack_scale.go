@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackScale sets the number of replicas of a service within a Stack
+func (cli *Client) StackScale(ctx context.Context, id string, service string, options types.StackScaleOptions) error {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	query.Set("replicas", strconv.FormatUint(options.Replicas, 10))
+
+	resp, err := cli.post(ctx, "/stacks/"+id+"/services/"+service+"/scale", query, nil, headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackScaleServerError(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackScale(ctx, id, "web", types.StackScaleOptions{Replicas: 3})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackScale(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/stacks/dummy/services/web/scale" {
+				return nil, fmt.Errorf("unexpected path: %s", req.URL.Path)
+			}
+			if val := req.URL.Query().Get("replicas"); val != "3" {
+				return nil, fmt.Errorf("unexpected replicas parameter: %s", val)
+			}
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackScale(ctx, id, "web", types.StackScaleOptions{Replicas: 3})
+	assert.NilError(t, err)
+}
@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/url"
 	"strconv"
 
@@ -9,7 +11,7 @@ import (
 )
 
 // StackUpdate updates an existing Stack
-func (cli *Client) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error {
+func (cli *Client) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) (types.StackUpdateResponse, error) {
 
 	headers := map[string][]string{
 		"version": {cli.settings.Version},
@@ -21,8 +23,23 @@ func (cli *Client) StackUpdate(ctx context.Context, id string, version types.Ver
 
 	query := url.Values{}
 	query.Set("version", strconv.FormatUint(version.Index, 10))
+	if options.Strict {
+		query.Set("strict", "1")
+	}
+	for _, name := range options.SensitiveProperties {
+		query.Add("sensitive", name)
+	}
 
+	var response types.StackUpdateResponse
 	resp, err := cli.post(ctx, "/stacks/"+id, query, spec, headers)
-	ensureReaderClosed(resp)
-	return wrapResponseError(err, resp, "stack", id)
+	defer ensureReaderClosed(resp)
+	if err != nil {
+		return response, wrapResponseError(err, resp, "stack", id)
+	}
+
+	// the servers which predate the warnings respond with an empty body.
+	if err := json.NewDecoder(resp.body).Decode(&response); err != nil && err != io.EOF {
+		return response, err
+	}
+	return response, nil
 }
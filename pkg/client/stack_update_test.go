@@ -22,7 +22,7 @@ func TestStackUpdateServerError(t *testing.T) {
 	}
 	cli, err := NewClientWithSettings(s)
 	assert.NilError(t, err)
-	err = cli.StackUpdate(ctx, id, version, types.StackSpec{}, types.StackUpdateOptions{})
+	_, err = cli.StackUpdate(ctx, id, version, types.StackSpec{}, types.StackUpdateOptions{})
 	assert.ErrorContains(t, err, "Server error")
 }
 
@@ -45,6 +45,31 @@ func TestStackUpdateEmpty(t *testing.T) {
 	}
 	cli, err := NewClientWithSettings(s)
 	assert.NilError(t, err)
-	err = cli.StackUpdate(ctx, id, version, types.StackSpec{}, types.StackUpdateOptions{})
+	_, err = cli.StackUpdate(ctx, id, version, types.StackSpec{}, types.StackUpdateOptions{})
 	assert.NilError(t, err)
 }
+
+func TestStackUpdateWarnings(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if strict := req.URL.Query().Get("strict"); strict != "1" {
+				return nil, fmt.Errorf("missing strict parameter- found: %q", strict)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"Warnings":[{"property":"services.web.links","service":"web","message":"links is not supported, and is ignored","severity":"warning"}]}`)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	resp, err := cli.StackUpdate(ctx, "dummy", types.Version{}, types.StackSpec{}, types.StackUpdateOptions{Strict: true})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []types.Warning{{
+		Property: "services.web.links",
+		Service:  "web",
+		Message:  "links is not supported, and is ignored",
+		Severity: types.WarningSeverityWarning,
+	}}, resp.Warnings)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackWait blocks until a Stack satisfies the provided wait condition, and
+// returns the final status of the Stack
+func (cli *Client) StackWait(ctx context.Context, id string, options types.StackWaitOptions) (types.StackStatus, error) {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	if options.Condition != "" {
+		query.Set("condition", string(options.Condition))
+	}
+	if options.Timeout > 0 {
+		query.Set("timeout", options.Timeout.String())
+	}
+
+	var response types.StackStatus
+	resp, err := cli.get(ctx, "/stacks/"+id+"/wait", query, headers)
+	if err != nil {
+		return response, wrapResponseError(err, resp, "stack", id)
+	}
+
+	err = json.NewDecoder(resp.body).Decode(&response)
+
+	ensureReaderClosed(resp)
+	return response, err
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackWaitServerError(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackWait(ctx, id, types.StackWaitOptions{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackWait(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			query := req.URL.Query()
+			if val := query.Get("condition"); val != "running" {
+				return nil, fmt.Errorf("unexpected condition parameter: %s", val)
+			}
+			if val := query.Get("timeout"); val != "1m30s" {
+				return nil, fmt.Errorf("unexpected timeout parameter: %s", val)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"phase":"running"}`)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	status, err := cli.StackWait(ctx, id, types.StackWaitOptions{
+		Condition: types.StackWaitConditionRunning,
+		Timeout:   90 * time.Second,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, status.Phase, types.StackPhaseRunning)
+}
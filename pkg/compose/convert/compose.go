@@ -1,13 +1,13 @@
 package convert
 
 import (
-	"io/ioutil"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 	networktypes "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/swarm"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -106,11 +106,29 @@ func Secrets(namespace Namespace, secrets map[string]composetypes.SecretConfig)
 			continue
 		}
 
-		obj, err := fileObjectConfig(namespace, name, composetypes.FileObjectConfig(secret))
+		var obj swarmFileObject
+		var err error
+		if secret.Driver != "" {
+			obj = driverObjectConfig(namespace, name, composetypes.FileObjectConfig(secret))
+		} else {
+			obj, err = fileObjectConfig(namespace, name, composetypes.FileObjectConfig(secret))
+		}
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "secrets", name)
+		}
+		spec := swarm.SecretSpec{Annotations: obj.Annotations, Data: obj.Data}
+		if secret.Driver != "" {
+			spec.Driver = &swarm.Driver{
+				Name:    secret.Driver,
+				Options: secret.DriverOpts,
+			}
 		}
-		result = append(result, swarm.SecretSpec{Annotations: obj.Annotations, Data: obj.Data})
+		if secret.TemplateDriver != "" {
+			spec.Templating = &swarm.Driver{
+				Name: secret.TemplateDriver,
+			}
+		}
+		result = append(result, spec)
 	}
 	return result, nil
 }
@@ -125,9 +143,15 @@ func Configs(namespace Namespace, configs map[string]composetypes.ConfigObjConfi
 
 		obj, err := fileObjectConfig(namespace, name, composetypes.FileObjectConfig(config))
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "configs", name)
 		}
-		result = append(result, swarm.ConfigSpec{Annotations: obj.Annotations, Data: obj.Data})
+		spec := swarm.ConfigSpec{Annotations: obj.Annotations, Data: obj.Data}
+		if config.TemplateDriver != "" {
+			spec.Templating = &swarm.Driver{
+				Name: config.TemplateDriver,
+			}
+		}
+		result = append(result, spec)
 	}
 	return result, nil
 }
@@ -137,10 +161,29 @@ type swarmFileObject struct {
 	Data        []byte
 }
 
+// driverObjectConfig returns the swarm object of a secret stored by an
+// external secret store, which has no data.
+func driverObjectConfig(namespace Namespace, name string, obj composetypes.FileObjectConfig) swarmFileObject {
+	if obj.Name != "" {
+		name = obj.Name
+	} else {
+		name = namespace.Scope(name)
+	}
+
+	return swarmFileObject{
+		Annotations: swarm.Annotations{
+			Name:   name,
+			Labels: AddStackLabel(namespace, obj.Labels),
+		},
+	}
+}
+
 func fileObjectConfig(namespace Namespace, name string, obj composetypes.FileObjectConfig) (swarmFileObject, error) {
-	data, err := ioutil.ReadFile(obj.File)
-	if err != nil {
-		return swarmFileObject{}, err
+	// the files named by a stack are never read from the filesystem of the
+	// server: their content must be carried by the stack.
+	data := obj.Data
+	if data == nil {
+		return swarmFileObject{}, errors.Errorf("the content of file %s is missing", obj.File)
 	}
 
 	if obj.Name != "" {
@@ -5,10 +5,10 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
-	"gotest.tools/fs"
 )
 
 func TestNamespaceScope(t *testing.T) {
@@ -112,12 +112,11 @@ func TestSecrets(t *testing.T) {
 	namespace := Namespace{name: "foo"}
 
 	secretText := "this is the first secret"
-	secretFile := fs.NewFile(t, "convert-secrets", fs.WithContent(secretText))
-	defer secretFile.Remove()
 
 	source := map[string]composetypes.SecretConfig{
 		"one": {
-			File:   secretFile.Path(),
+			File:   "./secret.txt",
+			Data:   []byte(secretText),
 			Labels: map[string]string{"monster": "mash"},
 		},
 		"ext": {
@@ -139,16 +138,50 @@ func TestSecrets(t *testing.T) {
 	assert.Check(t, is.DeepEqual([]byte(secretText), secret.Data))
 }
 
+func TestSecretsWithoutData(t *testing.T) {
+	namespace := Namespace{name: "foo"}
+
+	source := map[string]composetypes.SecretConfig{
+		"one": {File: "./secret.txt"},
+	}
+
+	_, err := Secrets(namespace, source)
+	assert.Check(t, is.Error(err, "the content of file ./secret.txt is missing"))
+}
+
+func TestSecretsWithDriver(t *testing.T) {
+	namespace := Namespace{name: "foo"}
+
+	source := map[string]composetypes.SecretConfig{
+		"one": {
+			Driver:         "vault",
+			DriverOpts:     map[string]string{"path": "secret/one"},
+			TemplateDriver: "golang",
+		},
+	}
+
+	specs, err := Secrets(namespace, source)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(specs, 1))
+	secret := specs[0]
+	assert.Check(t, is.Equal("foo_one", secret.Name))
+	assert.Check(t, is.Nil(secret.Data))
+	assert.Check(t, is.DeepEqual(&swarm.Driver{
+		Name:    "vault",
+		Options: map[string]string{"path": "secret/one"},
+	}, secret.Driver))
+	assert.Check(t, is.DeepEqual(&swarm.Driver{Name: "golang"}, secret.Templating))
+}
+
 func TestConfigs(t *testing.T) {
 	namespace := Namespace{name: "foo"}
 
 	configText := "this is the first config"
-	configFile := fs.NewFile(t, "convert-configs", fs.WithContent(configText))
-	defer configFile.Remove()
 
 	source := map[string]composetypes.ConfigObjConfig{
 		"one": {
-			File:   configFile.Path(),
+			File:   "./config.txt",
+			Data:   []byte(configText),
 			Labels: map[string]string{"monster": "mash"},
 		},
 		"ext": {
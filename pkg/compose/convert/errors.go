@@ -0,0 +1,46 @@
+package convert
+
+import (
+	"strings"
+)
+
+// Error is an error converting the value at a dotted path of a stack spec,
+// e.g. "services.web.deploy.resources", so that it can be located in the
+// compose files the stack spec was loaded from.
+type Error struct {
+	path string
+	err  error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the underlying error.
+func (e *Error) Cause() error {
+	return e.err
+}
+
+// Path returns the dotted path of the value the error is about.
+func (e *Error) Path() string {
+	return e.path
+}
+
+// withPath returns an error about the value at a path, or nil if err is nil.
+// The path of an error which already has one is appended to the path.
+func withPath(err error, path ...string) error {
+	if err == nil {
+		return nil
+	}
+	if cerr, ok := err.(*Error); ok {
+		path = append(path, cerr.path)
+		err = cerr.err
+	}
+	parts := make([]string, 0, len(path))
+	for _, part := range path {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return &Error{path: strings.Join(parts, "."), err: err}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,17 +41,17 @@ func Services(
 	for _, service := range services {
 		secrets, err := convertServiceSecrets(backend, namespace, service.Secrets, stackSpec.Secrets)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service %s", service.Name)
+			return nil, serviceError(service.Name, err)
 		}
 
 		configs, err := convertServiceConfigObjs(backend, namespace, service.Configs, stackSpec.Configs)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service %s", service.Name)
+			return nil, serviceError(service.Name, err)
 		}
 
 		serviceSpec, err := Service(namespace, service, networks, volumes, secrets, configs)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service %s", service.Name)
+			return nil, serviceError(service.Name, err)
 		}
 
 		serviceSpec.Annotations.Name = service.Name
@@ -60,7 +61,18 @@ func Services(
 	return result, nil
 }
 
-// Service converts a ServiceConfig into a swarm ServiceSpec
+// serviceError returns an error converting a service, located at the path of
+// the service.
+func serviceError(name string, err error) error {
+	path := ""
+	if cerr, ok := err.(*Error); ok {
+		path, err = cerr.path, cerr.err
+	}
+	return withPath(errors.Wrapf(err, "service %s", name), "services", name, path)
+}
+
+// Service converts a ServiceConfig into a swarm ServiceSpec. The errors are
+// located at the path of the value of the ServiceConfig they are about.
 func Service(
 	namespace Namespace,
 	service composetypes.ServiceConfig,
@@ -73,49 +85,49 @@ func Service(
 
 	endpoint, err := convertEndpointSpec(service.Deploy.EndpointMode, service.Ports)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "ports")
 	}
 
 	mode, err := convertDeployMode(service.Deploy.Mode, service.Deploy.Replicas)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "deploy.mode")
 	}
 
 	mounts, err := Volumes(service.Volumes, volumes, namespace)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "volumes")
 	}
 
 	resources, err := convertResources(service.Deploy.Resources)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "deploy.resources")
 	}
 
 	restartPolicy, err := convertRestartPolicy(
 		service.Restart, service.Deploy.RestartPolicy)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "deploy.restart_policy")
 	}
 
 	healthcheck, err := convertHealthcheck(service.HealthCheck)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "healthcheck")
 	}
 
 	networks, err := convertServiceNetworks(service.Networks, networkConfigs, namespace, service.Name)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "networks")
 	}
 
 	dnsConfig, err := convertDNSConfig(service.DNS, service.DNSSearch)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "dns")
 	}
 
 	var privileges swarm.Privileges
 	privileges.CredentialSpec, err = convertCredentialSpec(service.CredentialSpec)
 	if err != nil {
-		return swarm.ServiceSpec{}, err
+		return swarm.ServiceSpec{}, withPath(err, "credential_spec")
 	}
 
 	var logDriver *swarm.Driver
@@ -162,6 +174,7 @@ func Service(
 			Placement: &swarm.Placement{
 				Constraints: service.Deploy.Placement.Constraints,
 				Preferences: getPlacementPreference(service.Deploy.Placement.Preferences),
+				MaxReplicas: service.Deploy.Placement.MaxReplicas,
 			},
 		},
 		EndpointSpec:   endpoint,
@@ -254,10 +267,10 @@ func convertServiceSecrets(
 		}
 		return composetypes.FileObjectConfig(secretSpec), nil
 	}
-	for _, secret := range secrets {
+	for i, secret := range secrets {
 		obj, err := convertFileObject(namespace, composetypes.FileReferenceConfig(secret), lookup)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "secrets", strconv.Itoa(i))
 		}
 
 		file := swarm.SecretReferenceFileTarget(obj.File)
@@ -291,10 +304,10 @@ func convertServiceConfigObjs(
 		}
 		return composetypes.FileObjectConfig(configSpec), nil
 	}
-	for _, config := range configs {
+	for i, config := range configs {
 		obj, err := convertFileObject(namespace, composetypes.FileReferenceConfig(config), lookup)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "configs", strconv.Itoa(i))
 		}
 
 		file := swarm.ConfigReferenceFileTarget(obj.File)
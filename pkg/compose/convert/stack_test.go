@@ -1,4 +1,4 @@
-package convert
+package convert_test
 
 import (
 	"testing"
@@ -7,6 +7,7 @@ import (
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 
+	"github.com/docker/stacks/pkg/compose/convert"
 	"github.com/docker/stacks/pkg/compose/loader"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 )
@@ -18,21 +19,21 @@ func TestLoadStacks(t *testing.T) {
 	stack, err := loader.ParseComposeInput(*input)
 	assert.NilError(t, err)
 
-	namespace := NewNamespace("dummy")
+	namespace := convert.NewNamespace("dummy")
 
 	// TODO - figure out how to map theses...
 	// the compose file used above doesn't have secrets/config so we'll need to wire this up further...
 	secrets := []*swarm.SecretReference{}
 	configs := []*swarm.ConfigReference{}
 
-	secretlist, err := Secrets(
+	secretlist, err := convert.Secrets(
 		namespace,
 		stack.Spec.Secrets,
 	)
 	assert.NilError(t, err)
 	assert.Check(t, is.Len(secretlist, 0))
 
-	cfglist, err := Configs(
+	cfglist, err := convert.Configs(
 		namespace,
 		stack.Spec.Configs,
 	)
@@ -42,7 +43,7 @@ func TestLoadStacks(t *testing.T) {
 	volumes := []composetypes.ServiceVolumeConfig{}
 	networks := map[string]struct{}{}
 	for _, service := range stack.Spec.Services {
-		res, err := Service(namespace,
+		res, err := convert.Service(namespace,
 			service,
 			stack.Spec.Networks,
 			stack.Spec.Volumes,
@@ -58,7 +59,7 @@ func TestLoadStacks(t *testing.T) {
 		}
 	}
 
-	nwlist, externalNames := Networks(
+	nwlist, externalNames := convert.Networks(
 		namespace,
 		stack.Spec.Networks,
 		networks,
@@ -66,7 +67,7 @@ func TestLoadStacks(t *testing.T) {
 	assert.Check(t, is.Len(nwlist, 1), stack.Spec.Networks)
 	assert.Check(t, is.Len(externalNames, 0))
 
-	mounts, err := Volumes(
+	mounts, err := convert.Volumes(
 		volumes,
 		stack.Spec.Volumes,
 		namespace,
@@ -2,6 +2,8 @@ package interpolation
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/docker/stacks/pkg/compose/template"
@@ -40,71 +42,133 @@ func Interpolate(config map[string]interface{}, opts Options) (map[string]interf
 	}
 
 	out := map[string]interface{}{}
+	var errs Errors
 
 	for key, value := range config {
-		interpolatedValue, err := recursiveInterpolate(value, NewPath(key), opts)
-		if err != nil {
-			return out, err
-		}
-		out[key] = interpolatedValue
+		out[key] = recursiveInterpolate(value, NewPath(key), NewPath(key), opts, &errs)
 	}
 
-	return out, nil
+	switch len(errs) {
+	case 0:
+		return out, nil
+	case 1:
+		return out, errs[0]
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].location < errs[j].location })
+	return out, errs
 }
 
-func recursiveInterpolate(value interface{}, path Path, opts Options) (interface{}, error) {
+// recursiveInterpolate interpolates the value at a path of the config. The
+// location of the value is its path, with the indexes of the items of lists.
+// The errors are collected in errs, so that they are all reported at once.
+func recursiveInterpolate(value interface{}, path Path, location Path, opts Options, errs *Errors) interface{} {
 	switch value := value.(type) {
 
 	case string:
 		newValue, err := opts.Substitute(value, template.Mapping(opts.LookupValue))
 		if err != nil || newValue == value {
-			return value, newPathError(path, err)
+			errs.add(newPathError(path, location, err))
+			return value
 		}
 		caster, ok := opts.getCasterForPath(path)
 		if !ok {
-			return newValue, nil
+			return newValue
 		}
 		casted, err := caster(newValue)
-		return casted, newPathError(path, errors.Wrap(err, "failed to cast to expected type"))
+		if err != nil {
+			errs.add(newPathError(path, location, errors.Wrap(err, "failed to cast to expected type")))
+		}
+		return casted
 
 	case map[string]interface{}:
 		out := map[string]interface{}{}
 		for key, elem := range value {
-			interpolatedElem, err := recursiveInterpolate(elem, path.Next(key), opts)
-			if err != nil {
-				return nil, err
-			}
-			out[key] = interpolatedElem
+			out[key] = recursiveInterpolate(elem, path.Next(key), location.Next(key), opts, errs)
 		}
-		return out, nil
+		return out
 
 	case []interface{}:
 		out := make([]interface{}, len(value))
 		for i, elem := range value {
-			interpolatedElem, err := recursiveInterpolate(elem, path.Next(PathMatchList), opts)
-			if err != nil {
-				return nil, err
-			}
-			out[i] = interpolatedElem
+			out[i] = recursiveInterpolate(elem, path.Next(PathMatchList), location.Next(strconv.Itoa(i)), opts, errs)
 		}
-		return out, nil
+		return out
 
 	default:
-		return value, nil
+		return value
+
+	}
+}
+
+// Errors are the errors interpolating several values of a config, sorted by
+// location.
+type Errors []*PathError
 
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
 	}
+	return strings.Join(messages, "\n")
 }
 
-func newPathError(path Path, err error) error {
+func (e *Errors) add(err *PathError) {
+	if err != nil {
+		*e = append(*e, err)
+	}
+}
+
+// PathError is an error interpolating the value at a path of a config.
+type PathError struct {
+	location Path
+	err      error
+	missing  []template.MissingVariable
+}
+
+func (e *PathError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the underlying error.
+func (e *PathError) Cause() error {
+	return e.err
+}
+
+// Path returns the dotted path of the value, with the indexes of the items of
+// lists.
+func (e *PathError) Path() string {
+	return string(e.location)
+}
+
+// Missing returns the required variables missing a value, if the value can't
+// be interpolated because of them.
+func (e *PathError) Missing() []template.MissingVariable {
+	return e.missing
+}
+
+func newPathError(path Path, location Path, err error) *PathError {
 	switch err := err.(type) {
 	case nil:
 		return nil
 	case *template.InvalidTemplateError:
-		return errors.Errorf(
-			"invalid interpolation format for %s: %#v. You may need to escape any $ with another $.",
-			path, err.Template)
+		if len(err.Missing) > 0 {
+			return &PathError{
+				location: location,
+				err:      errors.Errorf("invalid value for %s: %s", path, err.Template),
+				missing:  err.Missing,
+			}
+		}
+		return &PathError{
+			location: location,
+			err: errors.Errorf(
+				"invalid interpolation format for %s: %#v. You may need to escape any $ with another $.",
+				path, err.Template),
+		}
 	default:
-		return errors.Wrapf(err, "error while interpolating %s", path)
+		return &PathError{
+			location: location,
+			err:      errors.Wrapf(err, "error while interpolating %s", path),
+		}
 	}
 }
 
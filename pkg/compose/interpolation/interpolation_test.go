@@ -5,6 +5,7 @@ import (
 
 	"strconv"
 
+	"github.com/docker/stacks/pkg/compose/template"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 	"gotest.tools/env"
@@ -61,6 +62,26 @@ func TestInvalidInterpolation(t *testing.T) {
 	assert.Error(t, err, `invalid interpolation format for servicea.image: "${". You may need to escape any $ with another $.`)
 }
 
+func TestInterpolateReportsAllMissingVariables(t *testing.T) {
+	services := map[string]interface{}{
+		"servicea": map[string]interface{}{
+			"image":   "${UNSET:?}/${OTHER:?no other}",
+			"volumes": []interface{}{"${UNSET:?}:/target"},
+		},
+	}
+	_, err := Interpolate(services, Options{LookupValue: defaultMapping})
+	errs, ok := err.(Errors)
+	assert.Assert(t, ok, "unexpected error: %v", err)
+	assert.Assert(t, is.Len(errs, 2))
+	assert.Check(t, is.Equal("servicea.image", errs[0].Path()))
+	assert.Check(t, is.DeepEqual([]template.MissingVariable{
+		{Name: "UNSET"},
+		{Name: "OTHER", Message: "no other"},
+	}, errs[0].Missing()))
+	assert.Check(t, is.Equal("servicea.volumes.0", errs[1].Path()))
+	assert.Check(t, is.DeepEqual([]template.MissingVariable{{Name: "UNSET"}}, errs[1].Missing()))
+}
+
 func TestInterpolateWithDefaults(t *testing.T) {
 	defer env.Patch(t, "FOO", "BARZ")()
 
@@ -0,0 +1,140 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// envFilename is the name of the file in the project directory which defines
+// the default values of the variables of the compose files.
+const envFilename = ".env"
+
+// collectFiles reads the files referenced by a compose file from the project
+// directory into files.
+func collectFiles(files map[string][]byte, workingDir string, config map[string]interface{}) error {
+	for _, file := range referencedFiles(config) {
+		if err := collectFile(files, workingDir, file); err != nil {
+			return err
+		}
+	}
+
+	// the files extended by the services are compose files themselves, whose
+	// referenced files are collected too. Files already collected have had
+	// their references collected as well.
+	for _, file := range extendedFiles(config) {
+		key, err := bundlePath(file)
+		if err != nil {
+			return err
+		}
+		if _, ok := files[key]; ok {
+			continue
+		}
+		if err := collectFile(files, workingDir, file); err != nil {
+			return err
+		}
+		extended, err := ParseYAML(files[key])
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse %s", file)
+		}
+		if err := collectFiles(files, workingDir, extended); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectEnvFile reads the .env file of the project directory, if any, into
+// files.
+func collectEnvFile(files map[string][]byte, workingDir string) error {
+	err := collectFile(files, workingDir, envFilename)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil
+	}
+	return err
+}
+
+func collectFile(files map[string][]byte, workingDir string, file string) error {
+	key, err := bundlePath(file)
+	if err != nil {
+		return err
+	}
+	if _, ok := files[key]; ok {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(workingDir, filepath.FromSlash(key)))
+	if err != nil {
+		return err
+	}
+	files[key] = data
+	return nil
+}
+
+// composeFileServices returns the service definitions of a compose file. The
+// compose file hasn't been validated yet, so its sections may not be mappings.
+func composeFileServices(config map[string]interface{}) map[string]map[string]interface{} {
+	result := map[string]map[string]interface{}{}
+	services, _ := config["services"].(map[string]interface{})
+	for name, service := range services {
+		if serviceDict, ok := service.(map[string]interface{}); ok {
+			result[name] = serviceDict
+		}
+	}
+	return result
+}
+
+// extendedFiles returns the paths of the files extended by the services of a
+// compose file. Paths containing variables can only be resolved by the
+// server and are skipped.
+func extendedFiles(config map[string]interface{}) []string {
+	var files []string
+	for _, serviceDict := range composeFileServices(config) {
+		extends, _ := serviceDict[extendsKey].(map[string]interface{})
+		if file, ok := extends["file"].(string); ok && !strings.Contains(file, "$") {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// referencedFiles returns the paths of the env files of the services, and of
+// the files of the secrets and configs, of a compose file. Paths containing
+// variables can only be resolved by the server and are skipped.
+func referencedFiles(config map[string]interface{}) []string {
+	var files []string
+	add := func(file interface{}) {
+		if s, ok := file.(string); ok && !strings.Contains(s, "$") {
+			files = append(files, s)
+		}
+	}
+
+	for _, serviceDict := range composeFileServices(config) {
+		switch envFile := serviceDict["env_file"].(type) {
+		case string:
+			add(envFile)
+		case []interface{}:
+			for _, file := range envFile {
+				add(file)
+			}
+		}
+	}
+
+	// like the services, these sections may not be mappings.
+	for _, key := range []string{"secrets", "configs"} {
+		objs, _ := config[key].(map[string]interface{})
+		for _, obj := range objs {
+			objDict, ok := obj.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if external, ok := objDict["external"]; ok && external != false {
+				continue
+			}
+			add(objDict["file"])
+		}
+	}
+	return files
+}
@@ -0,0 +1,79 @@
+package loader
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	"github.com/docker/stacks/pkg/compose/types"
+)
+
+// checkConversion converts the services of a loaded config to swarm service
+// specs, so that the values which can't be converted are reported, located
+// in the compose files, when the files are parsed rather than when the stack
+// is created. The values holding variables are only checked once their
+// variables are substituted, when the stack is created.
+func checkConversion(config *types.Config, files []types.ConfigFile) error {
+	namespace := convert.NewNamespace(config.Name)
+	for _, service := range config.Services {
+		_, err := convert.Service(namespace, service, config.Networks, config.Volumes, nil, nil)
+		if err == nil {
+			continue
+		}
+		cerr, ok := err.(*convert.Error)
+		if !ok {
+			return errors.Wrapf(err, "service %s", service.Name)
+		}
+
+		path := strings.Join([]string{"services", service.Name, cerr.Path()}, ".")
+		index, holdsVariables := definingFile(files, path)
+		if holdsVariables {
+			continue
+		}
+		err = &valueError{path: path, err: errors.Errorf("service %s: %s", service.Name, cerr.Cause())}
+		return locateError(err, index, files[index])
+	}
+	return nil
+}
+
+// definingFile returns the index of the last of the compose files which
+// defines the value at a dotted path, or else of the last file which defines
+// one of its parents, and whether that value holds variables.
+func definingFile(files []types.ConfigFile, path string) (int, bool) {
+	parent := -1
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].Content == nil {
+			continue
+		}
+		key, value, exact := lookupPath(files[i].Content, path)
+		if exact {
+			return i, holdsVariables(value)
+		}
+		if key != nil && parent < 0 {
+			parent = i
+		}
+	}
+	if parent < 0 {
+		return len(files) - 1, false
+	}
+	return parent, false
+}
+
+// holdsVariables returns true if a YAML node, or one of its children, holds a
+// variable.
+func holdsVariables(node *yaml.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind == yaml.ScalarNode {
+		return strings.Contains(node.Value, "$")
+	}
+	for _, child := range node.Content {
+		if holdsVariables(child) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package loader
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/template"
+	"github.com/docker/stacks/pkg/compose/types"
+	"github.com/pkg/errors"
+)
+
+var (
+	durationType  = reflect.TypeOf(types.Duration(0))
+	unitBytesType = reflect.TypeOf(types.UnitBytes(0))
+)
+
+// deferVariables returns a copy of a compose file without the values of the
+// numeric, boolean and duration properties of its services which still hold
+// variables, which can't be decoded until the variables are substituted, and
+// these values keyed by service name, then by the dotted path of the property
+// in the service.
+//
+// Only the properties of mappings are deferred: the items of lists, such as
+// ports, may be reordered or expanded when they are loaded.
+func deferVariables(configDict map[string]interface{}) (map[string]interface{}, map[string]map[string]string) {
+	services, ok := configDict["services"].(map[string]interface{})
+	if !ok {
+		return configDict, nil
+	}
+
+	deferred := map[string]map[string]string{}
+	copied := make(map[string]interface{}, len(services))
+	for name, service := range services {
+		copied[name] = service
+		serviceDict, ok := service.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		values := map[string]string{}
+		copied[name] = deferDict(serviceDict, reflect.TypeOf(types.ServiceConfig{}), nil, values)
+		if len(values) > 0 {
+			deferred[name] = values
+		}
+	}
+	if len(deferred) == 0 {
+		return configDict, nil
+	}
+
+	result := make(map[string]interface{}, len(configDict))
+	for key, value := range configDict {
+		result[key] = value
+	}
+	result["services"] = copied
+	return result, deferred
+}
+
+// deferDict returns a copy of a mapping to be decoded into a value of type t,
+// without the values it records in deferred.
+func deferDict(dict map[string]interface{}, t reflect.Type, path []string, deferred map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(dict))
+	for key, value := range dict {
+		fieldType, ok := propertyType(t, key)
+		if !ok {
+			result[key] = value
+			continue
+		}
+		keyPath := append(path[:len(path):len(path)], key)
+		switch value := value.(type) {
+		case string:
+			if isDeferrable(fieldType) && template.DefaultPattern.MatchString(value) {
+				deferred[strings.Join(keyPath, ".")] = value
+				continue
+			}
+		case map[string]interface{}:
+			result[key] = deferDict(value, fieldType, keyPath, deferred)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// propertyType returns the type of the property key of a value of type t, as
+// decoded by mapstructure. The values of maps must be pointers, so that they
+// can be set once the variables are substituted.
+func propertyType(t reflect.Type, key string) (reflect.Type, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if field, ok := structField(t, key); ok {
+			return field.Type, true
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Ptr {
+			return t.Elem(), true
+		}
+	}
+	return nil, false
+}
+
+// structField returns the field of a struct decoded from a property, which
+// is named by the mapstructure tag of the field, or else by the field name
+// regardless of its case.
+func structField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == key || (name == "" && strings.EqualFold(field.Name, key)) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// isDeferrable returns whether the values of type t are deferred when they
+// hold variables.
+func isDeferrable(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setDeferred sets the deferred values of the services of a compose file.
+func setDeferred(services []types.ServiceConfig, deferred map[string]map[string]string) {
+	for i, service := range services {
+		if values, ok := deferred[service.Name]; ok {
+			services[i].Deferred = values
+		}
+	}
+}
+
+// mergeDeferred returns the deferred values of the merge of two services. The
+// values of the base service are dropped if the override service sets the
+// property.
+func mergeDeferred(base, override types.ServiceConfig) map[string]string {
+	merged := map[string]string{}
+	overrideValue := reflect.ValueOf(override)
+	for path, value := range base.Deferred {
+		if field, ok := lookupProperty(overrideValue, path); ok && !field.IsZero() {
+			continue
+		}
+		merged[path] = value
+	}
+	for path, value := range override.Deferred {
+		merged[path] = value
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// lookupProperty returns the value of the property of a service at a dotted
+// path, if it is set.
+func lookupProperty(value reflect.Value, path string) (reflect.Value, bool) {
+	for _, key := range strings.Split(path, ".") {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct:
+			field, ok := structField(value.Type(), key)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			value = value.FieldByIndex(field.Index)
+		case reflect.Map:
+			value = value.MapIndex(reflect.ValueOf(key))
+			if !value.IsValid() {
+				return reflect.Value{}, false
+			}
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return value, true
+}
+
+// ResolveDeferred sets the properties of a service whose values were deferred
+// until the substitution of their variables, which must have been substituted
+// in service.Deferred. The values are validated against the type of the
+// properties.
+func ResolveDeferred(service *types.ServiceConfig) error {
+	for path, value := range service.Deferred {
+		field, err := propertyField(reflect.ValueOf(service).Elem(), path)
+		if err != nil {
+			return errors.Wrapf(err, "service %s: %s", service.Name, path)
+		}
+		if err := setProperty(field, value); err != nil {
+			return errors.Wrapf(err, "service %s: %s", service.Name, path)
+		}
+	}
+	service.Deferred = nil
+	return nil
+}
+
+// propertyField returns the settable field of the property of a service at a
+// dotted path, allocating the pointers and the values of maps on the way.
+func propertyField(value reflect.Value, path string) (reflect.Value, error) {
+	for _, key := range strings.Split(path, ".") {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct:
+			field, ok := structField(value.Type(), key)
+			if !ok {
+				return reflect.Value{}, errors.Errorf("unknown property %s", key)
+			}
+			value = value.FieldByIndex(field.Index)
+		case reflect.Map:
+			if value.Type().Elem().Kind() != reflect.Ptr {
+				return reflect.Value{}, errors.Errorf("unknown property %s", key)
+			}
+			if value.IsNil() {
+				value.Set(reflect.MakeMap(value.Type()))
+			}
+			elem := value.MapIndex(reflect.ValueOf(key))
+			if !elem.IsValid() || elem.IsNil() {
+				elem = reflect.New(value.Type().Elem().Elem())
+				value.SetMapIndex(reflect.ValueOf(key), elem)
+			}
+			// the values of the map are pointers, whose elements can be set.
+			value = elem.Elem()
+		default:
+			return reflect.Value{}, errors.Errorf("unknown property %s", key)
+		}
+	}
+	if !isDeferrable(value.Type()) {
+		return reflect.Value{}, errors.New("the value of the property can't hold variables")
+	}
+	return value, nil
+}
+
+// setProperty sets a numeric, boolean or duration property from its value in
+// a compose file.
+func setProperty(field reflect.Value, value string) error {
+	t := field.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var source interface{} = value
+	var err error
+	switch {
+	case t == durationType, t == unitBytesType:
+		// the transform hooks decode these from strings.
+	case t.Kind() == reflect.Bool:
+		source, err = toBoolean(value)
+	case t.Kind() == reflect.Float32, t.Kind() == reflect.Float64:
+		source, err = toFloat(value)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		source, err = strconv.ParseUint(value, 10, 64)
+	default:
+		source, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil {
+		return errors.Errorf("invalid value %q", value)
+	}
+
+	target := reflect.New(field.Type())
+	if err := Transform(source, target.Interface()); err != nil {
+		return errors.Errorf("invalid value %q", value)
+	}
+	field.Set(target.Elem())
+	return nil
+}
@@ -0,0 +1,218 @@
+package loader
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/types"
+	stacktypes "github.com/docker/stacks/pkg/types"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Error is an error of one of the compose files being loaded, located in the
+// file when possible.
+type Error struct {
+	// FileIndex is the index of the file in ConfigDetails.ConfigFiles.
+	FileIndex int
+	Filename  string
+	// Line and Column are the position in the file of the value the error is
+	// about, starting at 1, or 0 if unknown.
+	Line   int
+	Column int
+	// Path is the dotted path of the value the error is about, if known.
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.ComposeError().Error()
+}
+
+// Cause returns the underlying error.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// ComposeError returns the error as returned by the API.
+func (e *Error) ComposeError() *stacktypes.ComposeError {
+	return &stacktypes.ComposeError{
+		FileIndex: e.FileIndex,
+		File:      e.Filename,
+		Line:      e.Line,
+		Column:    e.Column,
+		Path:      e.Path,
+		Message:   e.Err.Error(),
+	}
+}
+
+// pathError is implemented by the errors about the value at a path of a
+// compose file, such as the schema validation and interpolation errors.
+type pathError interface {
+	error
+	Path() string
+}
+
+type causer interface {
+	Cause() error
+}
+
+// valueError is an error about the value at a path of a compose file.
+type valueError struct {
+	path string
+	err  error
+}
+
+func (e *valueError) Error() string {
+	return e.err.Error()
+}
+
+func (e *valueError) Cause() error {
+	return e.err
+}
+
+func (e *valueError) Path() string {
+	return e.path
+}
+
+// withPath returns an error about the value at a path of a compose file, or
+// nil if err is nil. The path of an error which already has one is kept, as
+// it is the most specific.
+func withPath(err error, path ...string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := findPathError(err); ok {
+		return err
+	}
+	return &valueError{path: strings.Join(path, "."), err: err}
+}
+
+func findPathError(err error) (pathError, bool) {
+	for err != nil {
+		if perr, ok := err.(pathError); ok {
+			return perr, true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return nil, false
+}
+
+// yamlLineRegexp matches the line of the YAML syntax errors.
+var yamlLineRegexp = regexp.MustCompile(`^yaml: line (\d+):`)
+
+// locateError returns an error of a compose file located in the file, unless
+// it is already located.
+func locateError(err error, index int, file types.ConfigFile) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	located := &Error{FileIndex: index, Filename: file.Filename, Err: err}
+	if perr, ok := findPathError(err); ok {
+		located.Path = perr.Path()
+		if file.Content != nil {
+			located.Line, located.Column = position(file.Content, located.Path)
+		}
+	} else if match := yamlLineRegexp.FindStringSubmatch(err.Error()); match != nil {
+		located.Line, _ = strconv.Atoi(match[1])
+	}
+	return located
+}
+
+// position returns the position in the YAML content of a compose file of the
+// value at a dotted path, or of its closest parent defined in the content.
+// The position of a value of a mapping is the position of its key.
+func position(content []byte, path string) (line int, column int) {
+	key, _, _ := lookupPath(content, path)
+	if key == nil {
+		return 0, 0
+	}
+	return key.Line, key.Column
+}
+
+// lookupPath returns the key and the value of the node at a dotted path in
+// the YAML content of a compose file, or of its closest parent defined in the
+// content, and whether the node at the path itself was found.
+func lookupPath(content []byte, path string) (*yaml.Node, *yaml.Node, bool) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil || len(root.Content) == 0 {
+		return nil, nil, false
+	}
+
+	var foundKey, foundValue *yaml.Node
+	node := root.Content[0]
+	parts := splitPath(path)
+	for i := 0; i < len(parts); i++ {
+		// the keys may contain dots, e.g. the keys of labels.
+		key, value := lookupNode(node, parts[i])
+		for j := i + 1; value == nil && j < len(parts); j++ {
+			key, value = lookupNode(node, strings.Join(parts[i:j+1], "."))
+			if value != nil {
+				i = j
+			}
+		}
+		if value == nil {
+			return foundKey, foundValue, false
+		}
+		foundKey, foundValue = key, value
+		node = value
+	}
+	return foundKey, foundValue, foundValue != nil
+}
+
+// splitPath splits a dotted path, whose list indexes may also be in brackets,
+// e.g. "services.web.ports[0]".
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	path = strings.Replace(path, "[", ".", -1)
+	path = strings.Replace(path, "]", "", -1)
+	return strings.Split(path, ".")
+}
+
+// lookupNode returns the key and the value of an entry of a mapping node, or
+// the item of a sequence node. The key of an item is the item itself.
+func lookupNode(node *yaml.Node, part string) (*yaml.Node, *yaml.Node) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key := node.Content[i]; key.Value == part && key.Tag != "!!merge" {
+				return key, node.Content[i+1]
+			}
+		}
+		// the entries merged with "<<" are defined by their anchors.
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Tag != "!!merge" {
+				continue
+			}
+			merged := []*yaml.Node{node.Content[i+1]}
+			if merged[0].Kind == yaml.SequenceNode {
+				merged = merged[0].Content
+			}
+			for _, m := range merged {
+				if key, value := lookupNode(m, part); value != nil {
+					return key, value
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(part)
+		if err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index], node.Content[index]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,139 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/docker/stacks/pkg/compose/types"
+	stacktypes "github.com/docker/stacks/pkg/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const positionsYAML = `version: "3.7"
+x-defaults: &defaults
+  restart: always
+services:
+  web:
+    <<: *defaults
+    image: nginx
+    ports:
+      - 80:80
+      - 443:443
+    labels:
+      com.example.tier: frontend
+`
+
+func TestPosition(t *testing.T) {
+	testcases := []struct {
+		path         string
+		line, column int
+	}{
+		{path: "services", line: 4, column: 1},
+		{path: "services.web.image", line: 7, column: 5},
+		{path: "services.web.ports.1", line: 10, column: 9},
+		{path: "services.web.ports[0]", line: 9, column: 9},
+		{path: "services.web.labels.com.example.tier", line: 12, column: 7},
+		{path: "services.web.restart", line: 3, column: 3},
+		// the closest parent of undefined values
+		{path: "services.web.deploy.replicas", line: 5, column: 3},
+		{path: "services.web.ports.2", line: 8, column: 5},
+		{path: "undefined", line: 0, column: 0},
+		{path: "", line: 0, column: 0},
+	}
+	for _, tc := range testcases {
+		line, column := position([]byte(positionsYAML), tc.path)
+		assert.Check(t, is.Equal(tc.line, line), tc.path)
+		assert.Check(t, is.Equal(tc.column, column), tc.path)
+	}
+}
+
+func TestLoadErrorPosition(t *testing.T) {
+	content := []byte(`version: "3.7"
+services:
+  web:
+    image: nginx
+    ports:
+      - target: 80
+        helicopters: true
+`)
+	dict, err := ParseYAML(content)
+	assert.NilError(t, err)
+	details := buildConfigDetails(dict, nil)
+	details.ConfigFiles[0].Content = content
+
+	_, err = Load(details)
+	assert.Assert(t, is.ErrorType(err, &Error{}))
+	located := err.(*Error)
+	assert.Check(t, is.Equal(0, located.FileIndex))
+	assert.Check(t, is.Equal("services.web.ports.0.helicopters", located.Path))
+	assert.Check(t, is.Equal(7, located.Line))
+	assert.Check(t, is.Equal(9, located.Column))
+	assert.Check(t, is.ErrorContains(err, "filename.yml:7:9: services.web.ports.0 Additional property helicopters is not allowed"))
+}
+
+func TestLoadInterpolationErrorPosition(t *testing.T) {
+	content := []byte(`version: "3.7"
+services:
+  web:
+    image: nginx
+    environment:
+      - FOO=${BAR
+`)
+	dict, err := ParseYAML(content)
+	assert.NilError(t, err)
+	details := buildConfigDetails(dict, nil)
+	details.ConfigFiles[0].Content = content
+
+	_, err = Load(details)
+	assert.Assert(t, is.ErrorType(err, &Error{}))
+	located := err.(*Error)
+	assert.Check(t, is.Equal("services.web.environment.0", located.Path))
+	assert.Check(t, is.Equal(6, located.Line))
+	assert.Check(t, is.Equal(9, located.Column))
+}
+
+func TestLoadReportsAllMissingVariables(t *testing.T) {
+	content := []byte(`version: "3.7"
+services:
+  web:
+    image: ${REGISTRY:?}/web
+    environment:
+      - FOO=${FOO:?foo is required}
+`)
+	override := []byte(`version: "3.7"
+services:
+  worker:
+    image: ${REGISTRY:?}/worker
+`)
+	dict, err := ParseYAML(content)
+	assert.NilError(t, err)
+	overrideDict, err := ParseYAML(override)
+	assert.NilError(t, err)
+	details := buildConfigDetails(dict, nil)
+	details.ConfigFiles = append(details.ConfigFiles, types.ConfigFile{
+		Filename: "override.yml",
+		Config:   overrideDict,
+		Content:  override,
+	})
+	details.ConfigFiles[0].Content = content
+
+	_, err = Load(details)
+	assert.Assert(t, is.ErrorType(err, &stacktypes.VariablesError{}))
+	assert.Check(t, is.DeepEqual([]stacktypes.VariableError{
+		{
+			Variable: "FOO",
+			Message:  "required variable FOO is missing a value: foo is required",
+			Locations: []stacktypes.VariableLocation{
+				{File: "filename.yml", Line: 6, Column: 9, Path: "services.web.environment.0"},
+			},
+		},
+		{
+			Variable: "REGISTRY",
+			Message:  "required variable REGISTRY is missing a value",
+			Locations: []stacktypes.VariableLocation{
+				{File: "filename.yml", Line: 4, Column: 5, Path: "services.web.image"},
+				{FileIndex: 1, File: "override.yml", Line: 4, Column: 5, Path: "services.worker.image"},
+			},
+		},
+	}, err.(*stacktypes.VariablesError).Variables))
+}
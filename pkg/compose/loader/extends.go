@@ -0,0 +1,284 @@
+package loader
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/types"
+	"github.com/pkg/errors"
+)
+
+// extendsKey is the key of the service definitions naming the service they
+// extend.
+const extendsKey = "extends"
+
+// extendsConfig is the service extended by a service, defined in file, or in
+// the same file if file is empty.
+type extendsConfig struct {
+	service string
+	file    string
+}
+
+// extractExtends returns a copy of a compose file without the extends keys of
+// its services, which the schemas of the version 3 compose files don't
+// define, and the services they extend, keyed by service name.
+func extractExtends(configDict map[string]interface{}) (map[string]interface{}, map[string]extendsConfig, error) {
+	services, ok := configDict["services"].(map[string]interface{})
+	if !ok {
+		return configDict, nil, nil
+	}
+
+	extends := map[string]extendsConfig{}
+	copied := make(map[string]interface{}, len(services))
+	for name, service := range services {
+		copied[name] = service
+		serviceDict, ok := service.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := serviceDict[extendsKey]
+		if !ok {
+			continue
+		}
+		extendsConfig, err := toExtendsConfig(value)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "service %s", name)
+		}
+		extends[name] = extendsConfig
+
+		copiedService := make(map[string]interface{}, len(serviceDict))
+		for key, value := range serviceDict {
+			if key != extendsKey {
+				copiedService[key] = value
+			}
+		}
+		copied[name] = copiedService
+	}
+	if len(extends) == 0 {
+		return configDict, nil, nil
+	}
+
+	result := make(map[string]interface{}, len(configDict))
+	for key, value := range configDict {
+		result[key] = value
+	}
+	result["services"] = copied
+	return result, extends, nil
+}
+
+func toExtendsConfig(value interface{}) (extendsConfig, error) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return extendsConfig{service: v}, nil
+		}
+	case map[string]interface{}:
+		var result extendsConfig
+		valid := true
+		for key, value := range v {
+			s, ok := value.(string)
+			switch {
+			case !ok:
+				valid = false
+			case key == "service":
+				result.service = s
+			case key == "file":
+				result.file = s
+			default:
+				valid = false
+			}
+		}
+		if valid && result.service != "" {
+			return result, nil
+		}
+	}
+	return extendsConfig{}, errors.New("extends must be a service name, or a mapping with a service and an optional file")
+}
+
+// servicesFile holds the services of a compose file, some of which may extend
+// other services.
+type servicesFile struct {
+	// file is the path of the file, or empty for the compose files being
+	// loaded.
+	file     string
+	services map[string]types.ServiceConfig
+	extends  map[string]extendsConfig
+}
+
+// link returns the name of a service of the file in an extends chain.
+func (f *servicesFile) link(name string) string {
+	if f.file == "" {
+		return name
+	}
+	return f.file + ":" + name
+}
+
+// extendsResolver merges the services extended by services into them, with
+// the semantics of merging compose files. The extended files are loaded with
+// loadFile, once.
+type extendsResolver struct {
+	loadFile func(file string) (*servicesFile, error)
+	files    map[string]*servicesFile
+}
+
+func newExtendsResolver(loadFile func(file string) (*servicesFile, error)) *extendsResolver {
+	return &extendsResolver{
+		loadFile: loadFile,
+		files:    map[string]*servicesFile{},
+	}
+}
+
+// resolveServices returns the services of a compose file, with the services
+// they extend merged into them.
+func (r *extendsResolver) resolveServices(services []types.ServiceConfig, extends map[string]extendsConfig) ([]types.ServiceConfig, error) {
+	if len(extends) == 0 {
+		return services, nil
+	}
+
+	f := &servicesFile{
+		services: mapByName(services),
+		extends:  extends,
+	}
+	// the services are resolved in a stable order, so the errors are too.
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	sort.Strings(names)
+
+	result := make([]types.ServiceConfig, 0, len(services))
+	for _, name := range names {
+		resolved, err := r.resolve(f, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resolved)
+	}
+	return result, nil
+}
+
+// resolve returns a service of a file, with the services it extends merged
+// into it. chain contains the services extending the service.
+func (r *extendsResolver) resolve(f *servicesFile, name string, chain []string) (types.ServiceConfig, error) {
+	link := f.link(name)
+	for _, extending := range chain {
+		if extending == link {
+			return types.ServiceConfig{}, chainError(append(chain, link), "cycle between services")
+		}
+	}
+	chain = append(chain, link)
+
+	service, ok := f.services[name]
+	if !ok {
+		if f.file == "" {
+			return types.ServiceConfig{}, chainError(chain, "service %s is not defined", name)
+		}
+		return types.ServiceConfig{}, chainError(chain, "service %s is not defined in %s", name, f.file)
+	}
+	extends, ok := f.extends[name]
+	if !ok {
+		return service, nil
+	}
+
+	extended := f
+	if extends.file != "" {
+		var err error
+		extended, err = r.file(extends.file)
+		if err != nil {
+			return types.ServiceConfig{}, chainError(append(chain, extends.file+":"+extends.service), "%s", err)
+		}
+	}
+	base, err := r.resolve(extended, extends.service, chain)
+	if err != nil {
+		return types.ServiceConfig{}, err
+	}
+
+	// mergo merges maps in place, and the extended service may be extended
+	// by other services, or be a service itself.
+	merged, err := mergeService(copyService(base), service)
+	if err != nil {
+		return types.ServiceConfig{}, chainError(chain, "cannot merge services: %s", err)
+	}
+	merged.Name = name
+
+	// the service is only resolved once.
+	f.services[name] = merged
+	delete(f.extends, name)
+	return merged, nil
+}
+
+// file returns the services of an extended file.
+func (r *extendsResolver) file(file string) (*servicesFile, error) {
+	key := path.Clean(filepath.ToSlash(file))
+	if f, ok := r.files[key]; ok {
+		return f, nil
+	}
+	f, err := r.loadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f.file = key
+	r.files[key] = f
+	return f, nil
+}
+
+// copyService returns a deep copy of a service.
+func copyService(service types.ServiceConfig) types.ServiceConfig {
+	return deepCopy(reflect.ValueOf(service)).Interface().(types.ServiceConfig)
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			c.SetMapIndex(key, deepCopy(v.MapIndex(key)))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// chainError returns an error about an extends chain.
+func chainError(chain []string, format string, args ...interface{}) error {
+	return errors.Errorf("extends %s: %s", strings.Join(chain, " -> "), fmt.Sprintf(format, args...))
+}
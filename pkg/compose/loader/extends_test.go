@@ -0,0 +1,224 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/docker/stacks/pkg/compose/types"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func loadYAMLWithFiles(yaml string, files map[string]string) (*types.Config, error) {
+	dict, err := ParseYAML([]byte(yaml))
+	if err != nil {
+		return nil, err
+	}
+	details := buildConfigDetails(dict, nil)
+	details.Files = map[string][]byte{}
+	for name, content := range files {
+		details.Files[name] = []byte(content)
+	}
+	return Load(details)
+}
+
+func servicesByName(config *types.Config) map[string]types.ServiceConfig {
+	return mapByName(config.Services)
+}
+
+func TestLoadExtendsWithinFile(t *testing.T) {
+	config, err := loadYAMLWithFiles(`
+version: "3.7"
+services:
+  base:
+    image: busybox
+    environment:
+      FOO: "1"
+      BAR: "1"
+    ports:
+      - 8080:80
+    logging:
+      driver: json-file
+      options:
+        max-size: 10m
+  web:
+    extends: base
+    environment:
+      BAR: "2"
+    ports:
+      - 8080:8080
+      - 9090:90
+    logging:
+      options:
+        max-file: "3"
+  worker:
+    extends:
+      service: web
+    command: work
+`, nil)
+	assert.NilError(t, err)
+	services := servicesByName(config)
+	assert.Assert(t, is.Len(services, 3))
+
+	web := services["web"]
+	assert.Check(t, is.Equal("web", web.Name))
+	assert.Check(t, is.Equal("busybox", web.Image))
+	assert.Check(t, is.DeepEqual(types.MappingWithEquals{"FOO": strPtr("1"), "BAR": strPtr("2")}, web.Environment))
+	assert.Check(t, is.DeepEqual([]types.ServicePortConfig{
+		{Mode: "ingress", Target: 8080, Published: 8080, Protocol: "tcp"},
+		{Mode: "ingress", Target: 90, Published: 9090, Protocol: "tcp"},
+	}, web.Ports))
+	assert.Check(t, is.DeepEqual(&types.LoggingConfig{
+		Driver:  "json-file",
+		Options: map[string]string{"max-size": "10m", "max-file": "3"},
+	}, web.Logging))
+
+	worker := services["worker"]
+	assert.Check(t, is.DeepEqual(types.ShellCommand{"work"}, worker.Command))
+	assert.Check(t, is.DeepEqual(web.Environment, worker.Environment))
+
+	// the extended service is left alone
+	base := services["base"]
+	assert.Check(t, is.DeepEqual(types.MappingWithEquals{"FOO": strPtr("1"), "BAR": strPtr("1")}, base.Environment))
+	assert.Check(t, is.DeepEqual(map[string]string{"max-size": "10m"}, base.Logging.Options))
+}
+
+func TestLoadExtendsAcrossFiles(t *testing.T) {
+	config, err := loadYAMLWithFiles(`
+version: "3.7"
+services:
+  web:
+    extends:
+      file: ./common/web.yml
+      service: web
+    environment:
+      FOO: "2"
+`, map[string]string{
+		"common/web.yml": `
+version: "3.7"
+services:
+  web:
+    extends:
+      file: common/base.yml
+      service: base
+    environment:
+      FOO: "1"
+`,
+		"common/base.yml": `
+version: "3.7"
+services:
+  base:
+    image: busybox
+    env_file: base.env
+`,
+		"base.env": "BAR=1\n",
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(config.Services, 1))
+	web := config.Services[0]
+	assert.Check(t, is.Equal("web", web.Name))
+	assert.Check(t, is.Equal("busybox", web.Image))
+	assert.Check(t, is.DeepEqual(types.MappingWithEquals{"FOO": strPtr("2"), "BAR": strPtr("1")}, web.Environment))
+}
+
+func TestLoadExtendsErrors(t *testing.T) {
+	testcases := []struct {
+		doc      string
+		yaml     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			doc: "cycle within a file",
+			yaml: `
+version: "3.7"
+services:
+  web:
+    extends: base
+  base:
+    extends: web
+`,
+			expected: "extends base -> web -> base: cycle between services",
+		},
+		{
+			doc: "cycle across files",
+			yaml: `
+version: "3.7"
+services:
+  web:
+    extends:
+      file: other.yml
+      service: other
+`,
+			files: map[string]string{
+				"other.yml": `
+version: "3.7"
+services:
+  other:
+    extends:
+      file: ./other.yml
+      service: other
+`,
+			},
+			expected: "extends web -> other.yml:other -> other.yml:other: cycle between services",
+		},
+		{
+			doc: "undefined service",
+			yaml: `
+version: "3.7"
+services:
+  web:
+    extends: base
+`,
+			expected: "extends web -> base: service base is not defined",
+		},
+		{
+			doc: "undefined service in another file",
+			yaml: `
+version: "3.7"
+services:
+  web:
+    extends:
+      file: other.yml
+      service: base
+`,
+			files: map[string]string{
+				"other.yml": `
+version: "3.7"
+services:
+  other:
+    image: busybox
+`,
+			},
+			expected: "extends web -> other.yml:base: service base is not defined in other.yml",
+		},
+		{
+			doc: "file not part of the input",
+			yaml: `
+version: "3.7"
+services:
+  web:
+    extends:
+      file: other.yml
+      service: base
+`,
+			expected: "extends web -> other.yml:base: file other.yml is not part of the compose input",
+		},
+		{
+			doc: "invalid extends",
+			yaml: `
+version: "3.7"
+services:
+  web:
+    extends:
+      file: other.yml
+`,
+			expected: "service web: extends must be a service name, or a mapping with a service and an optional file",
+		},
+	}
+
+	for _, tc := range testcases {
+		_, err := loadYAMLWithFiles(tc.yaml, tc.files)
+		assert.Check(t, is.ErrorContains(err, tc.expected), tc.doc)
+	}
+}
@@ -0,0 +1,102 @@
+package loader
+
+import (
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/opts"
+	"github.com/pkg/errors"
+)
+
+// fileSystem resolves the files referenced by compose files. Compose files are
+// submitted to the server by users, so the loader must not access the files of
+// the host, unless it runs on the client.
+type fileSystem interface {
+	// readFile returns the content of a file, given its path in a compose
+	// file.
+	readFile(file string) ([]byte, error)
+	// parseEnvFile parses an env file, given its path in a compose file.
+	parseEnvFile(file string) ([]string, error)
+	// resolveFileObject resolves the file of a secret or config.
+	resolveFileObject(obj *types.FileObjectConfig) error
+}
+
+// newFileSystem returns the fileSystem resolving the files referenced by the
+// provided compose files.
+func newFileSystem(details types.ConfigDetails, resolveHostPaths bool) fileSystem {
+	if resolveHostPaths {
+		return hostFileSystem{workingDir: details.WorkingDir}
+	}
+	return mapFileSystem(details.Files)
+}
+
+// mapFileSystem is a virtual fileSystem made of the files of a compose input,
+// keyed by their slash separated path relative to the project directory. Files
+// outside of the project directory can't be accessed.
+type mapFileSystem map[string][]byte
+
+func (fsys mapFileSystem) parseEnvFile(file string) ([]string, error) {
+	data, err := fsys.readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return opts.ParseEnvFileContent(file, data)
+}
+
+// resolveFileObject sets the data of the secret or config to the content of
+// its file.
+func (fsys mapFileSystem) resolveFileObject(obj *types.FileObjectConfig) error {
+	data, err := fsys.readFile(obj.File)
+	if err != nil {
+		return err
+	}
+	obj.Data = data
+	return nil
+}
+
+func (fsys mapFileSystem) readFile(file string) ([]byte, error) {
+	key, err := bundlePath(file)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := fsys[key]
+	if !ok {
+		return nil, errors.Errorf("file %s is not part of the compose input", file)
+	}
+	return data, nil
+}
+
+// hostFileSystem resolves files on the filesystem of the host, relative to the
+// working directory.
+type hostFileSystem struct {
+	workingDir string
+}
+
+func (fsys hostFileSystem) readFile(file string) ([]byte, error) {
+	return ioutil.ReadFile(absPath(fsys.workingDir, file))
+}
+
+func (fsys hostFileSystem) parseEnvFile(file string) ([]string, error) {
+	return opts.ParseEnvFile(absPath(fsys.workingDir, file))
+}
+
+// resolveFileObject makes the path of the file of the secret or config
+// absolute. The file is only read when the secret or config is created.
+func (fsys hostFileSystem) resolveFileObject(obj *types.FileObjectConfig) error {
+	obj.File = absPath(fsys.workingDir, obj.File)
+	return nil
+}
+
+// bundlePath returns the key of a file in the files of a compose input, given
+// its path relative to the project directory. Absolute paths and paths outside
+// of the project directory are rejected.
+func bundlePath(file string) (string, error) {
+	key := path.Clean(filepath.ToSlash(file))
+	if filepath.IsAbs(file) || path.IsAbs(key) || key == ".." || strings.HasPrefix(key, "../") {
+		return "", errors.Errorf("file %s is outside of the project directory", file)
+	}
+	return key, nil
+}
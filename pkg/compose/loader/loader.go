@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/go-connections/nat"
 	units "github.com/docker/go-units"
 	interp "github.com/docker/stacks/pkg/compose/interpolation"
@@ -32,6 +31,18 @@ type Options struct {
 	SkipInterpolation bool
 	// Interpolation options
 	Interpolate *interp.Options
+	// Resolve the files referenced by the compose files, such as env files
+	// and the files of secrets and configs, on the filesystem of the host,
+	// relative to ConfigDetails.WorkingDir. Only client-side callers may set
+	// it: by default, files are only looked up in ConfigDetails.Files.
+	ResolveHostPaths bool
+	// Profiles are the enabled profiles. The services with profiles are only
+	// loaded if one of their profiles is enabled.
+	Profiles []string
+	// Defer the numeric, boolean and duration properties of the services
+	// which still hold variables after interpolation to ServiceConfig.Deferred,
+	// instead of failing to decode them.
+	DeferVariables bool
 }
 
 // ParseYAML reads the bytes from a file, parses the bytes into a mapping
@@ -70,46 +81,160 @@ func Load(configDetails types.ConfigDetails, options ...func(*Options)) (*types.
 		op(opts)
 	}
 
-	configs := []*types.Config{}
-	var err error
-
-	for _, file := range configDetails.ConfigFiles {
-		configDict := file.Config
-		version := schema.Version(configDict)
-		if configDetails.Version == "" {
-			configDetails.Version = version
-		}
-		if configDetails.Version != version {
-			return nil, errors.Errorf("version mismatched between two composefiles : %v and %v", configDetails.Version, version)
-		}
+	fsys := newFileSystem(configDetails, opts.ResolveHostPaths)
 
+	// prepare returns a compose file ready to be loaded, without the extends
+	// keys of its services, and the services they extend, and without the
+	// values deferred until the substitution of their variables, keyed by
+	// service name.
+	prepare := func(configDict map[string]interface{}) (map[string]interface{}, map[string]extendsConfig, map[string]map[string]string, error) {
 		if err := validateForbidden(configDict); err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 
 		if !opts.SkipInterpolation {
+			var err error
 			configDict, err = interpolateConfig(configDict, *opts.Interpolate)
 			if err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
 		}
 
+		configDict, extends, err := extractExtends(configDict)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		var deferred map[string]map[string]string
+		if opts.DeferVariables {
+			configDict, deferred = deferVariables(configDict)
+		}
+
 		if !opts.SkipValidation {
 			if err := schema.Validate(configDict, configDetails.Version); err != nil {
-				return nil, err
+				return nil, nil, nil, err
 			}
 		}
+		return configDict, extends, deferred, nil
+	}
 
-		cfg, err := loadSections(configDict, configDetails)
+	// the services of the extended files are loaded like the services of the
+	// compose files.
+	resolver := newExtendsResolver(func(file string) (*servicesFile, error) {
+		data, err := fsys.readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		configDict, err := ParseYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		configDict, extends, deferred, err := prepare(configDict)
+		if err != nil {
+			return nil, err
+		}
+		services, err := loadServices(getSection(configDict, "services"), configDetails.WorkingDir, fsys, configDetails.LookupEnv)
 		if err != nil {
 			return nil, err
 		}
+		setDeferred(services, deferred)
+		return &servicesFile{services: mapByName(services), extends: extends}, nil
+	})
+
+	var err error
+	configDetails.Version, err = configVersion(configDetails.Version, configDetails.ConfigFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []*types.Config{}
+
+	// the required variables missing a value in any of the files are all
+	// reported at once.
+	var missing MissingVariables
+	for i, file := range configDetails.ConfigFiles {
+		configDict, extends, deferred, err := prepare(file.Config)
+		if errs, ok := missingVariables(err); ok {
+			missing.addFile(errs, i, file)
+			continue
+		}
+		if err != nil {
+			return nil, locateError(err, i, file)
+		}
+
+		cfg, err := loadSections(configDict, configDetails, fsys)
+		if err != nil {
+			return nil, locateError(err, i, file)
+		}
 		cfg.Filename = file.Filename
+		setDeferred(cfg.Services, deferred)
+
+		cfg.Services, err = resolver.resolveServices(cfg.Services, extends)
+		if err != nil {
+			return nil, locateError(err, i, file)
+		}
 
 		configs = append(configs, cfg)
 	}
+	if err := missing.err(); err != nil {
+		return nil, err
+	}
+
+	config, err := merge(configs)
+	if err != nil {
+		return nil, err
+	}
+	config.Services = filterByProfiles(config.Services, opts.Profiles)
+	return config, nil
+}
+
+// configVersion returns the version of a group of compose files, starting from
+// the given version if any. The files which don't declare a version follow the
+// Compose Specification, a superset of the version 3 file formats: when they
+// are combined with version 3 files, all of them are loaded as such.
+func configVersion(version string, files []types.ConfigFile) (string, error) {
+	for _, file := range files {
+		fileVersion := schema.Version(file.Config)
+		switch {
+		case version == "":
+			version = fileVersion
+		case version == fileVersion:
+		case version == schema.SpecVersion && isVersion3(fileVersion):
+		case fileVersion == schema.SpecVersion && isVersion3(version):
+			version = schema.SpecVersion
+		default:
+			return "", errors.Errorf("version mismatched between two composefiles : %v and %v", version, fileVersion)
+		}
+	}
+	return version, nil
+}
 
-	return merge(configs)
+func isVersion3(version string) bool {
+	return strings.HasPrefix(version, "3.")
+}
+
+// filterByProfiles returns the services without profiles, and the services
+// with at least one of the enabled profiles.
+func filterByProfiles(services []types.ServiceConfig, profiles []string) []types.ServiceConfig {
+	enabled := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		enabled[profile] = true
+	}
+
+	result := make([]types.ServiceConfig, 0, len(services))
+	for _, service := range services {
+		if len(service.Profiles) == 0 {
+			result = append(result, service)
+			continue
+		}
+		for _, profile := range service.Profiles {
+			if enabled[profile] {
+				result = append(result, service)
+				break
+			}
+		}
+	}
+	return result
 }
 
 func validateForbidden(configDict map[string]interface{}) error {
@@ -119,15 +244,48 @@ func validateForbidden(configDict map[string]interface{}) error {
 	}
 	forbidden := getProperties(servicesDict, types.ForbiddenProperties)
 	if len(forbidden) > 0 {
-		return &ForbiddenPropertiesError{Properties: forbidden}
+		return withPath(&ForbiddenPropertiesError{Properties: forbidden}, forbiddenPropertyPath(servicesDict, forbidden))
 	}
 	return nil
 }
 
-func loadSections(config map[string]interface{}, configDetails types.ConfigDetails) (*types.Config, error) {
+// forbiddenPropertyPath returns the path of the first forbidden property of
+// the first service defining one, in name order.
+func forbiddenPropertyPath(servicesDict map[string]interface{}, forbidden map[string]string) string {
+	names := make([]string, 0, len(servicesDict))
+	for name := range servicesDict {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties := make([]string, 0, len(forbidden))
+	for property := range forbidden {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	for _, name := range names {
+		serviceDict, ok := servicesDict[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, property := range properties {
+			if _, ok := serviceDict[property]; ok {
+				return "services." + name + "." + property
+			}
+		}
+	}
+	return "services"
+}
+
+func loadSections(config map[string]interface{}, configDetails types.ConfigDetails, fsys fileSystem) (*types.Config, error) {
 	var err error
-	cfg := types.Config{
-		Version: schema.Version(config),
+	cfg := types.Config{}
+	if version := schema.Version(config); version != schema.SpecVersion {
+		cfg.Version = version
+	}
+	if name, ok := config["name"].(string); ok {
+		cfg.Name = name
 	}
 
 	var loaders = []struct {
@@ -137,7 +295,7 @@ func loadSections(config map[string]interface{}, configDetails types.ConfigDetai
 		{
 			key: "services",
 			fnc: func(config map[string]interface{}) error {
-				cfg.Services, err = LoadServices(config, configDetails.WorkingDir, configDetails.LookupEnv)
+				cfg.Services, err = loadServices(config, configDetails.WorkingDir, fsys, configDetails.LookupEnv)
 				return err
 			},
 		},
@@ -158,21 +316,21 @@ func loadSections(config map[string]interface{}, configDetails types.ConfigDetai
 		{
 			key: "secrets",
 			fnc: func(config map[string]interface{}) error {
-				cfg.Secrets, err = LoadSecrets(config, configDetails)
+				cfg.Secrets, err = loadSecrets(config, configDetails, fsys)
 				return err
 			},
 		},
 		{
 			key: "configs",
 			fnc: func(config map[string]interface{}) error {
-				cfg.Configs, err = LoadConfigObjs(config, configDetails)
+				cfg.Configs, err = loadConfigObjs(config, configDetails, fsys)
 				return err
 			},
 		},
 	}
 	for _, loader := range loaders {
 		if err := loader.fnc(getSection(config, loader.key)); err != nil {
-			return nil, err
+			return nil, withPath(err, loader.key)
 		}
 	}
 	cfg.Extras = getExtras(config)
@@ -333,7 +491,7 @@ func convertToStringKeysRecursive(value interface{}, keyPrefix string) (interfac
 		for key, entry := range mapping {
 			str, ok := key.(string)
 			if !ok {
-				return nil, formatInvalidKeyError(keyPrefix, key)
+				return nil, withPath(formatInvalidKeyError(keyPrefix, key), keyPrefix)
 			}
 			var newKeyPrefix string
 			if keyPrefix == "" {
@@ -376,13 +534,18 @@ func formatInvalidKeyError(keyPrefix string, key interface{}) error {
 
 // LoadServices produces a ServiceConfig map from a compose file Dict
 // the servicesDict is not validated if directly used. Use Load() to enable validation
+// The env files of the services are not accessible.
 func LoadServices(servicesDict map[string]interface{}, workingDir string, lookupEnv template.Mapping) ([]types.ServiceConfig, error) {
+	return loadServices(servicesDict, workingDir, mapFileSystem(nil), lookupEnv)
+}
+
+func loadServices(servicesDict map[string]interface{}, workingDir string, fsys fileSystem, lookupEnv template.Mapping) ([]types.ServiceConfig, error) {
 	var services []types.ServiceConfig
 
 	for name, serviceDef := range servicesDict {
-		serviceConfig, err := LoadService(name, serviceDef.(map[string]interface{}), workingDir, lookupEnv)
+		serviceConfig, err := loadService(name, serviceDef.(map[string]interface{}), workingDir, fsys, lookupEnv)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "services", name)
 		}
 		services = append(services, *serviceConfig)
 	}
@@ -392,14 +555,19 @@ func LoadServices(servicesDict map[string]interface{}, workingDir string, lookup
 
 // LoadService produces a single ServiceConfig from a compose file Dict
 // the serviceDict is not validated if directly used. Use Load() to enable validation
+// The env files of the service are not accessible.
 func LoadService(name string, serviceDict map[string]interface{}, workingDir string, lookupEnv template.Mapping) (*types.ServiceConfig, error) {
+	return loadService(name, serviceDict, workingDir, mapFileSystem(nil), lookupEnv)
+}
+
+func loadService(name string, serviceDict map[string]interface{}, workingDir string, fsys fileSystem, lookupEnv template.Mapping) (*types.ServiceConfig, error) {
 	serviceConfig := &types.ServiceConfig{}
 	if err := Transform(serviceDict, serviceConfig); err != nil {
 		return nil, err
 	}
 	serviceConfig.Name = name
 
-	if err := resolveEnvironment(serviceConfig, workingDir, lookupEnv); err != nil {
+	if err := resolveEnvironment(serviceConfig, fsys, lookupEnv); err != nil {
 		return nil, err
 	}
 
@@ -444,15 +612,14 @@ func updateEnvironment(environment map[string]*string, vars map[string]*string,
 	}
 }
 
-func resolveEnvironment(serviceConfig *types.ServiceConfig, workingDir string, lookupEnv template.Mapping) error {
+func resolveEnvironment(serviceConfig *types.ServiceConfig, fsys fileSystem, lookupEnv template.Mapping) error {
 	environment := make(map[string]*string)
 
 	if len(serviceConfig.EnvFile) > 0 {
 		var envVars []string
 
 		for _, file := range serviceConfig.EnvFile {
-			filePath := absPath(workingDir, file)
-			fileVars, err := opts.ParseEnvFile(filePath)
+			fileVars, err := fsys.parseEnvFile(file)
 			if err != nil {
 				return err
 			}
@@ -534,9 +701,9 @@ func LoadNetworks(source map[string]interface{}, version string) (map[string]typ
 		switch {
 		case network.External.Name != "":
 			if network.Name != "" {
-				return nil, errors.Errorf("network %s: network.external.name and network.name conflict; only use network.name", name)
+				return nil, withPath(errors.Errorf("network %s: network.external.name and network.name conflict; only use network.name", name), "networks", name)
 			}
-			if versions.GreaterThanOrEqualTo(version, "3.5") {
+			if schema.VersionAtLeast(version, "3.5") {
 				logrus.Warnf("network %s: network.external.name is deprecated in favor of network.name", name)
 			}
 			network.Name = network.External.Name
@@ -570,16 +737,16 @@ func LoadVolumes(source map[string]interface{}, version string) (map[string]type
 		}
 		switch {
 		case volume.Driver != "":
-			return nil, externalVolumeError(name, "driver")
+			return nil, withPath(externalVolumeError(name, "driver"), "volumes", name, "driver")
 		case len(volume.DriverOpts) > 0:
-			return nil, externalVolumeError(name, "driver_opts")
+			return nil, withPath(externalVolumeError(name, "driver_opts"), "volumes", name, "driver_opts")
 		case len(volume.Labels) > 0:
-			return nil, externalVolumeError(name, "labels")
+			return nil, withPath(externalVolumeError(name, "labels"), "volumes", name, "labels")
 		case volume.External.Name != "":
 			if volume.Name != "" {
-				return nil, errors.Errorf("volume %s: volume.external.name and volume.name conflict; only use volume.name", name)
+				return nil, withPath(errors.Errorf("volume %s: volume.external.name and volume.name conflict; only use volume.name", name), "volumes", name)
 			}
-			if versions.GreaterThanOrEqualTo(version, "3.4") {
+			if schema.VersionAtLeast(version, "3.4") {
 				logrus.Warnf("volume %s: volume.external.name is deprecated in favor of volume.name", name)
 			}
 			volume.Name = volume.External.Name
@@ -595,15 +762,20 @@ func LoadVolumes(source map[string]interface{}, version string) (map[string]type
 
 // LoadSecrets produces a SecretConfig map from a compose file Dict
 // the source Dict is not validated if directly used. Use Load() to enable validation
+// The files of the secrets are only looked up in details.Files.
 func LoadSecrets(source map[string]interface{}, details types.ConfigDetails) (map[string]types.SecretConfig, error) {
+	return loadSecrets(source, details, mapFileSystem(details.Files))
+}
+
+func loadSecrets(source map[string]interface{}, details types.ConfigDetails, fsys fileSystem) (map[string]types.SecretConfig, error) {
 	secrets := make(map[string]types.SecretConfig)
 	if err := Transform(source, &secrets); err != nil {
 		return secrets, err
 	}
 	for name, secret := range secrets {
-		obj, err := loadFileObjectConfig(name, "secret", types.FileObjectConfig(secret), details)
+		obj, err := loadFileObjectConfig(name, "secret", types.FileObjectConfig(secret), details, fsys)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "secrets", name)
 		}
 		secretConfig := types.SecretConfig(obj)
 		secretConfig.Extras = loadExtras(name, source)
@@ -614,15 +786,20 @@ func LoadSecrets(source map[string]interface{}, details types.ConfigDetails) (ma
 
 // LoadConfigObjs produces a ConfigObjConfig map from a compose file Dict
 // the source Dict is not validated if directly used. Use Load() to enable validation
+// The files of the configs are only looked up in details.Files.
 func LoadConfigObjs(source map[string]interface{}, details types.ConfigDetails) (map[string]types.ConfigObjConfig, error) {
+	return loadConfigObjs(source, details, mapFileSystem(details.Files))
+}
+
+func loadConfigObjs(source map[string]interface{}, details types.ConfigDetails, fsys fileSystem) (map[string]types.ConfigObjConfig, error) {
 	configs := make(map[string]types.ConfigObjConfig)
 	if err := Transform(source, &configs); err != nil {
 		return configs, err
 	}
 	for name, config := range configs {
-		obj, err := loadFileObjectConfig(name, "config", types.FileObjectConfig(config), details)
+		obj, err := loadFileObjectConfig(name, "config", types.FileObjectConfig(config), details, fsys)
 		if err != nil {
-			return nil, err
+			return nil, withPath(err, "configs", name)
 		}
 		configConfig := types.ConfigObjConfig(obj)
 		configConfig.Extras = loadExtras(name, source)
@@ -631,7 +808,7 @@ func LoadConfigObjs(source map[string]interface{}, details types.ConfigDetails)
 	return configs, nil
 }
 
-func loadFileObjectConfig(name string, objType string, obj types.FileObjectConfig, details types.ConfigDetails) (types.FileObjectConfig, error) {
+func loadFileObjectConfig(name string, objType string, obj types.FileObjectConfig, details types.ConfigDetails, fsys fileSystem) (types.FileObjectConfig, error) {
 	// if "external: true"
 	if obj.External.External {
 		// handle deprecated external.name
@@ -639,7 +816,7 @@ func loadFileObjectConfig(name string, objType string, obj types.FileObjectConfi
 			if obj.Name != "" {
 				return obj, errors.Errorf("%[1]s %[2]s: %[1]s.external.name and %[1]s.name conflict; only use %[1]s.name", objType, name)
 			}
-			if versions.GreaterThanOrEqualTo(details.Version, "3.5") {
+			if schema.VersionAtLeast(details.Version, "3.5") {
 				logrus.Warnf("%[1]s %[2]s: %[1]s.external.name is deprecated in favor of %[1]s.name", objType, name)
 			}
 			obj.Name = obj.External.Name
@@ -650,8 +827,13 @@ func loadFileObjectConfig(name string, objType string, obj types.FileObjectConfi
 			}
 		}
 		// if not "external: true"
-	} else {
-		obj.File = absPath(details.WorkingDir, obj.File)
+	} else if obj.Driver != "" {
+		// the secrets of a driver are stored by an external secret store
+		if obj.File != "" {
+			return obj, errors.Errorf("%[1]s %[2]s: %[1]s.driver and %[1]s.file conflict; only use %[1]s.driver", objType, name)
+		}
+	} else if err := fsys.resolveFileObject(&obj); err != nil {
+		return obj, errors.Wrapf(err, "%s %s", objType, name)
 	}
 
 	return obj, nil
@@ -3,6 +3,7 @@ package loader
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/docker/stacks/pkg/compose/interpolation"
 	"github.com/docker/stacks/pkg/compose/schema"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/opts"
 	"github.com/docker/stacks/pkg/types"
 
 	"github.com/pkg/errors"
@@ -18,15 +20,39 @@ import (
 // TODO - this file needs some refactoring
 
 // LoadComposefile will load the compose files into ComposeInput which can be sent to the server
-// for parsing into a Stack representation
+// for parsing into a Stack representation. The .env file of the project
+// directory, which is the directory of the first compose file, and the files
+// referenced by the compose files are loaded along with them.
 func LoadComposefile(composefiles []string) (*types.ComposeInput, error) {
 	input := types.ComposeInput{}
+	if len(composefiles) == 0 {
+		return &input, nil
+	}
+
+	workingDir := filepath.Dir(composefiles[0])
+	files := map[string][]byte{}
 	for _, filename := range composefiles {
 		bytes, err := ioutil.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
 		input.ComposeFiles = append(input.ComposeFiles, string(bytes))
+		input.Filenames = append(input.Filenames, filename)
+
+		config, err := ParseYAML(bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse %s", filename)
+		}
+		if err := collectFiles(files, workingDir, config); err != nil {
+			return nil, errors.Wrapf(err, "unable to load the files referenced by %s", filename)
+		}
+	}
+	if err := collectEnvFile(files, workingDir); err != nil {
+		return nil, err
+	}
+
+	if len(files) > 0 {
+		input.Files = files
 	}
 	return &input, nil
 }
@@ -38,6 +64,8 @@ func LoadComposefile(composefiles []string) (*types.ComposeInput, error) {
 // listed in the StackSpec.PropertyValues field, so they can be filled
 // in prior to sending the StackCreate to the Create API.  If defaults
 // are defined in the compose file(s) those defaults will be included.
+// The properties declared by the x-properties extension of the compose
+// files are listed in the StackSpec.Properties field.
 func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 	if len(input.ComposeFiles) == 0 {
 		return nil, nil
@@ -45,10 +73,23 @@ func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 
 	configDetails, err := getConfigDetails(input)
 	if err != nil {
-		return nil, err
+		return nil, composeError(err)
 	}
 
-	dicts := getDictsFrom(configDetails.ConfigFiles)
+	// the declarations of the properties aren't interpolated, as their
+	// descriptions and regexes may contain dollar signs.
+	declared, err := loadProperties(configDetails.ConfigFiles)
+	if err != nil {
+		return nil, composeError(err)
+	}
+	configDetails.ConfigFiles = withoutProperties(configDetails.ConfigFiles)
+
+	// the .env file provides the default values of the variables, over the
+	// defaults of the compose files.
+	envDefaults, err := loadEnvDefaults(input.Files)
+	if err != nil {
+		return nil, err
+	}
 
 	// Wire up interpolation as a no-op so we can track the variables in play and default values
 	propertiesMap := map[string]string{}
@@ -64,32 +105,49 @@ func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 		},
 		Substitute: defaults.RecordVariablesWithDefaults,
 	}
+	// the files are validated against the schema of their version. The
+	// files of versions without a schema, such as version 2 files, are
+	// loaded as they are.
+	version, err := configVersion(configDetails.Version, configDetails.ConfigFiles)
+	if err != nil {
+		return nil, composeError(err)
+	}
 	config, err := Load(configDetails, func(opts *Options) {
 		opts.Interpolate = &interpolateOpts
-		opts.SkipValidation = true
+		opts.SkipValidation = !schema.Supported(version)
+		opts.Profiles = input.Profiles
+		opts.DeferVariables = true
 	})
 	if err != nil {
-		if fpe, ok := err.(*ForbiddenPropertiesError); ok {
-			return nil, errors.Errorf("Compose file contains unsupported options:\n\n%s\n",
-				propertyWarnings(fpe.Properties))
-		}
+		return nil, composeError(err)
+	}
 
-		return nil, err
+	if err := checkConversion(config, configDetails.ConfigFiles); err != nil {
+		return nil, composeError(err)
 	}
 
-	unsupportedProperties := GetUnsupportedProperties(dicts...)
-	if len(unsupportedProperties) > 0 {
-		fmt.Printf("Ignoring unsupported options: %s\n\n",
-			strings.Join(unsupportedProperties, ", "))
+	// the unsupported and deprecated properties are ignored, and reported
+	// along with the StackCreate.
+	warnings := Warnings(config.Services)
+	if input.Strict && len(warnings) > 0 {
+		return nil, &types.WarningsError{Warnings: warnings}
 	}
 
-	deprecatedProperties := GetDeprecatedProperties(dicts...)
-	if len(deprecatedProperties) > 0 {
-		fmt.Printf("Ignoring deprecated options:\n\n%s\n\n",
-			propertyWarnings(deprecatedProperties))
+	// the declared properties are listed even if no variable refers to them,
+	// and their defaults take precedence over these of the variables.
+	for name, property := range declared {
+		if property.Default != nil {
+			propertiesMap[name] = *property.Default
+		} else if _, exists := propertiesMap[name]; !exists {
+			propertiesMap[name] = ""
+		}
 	}
+
 	properties := []string{}
 	for key, value := range propertiesMap {
+		if envValue, ok := envDefaults[key]; ok {
+			value = envValue
+		}
 		if len(value) > 0 {
 			properties = append(properties, fmt.Sprintf("%s=%s", key, value))
 		} else {
@@ -104,19 +162,26 @@ func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 			Networks:       config.Networks,
 			Volumes:        config.Volumes,
 			PropertyValues: properties,
+			Properties:     declared,
 		},
+		Warnings: warnings,
 	}, nil
 
 }
 
-func getDictsFrom(configFiles []composetypes.ConfigFile) []map[string]interface{} {
-	dicts := []map[string]interface{}{}
-
-	for _, configFile := range configFiles {
-		dicts = append(dicts, configFile.Config)
+// composeError returns the errors of the compose files as returned by the
+// API, located in the files when possible.
+func composeError(err error) error {
+	located, ok := err.(*Error)
+	if !ok {
+		return err
 	}
-
-	return dicts
+	composeErr := located.ComposeError()
+	if fpe, ok := errors.Cause(located).(*ForbiddenPropertiesError); ok {
+		composeErr.Message = fmt.Sprintf("Compose file contains unsupported options:\n\n%s\n",
+			propertyWarnings(fpe.Properties))
+	}
+	return composeErr
 }
 
 func propertyWarnings(properties map[string]string) string {
@@ -131,23 +196,29 @@ func propertyWarnings(properties map[string]string) string {
 func getConfigDetails(input types.ComposeInput) (composetypes.ConfigDetails, error) {
 	var details composetypes.ConfigDetails
 
+	// the files referenced by the compose files are only looked up in the
+	// files of the input, never on the filesystem of the server.
+	details.Files = input.Files
+
 	var err error
 	details.ConfigFiles, err = loadConfigFiles(input)
 	if err != nil {
 		return details, err
 	}
-	// Take the first file version (2 files can't have different version)
-	details.Version = schema.Version(details.ConfigFiles[0].Config)
+	details.Version, err = configVersion("", details.ConfigFiles)
 	return details, err
 }
 
 func loadConfigFiles(input types.ComposeInput) ([]composetypes.ConfigFile, error) {
 	var configFiles []composetypes.ConfigFile
 
-	for _, data := range input.ComposeFiles {
+	for i, data := range input.ComposeFiles {
 		configFile, err := loadConfigFile(data)
+		if i < len(input.Filenames) {
+			configFile.Filename = input.Filenames[i]
+		}
 		if err != nil {
-			return configFiles, err
+			return configFiles, locateError(err, i, *configFile)
 		}
 		configFiles = append(configFiles, *configFile)
 	}
@@ -156,14 +227,30 @@ func loadConfigFiles(input types.ComposeInput) ([]composetypes.ConfigFile, error
 }
 
 func loadConfigFile(data string) (*composetypes.ConfigFile, error) {
+	configFile := &composetypes.ConfigFile{
+		Content: []byte(data),
+	}
+
 	var err error
+	configFile.Config, err = ParseYAML(configFile.Content)
+	return configFile, err
+}
 
-	config, err := ParseYAML([]byte(data))
+// loadEnvDefaults returns the values defined by the .env file of a compose
+// input, if any.
+func loadEnvDefaults(files map[string][]byte) (map[string]string, error) {
+	data, ok := files[envFilename]
+	if !ok {
+		return nil, nil
+	}
+	vars, err := opts.ParseEnvFileContent(envFilename, data)
 	if err != nil {
 		return nil, err
 	}
-
-	return &composetypes.ConfigFile{
-		Config: config,
-	}, nil
+	defaults := make(map[string]string, len(vars))
+	for _, v := range vars {
+		kv := strings.SplitN(v, "=", 2)
+		defaults[kv[0]] = kv[1]
+	}
+	return defaults, nil
 }
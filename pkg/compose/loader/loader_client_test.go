@@ -2,10 +2,15 @@ package loader
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/docker/stacks/pkg/compose/template"
+	"github.com/docker/stacks/pkg/types"
 
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
@@ -73,3 +78,395 @@ func TestComposeWithEnv(t *testing.T) {
 		// TODO - deeper inspection of the results, default values, etc.
 	*/
 }
+
+// writeProject writes the provided files, keyed by their slash separated path,
+// to a new temporary directory, and returns the directory.
+func writeProject(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "compose-files")
+	assert.NilError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NilError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestComposeWithFiles(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"docker-compose.yml": `version: '3.3'
+services:
+  web:
+    image: ${IMAGE}
+    env_file:
+      - ./web.env
+      - config/${ENV_FILE}
+secrets:
+  password:
+    file: ./secrets/password
+  external:
+    external: true
+configs:
+  settings:
+    file: config/settings.yml
+`,
+		".env":                "IMAGE=nginx\n",
+		"web.env":             "LOG_LEVEL=debug\n",
+		"secrets/password":    "secret",
+		"config/settings.yml": "a: b\n",
+		"unused":              "unused",
+	})
+	defer os.RemoveAll(dir)
+
+	input, err := LoadComposefile([]string{filepath.Join(dir, "docker-compose.yml")})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(map[string][]byte{
+		".env":                []byte("IMAGE=nginx\n"),
+		"web.env":             []byte("LOG_LEVEL=debug\n"),
+		"secrets/password":    []byte("secret"),
+		"config/settings.yml": []byte("a: b\n"),
+	}, input.Files))
+
+	// the env file named by a variable can't be resolved
+	_, err = ParseComposeInput(*input)
+	assert.Check(t, is.ErrorContains(err, "file config/${ENV_FILE} is not part of the compose input"))
+
+	input.ComposeFiles[0] = strings.Replace(input.ComposeFiles[0], "      - config/${ENV_FILE}\n", "", 1)
+	stack, err := ParseComposeInput(*input)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"IMAGE=nginx"}, stack.Spec.PropertyValues))
+	assert.Assert(t, is.Len(stack.Spec.Services, 1))
+	logLevel := "debug"
+	assert.Check(t, is.DeepEqual(map[string]*string{"LOG_LEVEL": &logLevel}, map[string]*string(stack.Spec.Services[0].Environment)))
+	assert.Check(t, is.DeepEqual([]byte("secret"), stack.Spec.Secrets["password"].Data))
+	assert.Check(t, is.DeepEqual([]byte("a: b\n"), stack.Spec.Configs["settings"].Data))
+
+	// files are never read from the filesystem of the server
+	delete(input.Files, "secrets/password")
+	_, err = ParseComposeInput(*input)
+	assert.Check(t, is.ErrorContains(err, "secret password: file ./secrets/password is not part of the compose input"))
+}
+
+func TestComposeWithFilesOutsideProject(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"project/docker-compose.yml": `version: '3.3'
+services:
+  web:
+    image: nginx
+    env_file: ../web.env
+`,
+		"web.env": "LOG_LEVEL=debug\n",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := LoadComposefile([]string{filepath.Join(dir, "project", "docker-compose.yml")})
+	assert.Check(t, is.ErrorContains(err, "file ../web.env is outside of the project directory"))
+}
+
+func TestComposeWithExtends(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"docker-compose.yml": `version: '3.3'
+services:
+  web:
+    extends:
+      file: common/services.yml
+      service: web
+    environment:
+      FOO: "2"
+`,
+		"common/services.yml": `version: '3.3'
+services:
+  web:
+    extends:
+      file: common/base.yml
+      service: base
+    environment:
+      FOO: "1"
+`,
+		"common/base.yml": `version: '3.3'
+services:
+  base:
+    image: ${IMAGE}
+    env_file: base.env
+`,
+		"base.env": "BAR=1\n",
+	})
+	defer os.RemoveAll(dir)
+
+	input, err := LoadComposefile([]string{filepath.Join(dir, "docker-compose.yml")})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(input.Files, 3))
+	assert.Check(t, is.Contains(input.Files, "common/services.yml"))
+	assert.Check(t, is.Contains(input.Files, "common/base.yml"))
+	assert.Check(t, is.Contains(input.Files, "base.env"))
+
+	stack, err := ParseComposeInput(*input)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"IMAGE"}, stack.Spec.PropertyValues))
+	assert.Assert(t, is.Len(stack.Spec.Services, 1))
+	assert.Check(t, is.Equal("${IMAGE}", stack.Spec.Services[0].Image))
+	foo, bar := "2", "1"
+	assert.Check(t, is.DeepEqual(map[string]*string{"FOO": &foo, "BAR": &bar}, map[string]*string(stack.Spec.Services[0].Environment)))
+}
+
+func TestComposeInputWarnings(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`version: '3.3'
+services:
+  web:
+    image: busybox
+    privileged: true
+`},
+	}
+
+	stack, err := ParseComposeInput(input)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]types.Warning{{
+		Property: "services.web.privileged",
+		Service:  "web",
+		Message:  "privileged is not supported, and is ignored",
+		Severity: types.WarningSeverityWarning,
+	}}, stack.Warnings))
+
+	input.Strict = true
+	_, err = ParseComposeInput(input)
+	assert.Check(t, is.ErrorContains(err, "services.web.privileged: privileged is not supported"))
+	assert.Check(t, is.ErrorType(err, &types.WarningsError{}))
+}
+
+func TestComposeInputDeferredVariables(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`version: '3.7'
+services:
+  web:
+    image: busybox
+    read_only: ${READ_ONLY:-true}
+    stop_grace_period: ${GRACE_PERIOD}
+    deploy:
+      replicas: ${REPLICAS}
+      resources:
+        limits:
+          cpus: ${CPUS}
+`, `version: '3.7'
+services:
+  web:
+    deploy:
+      replicas: 3
+`},
+	}
+
+	stack, err := ParseComposeInput(input)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(stack.Spec.Services, 1))
+	web := stack.Spec.Services[0]
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"read_only":         "${READ_ONLY:-true}",
+		"stop_grace_period": "${GRACE_PERIOD}",
+	}, web.Deferred))
+	replicas := uint64(3)
+	assert.Check(t, is.DeepEqual(&replicas, web.Deploy.Replicas))
+	// the string properties hold their variables.
+	assert.Check(t, is.Equal("${CPUS}", web.Deploy.Resources.Limits.NanoCPUs))
+	assert.Check(t, is.Contains(stack.Spec.PropertyValues, "READ_ONLY=true"))
+	assert.Check(t, is.Contains(stack.Spec.PropertyValues, "GRACE_PERIOD"))
+}
+
+func TestComposeInputProperties(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`version: '3.7'
+x-properties:
+  REPLICAS:
+    description: The number of replicas, e.g. $NODES
+    type: int
+    required: true
+    default: 2
+  ENV:
+    enum: [dev, prod]
+  DEBUG:
+    type: bool
+  PASSWORD:
+    sensitive: true
+    secret: true
+services:
+  web:
+    image: busybox:${TAG}
+    environment:
+      ENV: ${ENV:-dev}
+    deploy:
+      replicas: ${REPLICAS:-1}
+`, `version: '3.7'
+x-properties:
+  TAG:
+    regex: "[0-9.]+"
+`},
+	}
+
+	stack, err := ParseComposeInput(input)
+	assert.NilError(t, err)
+	two := "2"
+	assert.Check(t, is.DeepEqual(map[string]types.Property{
+		"REPLICAS": {
+			Description: "The number of replicas, e.g. $NODES",
+			Type:        types.PropertyTypeInt,
+			Required:    true,
+			Default:     &two,
+		},
+		"ENV":      {Enum: []string{"dev", "prod"}},
+		"DEBUG":    {Type: types.PropertyTypeBool},
+		"PASSWORD": {Sensitive: true, Secret: true},
+		"TAG":      {Regex: "[0-9.]+"},
+	}, stack.Spec.Properties))
+
+	sort.Strings(stack.Spec.PropertyValues)
+	assert.Check(t, is.DeepEqual([]string{"DEBUG", "ENV=dev", "PASSWORD", "REPLICAS=2", "TAG"}, stack.Spec.PropertyValues))
+}
+
+func TestComposeInputInvalidProperties(t *testing.T) {
+	testcases := []struct {
+		doc      string
+		yaml     string
+		expected string
+		line     int
+	}{
+		{
+			doc: "unknown type",
+			yaml: `version: '3.7'
+x-properties:
+  FOO:
+    type: float
+`,
+			expected: "property FOO: type: unknown type float",
+			line:     4,
+		},
+		{
+			doc: "invalid default",
+			yaml: `version: '3.7'
+x-properties:
+  FOO:
+    enum: [a, b]
+    default: c
+`,
+			expected: `property FOO: default: "c" is not one of a, b`,
+			line:     5,
+		},
+		{
+			doc: "unsupported field",
+			yaml: `version: '3.7'
+x-properties:
+  FOO:
+    hidden: true
+`,
+			expected: "property FOO: hidden: unsupported field",
+			line:     4,
+		},
+		{
+			doc: "secret property which is not sensitive",
+			yaml: `version: '3.7'
+x-properties:
+  FOO:
+    secret: true
+`,
+			expected: "property FOO: secret: the property must be sensitive",
+			line:     4,
+		},
+	}
+
+	for _, tc := range testcases {
+		_, err := ParseComposeInput(types.ComposeInput{ComposeFiles: []string{tc.yaml}})
+		composeErr, ok := err.(*types.ComposeError)
+		assert.Assert(t, ok, "%s: %v", tc.doc, err)
+		assert.Check(t, is.Equal(tc.expected, composeErr.Message), tc.doc)
+		assert.Check(t, is.Equal(tc.line, composeErr.Line), tc.doc)
+	}
+}
+
+func TestComposeInputErrorPositions(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`
+version: "3.7"
+services:
+  web:
+    image: nginx
+`, `
+version: "3.7"
+services:
+  web:
+    cpu_quota: 50000
+`},
+		Filenames: []string{"docker-compose.yml", "docker-compose.override.yml"},
+	}
+	_, err := ParseComposeInput(input)
+	assert.Assert(t, is.ErrorType(err, &types.ComposeError{}))
+	assert.Check(t, is.DeepEqual(&types.ComposeError{
+		FileIndex: 1,
+		File:      "docker-compose.override.yml",
+		Line:      5,
+		Column:    5,
+		Path:      "services.web.cpu_quota",
+		Message:   "Compose file contains unsupported options:\n\ncpu_quota: Set resource limits using deploy.resources\n",
+	}, err))
+
+	input.ComposeFiles[1] = `
+version: "3.7"
+services:
+  web:
+    image: [nginx
+`
+	_, err = ParseComposeInput(input)
+	assert.Assert(t, is.ErrorType(err, &types.ComposeError{}))
+	composeErr := err.(*types.ComposeError)
+	assert.Check(t, is.Equal(1, composeErr.FileIndex))
+	assert.Check(t, is.Equal(5, composeErr.Line))
+	assert.Check(t, is.ErrorContains(err, "docker-compose.override.yml:5: yaml: line 5:"))
+}
+
+func TestComposeInputValidation(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`version: "3.7"
+services:
+  web:
+    image: nginx
+    restart_policy: always
+`},
+	}
+	_, err := ParseComposeInput(input)
+	assert.Assert(t, is.ErrorType(err, &types.ComposeError{}))
+	assert.Check(t, is.ErrorContains(err, "Additional property restart_policy is not allowed"))
+}
+
+func TestComposeInputConversionErrors(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`version: "3.7"
+services:
+  web:
+    image: nginx
+`, `version: "3.7"
+services:
+  web:
+    deploy:
+      resources:
+        limits:
+          cpus: lots
+`},
+		Filenames: []string{"docker-compose.yml", "docker-compose.override.yml"},
+	}
+	_, err := ParseComposeInput(input)
+	assert.Assert(t, is.ErrorType(err, &types.ComposeError{}))
+	composeErr := err.(*types.ComposeError)
+	assert.Check(t, is.Equal(1, composeErr.FileIndex))
+	assert.Check(t, is.Equal("services.web.deploy.resources", composeErr.Path))
+	assert.Check(t, is.Equal(5, composeErr.Line))
+	assert.Check(t, is.Contains(composeErr.Message, "service web: "))
+
+	// the values holding variables are converted when the stack is created
+	input.ComposeFiles[1] = `version: "3.7"
+services:
+  web:
+    deploy:
+      resources:
+        limits:
+          cpus: ${CPUS}
+`
+	_, err = ParseComposeInput(input)
+	assert.NilError(t, err)
+}
@@ -10,7 +10,9 @@ import (
 	"time"
 
 	"github.com/docker/stacks/pkg/compose/types"
+	stacktypes "github.com/docker/stacks/pkg/types"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
@@ -31,6 +33,12 @@ func buildConfigDetails(source map[string]interface{}, env map[string]string) ty
 	}
 }
 
+// withHostPaths resolves the files referenced by the compose files of the
+// tests, which are in the working directory, on the host.
+func withHostPaths(opts *Options) {
+	opts.ResolveHostPaths = true
+}
+
 func loadYAML(yaml string) (*types.Config, error) {
 	return loadYAMLWithEnv(yaml, nil)
 }
@@ -41,7 +49,7 @@ func loadYAMLWithEnv(yaml string, env map[string]string) (*types.Config, error)
 		return nil, err
 	}
 
-	return Load(buildConfigDetails(dict, env))
+	return Load(buildConfigDetails(dict, env), withHostPaths)
 }
 
 var sampleYAML = `
@@ -404,11 +412,114 @@ services:
 }
 
 func TestV1Unsupported(t *testing.T) {
+	// the files without a version follow the Compose Specification, whose
+	// services are not top-level keys.
 	_, err := loadYAML(`
 foo:
   image: busybox
 `)
-	assert.ErrorContains(t, err, "unsupported Compose file version: 1.0")
+	assert.ErrorContains(t, err, "Additional property foo is not allowed")
+}
+
+func TestLoadComposeSpec(t *testing.T) {
+	config, err := loadYAML(`
+name: myproject
+x-defaults: &defaults
+  image: busybox
+services:
+  foo:
+    <<: *defaults
+    x-owner: team
+    deploy:
+      x-tier: backend
+      placement:
+        max_replicas_per_node: 2
+  debug:
+    <<: *defaults
+    profiles: [debug]
+secrets:
+  vault:
+    driver: vault
+    driver_opts:
+      path: secret/foo
+    template_driver: golang
+`)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("myproject", config.Name))
+	assert.Check(t, is.Equal("", config.Version))
+	assert.Check(t, is.Len(config.Services, 1))
+	foo := config.Services[0]
+	assert.Check(t, is.Equal("foo", foo.Name))
+	assert.Check(t, is.DeepEqual(map[string]interface{}{"x-owner": "team"}, foo.Extras))
+	assert.Check(t, is.Equal(uint64(2), foo.Deploy.Placement.MaxReplicas))
+	assert.Check(t, is.DeepEqual(types.SecretConfig{
+		Driver:         "vault",
+		DriverOpts:     map[string]string{"path": "secret/foo"},
+		TemplateDriver: "golang",
+	}, config.Secrets["vault"]))
+}
+
+func TestLoadWithProfiles(t *testing.T) {
+	dict, err := ParseYAML([]byte(`
+services:
+  foo:
+    image: busybox
+  debug:
+    image: busybox
+    profiles: [debug, test]
+  bar:
+    image: busybox
+    profiles: [bar]
+`))
+	assert.NilError(t, err)
+	config, err := Load(buildConfigDetails(dict, nil), func(opts *Options) {
+		opts.Profiles = []string{"test"}
+	})
+	assert.NilError(t, err)
+	services := servicesByName(config)
+	assert.Check(t, is.Len(services, 2))
+	assert.Check(t, is.Contains(services, "foo"))
+	assert.Check(t, is.Contains(services, "debug"))
+}
+
+func TestLoadMixedVersions(t *testing.T) {
+	details := types.ConfigDetails{
+		ConfigFiles: []types.ConfigFile{
+			{Filename: "base.yml", Config: map[string]interface{}{
+				"version":  "3.8",
+				"services": map[string]interface{}{"foo": map[string]interface{}{"image": "busybox"}},
+			}},
+			{Filename: "override.yml", Config: map[string]interface{}{
+				"name":     "myproject",
+				"services": map[string]interface{}{"foo": map[string]interface{}{"profiles": []interface{}{"foo"}}},
+			}},
+		},
+	}
+	config, err := Load(details, func(opts *Options) {
+		opts.Profiles = []string{"foo"}
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("myproject", config.Name))
+	assert.Check(t, is.Equal("3.8", config.Version))
+	assert.Check(t, is.Len(config.Services, 1))
+
+	details.ConfigFiles[0].Config["version"] = "2.4"
+	_, err = Load(details)
+	assert.ErrorContains(t, err, "version mismatched between two composefiles : 2.4 and spec")
+}
+
+func TestLoadSecretDriverAndFileConflict(t *testing.T) {
+	_, err := loadYAML(`
+version: "3.8"
+services:
+  foo:
+    image: busybox
+secrets:
+  foo:
+    driver: vault
+    file: ./secret.txt
+`)
+	assert.ErrorContains(t, err, "secret foo: secret.driver and secret.file conflict; only use secret.driver")
 }
 
 func TestNonMappingObject(t *testing.T) {
@@ -783,6 +894,37 @@ services:
 	assert.Check(t, is.Contains(deprecated, "expose"))
 }
 
+func TestWarnings(t *testing.T) {
+	config, err := loadYAML(`
+version: "3"
+services:
+  web:
+    image: web
+    build: ./web
+    container_name: web
+    privileged: false
+  db:
+    image: db
+    cap_add: [NET_ADMIN]
+    expose: ["5434"]
+`)
+	assert.NilError(t, err)
+
+	warnings := Warnings(config.Services)
+	assert.Assert(t, is.Len(warnings, 4))
+	assert.Check(t, is.DeepEqual(stacktypes.Warning{
+		Property: "services.db.cap_add",
+		Service:  "db",
+		Message:  "cap_add is not supported, and is ignored",
+		Severity: stacktypes.WarningSeverityWarning,
+	}, warnings[0]))
+	assert.Check(t, is.Equal("services.db.expose", warnings[1].Property))
+	assert.Check(t, is.Equal(stacktypes.WarningSeverityInfo, warnings[1].Severity))
+	assert.Check(t, is.Equal("services.web.build", warnings[2].Property))
+	assert.Check(t, is.Equal("services.web.container_name", warnings[3].Property))
+	assert.Check(t, is.Contains(warnings[3].Message, "Setting the container name is not supported."))
+}
+
 func TestForbiddenProperties(t *testing.T) {
 	_, err := loadYAML(`
 version: "3"
@@ -793,16 +935,17 @@ services:
       - /data
     volume_driver: some-driver
   bar:
-    extends:
-      service: foo
+    image: busybox
+    cpu_quota: 50000
 `)
 
-	assert.ErrorType(t, err, reflect.TypeOf(&ForbiddenPropertiesError{}))
+	assert.ErrorType(t, errors.Cause(err), reflect.TypeOf(&ForbiddenPropertiesError{}))
+	assert.Check(t, is.Equal("services.bar.cpu_quota", err.(*Error).Path))
 
-	props := err.(*ForbiddenPropertiesError).Properties
+	props := errors.Cause(err).(*ForbiddenPropertiesError).Properties
 	assert.Check(t, is.Len(props, 2))
 	assert.Check(t, is.Contains(props, "volume_driver"))
-	assert.Check(t, is.Contains(props, "extends"))
+	assert.Check(t, is.Contains(props, "cpu_quota"))
 }
 
 func TestInvalidResource(t *testing.T) {
@@ -961,7 +1104,7 @@ services:
       - type: bind
         target: /app
 `)
-	assert.Error(t, err, `invalid mount config for type "bind": field Source must not be empty`)
+	assert.Error(t, err, `filename.yml: invalid mount config for type "bind": field Source must not be empty`)
 }
 
 func TestLoadBindMountWithSource(t *testing.T) {
@@ -1315,6 +1458,60 @@ func TestLoadSecretsWarnOnDeprecatedExternalNameVersion35(t *testing.T) {
 	assert.Check(t, is.Contains(buf.String(), "secret.external.name is deprecated"))
 }
 
+func TestLoadWithoutHostAccess(t *testing.T) {
+	load := func(yaml string, files map[string][]byte) (*types.Config, error) {
+		dict, err := ParseYAML([]byte(yaml))
+		assert.NilError(t, err)
+		details := buildConfigDetails(dict, nil)
+		details.Files = files
+		return Load(details)
+	}
+
+	// files of the host are never accessed
+	_, err := load(`
+version: "3.5"
+secrets:
+  shadow:
+    file: /etc/shadow
+`, nil)
+	assert.Check(t, is.ErrorContains(err, "secret shadow: file /etc/shadow is outside of the project directory"))
+
+	_, err = load(`
+version: "3.5"
+configs:
+  parent:
+    file: ./config/../../parent.yml
+`, nil)
+	assert.Check(t, is.ErrorContains(err, "config parent: file ./config/../../parent.yml is outside of the project directory"))
+
+	_, err = load(`
+version: "3.5"
+services:
+  web:
+    image: busybox
+    env_file: full-example.yml
+`, nil)
+	assert.Check(t, is.ErrorContains(err, "file full-example.yml is not part of the compose input"))
+
+	config, err := load(`
+version: "3.5"
+services:
+  web:
+    image: busybox
+    env_file: config/../web.env
+secrets:
+  password:
+    file: ./password
+`, map[string][]byte{
+		"web.env":  []byte("A=1\n"),
+		"password": []byte("secret"),
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(types.MappingWithEquals{"A": strPtr("1")}, config.Services[0].Environment))
+	assert.Check(t, is.Equal("./password", config.Secrets["password"].File))
+	assert.Check(t, is.DeepEqual([]byte("secret"), config.Secrets["password"].Data))
+}
+
 func TestLoadNetworksWarnOnDeprecatedExternalNameVersion35(t *testing.T) {
 	buf, cleanup := patchLogrus()
 	defer cleanup()
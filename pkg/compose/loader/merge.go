@@ -23,6 +23,9 @@ func (s *specials) Transformer(t reflect.Type) func(dst, src reflect.Value) erro
 func merge(configs []*types.Config) (*types.Config, error) {
 	base := configs[0]
 	for _, override := range configs[1:] {
+		if override.Name != "" {
+			base.Name = override.Name
+		}
 		var err error
 		base.Services, err = mergeServices(base.Services, override.Services)
 		if err != nil {
@@ -48,23 +51,27 @@ func merge(configs []*types.Config) (*types.Config, error) {
 	return base, nil
 }
 
+// serviceSpecials are the fields of services which are not simply appended or
+// overridden when merging services.
+var serviceSpecials = &specials{
+	m: map[reflect.Type]func(dst, src reflect.Value) error{
+		reflect.TypeOf(&types.LoggingConfig{}):           safelyMerge(mergeLoggingConfig),
+		reflect.TypeOf([]types.ServicePortConfig{}):      mergeSlice(toServicePortConfigsMap, toServicePortConfigsSlice),
+		reflect.TypeOf([]types.ServiceSecretConfig{}):    mergeSlice(toServiceSecretConfigsMap, toServiceSecretConfigsSlice),
+		reflect.TypeOf([]types.ServiceConfigObjConfig{}): mergeSlice(toServiceConfigObjConfigsMap, toSServiceConfigObjConfigsSlice),
+	},
+}
+
 func mergeServices(base, override []types.ServiceConfig) ([]types.ServiceConfig, error) {
 	baseServices := mapByName(base)
 	overrideServices := mapByName(override)
-	specials := &specials{
-		m: map[reflect.Type]func(dst, src reflect.Value) error{
-			reflect.TypeOf(&types.LoggingConfig{}):           safelyMerge(mergeLoggingConfig),
-			reflect.TypeOf([]types.ServicePortConfig{}):      mergeSlice(toServicePortConfigsMap, toServicePortConfigsSlice),
-			reflect.TypeOf([]types.ServiceSecretConfig{}):    mergeSlice(toServiceSecretConfigsMap, toServiceSecretConfigsSlice),
-			reflect.TypeOf([]types.ServiceConfigObjConfig{}): mergeSlice(toServiceConfigObjConfigsMap, toSServiceConfigObjConfigsSlice),
-		},
-	}
 	for name, overrideService := range overrideServices {
 		if baseService, ok := baseServices[name]; ok {
-			if err := mergo.Merge(&baseService, &overrideService, mergo.WithAppendSlice, mergo.WithOverride, mergo.WithTransformers(specials)); err != nil {
+			merged, err := mergeService(baseService, overrideService)
+			if err != nil {
 				return base, errors.Wrapf(err, "cannot merge service %s", name)
 			}
-			baseServices[name] = baseService
+			baseServices[name] = merged
 			continue
 		}
 		baseServices[name] = overrideService
@@ -77,6 +84,14 @@ func mergeServices(base, override []types.ServiceConfig) ([]types.ServiceConfig,
 	return services, nil
 }
 
+// mergeService merges the override service into the base service.
+func mergeService(base, override types.ServiceConfig) (types.ServiceConfig, error) {
+	deferred := mergeDeferred(base, override)
+	err := mergo.Merge(&base, &override, mergo.WithAppendSlice, mergo.WithOverride, mergo.WithTransformers(serviceSpecials))
+	base.Deferred = deferred
+	return base, err
+}
+
 func toServiceSecretConfigsMap(s interface{}) (map[interface{}]interface{}, error) {
 	secrets, ok := s.([]types.ServiceSecretConfig)
 	if !ok {
@@ -0,0 +1,187 @@
+package loader
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/docker/stacks/pkg/compose/types"
+	stacktypes "github.com/docker/stacks/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// propertiesKey is the key of the extension of the compose files declaring
+// the properties of the stack, i.e. its variables.
+const propertiesKey = "x-properties"
+
+// loadProperties returns the properties declared by the compose files, keyed
+// by name. The declarations of the later files override these of the earlier
+// ones.
+func loadProperties(files []types.ConfigFile) (map[string]stacktypes.Property, error) {
+	properties := map[string]stacktypes.Property{}
+	for i, file := range files {
+		section, ok := file.Config[propertiesKey]
+		if !ok {
+			continue
+		}
+		declared, err := toProperties(section)
+		if err != nil {
+			return nil, locateError(err, i, file)
+		}
+		for name, property := range declared {
+			properties[name] = property
+		}
+	}
+	if len(properties) == 0 {
+		return nil, nil
+	}
+	return properties, nil
+}
+
+// withoutProperties returns copies of compose files without the declarations
+// of the properties.
+func withoutProperties(files []types.ConfigFile) []types.ConfigFile {
+	result := make([]types.ConfigFile, len(files))
+	for i, file := range files {
+		result[i] = file
+		if _, ok := file.Config[propertiesKey]; !ok {
+			continue
+		}
+		config := make(map[string]interface{}, len(file.Config))
+		for key, value := range file.Config {
+			if key != propertiesKey {
+				config[key] = value
+			}
+		}
+		result[i].Config = config
+	}
+	return result
+}
+
+func toProperties(section interface{}) (map[string]stacktypes.Property, error) {
+	dict, ok := section.(map[string]interface{})
+	if !ok {
+		return nil, withPath(errors.Errorf("%s must be a mapping", propertiesKey), propertiesKey)
+	}
+
+	properties := make(map[string]stacktypes.Property, len(dict))
+	for name, value := range dict {
+		property, err := toProperty(name, value)
+		if err != nil {
+			return nil, err
+		}
+		properties[name] = property
+	}
+	return properties, nil
+}
+
+// toProperty returns the declaration of a property. A property may be
+// declared without any field, e.g. "FOO: {}".
+func toProperty(name string, value interface{}) (stacktypes.Property, error) {
+	var property stacktypes.Property
+	if value == nil {
+		return property, nil
+	}
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return property, withPath(errors.Errorf("property %s must be a mapping", name), propertiesKey, name)
+	}
+	fieldError := func(key string, err error) error {
+		return withPath(errors.Wrapf(err, "property %s: %s", name, key), propertiesKey, name, key)
+	}
+
+	for key, value := range dict {
+		var err error
+		switch key {
+		case "description":
+			property.Description, err = toPropertyString(value)
+		case "type":
+			var t string
+			t, err = toPropertyString(value)
+			property.Type = stacktypes.PropertyType(t)
+		case "required":
+			var isBool bool
+			property.Required, isBool = value.(bool)
+			if !isBool {
+				err = errors.New("must be a boolean")
+			}
+		case "sensitive":
+			var isBool bool
+			property.Sensitive, isBool = value.(bool)
+			if !isBool {
+				err = errors.New("must be a boolean")
+			}
+		case "secret":
+			var isBool bool
+			property.Secret, isBool = value.(bool)
+			if !isBool {
+				err = errors.New("must be a boolean")
+			}
+		case "default":
+			var def string
+			def, err = toPropertyScalar(value)
+			property.Default = &def
+		case "enum":
+			items, isList := value.([]interface{})
+			if !isList {
+				err = errors.New("must be a list")
+			}
+			for _, item := range items {
+				var s string
+				if s, err = toPropertyScalar(item); err != nil {
+					break
+				}
+				property.Enum = append(property.Enum, s)
+			}
+		case "regex":
+			property.Regex, err = toPropertyString(value)
+			if err == nil {
+				_, err = regexp.Compile(property.Regex)
+			}
+		default:
+			err = errors.New("unsupported field")
+		}
+		if err != nil {
+			return property, fieldError(key, err)
+		}
+	}
+
+	if property.Secret && !property.Sensitive {
+		return property, fieldError("secret", errors.New("the property must be sensitive"))
+	}
+	if property.Type != "" && !propertyTypes[property.Type] {
+		return property, fieldError("type", errors.Errorf("unknown type %s", property.Type))
+	}
+	// the default value must be valid too.
+	if property.Default != nil {
+		if err := property.Validate(*property.Default); err != nil {
+			return property, fieldError("default", err)
+		}
+	}
+	return property, nil
+}
+
+var propertyTypes = map[stacktypes.PropertyType]bool{
+	stacktypes.PropertyTypeString:   true,
+	stacktypes.PropertyTypeInt:      true,
+	stacktypes.PropertyTypeBool:     true,
+	stacktypes.PropertyTypePort:     true,
+	stacktypes.PropertyTypeDuration: true,
+}
+
+func toPropertyString(value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.New("must be a string")
+	}
+	return s, nil
+}
+
+// toPropertyScalar returns the string form of a scalar value, as values are
+// substituted as strings.
+func toPropertyScalar(value interface{}) (string, error) {
+	switch value.(type) {
+	case string, bool, int, int64, uint64, float64:
+		return fmt.Sprint(value), nil
+	}
+	return "", errors.New("must be a scalar")
+}
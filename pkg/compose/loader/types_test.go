@@ -22,7 +22,7 @@ func TestMarshallConfig(t *testing.T) {
 	// Make sure the expected still
 	dict, err := ParseYAML([]byte("version: '3.7'\n" + expected))
 	assert.NilError(t, err)
-	_, err = Load(buildConfigDetails(dict, map[string]string{}))
+	_, err = Load(buildConfigDetails(dict, map[string]string{}), withHostPaths)
 	assert.NilError(t, err)
 }
 
@@ -38,6 +38,6 @@ func TestJSONMarshallConfig(t *testing.T) {
 
 	dict, err := ParseYAML([]byte(expected))
 	assert.NilError(t, err)
-	_, err = Load(buildConfigDetails(dict, map[string]string{}))
+	_, err = Load(buildConfigDetails(dict, map[string]string{}), withHostPaths)
 	assert.NilError(t, err)
 }
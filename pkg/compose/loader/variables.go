@@ -0,0 +1,90 @@
+package loader
+
+import (
+	"sort"
+
+	interp "github.com/docker/stacks/pkg/compose/interpolation"
+	"github.com/docker/stacks/pkg/compose/types"
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+// missingVariables returns the errors interpolating the values of a compose
+// file, if they are all about required variables missing a value. The other
+// errors, such as invalid templates, are reported as they are.
+func missingVariables(err error) ([]*interp.PathError, bool) {
+	var errs []*interp.PathError
+	switch err := err.(type) {
+	case *interp.PathError:
+		errs = []*interp.PathError{err}
+	case interp.Errors:
+		errs = err
+	default:
+		return nil, false
+	}
+	for _, err := range errs {
+		if len(err.Missing()) == 0 {
+			return nil, false
+		}
+	}
+	return errs, true
+}
+
+// MissingVariables collects the required variables missing a value, e.g.
+// "${FOO:?message}", so that they can all be reported at once. The values
+// referring to the same variable are merged into one VariableError.
+type MissingVariables struct {
+	errors []stacktypes.VariableError
+	// indexes are the indexes of the errors, by variable name.
+	indexes map[string]int
+}
+
+// Add adds the missing variables of interpolation errors, each located at
+// the location returned by locate for its error.
+func (v *MissingVariables) Add(errs []*interp.PathError, locate func(*interp.PathError) stacktypes.VariableLocation) {
+	for _, err := range errs {
+		location := locate(err)
+		for _, missing := range err.Missing() {
+			if i, ok := v.indexes[missing.Name]; ok {
+				v.errors[i].Locations = append(v.errors[i].Locations, location)
+				continue
+			}
+			if v.indexes == nil {
+				v.indexes = map[string]int{}
+			}
+			v.indexes[missing.Name] = len(v.errors)
+			v.errors = append(v.errors, stacktypes.VariableError{
+				Variable:  missing.Name,
+				Message:   missing.String(),
+				Locations: []stacktypes.VariableLocation{location},
+			})
+		}
+	}
+}
+
+// Errors returns the collected variables sorted by name.
+func (v *MissingVariables) Errors() []stacktypes.VariableError {
+	errs := make([]stacktypes.VariableError, len(v.errors))
+	copy(errs, v.errors)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Variable < errs[j].Variable })
+	return errs
+}
+
+// addFile adds the missing variables of a compose file, located in the file.
+func (v *MissingVariables) addFile(errs []*interp.PathError, index int, file types.ConfigFile) {
+	v.Add(errs, func(err *interp.PathError) stacktypes.VariableLocation {
+		location := stacktypes.VariableLocation{FileIndex: index, File: file.Filename, Path: err.Path()}
+		if located, ok := locateError(err, index, file).(*Error); ok {
+			location.Line, location.Column = located.Line, located.Column
+		}
+		return location
+	})
+}
+
+// err returns the collected variables as a VariablesError sorted by name, or
+// nil if there are none.
+func (v *MissingVariables) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &stacktypes.VariablesError{Variables: v.Errors()}
+}
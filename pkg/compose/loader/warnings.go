@@ -0,0 +1,73 @@
+package loader
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/types"
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+// serviceFields are the indexes of the fields of ServiceConfig, keyed by the
+// name of their property in the compose files.
+var serviceFields = fieldsByProperty(reflect.TypeOf(types.ServiceConfig{}))
+
+func fieldsByProperty(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// Warnings returns the warnings about the unsupported and deprecated
+// properties set on services, which are ignored. They are sorted by service,
+// then by property.
+func Warnings(services []types.ServiceConfig) []stacktypes.Warning {
+	sorted := make([]types.ServiceConfig, len(services))
+	copy(sorted, services)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	deprecated := make([]string, 0, len(types.DeprecatedProperties))
+	for property := range types.DeprecatedProperties {
+		deprecated = append(deprecated, property)
+	}
+	sort.Strings(deprecated)
+
+	var warnings []stacktypes.Warning
+	for _, service := range sorted {
+		value := reflect.ValueOf(service)
+		for _, property := range types.UnsupportedProperties {
+			if isSet(value, property) {
+				warnings = append(warnings, stacktypes.Warning{
+					Property: "services." + service.Name + "." + property,
+					Service:  service.Name,
+					Message:  fmt.Sprintf("%s is not supported, and is ignored", property),
+					Severity: stacktypes.WarningSeverityWarning,
+				})
+			}
+		}
+		for _, property := range deprecated {
+			if isSet(value, property) {
+				warnings = append(warnings, stacktypes.Warning{
+					Property: "services." + service.Name + "." + property,
+					Service:  service.Name,
+					Message:  fmt.Sprintf("%s is deprecated, and is ignored. %s", property, types.DeprecatedProperties[property]),
+					Severity: stacktypes.WarningSeverityInfo,
+				})
+			}
+		}
+	}
+	return warnings
+}
+
+// isSet returns whether a property of a service has a non-zero value.
+func isSet(service reflect.Value, property string) bool {
+	index, ok := serviceFields[property]
+	return ok && !service.Field(index).IsZero()
+}
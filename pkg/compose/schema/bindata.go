@@ -190,6 +190,51 @@ func _escFSMustString(useLocal bool, name string) string {
 
 var _escData = map[string]*_escFile{
 
+	"/data/compose_spec.json": {
+		local:   "data/compose_spec.json",
+		size:    19425,
+		modtime: 1518458244,
+		compressed: `
+H4sIAAAAAAAC/+wcy47jNvLuryCYOW3s6TkEC2Rue9zT7nkbHoGWyjbTFMmQlKedgf99QethPUiRstVu
+J+kBBt0tFVms94Nl/1gghD/pdA85wV8R3hsjvz49/aYFX5VPPwu1e8oU2ZrVl1+eymc/4eUCIUwzuyQV
+uRQaEi0h/WwXli/NUYJ9LTa/QWrwcmEfSiUkKENB46/I4kYIH0BpKnjzoLVWG0X5Di8QQuh03gEhzEkO
+I8DL+rkkxoCy++Jvz2T1x5fVr+vqZ7Ja/+NTb1sN6kDT1sEaAn96uhz7qQFb9vE3hHbx/3dIMkKoOtO/
+Vv/7svr1c7Ja//yp89pKRcG2RJ/BlnJqqOANftxAnqrfTg1ikmVnYMI6uLeEaeixEsx3oV5CNDdg70Rz
+hd9Bc5ecg2BFHpRgDfVOxJTo55GfhlSBCatsCfVuGmvRz0NwKviW7kIE11DvRHCJ/jaCFzXR7jPib68r
++/N03nN0v3KX1vnORHR8noudLp/j52fDUA8nM5BMHM8nd/OsBMiBG9ywCSG8KSjL+lwXHP5jt3huPUTo
+Rz8QnJbd952//EqB0Dgt9T+riwZezZmocdQlC0T6AmpLGcSuIGqnR1jGqDaJUElGU+Ncz8gG2E07pCTd
+Q7JVIg/usk1KSrRzo9qDR1JuiNpBNGf1Pk80/aPD12dMuYEdKLxs1q5PvbWnoczDxtYVUciQ+z4AIYTW
+C8cBcEpkQrKsQzRRihwtBdRArt38QLjg9PcC/l2BGFVAf99MCTn/xjslCplIoqzVjsvKJok54XOZ8hQ6
+Ijg/CCod/1DhaL9qsLUeeqkJ04Nc7iXgnsIOCiGsRaHSWH8z1e4QwgXN4oF3U4BzkXXPzYt8AwqfBsCn
+xdjf64XrTU/6hlAOKqkLi1E9VpABN5Swc73j0xmH0MbEValgBHtwZADBCnZUG3V0wi48PnCK/4vzfW3e
+ZSCBZzoR/LpogjNoKrRZPVnGx6JkuY2Nk/ZsuLcw0UBUur9yvcgJ5TF6B9yooxS09LQP50KBH5JGMyez
+AfiBKsHzOo7EZSut9a+2AXG7/25yh7qV0Liddd8KhcqJPWyN22tRQ81rM7BNgzU8whJG+cv8Kg6vRpFk
+L7S5JiHEeyDM7NM9pC8jy9tQndVCmxglpznZhYE47UaojRAMCO8CyTS4jxaMmKrxNAZ4dRqNZxVla1ux
+21lQn/4OyrLIgiZT9AAqNusW8lJNulKJUPoSLL/b//C3z2X1PWKj598YG6b5riyh/6QfPicVAtGhcHHJ
+b0hqs30FWof0r6qdkkFKdIEdAOvYKHFVSTe9lI4SdLDfEqDGd7wpOhljKBexM0o06Ntq45bPOvwSqROu
+tf8cXetZevLzYIoBXFMRB47QzvwZcxKwDtcCb1mwS5r5PdLZD7UNUwpl7lJiXrzhJUkpkZ+W3kWXo4cX
+vU2pOuLd4grVut/jXiCLDaN6D9mUNUoYkQoWZ1Cn5eJ2I5piPiOF7lUZqFT0QBnsIAumV1IJm+1f6fmw
+ApIlgrNjEJECbYgKdpc0pIWi5pgIaWZPnd3txYuZNd3F7oF6FzPoo6X0t2kp6aNOzXUlgzYZ5YmQwIO2
+oY2QyU6RFBIJigonKzoePStUWfEMttF0xwkLmZnJ5fbKTokxYWMvGM2p32icPbVgYlkmle5c0mtdKC5G
+jBQ+43VPRMGzJ2pCrDob5tYTEBeRSRdW8HtB1dn/P5f7LauDrOPrt/EccVp+2D/62puiuQ2x0MF69gzD
+dRKRfzjmC/4cXr0j1zP4+irfX2GK9LdvHSmis4hun11TbYCnx3hEGzq4uJrC/liTr6DIzt+Vcq6Lt+9S
+f+9DChepkB7R3EhGE4benoo67/O8R2jgbUeK9Jxymhc5/oq+eIAmcObh6o/uZmNVh89b28aVzR8yqsa0
+/7RcTCNvwnxRr889NhTTBr1u0GjiSZfBIRuqyYaB2+Y6HXQD6kDYdfmiAqMoaGcm3QIzoB/zqsrQHERh
+rk2WiTLT0+3+uCK6zETVl15jqtaC7Gvac6NqddcpqCYxmQ7w7HzZGJUWKZCMpkSH0tUbblKUYGxD0pfk
+clE+x7W7JIowBozqPHT4SmSMHK/SHIQQwltCWaEgIWnEvVMlK06NUNejzMlrUqM9gwTsFiGEsFAZ+HAC
+L/KBCdeWsdpSpU3ZFBGy+qsbJt5p9qCQGTHwoT4f6nOV+igoqxk9l+o4Wx1olrFUWcReH+EcchEeEUJ3
+HpUcTDLZ+WPiu21+FIY5oHfAQdE06WiPJ/QNYR9kWvW+VlbmV4LRskCfabauPEeMx7zRRVt/aTmUS6Oj
+QsJ3yjPxfXoqeWfJSEZS6KWqtwpFG0UoN5MHZAajGgq2oICnMOoeht055O/QodmuS6RtU/0pbhBdulyn
+9rbkSXi/FnB1mN9aKe/QBHC67bH6bLhguRjVPofW+bXNr2W24rf3jNBgdg0lhzR5XIvxS9X9DAY8fCCs
+iLhhu8Oola/DFI0qBs3J+VnKkK7UYDMU8jEjlVEzfRWUvT2f/fYtPLe3Dt/jUEnyuSJPFEdQdyj/wWJK
+seGei5KHiynvk67Uw9QenXlu2qLLRhLraAXymt370Er5hdbRti8xhqT7qA7xxEbdHWLy4OrK6VwrqA/f
+OsG3/p1s5fH0uvpwfPAD2Geo8DXTDdoc8cGyB9CVOcT6lzNh229gtr09Qs4ddHmQLzl1uYL60OW5dflB
+tKA3BNjShuH175iAogeLFwih5ra3OUYfzPGFQb6a3Hso31BDD2klm3HKZ3Qin38eqVHGPigVmapPDbMz
+jF+7ZdprGtbcHX6Lid/11OsH32mCECb82JMSQj+6w3Pl95GsT0s/SPkhxpbXXke1clzfdNIf3au/ccQz
+gdztSyzs/9Pi/wMAwmAK5eFLAAA=
+`,
+	},
+
 	"/data/config_schema_v1.json": {
 		local:   "data/config_schema_v1.json",
 		size:    5841,
@@ -735,6 +780,50 @@ nEbFye/DBpf6q/LNQD4WSf11TQ8obKICc9f36nZ7TfvduKfjbxi9rsq/59X/AwAA//+9o87pcUUAAA==
 `,
 	},
 
+	"/data/config_schema_v3.8.json": {
+		local:   "data/config_schema_v3.8.json",
+		size:    18246,
+		modtime: 1518458244,
+		compressed: `
+H4sIAAAAAAAC/+xcS3PjuBG+61egsHtb2Z6qbKWSueWYU3KOS8OCyBaFNQhgAVBj7ZT+e4riQ3zgRYmy
+vYldNTW22Hj060N3o6kfK4TwzzrdQ0HwV4T3xsivT0+/acEf6k8fhcqfMkV25uHLr0/1Zz/h9QohTLNq
+SCr4juZJ/SQ5/OXxb4/V8JrEHCVURGL7G6Sm/kzB7yVVUA1+xgdQmgqON+tV9UwqIUEZChp/RT9WCCHU
+kbQf9KbVRlGe4xVCCJ3OMyCENagDTXszdFv96eky/1NHth7P2tssQghhSYwBxf893RtCCOFvz+Thj388
+/OfLw98fk4fNLz8PHlfyVbCrl89gRzk1VPBufdxRnprfTt3CJMvOxIQN1t4RpmHIMwfzXaiXEM8d2Tvx
+3Kxv4XnIzkGwsghqsKV6J2bq5ZfRn4ZUgQmbbE31bhZbLb8MwzVqhBhuqd6J4Xr52xhetUzb94i/vT5U
+/5/Oc3rnq2fp7e/MxADzbOK0YY5bnp1AHZLMQDJxPO/cLrOaoABucCcmhPC2pCwbS11w+Fc1xXPvQ4R+
+jOH9tB4+H/zlNgqE/Ly0P5UtGng1Z6b8S9ciEOkLqB1lEDuCqFx7RMaoNolQSUZTYx3PyBbYTTOkJN1D
+slOiCM6yS2pOtHWiFsEjOTdE5RAtWb0vEk3/GMj1GVNuIAeF193YzWk0djJZ2DHHPo0QQpuVZUKcEpmQ
+LBswQZQix2pH1ECh7fwhXHL6ewn/bEiMKmE8b6aEXH7iXIlSJpKoygv9ssepKArCl3LNOXxESH5ySAz8
+vVmj/6hbrfehk5swP8gGFwG4CQMOQliLUqWx+DHXjxDCJc3iifM5xIXIhvvmZbEFhU8T4tPK9/dmZXsy
+0r4hlINKOCkgaMcKMuCGEpZoCanLZixK86mrMcEI8eDIAwEryKk26milXTkwLQ7P+vLIQALPdCL4dYiP
+M+iyqEXRKeO+k6yepjrLqr3h0cBEA1Hp/srxoiCUx9gScKOOUtAaPT8cLAI/JJ21zRYD8ANVghft2RAX
+UfTGv0qh4XZM7s73hvF1ByWbsWcJVZBqs+3aTi+ZWl5fgH0eqkicsIRR/rK8icOrUSTZC22uCdrwHggz
++3QP6YtneJ9qMFpoE2PktCB5mIjT4amzFYIB4UMimQbn0YIR01RxfIRXh7p4UVX2phV5XpG67HeSOkUm
+HZmiB1CxkbGQl4zPFh6EQpJgitz/wd8e6wzZ46Pn3xibhuK2k3/8yfhIjD3cVpcohKRVTK5A65BFNRlL
+MglcLrQTYh2L+1clUvMT2CjVBascAW5c25tjZTGmf1E7o0SDvi0j7aHQ4ddIm7CN/at3rGOoc874/DMw
+VT/OZsy6kU048r5neixp5saKM0L0HUwKZd4kobvg1CV8qBc/rZ2DLlsPD7pPYuhBqbi0sK2W2AfIcsuo
+3kM2Z4wSRqSCxTmGtf4V7wyeJPGqSE8qeqAMcsiCYYwCkiWCs2MEpTZEBUsrGtJSUXNMhDSLx5j2WtnF
+6rtS2XBDo1sG9FlP+b+pp+ijTs11sbU2GeWJkMCDvqGNkEmuSAqJBEWFVRQDgM1KVacGk2k0zTlhITcz
+hdxdWVIwJuzsJaMFdTuNtaAUjNfqWM0eojm9C8VBtidD8CcIEZnBnqgZR8fZMXeO82kVGQMN+wXO862b
+jWys9LNCr/E2Ns7ox+5UpQ4mcWcarpOIo91y8f3nQOiBjs7km6twvFkpEjvvjfrREcGwYKypNsDTY/xC
+Wzq5gZkj/lj3bahI7i7FWMfF+2ptv2/DChepkA7V3MhGd6Tcn4s2hnM8R2iCnJ48tqCcFmWBv6IvDqIZ
+krlzaD+czBfQu7C3qtRUJ3tGlc+WT/4ukWEHBprXxjIq1fp6L/qkwX4Wfx+I38BwRjXZMrB7xqC4a0Ad
+CLsuQlNgFAVtjV17ZAb0x7xFMbQAUZprw1OizPwAd9zthi4tNe19jM+EepRjC3ruTKgtuwTNJCYeAZ6d
+78GighcFktGU6FCAeEORXwnGtiR9SS73skvc8kqiCGPAqC5Cm29UxsjxKstBCCG8I5SVChKSRlyJNLri
+1Ah1/ZIFeU3aZc8kAb9FCCEsVAauNYGXxcSFW8942FGlTV2GELL5awj/C151lzIjBj5N4tMkelBU5wZ6
+KXOwFgHQIt2Hsoy9r8AFFCLcOYJuLPlPGlaqtlHiuoD8KAKwUOfAQdE0GViD48iZ0t7pFuV2y65jD8Fo
+nWIu1OZU7yMGeW6Eugp3qkC8kEZHQet3yjPxfX6YtYC0JSMpjEKzWwWtjSKUm9m9CmOxSAU7UMBT8Lrl
+tGaE3HUjtFhBXlbFk3e4MrJZWxuYVgF7wseRrK0ieY3Z3PA2hBWofJnAdMB65dW7Rd9uPbv1W+WW1R0S
+dCvbui1DNuS3H/zSVMOCEI8PhJURtydX9Zu4qg4Rg0/Wl7NCOm3JFkjtYvq/ohqQGqrqBnPxG5Bwk9Em
+XH+nkhRLYXOURNCwK/iDoW655Y4C951Rd7kjt+3NdGj1uStlrTtZbaJV7HSM5fZP+WX/3vIbMYak+6hK
+3cyCyRsUPieFfiukNVSfiDYD0f7s9v/xbLV5bzX4buSZKvyq6Q0WGvGOyAfQ/xJq/Z9zyypfZVWZ0cPO
+G9jyJPKw2nJD9WnLS9vyB7GCUUtTzxqmV2s+BUX3Xa8QQt1NWreNMZnlGzpcWahzU66L4NGijW78nC8I
+Io+/eKJ93/sRdwqTF2gmtet0VKBqpTv9ggE39LTjJ183gBAm/DjSEkI/hu1D9VcFbE5rN0n97lIPtTdR
+xQvblxCMm5faLwNw9FMOM/xV9e+0+u8AFd/bF0ZHAAA=
+`,
+	},
+
 	"/": {
 		isDir: true,
 		local: "",
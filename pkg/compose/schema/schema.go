@@ -7,13 +7,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/docker/docker/api/types/versions"
 	"github.com/pkg/errors"
 	"github.com/xeipuuv/gojsonschema"
 )
 
 const (
-	defaultVersion = "1.0"
-	versionField   = "version"
+	// SpecVersion is the version of the compose files which follow the
+	// Compose Specification, i.e. which don't declare a version. The
+	// Compose Specification is a superset of the version 3 file formats,
+	// with a top-level name, x- extensions on every mapping, and profiles.
+	SpecVersion  = "spec"
+	versionField = "version"
 )
 
 type portsFormatChecker struct{}
@@ -36,17 +41,20 @@ func init() {
 	gojsonschema.FormatCheckers.Add("duration", durationFormatChecker{})
 }
 
-// Version returns the version of the config, defaulting to version 1.0
+// Version returns the version of the config, defaulting to SpecVersion for
+// the configs which don't declare one.
 func Version(config map[string]interface{}) string {
 	version, ok := config[versionField]
-	if !ok {
-		return defaultVersion
+	if !ok || version == nil {
+		return SpecVersion
 	}
 	return normalizeVersion(fmt.Sprintf("%v", version))
 }
 
 func normalizeVersion(version string) string {
 	switch version {
+	case "":
+		return SpecVersion
 	case "3":
 		return "3.0"
 	default:
@@ -54,9 +62,35 @@ func normalizeVersion(version string) string {
 	}
 }
 
+// VersionAtLeast returns true if version is the same as, or newer than, the
+// other version. SpecVersion is newer than any other version.
+func VersionAtLeast(version, other string) bool {
+	switch {
+	case version == SpecVersion:
+		return true
+	case other == SpecVersion:
+		return false
+	default:
+		return versions.GreaterThanOrEqualTo(version, other)
+	}
+}
+
+func schemaFile(version string) string {
+	if version == SpecVersion {
+		return "/data/compose_spec.json"
+	}
+	return fmt.Sprintf("/data/config_schema_v%s.json", version)
+}
+
+// Supported returns true if there is a schema for the provided version.
+func Supported(version string) bool {
+	_, err := _escFSByte(false, schemaFile(version))
+	return err == nil
+}
+
 // Validate uses the jsonschema to validate the configuration
 func Validate(config map[string]interface{}, version string) error {
-	schemaData, err := _escFSByte(false, fmt.Sprintf("/data/config_schema_v%s.json", version))
+	schemaData, err := _escFSByte(false, schemaFile(version))
 	if err != nil {
 		return errors.Errorf("unsupported Compose file version: %s: %s", version, err)
 	}
@@ -84,6 +118,7 @@ func toError(result *gojsonschema.Result) error {
 const (
 	jsonschemaOneOf = "number_one_of"
 	jsonschemaAnyOf = "number_any_of"
+	rootContext     = "(root)"
 )
 
 func getDescription(err validationError) string {
@@ -132,6 +167,27 @@ func (err validationError) Error() string {
 	return fmt.Sprintf("%s %s", err.parent.Field(), description)
 }
 
+// Path returns the dotted path of the value of the config the error is about.
+// The errors about an additional property are about the property, the errors
+// about a missing property about the mapping missing it.
+func (err validationError) Path() string {
+	specific := err.parent
+	if err.child != nil {
+		specific = err.child
+	}
+	path := strings.TrimPrefix(specific.Context().String(), rootContext)
+	path = strings.TrimPrefix(path, ".")
+	if specific.Type() == "additional_property_not_allowed" {
+		if property, ok := specific.Details()["property"].(string); ok {
+			if path == "" {
+				return property
+			}
+			return path + "." + property
+		}
+	}
+	return path
+}
+
 func getMostSpecificError(errors []gojsonschema.ResultError) validationError {
 	mostSpecificError := 0
 	for i, err := range errors {
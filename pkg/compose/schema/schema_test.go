@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
 )
 
 type dict map[string]interface{}
@@ -111,6 +112,12 @@ func TestValidateInvalidVersion(t *testing.T) {
 	assert.ErrorContains(t, err, "unsupported Compose file version: 1.99")
 }
 
+func TestSupported(t *testing.T) {
+	assert.Check(t, Supported("3.7"))
+	assert.Check(t, Supported(SpecVersion))
+	assert.Check(t, !Supported("2"))
+}
+
 type array []interface{}
 
 func TestValidatePlacement(t *testing.T) {
@@ -236,3 +243,114 @@ func TestValidateRollbackConfigWithUpdateConfigFull(t *testing.T) {
 
 	assert.NilError(t, Validate(config, "3.7"))
 }
+
+func TestVersion(t *testing.T) {
+	assert.Check(t, is.Equal(SpecVersion, Version(dict{})))
+	assert.Check(t, is.Equal(SpecVersion, Version(dict{"version": ""})))
+	assert.Check(t, is.Equal("3.0", Version(dict{"version": "3"})))
+	assert.Check(t, is.Equal("3.8", Version(dict{"version": "3.8"})))
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	assert.Check(t, VersionAtLeast("3.8", "3.5"))
+	assert.Check(t, !VersionAtLeast("3.4", "3.5"))
+	assert.Check(t, VersionAtLeast(SpecVersion, "3.8"))
+	assert.Check(t, !VersionAtLeast("3.8", SpecVersion))
+}
+
+func TestValidateV38(t *testing.T) {
+	config := dict{
+		"version": "3.8",
+		"services": dict{
+			"foo": dict{
+				"image": "busybox",
+				"credential_spec": dict{
+					"config": "credspec",
+				},
+				"deploy": dict{
+					"placement": dict{
+						"max_replicas_per_node": 2,
+					},
+				},
+			},
+		},
+		"secrets": dict{
+			"foo": dict{
+				"driver":          "vault",
+				"driver_opts":     dict{"path": "foo"},
+				"template_driver": "golang",
+			},
+		},
+		"configs": dict{
+			"foo": dict{
+				"file":            "./foo.conf",
+				"template_driver": "golang",
+			},
+		},
+	}
+
+	assert.NilError(t, Validate(config, "3.8"))
+	assert.ErrorContains(t, Validate(config, "3.7"), "Additional property")
+}
+
+func TestValidateComposeSpec(t *testing.T) {
+	config := dict{
+		"name": "myproject",
+		"services": dict{
+			"foo": dict{
+				"image":         "busybox",
+				"profiles":      array{"debug"},
+				"x-extra-stuff": dict{},
+				"deploy": dict{
+					"x-extra-stuff": dict{},
+					"resources": dict{
+						"limits": dict{
+							"x-extra-stuff": dict{},
+						},
+					},
+				},
+				"logging": dict{
+					"x-extra-stuff": dict{},
+				},
+			},
+		},
+	}
+
+	assert.NilError(t, Validate(config, SpecVersion))
+
+	config["name"] = "My Project"
+	assert.ErrorContains(t, Validate(config, SpecVersion), "name Does not match pattern")
+}
+
+func TestValidateErrorPath(t *testing.T) {
+	config := dict{
+		"version": "3.7",
+		"services": dict{
+			"foo": dict{
+				"image": "busybox",
+				"ports": array{true},
+			},
+		},
+	}
+	err := Validate(config, "3.7")
+	assert.Check(t, is.Equal("services.foo.ports.0", err.(validationError).Path()))
+
+	config = dict{
+		"version": "3.7",
+		"services": dict{
+			"foo": dict{
+				"image":       "busybox",
+				"helicopters": true,
+			},
+		},
+	}
+	err = Validate(config, "3.7")
+	assert.Check(t, is.Equal("services.foo.helicopters", err.(validationError).Path()))
+
+	config = dict{
+		"version":     "3.7",
+		"helicopters": true,
+	}
+	err = Validate(config, "3.7")
+	assert.Check(t, is.Equal("helicopters", err.(validationError).Path()))
+}
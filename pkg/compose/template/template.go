@@ -29,6 +29,24 @@ var DefaultSubstituteFuncs = []SubstituteFunc{
 // format
 type InvalidTemplateError struct {
 	Template string
+	// Missing are the required variables missing a value, if the template
+	// is invalid because of them.
+	Missing []MissingVariable
+}
+
+// MissingVariable is a required variable missing a value.
+type MissingVariable struct {
+	Name string
+	// Message is the error message of the variable, i.e. the message of
+	// "${FOO:?message}", if any.
+	Message string
+}
+
+func (v MissingVariable) String() string {
+	if v.Message == "" {
+		return fmt.Sprintf("required variable %s is missing a value", v.Name)
+	}
+	return fmt.Sprintf("required variable %s is missing a value: %s", v.Name, v.Message)
 }
 
 func (e InvalidTemplateError) Error() string {
@@ -47,10 +65,15 @@ type Mapping func(string) (string, bool)
 type SubstituteFunc func(string, Mapping) (string, bool, error)
 
 // SubstituteWith subsitute variables in the string with their values.
-// It accepts additional substitute function.
+// It accepts additional substitute function. The substitution goes on past the
+// required variables missing a value, so that they are all reported at once.
 func SubstituteWith(template string, mapping Mapping, pattern *regexp.Regexp, subsFuncs ...SubstituteFunc) (string, error) {
 	var err error
+	var missing []MissingVariable
 	result := pattern.ReplaceAllStringFunc(template, func(substring string) string {
+		if err != nil {
+			return substring
+		}
 		matches := pattern.FindStringSubmatch(substring)
 		groups := matchGroups(matches, pattern)
 		if escaped := groups["escaped"]; escaped != "" {
@@ -73,6 +96,11 @@ func SubstituteWith(template string, mapping Mapping, pattern *regexp.Regexp, su
 				applied bool
 			)
 			value, applied, err = f(substitution, mapping)
+			if terr, ok := err.(*InvalidTemplateError); ok && len(terr.Missing) > 0 {
+				missing = append(missing, terr.Missing...)
+				err = nil
+				return ""
+			}
 			if err != nil {
 				return ""
 			}
@@ -86,6 +114,13 @@ func SubstituteWith(template string, mapping Mapping, pattern *regexp.Regexp, su
 		return value
 	})
 
+	if err == nil && len(missing) > 0 {
+		messages := make([]string, 0, len(missing))
+		for _, variable := range missing {
+			messages = append(messages, variable.String())
+		}
+		err = &InvalidTemplateError{Template: strings.Join(messages, "; "), Missing: missing}
+	}
 	return result, err
 }
 
@@ -218,8 +253,10 @@ func withRequired(substitution string, mapping Mapping, sep string, valid func(s
 	name, errorMessage := partition(substitution, sep)
 	value, ok := mapping(name)
 	if !ok || !valid(value) {
+		variable := MissingVariable{Name: name, Message: errorMessage}
 		return "", true, &InvalidTemplateError{
-			Template: fmt.Sprintf("required variable %s is missing a value: %s", name, errorMessage),
+			Template: variable.String(),
+			Missing:  []MissingVariable{variable},
 		}
 	}
 	return value, true, nil
@@ -130,6 +130,15 @@ func TestMandatoryVariableErrors(t *testing.T) {
 	}
 }
 
+func TestMandatoryVariableErrorsListAllVariables(t *testing.T) {
+	_, err := Substitute("${UNSET_VAR:?first} ${FOO:?} ${OTHER_VAR?}", defaultMapping)
+	assert.Assert(t, is.ErrorType(err, &InvalidTemplateError{}))
+	assert.Check(t, is.DeepEqual([]MissingVariable{
+		{Name: "UNSET_VAR", Message: "first"},
+		{Name: "OTHER_VAR"},
+	}, err.(*InvalidTemplateError).Missing))
+}
+
 func TestDefaultsForMandatoryVariables(t *testing.T) {
 	testCases := []struct {
 		template string
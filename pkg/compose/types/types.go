@@ -40,7 +40,6 @@ var DeprecatedProperties = map[string]string{
 // ForbiddenProperties that are not supported in this implementation of the
 // compose file.
 var ForbiddenProperties = map[string]string{
-	"extends":       "Support for `extends` is not implemented yet.",
 	"volume_driver": "Instead of setting the volume driver on the service, define a volume using the top-level `volumes` option and specify the driver there.",
 	"volumes_from":  "To share a volume between services, define it using the top-level `volumes` option and reference it from each service that shares it using the service-level `volumes` option.",
 	"cpu_quota":     "Set resource limits using deploy.resources",
@@ -54,6 +53,9 @@ var ForbiddenProperties = map[string]string{
 type ConfigFile struct {
 	Filename string
 	Config   map[string]interface{}
+	// Content is the YAML source of Config, if any, used to locate the
+	// errors of the file.
+	Content []byte
 }
 
 // ConfigDetails are the details about a group of ConfigFiles
@@ -62,6 +64,11 @@ type ConfigDetails struct {
 	WorkingDir  string
 	ConfigFiles []ConfigFile
 	Environment map[string]string
+	// Files contains the files referenced by the ConfigFiles, keyed by their
+	// slash separated path relative to the project directory. Unless the
+	// loader resolves host paths, the referenced files are only looked up in
+	// Files.
+	Files map[string][]byte
 }
 
 // Duration is a thin wrapper around time.Duration with improved JSON marshalling
@@ -99,7 +106,8 @@ func (cd ConfigDetails) LookupEnv(key string) (string, bool) {
 // Config is a full compose file configuration
 type Config struct {
 	Filename string                     `yaml:"-" json:"-"`
-	Version  string                     `json:"version"`
+	Name     string                     `yaml:",omitempty" json:"name,omitempty"`
+	Version  string                     `yaml:",omitempty" json:"version,omitempty"`
 	Services Services                   `json:"services"`
 	Networks map[string]NetworkConfig   `yaml:",omitempty" json:"networks,omitempty"`
 	Volumes  map[string]VolumeConfig    `yaml:",omitempty" json:"volumes,omitempty"`
@@ -111,10 +119,16 @@ type Config struct {
 // MarshalJSON makes Config implement json.Marshaler
 func (c Config) MarshalJSON() ([]byte, error) {
 	m := map[string]interface{}{
-		"version":  c.Version,
 		"services": c.Services,
 	}
 
+	if c.Version != "" {
+		m["version"] = c.Version
+	}
+	if c.Name != "" {
+		m["name"] = c.Name
+	}
+
 	if len(c.Networks) > 0 {
 		m["networks"] = c.Networks
 	}
@@ -208,6 +222,7 @@ type ServiceConfig struct {
 	Pid             string                           `yaml:",omitempty" json:"pid,omitempty"`
 	Ports           []ServicePortConfig              `yaml:",omitempty" json:"ports,omitempty"`
 	Privileged      bool                             `yaml:",omitempty" json:"privileged,omitempty"`
+	Profiles        []string                         `yaml:",omitempty" json:"profiles,omitempty"`
 	ReadOnly        bool                             `mapstructure:"read_only" yaml:"read_only,omitempty" json:"read_only,omitempty"`
 	Restart         string                           `yaml:",omitempty" json:"restart,omitempty"`
 	Secrets         []ServiceSecretConfig            `yaml:",omitempty" json:"secrets,omitempty"`
@@ -225,6 +240,12 @@ type ServiceConfig struct {
 	Volumes         []ServiceVolumeConfig            `yaml:",omitempty" json:"volumes,omitempty"`
 	WorkingDir      string                           `mapstructure:"working_dir" yaml:"working_dir,omitempty" json:"working_dir,omitempty"`
 
+	// Deferred holds the values of the numeric, boolean and duration
+	// properties which hold variables, keyed by the dotted path of the
+	// property in the service, e.g. "deploy.replicas". The properties are
+	// set when the variables are substituted.
+	Deferred map[string]string `mapstructure:"-" yaml:"-" json:"deferred,omitempty"`
+
 	Extras map[string]interface{} `yaml:",inline" json:"-"`
 }
 
@@ -362,6 +383,7 @@ type RestartPolicy struct {
 type Placement struct {
 	Constraints []string               `yaml:",omitempty" json:"constraints,omitempty"`
 	Preferences []PlacementPreferences `yaml:",omitempty" json:"preferences,omitempty"`
+	MaxReplicas uint64                 `mapstructure:"max_replicas_per_node" yaml:"max_replicas_per_node,omitempty" json:"max_replicas_per_node,omitempty"`
 }
 
 // PlacementPreferences is the preferences for a service placement
@@ -549,6 +571,14 @@ type FileObjectConfig struct {
 	External External               `yaml:",omitempty" json:"external,omitempty"`
 	Labels   Labels                 `yaml:",omitempty" json:"labels,omitempty"`
 	Extras   map[string]interface{} `yaml:",inline" json:"-"`
+	// Driver is the driver of a secret stored in an external secret store,
+	// which has no file.
+	Driver         string            `yaml:",omitempty" json:"driver,omitempty"`
+	DriverOpts     map[string]string `mapstructure:"driver_opts" yaml:"driver_opts,omitempty" json:"driver_opts,omitempty"`
+	TemplateDriver string            `mapstructure:"template_driver" yaml:"template_driver,omitempty" json:"template_driver,omitempty"`
+	// Data is the content of File, when it was read from the files of a
+	// compose input rather than from the filesystem.
+	Data []byte `mapstructure:"-" yaml:"-" json:"data,omitempty"`
 }
 
 // SecretConfig for a secret
@@ -1,8 +1,15 @@
 package backend
 
 import (
+	"bytes"
 	"fmt"
 
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stringid"
+
 	"github.com/docker/stacks/pkg/compose/convert"
 	"github.com/docker/stacks/pkg/compose/loader"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
@@ -49,9 +56,9 @@ func (b *DefaultStacksBackend) CreateStack(create types.StackCreate) (types.Stac
 	}
 
 	// Convert to the Stack to a SwarmStack
-	swarmSpec, err := b.convertToSwarmStackSpec(create.Metadata.Name, create.Spec)
+	swarmSpec, err := b.convertToSwarmStackSpec(create.Metadata.Name, create.Spec, interfaces.SwarmStackSpec{})
 	if err != nil {
-		return types.StackCreateResponse{}, fmt.Errorf("unable to translate swarm spec: %s", err)
+		return types.StackCreateResponse{}, translationError(err)
 	}
 
 	swarmStack := interfaces.SwarmStack{
@@ -75,6 +82,13 @@ func (b *DefaultStacksBackend) GetStack(id string) (types.Stack, error) {
 		return types.Stack{}, fmt.Errorf("unable to retrieve stack %s: %s", id, err)
 	}
 
+	// Whether the stack is paused is only kept in the swarm stack.
+	swarmStack, err := b.stackStore.GetSwarmStack(id)
+	if err != nil {
+		return types.Stack{}, fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+	}
+	stack.Paused = swarmStack.Spec.Paused
+
 	return stack, err
 }
 
@@ -91,7 +105,25 @@ func (b *DefaultStacksBackend) GetSwarmStack(id string) (interfaces.SwarmStack,
 
 // ListStacks lists all stacks.
 func (b *DefaultStacksBackend) ListStacks() ([]types.Stack, error) {
-	return b.stackStore.ListStacks()
+	stacks, err := b.stackStore.ListStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	// Whether the stacks are paused is only kept in the swarm stacks.
+	swarmStacks, err := b.stackStore.ListSwarmStacks()
+	if err != nil {
+		return nil, err
+	}
+	paused := make(map[string]bool, len(swarmStacks))
+	for _, swarmStack := range swarmStacks {
+		paused[swarmStack.ID] = swarmStack.Spec.Paused
+	}
+	for i := range stacks {
+		stacks[i].Paused = paused[stacks[i].ID]
+	}
+
+	return stacks, nil
 }
 
 // ListSwarmStacks lists all swarm stacks.
@@ -102,11 +134,6 @@ func (b *DefaultStacksBackend) ListSwarmStacks() ([]interfaces.SwarmStack, error
 
 // UpdateStack updates a stack.
 func (b *DefaultStacksBackend) UpdateStack(id string, spec types.StackSpec, version uint64) error {
-	err := validateSpec(spec)
-	if err != nil {
-		return fmt.Errorf("invalid stack spec: %s", err)
-	}
-
 	// Inspect the existing stack of the same ID so we can retain the name of
 	// the stack in its labels. If the stack has changed since the user's
 	// request, the underlying StackStore should return an "update out of
@@ -116,13 +143,36 @@ func (b *DefaultStacksBackend) UpdateStack(id string, spec types.StackSpec, vers
 		return fmt.Errorf("unable to retrieve existing stack: %s", err)
 	}
 
+	// The existing swarm stack is retrieved after the stack, so that it is
+	// at least as recent as the version the update is checked against.
+	swarmStack, err := b.stackStore.GetSwarmStack(id)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve existing swarm stack: %s", err)
+	}
+
+	// The sensitive property values and secret data redacted from the API
+	// responses are kept as they are, and the secrets and configs of stacks
+	// created before their content was carried by the spec are given the
+	// content they were created with.
+	spec = spec.WithSensitiveValues(stack.Spec)
+	spec = withFileObjectData(stack.Name, spec, swarmStack.Spec)
+
+	if err := validateSpec(spec); err != nil {
+		return fmt.Errorf("invalid stack spec: %s", err)
+	}
+
 	// Convert the new StackSpec to a SwarmStackSpec, while retaining the
 	// namespace label.
-	swarmSpec, err := b.convertToSwarmStackSpec(stack.Name, spec)
+	swarmSpec, err := b.convertToSwarmStackSpec(stack.Name, spec, swarmStack.Spec)
 	if err != nil {
-		return fmt.Errorf("unable to translate swarm spec: %s", err)
+		return translationError(err)
 	}
 
+	// Retain the force update counters of the services, so that redeployed
+	// services are not updated again, and whether the stack is paused.
+	applyForceUpdates(&swarmSpec, swarmStack.Spec.ForceUpdates)
+	swarmSpec.Paused = swarmStack.Spec.Paused
+
 	return b.stackStore.UpdateStack(id, spec, swarmSpec, version)
 }
 
@@ -131,18 +181,184 @@ func (b *DefaultStacksBackend) DeleteStack(id string) error {
 	return b.stackStore.DeleteStack(id)
 }
 
+// getStackAtVersion retrieves a stack to make a change to, and checks that it
+// is at the expected version, if any.
+func (b *DefaultStacksBackend) getStackAtVersion(id string, version uint64) (types.Stack, error) {
+	stack, err := b.stackStore.GetStack(id)
+	if errdefs.IsNotFound(err) {
+		return types.Stack{}, errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+	}
+	if err != nil {
+		return types.Stack{}, fmt.Errorf("unable to retrieve stack %s: %s", id, err)
+	}
+
+	if version != 0 && version != stack.Version.Index {
+		return types.Stack{}, errdefs.Conflict(fmt.Errorf("stack %s is at version %d, not %d", id, stack.Version.Index, version))
+	}
+
+	return stack, nil
+}
+
+// updateStackSpec makes a change to the spec of a stack, such as the scaling
+// of one of its services. update is passed the stack and a copy of its spec
+// to change, whose services are copied too, so that the stack returned by the
+// store is not modified in place. The stack is then updated against the
+// version update was passed, so that a concurrent update results in an
+// "update out of sequence" error rather than being overwritten. If version is
+// not zero, the change is only made if the stack is still at that version.
+func (b *DefaultStacksBackend) updateStackSpec(id string, version uint64, update func(stack types.Stack, spec *types.StackSpec) error) error {
+	stack, err := b.getStackAtVersion(id, version)
+	if err != nil {
+		return err
+	}
+
+	spec := stack.Spec
+	spec.Services = make([]composetypes.ServiceConfig, len(stack.Spec.Services))
+	copy(spec.Services, stack.Spec.Services)
+	if err := update(stack, &spec); err != nil {
+		return err
+	}
+
+	return b.UpdateStack(id, spec, stack.Version.Index)
+}
+
+// updateSwarmStackSpec is updateStackSpec for the swarm stack spec of a
+// stack, whose change is stored as it is rather than converted from the
+// stack spec.
+func (b *DefaultStacksBackend) updateSwarmStackSpec(id string, update func(stack types.Stack, spec *interfaces.SwarmStackSpec) error) error {
+	stack, err := b.getStackAtVersion(id, 0)
+	if err != nil {
+		return err
+	}
+
+	// The swarm stack is retrieved after the stack, so that it is at least
+	// as recent as the version the update is checked against.
+	swarmStack, err := b.stackStore.GetSwarmStack(id)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+	}
+
+	spec := swarmStack.Spec
+	spec.Services = make([]swarm.ServiceSpec, len(swarmStack.Spec.Services))
+	copy(spec.Services, swarmStack.Spec.Services)
+	if err := update(stack, &spec); err != nil {
+		return err
+	}
+
+	return b.stackStore.UpdateStack(id, stack.Spec, spec, stack.Version.Index)
+}
+
+// translationError returns an error translating a stack spec to a swarm stack
+// spec. The errors of the variables of the spec are returned as they are, so
+// that the API can return all of the variables to the client.
+func translationError(err error) error {
+	if _, ok := err.(*types.VariablesError); ok {
+		return err
+	}
+	return fmt.Errorf("unable to translate swarm spec: %s", err)
+}
+
+// conversionError returns an error converting the objects of a stack, along
+// with the path in the stack spec of the value it is about, if known.
+func conversionError(objects string, err error) error {
+	if cerr, ok := err.(*convert.Error); ok {
+		return fmt.Errorf("failed to convert %s: %s: %s", objects, cerr.Path(), err)
+	}
+	return fmt.Errorf("failed to convert %s: %s", objects, err)
+}
+
 // validateSpec returns an error if the provided StackSpec is not valid.
-func validateSpec(_ types.StackSpec) error {
+func validateSpec(spec types.StackSpec) error {
 	// TODO(alexmavr): implement
+	if err := validateDependencies(spec.Services); err != nil {
+		return err
+	}
+	if err := validateFileObjects(spec); err != nil {
+		return err
+	}
+	return validateDriftPolicy(spec.DriftPolicy)
+}
+
+// validateFileObjects returns an error if a secret or config which is not
+// external doesn't carry the content of its file. Stacks are submitted by
+// users, so the files they name are never read from the filesystem of the
+// server.
+func validateFileObjects(spec types.StackSpec) error {
+	for name, secret := range spec.Secrets {
+		if !secret.External.External && secret.Driver == "" && secret.Data == nil {
+			return fmt.Errorf("secret %s: the content of file %s is missing, the stack must be updated from its compose file", name, secret.File)
+		}
+	}
+	for name, config := range spec.Configs {
+		if !config.External.External && config.Data == nil {
+			return fmt.Errorf("config %s: the content of file %s is missing, the stack must be updated from its compose file", name, config.File)
+		}
+	}
 	return nil
 }
 
+// withFileObjectData returns a copy of a stack spec whose secrets and configs
+// without content are given the content of the swarm secret or config of the
+// same name in the current swarm stack spec. The stacks created when the
+// server read the files of secrets and configs from its own filesystem don't
+// carry their content, and are thus migrated on their next update.
+func withFileObjectData(stackName string, spec types.StackSpec, swarmSpec interfaces.SwarmStackSpec) types.StackSpec {
+	namespace := convert.NewNamespace(stackName)
+	scope := func(name string, obj composetypes.FileObjectConfig) string {
+		if obj.Name != "" {
+			return obj.Name
+		}
+		return namespace.Scope(name)
+	}
+
+	secretData := map[string][]byte{}
+	for _, secret := range swarmSpec.Secrets {
+		secretData[secret.Name] = secret.Data
+	}
+	secrets := make(map[string]composetypes.SecretConfig, len(spec.Secrets))
+	for name, secret := range spec.Secrets {
+		if secret.Data == nil && secret.File != "" {
+			secret.Data = secretData[scope(name, composetypes.FileObjectConfig(secret))]
+		}
+		secrets[name] = secret
+	}
+
+	configData := map[string][]byte{}
+	for _, config := range swarmSpec.Configs {
+		configData[config.Name] = config.Data
+	}
+	configs := make(map[string]composetypes.ConfigObjConfig, len(spec.Configs))
+	for name, config := range spec.Configs {
+		if config.Data == nil && config.File != "" {
+			config.Data = configData[scope(name, composetypes.FileObjectConfig(config))]
+		}
+		configs[name] = config
+	}
+
+	if spec.Secrets != nil {
+		spec.Secrets = secrets
+	}
+	if spec.Configs != nil {
+		spec.Configs = configs
+	}
+	return spec
+}
+
 // ParseComposeInput parses a compose file and returns the StackCreate object with the spec and any properties
 func (b *DefaultStacksBackend) ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 	return loader.ParseComposeInput(input)
 }
 
-func (b *DefaultStacksBackend) convertToSwarmStackSpec(name string, spec types.StackSpec) (interfaces.SwarmStackSpec, error) {
+// StackWarnings returns the warnings about the properties of a stack spec
+// which are ignored.
+func (b *DefaultStacksBackend) StackWarnings(spec types.StackSpec) []types.Warning {
+	return loader.Warnings(spec.Services)
+}
+
+// convertToSwarmStackSpec converts a stack spec to a swarm stack spec. The
+// previous swarm stack spec of the stack, if any, provides the secrets which
+// materialize the current values of its secret properties.
+func (b *DefaultStacksBackend) convertToSwarmStackSpec(name string, spec types.StackSpec, previous interfaces.SwarmStackSpec) (interfaces.SwarmStackSpec, error) {
 
 	// Substitute variables with desired property values
 	substitutedSpec, err := substitution.DoSubstitution(spec)
@@ -150,21 +366,34 @@ func (b *DefaultStacksBackend) convertToSwarmStackSpec(name string, spec types.S
 		return interfaces.SwarmStackSpec{}, err
 	}
 
+	// Services are sorted so that the reconciler creates them after the
+	// services they depend on.
+	substitutedSpec.Services, err = sortServicesByDependencies(substitutedSpec.Services)
+	if err != nil {
+		return interfaces.SwarmStackSpec{}, err
+	}
+
 	namespace := convert.NewNamespace(name)
 
-	services, err := convert.Services(namespace, substitutedSpec, b.swarmBackend)
+	// The secrets of the secret properties are created by the reconciler, so
+	// the services refer to them by name only.
+	substitutedSpec, pending := namePropertySecrets(namespace, substitutedSpec, previous)
+	services, err := convert.Services(namespace, substitutedSpec, &pendingSecretsBackend{
+		SwarmResourceBackend: b.swarmBackend,
+		pending:              pending,
+	})
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, fmt.Errorf("failed to convert services : %s", err)
+		return interfaces.SwarmStackSpec{}, conversionError("services", err)
 	}
 
 	configs, err := convert.Configs(namespace, substitutedSpec.Configs)
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, fmt.Errorf("failed to convert configs: %s", err)
+		return interfaces.SwarmStackSpec{}, conversionError("configs", err)
 	}
 
 	secrets, err := convert.Secrets(namespace, substitutedSpec.Secrets)
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, fmt.Errorf("failed to convert secrets: %s", err)
+		return interfaces.SwarmStackSpec{}, conversionError("secrets", err)
 	}
 
 	serviceNetworks := getServicesDeclaredNetworks(substitutedSpec.Services)
@@ -176,11 +405,87 @@ func (b *DefaultStacksBackend) convertToSwarmStackSpec(name string, spec types.S
 		Configs:  configs,
 		Secrets:  secrets,
 		Networks: networkCreates,
+
+		Dependencies:        serviceDependencies(substitutedSpec.Services),
+		WaitForDependencies: spec.WaitForDependencies,
+		DriftPolicy:         spec.DriftPolicy,
 	}
 
 	return stackSpec, nil
 }
 
+// namePropertySecrets names the secrets materializing the values of the
+// secret properties of a substituted stack spec, and returns the names of
+// these secrets. The data of swarm secrets can't be updated, so a secret
+// keeps the name it has in the previous swarm stack spec as long as the value
+// of its property is unchanged, and is given a new name, with a random
+// suffix, whenever it changes.
+func namePropertySecrets(namespace convert.Namespace, spec types.StackSpec, previous interfaces.SwarmStackSpec) (types.StackSpec, map[string]struct{}) {
+	current := map[string]swarm.SecretSpec{}
+	for _, secret := range previous.Secrets {
+		if property, ok := secret.Labels[interfaces.PropertyLabel]; ok {
+			current[property] = secret
+		}
+	}
+
+	pending := map[string]struct{}{}
+	secrets := make(map[string]composetypes.SecretConfig, len(spec.Secrets))
+	for name, secret := range spec.Secrets {
+		if property, ok := secret.Labels[interfaces.PropertyLabel]; ok {
+			if old, ok := current[property]; ok && bytes.Equal(old.Data, secret.Data) {
+				secret.Name = old.Name
+			} else {
+				secret.Name = namespace.Scope(name) + "_" + stringid.TruncateID(stringid.GenerateRandomID())
+			}
+			pending[secret.Name] = struct{}{}
+		}
+		secrets[name] = secret
+	}
+	spec.Secrets = secrets
+	return spec, pending
+}
+
+// pendingSecretsBackend is a SwarmResourceBackend which lists the secrets of
+// the secret properties of a stack without an ID, whether they exist yet or
+// not, so that the services refer to them by name only. The reconciler
+// creates these secrets, and resolves their IDs.
+type pendingSecretsBackend struct {
+	interfaces.SwarmResourceBackend
+	pending map[string]struct{}
+}
+
+func (b *pendingSecretsBackend) GetSecrets(opts dockertypes.SecretListOptions) ([]swarm.Secret, error) {
+	var (
+		result []swarm.Secret
+		names  []string
+	)
+	for _, name := range opts.Filters.Get("name") {
+		if _, ok := b.pending[name]; ok {
+			result = append(result, swarm.Secret{Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: name}}})
+		} else {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	args := filters.NewArgs()
+	for _, name := range names {
+		args.Add("name", name)
+	}
+	secrets, err := b.SwarmResourceBackend.GetSecrets(dockertypes.SecretListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		if _, ok := b.pending[secret.Spec.Name]; !ok {
+			result = append(result, secret)
+		}
+	}
+	return result, nil
+}
+
 func getServicesDeclaredNetworks(serviceConfigs []composetypes.ServiceConfig) map[string]struct{} {
 	serviceNetworks := map[string]struct{}{}
 	for _, serviceConfig := range serviceConfigs {
@@ -28,6 +28,7 @@ func TestStacksBackendPropertySubstitutionBasic(t *testing.T) {
 	for i, fixture := range []string{
 		"default-env-file",
 		"volume-path-env",
+		"typed-variables",
 	} {
 
 		// Load up the test data
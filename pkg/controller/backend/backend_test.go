@@ -11,6 +11,7 @@ import (
 
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
 	composeTypes "github.com/docker/stacks/pkg/compose/types"
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/mocks"
@@ -81,6 +82,64 @@ func TestStacksBackendInvalidCreate(t *testing.T) {
 	require.Error(err)
 	require.Contains(err.Error(), "invalid orchestrator type")
 
+	// Attempt to create a stack with a secret read from the server.
+	_, err = b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Secrets: map[string]composeTypes.SecretConfig{
+				"shadow": {
+					File: "/etc/shadow",
+				},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "secret shadow: the content of file /etc/shadow is missing")
+
+	// Attempt to create a stack with a value which can't be converted.
+	_, err = b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: composeTypes.Services{
+				{
+					Name:  "web",
+					Image: "nginx",
+					Deploy: composeTypes.DeployConfig{
+						Resources: composeTypes.Resources{
+							Limits: &composeTypes.Resource{
+								NanoCPUs: "lots",
+							},
+						},
+					},
+				},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "failed to convert services: services.web.deploy.resources: ")
+
+	// Attempt to create a stack missing a required variable. The variables
+	// are returned as they are, for the API to return them.
+	_, err = b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: composeTypes.Services{
+				{Name: "web", Image: "nginx:${TAG:?}"},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.IsType(&types.VariablesError{}, err)
+	require.True(errdefs.IsInvalidParameter(err))
+
 	// Ensure no stacks were created
 	stacks, err := b.ListStacks()
 	require.NoError(err)
@@ -406,3 +465,135 @@ func assertNetworkEquality(t *testing.T, swarmNetworkSpec dockerTypes.NetworkCre
 	assert.Equal(swarmNetworkSpec.Options, stackNetworkSpec.DriverOpts)
 	assert.Equal(swarmNetworkSpec.IPAM.Driver, stackNetworkSpec.Ipam.Driver)
 }
+
+// TestStacksBackendUpdateLegacyFileObjects tests that the stacks whose secrets
+// don't carry the content of their file, which were created when the server
+// read them from its own filesystem, keep the content they were created with
+// when they are updated.
+func TestStacksBackendUpdateLegacyFileObjects(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	legacyStack := func(swarmSecrets []swarm.SecretSpec) string {
+		id, err := store.AddStack(types.Stack{
+			Metadata: types.Metadata{Name: "teststack"},
+			Spec: types.StackSpec{
+				Services: []composeTypes.ServiceConfig{
+					{Name: "service1", Image: "image1"},
+				},
+				Secrets: map[string]composeTypes.SecretConfig{
+					"password": {File: "./password.txt"},
+				},
+			},
+		}, interfaces.SwarmStack{
+			Spec: interfaces.SwarmStackSpec{Secrets: swarmSecrets},
+		})
+		require.NoError(err)
+		return id
+	}
+
+	id := legacyStack([]swarm.SecretSpec{
+		{Annotations: swarm.Annotations{Name: "teststack_password"}, Data: []byte("s3cret")},
+	})
+	require.NoError(b.ScaleService(id, "service1", 2))
+
+	stack, err := b.GetStack(id)
+	require.NoError(err)
+	require.Equal([]byte("s3cret"), stack.Spec.Secrets["password"].Data)
+	swarmStack, err := b.GetSwarmStack(id)
+	require.NoError(err)
+	require.Len(swarmStack.Spec.Secrets, 1)
+	require.Equal([]byte("s3cret"), swarmStack.Spec.Secrets[0].Data)
+
+	// without the swarm secret, the content can't be recovered.
+	id = legacyStack(nil)
+	err = b.ScaleService(id, "service1", 2)
+	require.Error(err)
+	require.Contains(err.Error(), "the content of file ./password.txt is missing, the stack must be updated from its compose file")
+}
+
+// TestStacksBackendSecretProperties tests that the values of the secret
+// properties of a stack are recorded as secrets of the swarm stack spec, which
+// the services refer to by name only, and that the secrets are given a new
+// name when the values change. The backend itself creates and removes no
+// secret, this is left to the reconciler.
+func TestStacksBackendSecretProperties(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// the mock fails the test on any call to the swarm backend.
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	password := "${PASSWORD}"
+	spec := types.StackSpec{
+		Services: []composeTypes.ServiceConfig{
+			{
+				Name:  "service1",
+				Image: "image1",
+				Environment: composeTypes.MappingWithEquals{
+					"DB_PASSWORD": &password,
+				},
+			},
+		},
+		Properties: map[string]types.Property{
+			"PASSWORD": {Sensitive: true, Secret: true},
+		},
+		PropertyValues: []string{"PASSWORD=s3cret"},
+	}
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata:     types.Metadata{Name: "teststack"},
+		Spec:         spec,
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	// propertySecret returns the secret of the swarm stack spec, after
+	// checking that the service refers to it by name.
+	propertySecret := func() swarm.SecretSpec {
+		swarmStack, err := b.GetSwarmStack(resp.ID)
+		require.NoError(err)
+		require.Len(swarmStack.Spec.Secrets, 1)
+		secret := swarmStack.Spec.Secrets[0]
+		require.Equal("PASSWORD", secret.Labels[interfaces.PropertyLabel])
+
+		require.Len(swarmStack.Spec.Services, 1)
+		containerSpec := swarmStack.Spec.Services[0].TaskTemplate.ContainerSpec
+		require.Contains(containerSpec.Env, "DB_PASSWORD_FILE=/run/secrets/property_password")
+		require.Len(containerSpec.Secrets, 1)
+		require.Equal("property_password", containerSpec.Secrets[0].File.Name)
+		require.Equal(secret.Name, containerSpec.Secrets[0].SecretName)
+		require.Empty(containerSpec.Secrets[0].SecretID)
+		return secret
+	}
+	secret := propertySecret()
+	require.True(strings.HasPrefix(secret.Name, "teststack_property_password_"))
+	require.Equal([]byte("s3cret"), secret.Data)
+
+	// the secret is kept as long as the value is unchanged, including when
+	// the stack is updated with its redacted value.
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	spec.PropertyValues = []string{"PASSWORD=" + types.RedactedValue}
+	require.NoError(b.UpdateStack(resp.ID, spec, stack.Version.Index))
+	require.Equal(secret, propertySecret())
+
+	// the data of swarm secrets can't be updated, so another secret is
+	// named for the new value.
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	spec.PropertyValues = []string{"PASSWORD=n3w"}
+	require.NoError(b.UpdateStack(resp.ID, spec, stack.Version.Index))
+	updated := propertySecret()
+	require.NotEqual(secret.Name, updated.Name)
+	require.True(strings.HasPrefix(updated.Name, "teststack_property_password_"))
+	require.Equal([]byte("n3w"), updated.Data)
+
+	// the reconciler removes the secrets of the deleted stack.
+	require.NoError(b.DeleteStack(resp.ID))
+	_, err = b.GetStack(resp.ID)
+	require.Error(err)
+}
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+)
+
+// validateDependencies returns an error if a service depends on a service
+// which is not part of the stack, or if the dependencies of the services form
+// a cycle.
+func validateDependencies(services []composetypes.ServiceConfig) error {
+	_, err := sortServicesByDependencies(services)
+	return err
+}
+
+// sortServicesByDependencies returns the services sorted so that every service
+// comes after the services it depends on. Services which don't depend on each
+// other keep their relative order.
+func sortServicesByDependencies(services []composetypes.ServiceConfig) ([]composetypes.ServiceConfig, error) {
+	index := make(map[string]int, len(services))
+	for i, service := range services {
+		index[service.Name] = i
+	}
+	for _, service := range services {
+		for _, dependency := range service.DependsOn {
+			if _, ok := index[dependency]; !ok {
+				return nil, fmt.Errorf("service %s depends on undefined service %s", service.Name, dependency)
+			}
+		}
+	}
+
+	// a service is only added once all of its dependencies have been added.
+	// each pass over the services adds at least one service, unless the
+	// remaining ones form a cycle.
+	sorted := make([]composetypes.ServiceConfig, 0, len(services))
+	added := make([]bool, len(services))
+	for len(sorted) < len(services) {
+		progress := false
+		for i, service := range services {
+			if added[i] || !dependenciesAdded(service, index, added) {
+				continue
+			}
+			sorted = append(sorted, service)
+			added[i] = true
+			progress = true
+		}
+		if !progress {
+			return nil, fmt.Errorf("dependency cycle between services: %s", findCycle(services, index, added))
+		}
+	}
+	return sorted, nil
+}
+
+func dependenciesAdded(service composetypes.ServiceConfig, index map[string]int, added []bool) bool {
+	for _, dependency := range service.DependsOn {
+		if !added[index[dependency]] {
+			return false
+		}
+	}
+	return true
+}
+
+// findCycle returns a description of a dependency cycle among the services
+// which could not be added.
+func findCycle(services []composetypes.ServiceConfig, index map[string]int, added []bool) string {
+	// every remaining service depends on a remaining service, so following
+	// these dependencies from any remaining service ends up in a cycle.
+	start := 0
+	for added[start] {
+		start++
+	}
+	visited := map[int]int{}
+	var path []string
+	for i := start; ; {
+		if pos, ok := visited[i]; ok {
+			return strings.Join(append(path[pos:], services[i].Name), " -> ")
+		}
+		visited[i] = len(path)
+		path = append(path, services[i].Name)
+		for _, dependency := range services[i].DependsOn {
+			if j := index[dependency]; !added[j] {
+				i = j
+				break
+			}
+		}
+	}
+}
+
+// serviceDependencies returns the names of the services each service depends
+// on, keyed by service name. Services without dependencies are omitted.
+func serviceDependencies(services []composetypes.ServiceConfig) map[string][]string {
+	var dependencies map[string][]string
+	for _, service := range services {
+		if len(service.DependsOn) == 0 {
+			continue
+		}
+		if dependencies == nil {
+			dependencies = make(map[string][]string)
+		}
+		dependencies[service.Name] = append([]string(nil), service.DependsOn...)
+	}
+	return dependencies
+}
@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func serviceNames(services []composeTypes.ServiceConfig) []string {
+	names := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	return names
+}
+
+func TestSortServicesByDependencies(t *testing.T) {
+	require := require.New(t)
+
+	sorted, err := sortServicesByDependencies([]composeTypes.ServiceConfig{
+		{Name: "web", DependsOn: []string{"api", "cache"}},
+		{Name: "api", DependsOn: []string{"db"}},
+		{Name: "cache"},
+		{Name: "db"},
+		{Name: "worker", DependsOn: []string{"db"}},
+	})
+	require.NoError(err)
+	require.Equal([]string{"cache", "db", "worker", "api", "web"}, serviceNames(sorted))
+
+	_, err = sortServicesByDependencies([]composeTypes.ServiceConfig{
+		{Name: "web", DependsOn: []string{"db"}},
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "service web depends on undefined service db")
+
+	_, err = sortServicesByDependencies([]composeTypes.ServiceConfig{
+		{Name: "web", DependsOn: []string{"api"}},
+		{Name: "api", DependsOn: []string{"db"}},
+		{Name: "db", DependsOn: []string{"api"}},
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "dependency cycle between services: api -> db -> api")
+}
+
+func TestStacksBackendDependencies(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	create := types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:      "web",
+					Image:     "image1",
+					DependsOn: []string{"db"},
+				},
+				{
+					Name:  "db",
+					Image: "image2",
+				},
+			},
+			WaitForDependencies: true,
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	}
+	resp, err := b.CreateStack(create)
+	require.NoError(err)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Len(swarmStack.Spec.Services, 2)
+	require.Equal("db", swarmStack.Spec.Services[0].Annotations.Name)
+	require.Equal("web", swarmStack.Spec.Services[1].Annotations.Name)
+	require.Equal(map[string][]string{"web": {"db"}}, swarmStack.Spec.Dependencies)
+	require.True(swarmStack.Spec.WaitForDependencies)
+
+	// The stack spec keeps the services in their original order.
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal([]string{"web", "db"}, serviceNames(stack.Spec.Services))
+
+	// Stacks with dependency cycles are rejected.
+	spec := stack.Spec
+	spec.Services = []composeTypes.ServiceConfig{
+		{Name: "web", Image: "image1", DependsOn: []string{"db"}},
+		{Name: "db", Image: "image2", DependsOn: []string{"web"}},
+	}
+	err = b.UpdateStack(resp.ID, spec, stack.Version.Index)
+	require.Error(err)
+	require.Contains(err.Error(), "dependency cycle between services")
+
+	create.Metadata.Name = "otherstack"
+	create.Spec.Services[0].DependsOn = []string{"cache"}
+	_, err = b.CreateStack(create)
+	require.Error(err)
+	require.Contains(err.Error(), "depends on undefined service cache")
+}
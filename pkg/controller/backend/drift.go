@@ -0,0 +1,196 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/drift"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// AdoptServiceSpec folds the changes made to a service of a stack outside of
+// the Stacks API back into the spec of the stack. Only the changes to the
+// image, entrypoint, command, environment and replicas of the service are
+// adopted; the other changes are reverted by the reconciler once the stack is
+// updated. The changes to values which are not set literally in the stack
+// spec, e.g. because they hold variables, are not adopted: a Forbidden error
+// is returned instead, and the service is left as it is.
+// NOTE: this is an internal-only method used by the Swarm Stacks Reconciler.
+func (b *DefaultStacksBackend) AdoptServiceSpec(id string, spec swarm.ServiceSpec) error {
+	return b.updateStackSpec(id, 0, func(stack types.Stack, stackSpec *types.StackSpec) error {
+		// The swarm stack is retrieved after the stack, so that it is at
+		// least as recent as the version the update is checked against.
+		swarmStack, err := b.stackStore.GetSwarmStack(id)
+		if err != nil {
+			return fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+		}
+
+		var desired *swarm.ServiceSpec
+		for i := range swarmStack.Spec.Services {
+			if swarmStack.Spec.Services[i].Annotations.Name == spec.Annotations.Name {
+				desired = &swarmStack.Spec.Services[i]
+				break
+			}
+		}
+
+		name := convert.NewNamespace(stack.Name).Descope(spec.Annotations.Name)
+		for i := range stackSpec.Services {
+			if desired != nil && stackSpec.Services[i].Name == name {
+				return adoptServiceSpec(&stackSpec.Services[i], *desired, spec)
+			}
+		}
+		return errdefs.NotFound(fmt.Errorf("service %s is not part of stack %s", name, id))
+	})
+}
+
+// adoptServiceSpec sets the fields of a service config which differ between
+// its desired and current service specs. Only the fields which changed are
+// set, so that the variables of the other fields are kept. A Forbidden error
+// is returned if a changed field is not set literally in the service config,
+// as adopting it would replace its variables with their values.
+func adoptServiceSpec(service *composetypes.ServiceConfig, desired, current swarm.ServiceSpec) error {
+	changed := map[string]bool{}
+	for _, change := range drift.ServiceChanges(desired, current) {
+		changed[change.Field] = true
+	}
+
+	notAdoptable := func(field string) error {
+		return errdefs.Forbidden(fmt.Errorf("%s of service %s is not set literally in the stack spec and cannot be adopted", field, service.Name))
+	}
+
+	desiredContainer, currentContainer := desired.TaskTemplate.ContainerSpec, current.TaskTemplate.ContainerSpec
+	if desiredContainer != nil && currentContainer != nil {
+		if changed[drift.FieldImage] {
+			if service.Image != desiredContainer.Image {
+				return notAdoptable(drift.FieldImage)
+			}
+			service.Image = currentContainer.Image
+		}
+		if changed[drift.FieldEntrypoint] {
+			if !equalCommands(service.Entrypoint, desiredContainer.Command) {
+				return notAdoptable(drift.FieldEntrypoint)
+			}
+			service.Entrypoint = composetypes.ShellCommand(currentContainer.Command)
+		}
+		if changed[drift.FieldCommand] {
+			if !equalCommands(service.Command, desiredContainer.Args) {
+				return notAdoptable(drift.FieldCommand)
+			}
+			service.Command = composetypes.ShellCommand(currentContainer.Args)
+		}
+		if changed[drift.FieldEnv] {
+			environment, err := adoptEnvironment(service.Environment, desiredContainer.Env, currentContainer.Env)
+			if err != nil {
+				return notAdoptable(err.Error())
+			}
+			service.Environment = environment
+		}
+	}
+
+	if changed[drift.FieldReplicas] && current.Mode.Replicated != nil && current.Mode.Replicated.Replicas != nil {
+		if _, ok := service.Deferred["deploy.replicas"]; ok {
+			return notAdoptable(drift.FieldReplicas)
+		}
+		replicas := *current.Mode.Replicated.Replicas
+		service.Deploy.Replicas = &replicas
+	}
+	return nil
+}
+
+// equalCommands returns true if a command of a service config is the same as
+// a command of a container spec.
+func equalCommands(command composetypes.ShellCommand, args []string) bool {
+	if len(command) != len(args) {
+		return false
+	}
+	for i := range command {
+		if command[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// adoptEnvironment returns a copy of the environment of a service config with
+// the variables which differ between the desired and current environments of
+// its container spec set to their current values. An error naming the
+// variable is returned if a changed variable is not set literally in the
+// environment of the service config, e.g. because its value holds variables
+// or because it is set from a secret.
+func adoptEnvironment(environment composetypes.MappingWithEquals, desired, current []string) (composetypes.MappingWithEquals, error) {
+	desiredEnv, currentEnv := parseEnvironment(desired), parseEnvironment(current)
+
+	adopted := make(composetypes.MappingWithEquals, len(environment))
+	for key, value := range environment {
+		adopted[key] = value
+	}
+
+	keys := make([]string, 0, len(desiredEnv)+len(currentEnv))
+	for key := range desiredEnv {
+		keys = append(keys, key)
+	}
+	for key := range currentEnv {
+		if _, ok := desiredEnv[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		desiredValue, inDesired := desiredEnv[key]
+		currentValue, inCurrent := currentEnv[key]
+		if inDesired == inCurrent && equalValues(desiredValue, currentValue) {
+			continue
+		}
+		value, inService := environment[key]
+		if inService != inDesired || !equalValues(value, desiredValue) {
+			return nil, fmt.Errorf("environment variable %s", key)
+		}
+		if inCurrent {
+			adopted[key] = currentValue
+		} else {
+			delete(adopted, key)
+		}
+	}
+	return adopted, nil
+}
+
+// parseEnvironment converts the environment of a container spec to a mapping.
+func parseEnvironment(env []string) composetypes.MappingWithEquals {
+	environment := make(composetypes.MappingWithEquals, len(env))
+	for _, variable := range env {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) == 1 {
+			environment[parts[0]] = nil
+			continue
+		}
+		value := parts[1]
+		environment[parts[0]] = &value
+	}
+	return environment
+}
+
+// equalValues returns true if two values of environment variables are equal.
+func equalValues(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// validateDriftPolicy returns an error if the provided DriftPolicy is not
+// valid. An empty policy is valid, and defaults to DriftPolicyRevert.
+func validateDriftPolicy(policy types.DriftPolicy) error {
+	switch policy {
+	case "", types.DriftPolicyRevert, types.DriftPolicyReportOnly, types.DriftPolicyAdopt:
+		return nil
+	default:
+		return fmt.Errorf("invalid drift policy %q", policy)
+	}
+}
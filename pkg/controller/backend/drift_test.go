@@ -0,0 +1,176 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendAdoptServiceSpec(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	value := "${VALUE}"
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:        "service1",
+					Image:       "image1",
+					Environment: composeTypes.MappingWithEquals{"KEY": &value},
+				},
+			},
+			PropertyValues: []string{"VALUE=value"},
+			DriftPolicy:    types.DriftPolicyAdopt,
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Len(swarmStack.Spec.Services, 1)
+	require.Equal(types.DriftPolicyAdopt, swarmStack.Spec.DriftPolicy)
+
+	// The image and the replicas of the service were changed by hand, along
+	// with its placement, which cannot be adopted.
+	replicas := uint64(3)
+	spec := swarmStack.Spec.Services[0]
+	containerSpec := *spec.TaskTemplate.ContainerSpec
+	containerSpec.Image = "image2"
+	spec.TaskTemplate.ContainerSpec = &containerSpec
+	spec.TaskTemplate.Placement = &swarm.Placement{Constraints: []string{"node.role==manager"}}
+	spec.Mode = swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}}
+
+	err = b.AdoptServiceSpec(resp.ID, spec)
+	require.NoError(err)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(2), stack.Version.Index)
+	service := stack.Spec.Services[0]
+	require.Equal("image2", service.Image)
+	require.NotNil(service.Deploy.Replicas)
+	require.Equal(uint64(3), *service.Deploy.Replicas)
+	// The fields which weren't changed keep their variables.
+	require.Equal(composeTypes.MappingWithEquals{"KEY": &value}, service.Environment)
+
+	// The environment variables are adopted one by one, so that the
+	// variables of the others are kept, but changing a variable whose value
+	// holds variables cannot be adopted.
+	swarmStack, err = b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	spec = swarmStack.Spec.Services[0]
+	containerSpec = *spec.TaskTemplate.ContainerSpec
+	containerSpec.Env = append([]string{"OTHER=other"}, containerSpec.Env...)
+	spec.TaskTemplate.ContainerSpec = &containerSpec
+
+	err = b.AdoptServiceSpec(resp.ID, spec)
+	require.NoError(err)
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(3), stack.Version.Index)
+	other := "other"
+	require.Equal(composeTypes.MappingWithEquals{"KEY": &value, "OTHER": &other}, stack.Spec.Services[0].Environment)
+
+	containerSpec.Env = []string{"OTHER=other", "KEY=changed"}
+	err = b.AdoptServiceSpec(resp.ID, spec)
+	require.True(errdefs.IsForbidden(err))
+	require.Contains(err.Error(), "environment variable KEY")
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(3), stack.Version.Index)
+
+	spec.Annotations.Name = "nosuchservice"
+	err = b.AdoptServiceSpec(resp.ID, spec)
+	require.True(errdefs.IsNotFound(err))
+
+	err = b.AdoptServiceSpec("nosuchid", spec)
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestStacksBackendInvalidDriftPolicy(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	_, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			DriftPolicy: "ignore",
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.Error(err)
+	require.Contains(err.Error(), "invalid drift policy")
+}
+
+func TestStacksBackendAdoptServiceSpecVariables(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:     "service1",
+					Image:    "image:${TAG}",
+					Deferred: map[string]string{"deploy.replicas": "${REPLICAS}"},
+				},
+			},
+			PropertyValues: []string{"TAG=1", "REPLICAS=2"},
+			DriftPolicy:    types.DriftPolicyAdopt,
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	spec := swarmStack.Spec.Services[0]
+
+	// The image holds a variable
+	image := spec
+	containerSpec := *spec.TaskTemplate.ContainerSpec
+	containerSpec.Image = "image:2"
+	image.TaskTemplate.ContainerSpec = &containerSpec
+	err = b.AdoptServiceSpec(resp.ID, image)
+	require.True(errdefs.IsForbidden(err))
+	require.Contains(err.Error(), "image of service service1")
+
+	// The replicas are set from a variable
+	replicas := spec
+	count := uint64(5)
+	replicas.Mode = swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &count}}
+	err = b.AdoptServiceSpec(resp.ID, replicas)
+	require.True(errdefs.IsForbidden(err))
+	require.Contains(err.Error(), "replicas of service service1")
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(1), stack.Version.Index)
+	require.Equal("image:${TAG}", stack.Spec.Services[0].Image)
+}
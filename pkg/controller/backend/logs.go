@@ -0,0 +1,177 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Attributes attached to log messages by swarm, identifying their origin.
+const (
+	logAttrServiceID = "com.docker.swarm.service.id"
+	logAttrTaskID    = "com.docker.swarm.task.id"
+	logAttrNodeID    = "com.docker.swarm.node.id"
+)
+
+// StackLogs returns the logs of the services of a stack, multiplexed into a
+// single channel. Each log line is prefixed with the name of the task and
+// node it originates from.
+func (b *DefaultStacksBackend) StackLogs(ctx context.Context, id string, options types.StackLogsOptions) (<-chan *backend.LogMessage, error) {
+	stack, err := b.stackStore.GetStack(id)
+	if errdefs.IsNotFound(err) {
+		return nil, errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve stack %s: %s", id, err)
+	}
+
+	swarmStack, err := b.stackStore.GetSwarmStack(id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+	}
+
+	// The logs are requested by the names of the services in the swarm
+	// stack, which are not necessarily the names used in the stack spec.
+	namespace := convert.NewNamespace(stack.Name)
+
+	var allServices []string
+	stackServices := make(map[string]string, len(swarmStack.Spec.Services))
+	for _, service := range swarmStack.Spec.Services {
+		name := namespace.Descope(service.Annotations.Name)
+		allServices = append(allServices, name)
+		stackServices[name] = service.Annotations.Name
+	}
+
+	names := options.Services
+	if len(names) == 0 {
+		names = allServices
+	}
+
+	selector := &backend.LogSelector{}
+	for _, name := range names {
+		swarmName, ok := stackServices[name]
+		if !ok {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("service %s is not part of stack %s", name, id))
+		}
+		selector.Services = append(selector.Services, swarmName)
+	}
+
+	msgs, err := b.swarmBackend.ServiceLogs(ctx, selector, &dockerTypes.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     options.Follow,
+		Tail:       options.Tail,
+		Since:      options.Since,
+		Timestamps: options.Timestamps,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &logPrefixer{
+		swarmBackend: b.swarmBackend,
+		namespace:    namespace,
+		serviceNames: make(map[string]string),
+		taskNames:    make(map[string]string),
+		hostnames:    make(map[string]string),
+	}
+
+	prefixed := make(chan *backend.LogMessage)
+	go func() {
+		defer close(prefixed)
+		for msg := range msgs {
+			if msg.Err == nil {
+				msg.Line = append([]byte(p.prefix(msg.Attrs)), msg.Line...)
+			}
+			select {
+			case prefixed <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return prefixed, nil
+}
+
+// logPrefixer computes the prefix of log lines from the attributes of log
+// messages. It caches the names it resolves, and is not thread-safe.
+type logPrefixer struct {
+	swarmBackend interfaces.SwarmResourceBackend
+	namespace    convert.Namespace
+
+	// serviceNames, taskNames and hostnames map service, task and node IDs
+	// to their names.
+	serviceNames map[string]string
+	taskNames    map[string]string
+	hostnames    map[string]string
+}
+
+// prefix returns the prefix of a log line, of the form
+// "<service>.<slot>.<task ID>@<hostname> | ". Global services use the node ID
+// in lieu of the slot, like the docker CLI does.
+func (p *logPrefixer) prefix(attrs []backend.LogAttr) string {
+	var serviceID, taskID, nodeID string
+	for _, attr := range attrs {
+		switch attr.Key {
+		case logAttrServiceID:
+			serviceID = attr.Value
+		case logAttrTaskID:
+			taskID = attr.Value
+		case logAttrNodeID:
+			nodeID = attr.Value
+		}
+	}
+
+	return fmt.Sprintf("%s@%s | ", p.taskName(serviceID, taskID), p.hostname(nodeID))
+}
+
+func (p *logPrefixer) serviceName(serviceID string) string {
+	if name, ok := p.serviceNames[serviceID]; ok {
+		return name
+	}
+
+	name := serviceID
+	if service, err := p.swarmBackend.GetService(serviceID, false); err == nil {
+		name = p.namespace.Descope(service.Spec.Annotations.Name)
+	}
+	p.serviceNames[serviceID] = name
+	return name
+}
+
+func (p *logPrefixer) taskName(serviceID, taskID string) string {
+	if name, ok := p.taskNames[taskID]; ok {
+		return name
+	}
+
+	name := fmt.Sprintf("%s.%s", p.serviceName(serviceID), taskID)
+	if task, err := p.swarmBackend.GetTask(taskID); err == nil {
+		if task.Slot != 0 {
+			name = fmt.Sprintf("%s.%d.%s", p.serviceName(serviceID), task.Slot, taskID)
+		} else {
+			name = fmt.Sprintf("%s.%s.%s", p.serviceName(serviceID), task.NodeID, taskID)
+		}
+	}
+	p.taskNames[taskID] = name
+	return name
+}
+
+func (p *logPrefixer) hostname(nodeID string) string {
+	if hostname, ok := p.hostnames[nodeID]; ok {
+		return hostname
+	}
+
+	hostname := nodeID
+	if node, err := p.swarmBackend.GetNode(nodeID); err == nil && node.Description.Hostname != "" {
+		hostname = node.Description.Hostname
+	}
+	p.hostnames[nodeID] = hostname
+	return hostname
+}
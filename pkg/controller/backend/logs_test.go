@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendLogs(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	id, err := store.AddStack(types.Stack{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: composeTypes.Services{
+				{Name: "web"},
+				{Name: "db"},
+			},
+		},
+	}, interfaces.SwarmStack{
+		Spec: interfaces.SwarmStackSpec{
+			Services: []swarm.ServiceSpec{
+				{Annotations: swarm.Annotations{Name: "web"}},
+				{Annotations: swarm.Annotations{Name: "db"}},
+			},
+		},
+	})
+	require.NoError(err)
+
+	// Selecting a service which isn't part of the stack is an error
+	_, err = b.StackLogs(context.Background(), id, types.StackLogsOptions{
+		Services: []string{"nosuchservice"},
+	})
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	msgs := make(chan *backend.LogMessage, 2)
+	msgs <- &backend.LogMessage{
+		Line:   []byte("hello\n"),
+		Source: "stdout",
+		Attrs: []backend.LogAttr{
+			{Key: "com.docker.swarm.service.id", Value: "serviceID"},
+			{Key: "com.docker.swarm.task.id", Value: "taskID"},
+			{Key: "com.docker.swarm.node.id", Value: "nodeID"},
+		},
+	}
+	msgs <- &backend.LogMessage{
+		Line:   []byte("world\n"),
+		Source: "stderr",
+		Attrs: []backend.LogAttr{
+			{Key: "com.docker.swarm.service.id", Value: "serviceID"},
+			{Key: "com.docker.swarm.task.id", Value: "taskID"},
+			{Key: "com.docker.swarm.node.id", Value: "nodeID"},
+		},
+	}
+	close(msgs)
+
+	backendClient.EXPECT().ServiceLogs(gomock.Any(), &backend.LogSelector{
+		Services: []string{"web"},
+	}, gomock.Any()).Return((<-chan *backend.LogMessage)(msgs), nil)
+
+	// Names are only resolved once
+	backendClient.EXPECT().GetService("serviceID", false).Return(swarm.Service{
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "web"},
+		},
+	}, nil)
+	backendClient.EXPECT().GetTask("taskID").Return(swarm.Task{Slot: 1}, nil)
+	backendClient.EXPECT().GetNode("nodeID").Return(swarm.Node{
+		Description: swarm.NodeDescription{Hostname: "node1"},
+	}, nil)
+
+	logs, err := b.StackLogs(context.Background(), id, types.StackLogsOptions{
+		Services: []string{"web"},
+	})
+	require.NoError(err)
+
+	var lines []string
+	for msg := range logs {
+		require.NoError(msg.Err)
+		lines = append(lines, string(msg.Line))
+	}
+	require.Equal([]string{
+		"web.1.taskID@node1 | hello\n",
+		"web.1.taskID@node1 | world\n",
+	}, lines)
+}
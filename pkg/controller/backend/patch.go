@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/mergepatch"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// PatchStack applies a JSON merge patch (RFC 7386) to the current spec of a
+// stack. The services of the spec are keyed by name in its JSON
+// representation, so a patch can target a single service. If version is not
+// zero, the patch is only applied if the stack is still at that version.
+func (b *DefaultStacksBackend) PatchStack(id string, patch []byte, version uint64) error {
+	return b.updateStackSpec(id, version, func(stack types.Stack, spec *types.StackSpec) error {
+		patched, err := mergepatch.ApplyStackSpec(stack.Spec, patch)
+		if err != nil {
+			return errdefs.InvalidParameter(err)
+		}
+		*spec = patched
+		return nil
+	})
+}
+
+// SetServiceImage sets the image of a service of a stack. If version is not
+// zero, the image is only set if the stack is still at that version.
+func (b *DefaultStacksBackend) SetServiceImage(id string, service string, image string, version uint64) error {
+	if image == "" {
+		return errdefs.InvalidParameter(fmt.Errorf("image must not be empty"))
+	}
+
+	return b.updateStackSpec(id, version, func(_ types.Stack, spec *types.StackSpec) error {
+		for i := range spec.Services {
+			if spec.Services[i].Name == service {
+				spec.Services[i].Image = image
+				return nil
+			}
+		}
+		return errdefs.NotFound(fmt.Errorf("service %s is not part of stack %s", service, id))
+	})
+}
@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/docker/errdefs"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendPatchStack(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "web",
+					Image: "nginx:1.14",
+				},
+			},
+			Collection: "test1",
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	version := stack.Version.Index
+
+	err = b.PatchStack(resp.ID, []byte(`{"collection":"test2"}`), 0)
+	require.NoError(err)
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(version+1, stack.Version.Index)
+	require.Equal("test2", stack.Spec.Collection)
+	require.Equal("nginx:1.14", stack.Spec.Services[0].Image)
+
+	// Services are keyed by name, so a patch can target a single service.
+	err = b.PatchStack(resp.ID, []byte(`{"services":{"web":{"image":"nginx:1.15"}}}`), version+1)
+	require.NoError(err)
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(version+2, stack.Version.Index)
+	require.Equal("nginx:1.15", stack.Spec.Services[0].Image)
+	require.Equal("web", stack.Spec.Services[0].Name)
+
+	// A patch with a stale version precondition is rejected.
+	err = b.PatchStack(resp.ID, []byte(`{"collection":"test3"}`), version)
+	require.Error(err)
+	require.True(errdefs.IsConflict(err))
+
+	err = b.PatchStack(resp.ID, []byte(`{"collection":`), 0)
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	err = b.PatchStack("nosuchid", []byte(`{}`), 0)
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(version+2, stack.Version.Index)
+	require.Equal("test2", stack.Spec.Collection)
+}
+
+func TestStacksBackendSetServiceImage(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "web",
+					Image: "nginx:1.14",
+				},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+
+	err = b.SetServiceImage(resp.ID, "web", "nginx:1.15", stack.Version.Index)
+	require.NoError(err)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Equal("nginx:1.15", swarmStack.Spec.Services[0].TaskTemplate.ContainerSpec.Image)
+
+	err = b.SetServiceImage(resp.ID, "nosuchservice", "nginx:1.15", 0)
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	err = b.SetServiceImage(resp.ID, "web", "", 0)
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+}
@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// PauseStack pauses the reconciliation of a stack. The services of a paused
+// stack are left as they are, so that they can be changed by hand, although
+// their drift and status are still reported.
+func (b *DefaultStacksBackend) PauseStack(id string) error {
+	return b.setStackPaused(id, true)
+}
+
+// ResumeStack resumes the reconciliation of a paused stack. The whole stack is
+// then reconciled.
+func (b *DefaultStacksBackend) ResumeStack(id string) error {
+	return b.setStackPaused(id, false)
+}
+
+// setStackPaused sets whether the reconciliation of a stack is paused.
+func (b *DefaultStacksBackend) setStackPaused(id string, paused bool) error {
+	stack, err := b.stackStore.GetStack(id)
+	if errdefs.IsNotFound(err) {
+		return errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+	}
+	if err != nil {
+		return fmt.Errorf("unable to retrieve stack %s: %s", id, err)
+	}
+
+	// The swarm stack is retrieved after the stack, so that it is at least
+	// as recent as the version the update is checked against.
+	swarmStack, err := b.stackStore.GetSwarmStack(id)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+	}
+
+	if swarmStack.Spec.Paused == paused {
+		return nil
+	}
+
+	swarmSpec := swarmStack.Spec
+	swarmSpec.Paused = paused
+	return b.stackStore.UpdateStack(id, stack.Spec, swarmSpec, stack.Version.Index)
+}
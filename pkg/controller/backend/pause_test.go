@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/docker/errdefs"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendPauseResumeStack(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "service1",
+					Image: "image1",
+				},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	err = b.PauseStack(resp.ID)
+	require.NoError(err)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.True(stack.Paused)
+	require.Equal(uint64(2), stack.Version.Index)
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.True(swarmStack.Spec.Paused)
+
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.True(stacks[0].Paused)
+
+	// Pausing a paused stack doesn't update it.
+	err = b.PauseStack(resp.ID)
+	require.NoError(err)
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(2), stack.Version.Index)
+
+	// The stack stays paused when its spec is updated.
+	err = b.UpdateStack(resp.ID, stack.Spec, stack.Version.Index)
+	require.NoError(err)
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.True(stack.Paused)
+
+	err = b.ResumeStack(resp.ID)
+	require.NoError(err)
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.False(stack.Paused)
+
+	err = b.PauseStack("nosuchid")
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+}
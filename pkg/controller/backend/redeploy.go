@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// RedeployStack forces the services of a stack to be redeployed, even if
+// their spec hasn't changed. If services is empty, all of the services of the
+// stack are redeployed.
+func (b *DefaultStacksBackend) RedeployStack(id string, services []string) error {
+	return b.updateSwarmStackSpec(id, func(stack types.Stack, spec *interfaces.SwarmStackSpec) error {
+		namespace := convert.NewNamespace(stack.Name)
+
+		var allServices []string
+		stackServices := make(map[string]string, len(spec.Services))
+		for _, service := range spec.Services {
+			name := namespace.Descope(service.Annotations.Name)
+			allServices = append(allServices, name)
+			stackServices[name] = service.Annotations.Name
+		}
+
+		if len(services) == 0 {
+			services = allServices
+		}
+
+		forceUpdates := make(map[string]uint64, len(spec.ForceUpdates))
+		for name, counter := range spec.ForceUpdates {
+			forceUpdates[name] = counter
+		}
+		for _, name := range services {
+			swarmName, ok := stackServices[name]
+			if !ok {
+				return errdefs.InvalidParameter(fmt.Errorf("service %s is not part of stack %s", name, id))
+			}
+			forceUpdates[swarmName]++
+		}
+
+		applyForceUpdates(spec, forceUpdates)
+		return nil
+	})
+}
+
+// applyForceUpdates sets the TaskTemplate.ForceUpdate of the services of a
+// SwarmStackSpec from the provided counters. Counters of services which are
+// no longer part of the spec are dropped.
+func applyForceUpdates(spec *interfaces.SwarmStackSpec, forceUpdates map[string]uint64) {
+	spec.ForceUpdates = nil
+	for i := range spec.Services {
+		name := spec.Services[i].Annotations.Name
+		counter, ok := forceUpdates[name]
+		if !ok {
+			continue
+		}
+		if spec.ForceUpdates == nil {
+			spec.ForceUpdates = make(map[string]uint64)
+		}
+		spec.ForceUpdates[name] = counter
+		spec.Services[i].TaskTemplate.ForceUpdate = counter
+	}
+}
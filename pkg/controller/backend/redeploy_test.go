@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/docker/errdefs"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getForceUpdates(t *testing.T, b *DefaultStacksBackend, id string) map[string]uint64 {
+	swarmStack, err := b.GetSwarmStack(id)
+	require.NoError(t, err)
+
+	forceUpdates := make(map[string]uint64)
+	for _, spec := range swarmStack.Spec.Services {
+		forceUpdates[spec.Annotations.Name] = spec.TaskTemplate.ForceUpdate
+		require.Equal(t, swarmStack.Spec.ForceUpdates[spec.Annotations.Name], spec.TaskTemplate.ForceUpdate)
+	}
+	return forceUpdates
+}
+
+func TestStacksBackendRedeployStack(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "web",
+					Image: "nginx:1.14",
+				},
+				{
+					Name:  "db",
+					Image: "mysql:5.7",
+				},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	require.NoError(b.RedeployStack(resp.ID, nil))
+	require.Equal(map[string]uint64{"web": 1, "db": 1}, getForceUpdates(t, b, resp.ID))
+
+	require.NoError(b.RedeployStack(resp.ID, []string{"web"}))
+	require.Equal(map[string]uint64{"web": 2, "db": 1}, getForceUpdates(t, b, resp.ID))
+
+	err = b.RedeployStack(resp.ID, []string{"nosuchservice"})
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	err = b.RedeployStack("nosuchid", nil)
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	// Updating the stack retains the counters of the services which are
+	// still part of it.
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	stack.Spec.Services = []composeTypes.ServiceConfig{
+		{
+			Name:  "web",
+			Image: "nginx:1.15",
+		},
+	}
+	require.NoError(b.UpdateStack(resp.ID, stack.Spec, stack.Version.Index))
+	require.Equal(map[string]uint64{"web": 2}, getForceUpdates(t, b, resp.ID))
+}
@@ -0,0 +1,28 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// ScaleService sets the number of replicas of a service of a stack. The
+// stored spec of the stack is updated, which bumps the version of the stack
+// so that the reconciler applies the new number of replicas.
+func (b *DefaultStacksBackend) ScaleService(id string, service string, replicas uint64) error {
+	return b.updateStackSpec(id, 0, func(_ types.Stack, spec *types.StackSpec) error {
+		for i := range spec.Services {
+			if spec.Services[i].Name != service {
+				continue
+			}
+			if spec.Services[i].Deploy.Mode == "global" {
+				return errdefs.InvalidParameter(fmt.Errorf("service %s of stack %s is a global service and cannot be scaled", service, id))
+			}
+			spec.Services[i].Deploy.Replicas = &replicas
+			return nil
+		}
+		return errdefs.NotFound(fmt.Errorf("service %s is not part of stack %s", service, id))
+	})
+}
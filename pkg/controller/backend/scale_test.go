@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendScaleService(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Metadata: types.Metadata{
+			Name: "teststack",
+		},
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "replicated",
+					Image: "image1",
+				},
+				{
+					Name:  "global",
+					Image: "image2",
+					Deploy: composeTypes.DeployConfig{
+						Mode: "global",
+					},
+				},
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	version := stack.Version.Index
+
+	err = b.ScaleService(resp.ID, "replicated", 3)
+	require.NoError(err)
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(version+1, stack.Version.Index)
+	require.NotNil(stack.Spec.Services[0].Deploy.Replicas)
+	require.Equal(uint64(3), *stack.Spec.Services[0].Deploy.Replicas)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Len(swarmStack.Spec.Services, 2)
+	// The order of the services isn't preserved by the conversion.
+	specs := make(map[string]swarm.ServiceSpec)
+	for _, spec := range swarmStack.Spec.Services {
+		specs[spec.Annotations.Name] = spec
+	}
+	require.NotNil(specs["replicated"].Mode.Replicated)
+	require.Equal(uint64(3), *specs["replicated"].Mode.Replicated.Replicas)
+
+	// Global services cannot be scaled.
+	err = b.ScaleService(resp.ID, "global", 3)
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	err = b.ScaleService(resp.ID, "nosuchservice", 3)
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	err = b.ScaleService("nosuchid", "replicated", 3)
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	// Failed scale operations leave the stack untouched.
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(version+1, stack.Version.Index)
+}
@@ -0,0 +1,268 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/drift"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// waitPollInterval is the interval at which WaitStack re-computes the status
+// of a stack. It is a variable so that tests can shorten it.
+var waitPollInterval = time.Second
+
+// WaitStack blocks until the stack with the provided ID satisfies the
+// provided condition, and returns the last observed status of the stack. If
+// the context is done before the condition is satisfied, the last observed
+// status is returned along with an error.
+func (b *DefaultStacksBackend) WaitStack(ctx context.Context, id string, condition types.StackWaitCondition) (types.StackStatus, error) {
+	for {
+		var status types.StackStatus
+		swarmStack, err := b.stackStore.GetSwarmStack(id)
+		switch {
+		case errdefs.IsNotFound(err):
+			if condition != types.StackWaitConditionDeleted {
+				return status, errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+			}
+			status, err = b.deletedStackStatus(id)
+		case err != nil:
+			return status, fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+		default:
+			status, err = b.swarmStackStatus(swarmStack)
+		}
+		if err != nil {
+			return status, err
+		}
+
+		if statusSatisfies(status, condition) {
+			return status, nil
+		}
+
+		select {
+		case <-time.After(waitPollInterval):
+		case <-ctx.Done():
+			err := fmt.Errorf("stack %s is %s, not %s: %s", id, status.Phase, condition, ctx.Err())
+			if ctx.Err() == context.DeadlineExceeded {
+				return status, errdefs.Deadline(err)
+			}
+			return status, errdefs.Cancelled(err)
+		}
+	}
+}
+
+// GetStackStatus computes the current status of the stack with the provided
+// ID.
+func (b *DefaultStacksBackend) GetStackStatus(id string) (types.StackStatus, error) {
+	swarmStack, err := b.stackStore.GetSwarmStack(id)
+	if errdefs.IsNotFound(err) {
+		return types.StackStatus{}, errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+	}
+	if err != nil {
+		return types.StackStatus{}, fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+	}
+	return b.swarmStackStatus(swarmStack)
+}
+
+// statusSatisfies returns true if a stack with the provided status satisfies
+// the provided wait condition.
+func statusSatisfies(status types.StackStatus, condition types.StackWaitCondition) bool {
+	switch condition {
+	case types.StackWaitConditionRunning:
+		return status.Phase == types.StackPhaseRunning || status.Phase == types.StackPhaseConverged
+	case types.StackWaitConditionConverged:
+		return status.Phase == types.StackPhaseConverged
+	case types.StackWaitConditionDeleted:
+		return status.Phase == types.StackPhaseDeleted
+	default:
+		return false
+	}
+}
+
+// swarmStackStatus computes the current status of a stack from the state of
+// its services and their tasks.
+func (b *DefaultStacksBackend) swarmStackStatus(stack interfaces.SwarmStack) (types.StackStatus, error) {
+	status := types.StackStatus{
+		Phase:          types.StackPhaseConverged,
+		ServicesStatus: make(map[string]types.ServiceStatus),
+		LastUpdated:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var (
+		pending, outdated []string
+		// secretIDs are the IDs of the secrets the reconciler created for
+		// the secret properties of the stack, listed once needed.
+		secretIDs map[string]string
+	)
+	for _, spec := range stack.Spec.Services {
+		name := spec.Annotations.Name
+		service, err := b.swarmBackend.GetService(name, false)
+		if errdefs.IsNotFound(err) {
+			status.ServicesStatus[name] = types.ServiceStatus{}
+			pending = append(pending, name)
+			continue
+		}
+		if err != nil {
+			return status, fmt.Errorf("unable to inspect service %s: %s", name, err)
+		}
+
+		tasks, err := b.swarmBackend.GetTasks(dockerTypes.TaskListOptions{
+			Filters: filters.NewArgs(filters.Arg("service", service.ID)),
+		})
+		if err != nil {
+			return status, fmt.Errorf("unable to list tasks of service %s: %s", name, err)
+		}
+
+		serviceStatus := getServiceStatus(service, tasks)
+		status.ServicesStatus[name] = serviceStatus
+
+		if drift.HasPendingSecrets(spec) && secretIDs == nil {
+			secretIDs, err = b.stackSecretIDs(stack.ID)
+			if err != nil {
+				return status, err
+			}
+		}
+		desired := drift.ServiceSpec(stack.ID, drift.WithSecretIDs(spec, secretIDs))
+		if drift.Drifted(desired, service.Spec) {
+			status.Drift = append(status.Drift, drift.ServiceChanges(desired, service.Spec)...)
+		}
+
+		switch {
+		case serviceStatus.RunningTasks < serviceStatus.DesiredTasks:
+			pending = append(pending, name)
+		case !isServiceUpToDate(desired, service):
+			outdated = append(outdated, name)
+		}
+	}
+
+	switch {
+	case len(pending) > 0:
+		status.Phase = types.StackPhasePending
+		status.Message = fmt.Sprintf("services not running: %v", pending)
+	case len(outdated) > 0:
+		status.Phase = types.StackPhaseRunning
+		status.Message = fmt.Sprintf("services not on the latest stack spec: %v", outdated)
+	}
+
+	// The drifted fields may hold the values of sensitive properties, which
+	// are only known to the stack.
+	if len(status.Drift) > 0 {
+		st, err := b.stackStore.GetStack(stack.ID)
+		if err != nil {
+			return status, fmt.Errorf("unable to retrieve stack %s: %s", stack.ID, err)
+		}
+		status = st.Spec.RedactStatus(status)
+	}
+
+	return status, nil
+}
+
+// stackSecretIDs returns the IDs of the secrets labeled with the ID of a
+// stack, keyed by name.
+func (b *DefaultStacksBackend) stackSecretIDs(id string) (map[string]string, error) {
+	secrets, err := b.swarmBackend.GetSecrets(dockerTypes.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", interfaces.StackLabel, id))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list secrets of stack %s: %s", id, err)
+	}
+	ids := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		ids[secret.Spec.Name] = secret.ID
+	}
+	return ids, nil
+}
+
+// deletedStackStatus computes the status of a stack which no longer exists in
+// the store, from the services still labeled with its ID. The services are
+// listed again on every call, so that the services the reconciler has not
+// removed yet are waited for even if they were never known to the caller.
+func (b *DefaultStacksBackend) deletedStackStatus(id string) (types.StackStatus, error) {
+	status := types.StackStatus{
+		Phase:       types.StackPhaseDeleted,
+		LastUpdated: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	services, err := b.swarmBackend.GetServices(dockerTypes.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", interfaces.StackLabel, id))),
+	})
+	if err != nil {
+		return status, fmt.Errorf("unable to list services of stack %s: %s", id, err)
+	}
+
+	if len(services) > 0 {
+		remaining := make([]string, 0, len(services))
+		for _, service := range services {
+			remaining = append(remaining, service.Spec.Annotations.Name)
+		}
+		status.Phase = types.StackPhasePending
+		status.Message = fmt.Sprintf("services not removed: %v", remaining)
+	}
+
+	return status, nil
+}
+
+// getServiceStatus counts the desired and running tasks of a service. Only
+// tasks started after the last update of the service began are counted as
+// running, so that tasks of a previous spec are not taken into account.
+func getServiceStatus(service swarm.Service, tasks []swarm.Task) types.ServiceStatus {
+	var (
+		status       types.ServiceStatus
+		desiredTasks uint64
+	)
+
+	var updateStartedAt time.Time
+	if service.UpdateStatus != nil && service.UpdateStatus.StartedAt != nil {
+		updateStartedAt = *service.UpdateStatus.StartedAt
+	}
+
+	for _, task := range tasks {
+		if task.DesiredState != swarm.TaskStateRunning {
+			continue
+		}
+		desiredTasks++
+		if task.Status.State == swarm.TaskStateRunning && !task.Meta.CreatedAt.Before(updateStartedAt) {
+			status.RunningTasks++
+		}
+	}
+
+	switch {
+	case service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil:
+		status.DesiredTasks = *service.Spec.Mode.Replicated.Replicas
+	case service.Spec.Mode.Global == nil:
+		// Replicated services without an explicit number of replicas
+		// default to a single replica.
+		status.DesiredTasks = 1
+	default:
+		// Global services have one desired task per eligible node, which
+		// the orchestrator has already worked out for us.
+		status.DesiredTasks = desiredTasks
+	}
+
+	return status
+}
+
+// isServiceUpToDate returns true if the service matches the spec the
+// reconciler applies for the stack, and is not in the middle of a rolling
+// update.
+func isServiceUpToDate(spec swarm.ServiceSpec, service swarm.Service) bool {
+	if service.UpdateStatus != nil {
+		switch service.UpdateStatus.State {
+		case swarm.UpdateStateCompleted, swarm.UpdateStateRollbackCompleted:
+		default:
+			return false
+		}
+	}
+
+	// This mirrors the comparison the reconciler uses to decide whether a
+	// service needs to be updated.
+	return reflect.DeepEqual(spec, service.Spec)
+}
@@ -0,0 +1,294 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/stacks/pkg/drift"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func init() {
+	waitPollInterval = 10 * time.Millisecond
+}
+
+func getWaitTestFixtures(replicas uint64, running int) (interfaces.SwarmStack, swarm.Service, []swarm.Task) {
+	spec := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack_service1",
+		},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: "image1",
+			},
+		},
+		Mode: swarm.ServiceMode{
+			Replicated: &swarm.ReplicatedService{
+				Replicas: &replicas,
+			},
+		},
+	}
+
+	swarmStack := interfaces.SwarmStack{
+		Spec: interfaces.SwarmStackSpec{
+			Services: []swarm.ServiceSpec{spec},
+		},
+	}
+
+	service := swarm.Service{
+		ID:   "serviceID",
+		Spec: spec,
+	}
+
+	tasks := []swarm.Task{}
+	for i := uint64(0); i < replicas; i++ {
+		state := swarm.TaskStatePending
+		if int(i) < running {
+			state = swarm.TaskStateRunning
+		}
+		tasks = append(tasks, swarm.Task{
+			ServiceID:    service.ID,
+			DesiredState: swarm.TaskStateRunning,
+			Status: swarm.TaskStatus{
+				State: state,
+			},
+		})
+	}
+
+	return swarmStack, service, tasks
+}
+
+func TestStacksBackendWaitConverged(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, tasks := getWaitTestFixtures(2, 2)
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+	// The service was created by the reconciler.
+	service.Spec = drift.ServiceSpec(id, service.Spec)
+
+	backendClient.EXPECT().GetService("teststack_service1", false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+
+	status, err := b.WaitStack(context.Background(), id, types.StackWaitConditionConverged)
+	require.NoError(err)
+	require.Equal(types.StackPhaseConverged, status.Phase)
+	require.Equal(types.ServiceStatus{
+		DesiredTasks: 2,
+		RunningTasks: 2,
+	}, status.ServicesStatus["teststack_service1"])
+}
+
+func TestStacksBackendWaitRunning(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, tasks := getWaitTestFixtures(1, 1)
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+
+	// The running service has not been updated to the stored spec yet.
+	service.Spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{
+		Image: "oldimage",
+	}
+
+	backendClient.EXPECT().GetService("teststack_service1", false).Return(service, nil).AnyTimes()
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil).AnyTimes()
+
+	status, err := b.WaitStack(context.Background(), id, types.StackWaitConditionRunning)
+	require.NoError(err)
+	require.Equal(types.StackPhaseRunning, status.Phase)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	status, err = b.WaitStack(ctx, id, types.StackWaitConditionConverged)
+	require.Error(err)
+	require.True(errdefs.IsDeadline(err))
+	require.Equal(types.StackPhaseRunning, status.Phase)
+}
+
+func TestStacksBackendWaitTimeout(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, tasks := getWaitTestFixtures(2, 1)
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+
+	backendClient.EXPECT().GetService("teststack_service1", false).Return(service, nil).AnyTimes()
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil).AnyTimes()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	status, err := b.WaitStack(ctx, id, types.StackWaitConditionRunning)
+	require.Error(err)
+	require.True(errdefs.IsDeadline(err))
+	require.Equal(types.StackPhasePending, status.Phase)
+	require.Equal(types.ServiceStatus{
+		DesiredTasks: 2,
+		RunningTasks: 1,
+	}, status.ServicesStatus["teststack_service1"])
+}
+
+func TestStacksBackendWaitDeleted(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	// Waiting on a stack that doesn't exist should return a NotFound error,
+	// unless we are waiting for it to be deleted.
+	_, err := b.WaitStack(context.Background(), "nosuchid", types.StackWaitConditionConverged)
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	backendClient.EXPECT().GetServices(dockerTypes.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", interfaces.StackLabel+"=nosuchid")),
+	}).Return(nil, nil)
+	status, err := b.WaitStack(context.Background(), "nosuchid", types.StackWaitConditionDeleted)
+	require.NoError(err)
+	require.Equal(types.StackPhaseDeleted, status.Phase)
+}
+
+func TestStacksBackendWaitDeletedServices(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, _ := getWaitTestFixtures(1, 1)
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+	require.NoError(b.DeleteStack(id))
+
+	// The service of the stack is removed by the reconciler after the stack
+	// has been deleted from the store.
+	listOptions := dockerTypes.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", interfaces.StackLabel+"="+id)),
+	}
+	gomock.InOrder(
+		backendClient.EXPECT().GetServices(listOptions).Return([]swarm.Service{service}, nil).Times(2),
+		backendClient.EXPECT().GetServices(listOptions).Return([]swarm.Service{}, nil),
+	)
+
+	status, err := b.WaitStack(context.Background(), id, types.StackWaitConditionDeleted)
+	require.NoError(err)
+	require.Equal(types.StackPhaseDeleted, status.Phase)
+}
+
+func TestStacksBackendGetStackStatus(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, tasks := getWaitTestFixtures(2, 1)
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+
+	backendClient.EXPECT().GetService("teststack_service1", false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+
+	status, err := b.GetStackStatus(id)
+	require.NoError(err)
+	require.Equal(types.StackPhasePending, status.Phase)
+
+	_, err = b.GetStackStatus("unknown")
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestStacksBackendGetStackStatusDrift(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, tasks := getWaitTestFixtures(2, 2)
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+
+	// The image of the service was changed after the reconciler created it.
+	service.Spec = drift.ServiceSpec(id, service.Spec)
+	service.Spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{
+		Image: "image2",
+	}
+
+	backendClient.EXPECT().GetService("teststack_service1", false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+
+	status, err := b.GetStackStatus(id)
+	require.NoError(err)
+	require.Equal(types.StackPhaseRunning, status.Phase)
+	require.Equal([]types.ResourceDrift{
+		{
+			Kind:    "service",
+			Name:    "teststack_service1",
+			Field:   drift.FieldImage,
+			Current: "image2",
+			Desired: "image1",
+		},
+	}, status.Drift)
+}
+
+// TestStacksBackendGetStackStatusSecretProperties tests that the services
+// refer to the secrets the reconciler created for the secret properties of
+// the stack, which the swarm stack spec refers to by name only, without
+// drifting from the stack.
+func TestStacksBackendGetStackStatusSecretProperties(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	store := interfaces.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, backendClient)
+
+	swarmStack, service, tasks := getWaitTestFixtures(2, 2)
+	swarmStack.Spec.Services[0].TaskTemplate.ContainerSpec.Secrets = []*swarm.SecretReference{
+		{SecretName: "teststack_property_password_abc"},
+	}
+	id, err := store.AddStack(types.Stack{}, swarmStack)
+	require.NoError(err)
+
+	service.Spec = drift.ServiceSpec(id, drift.WithSecretIDs(swarmStack.Spec.Services[0], map[string]string{
+		"teststack_property_password_abc": "secretID",
+	}))
+
+	backendClient.EXPECT().GetService("teststack_service1", false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+	backendClient.EXPECT().GetSecrets(dockerTypes.SecretListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", interfaces.StackLabel+"="+id)),
+	}).Return([]swarm.Secret{
+		{
+			ID:   "secretID",
+			Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "teststack_property_password_abc"}},
+		},
+	}, nil)
+
+	status, err := b.GetStackStatus(id)
+	require.NoError(err)
+	require.Equal(types.StackPhaseConverged, status.Phase)
+	require.Empty(status.Drift)
+}
@@ -1,6 +1,12 @@
 package router
 
-import "github.com/docker/stacks/pkg/types"
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/backend"
+
+	"github.com/docker/stacks/pkg/types"
+)
 
 // Backend abstracts the Stacks API.
 type Backend interface {
@@ -9,5 +15,14 @@ type Backend interface {
 	ListStacks() ([]types.Stack, error)
 	UpdateStack(id string, spec types.StackSpec, version uint64) error
 	DeleteStack(id string) error
+	PatchStack(id string, patch []byte, version uint64) error
+	SetServiceImage(id string, service string, image string, version uint64) error
+	ScaleService(id string, service string, replicas uint64) error
+	RedeployStack(id string, services []string) error
+	PauseStack(id string) error
+	ResumeStack(id string) error
+	WaitStack(ctx context.Context, id string, condition types.StackWaitCondition) (types.StackStatus, error)
+	StackLogs(ctx context.Context, id string, options types.StackLogsOptions) (<-chan *backend.LogMessage, error)
 	ParseComposeInput(types.ComposeInput) (*types.StackCreate, error)
+	StackWarnings(spec types.StackSpec) []types.Warning
 }
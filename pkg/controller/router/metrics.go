@@ -0,0 +1,74 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/server/router"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_api_requests_total",
+		Help: "Number of requests to the Stacks API, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "stacks_api_request_duration_seconds",
+		Help: "Latency of the requests to the Stacks API, by method and route.",
+	}, []string{"method", "route"})
+)
+
+// RegisterMetrics registers the metrics of the Stacks API routes in the
+// provided registerer.
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{requestsTotal, requestDuration} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instrumentRoute wraps the handler of a route, recording the count and
+// latency of its requests.
+func instrumentRoute(route router.Route) router.Route {
+	method, path, handler := route.Method(), route.Path(), route.Handler()
+	return router.NewRoute(method, path, func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		start := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		err := handler(ctx, sw, r, vars)
+
+		status := sw.status
+		if err != nil {
+			status = httputils.GetHTTPErrorStatusCode(err)
+		}
+		requestsTotal.WithLabelValues(method, path, strconv.Itoa(status)).Inc()
+		requestDuration.WithLabelValues(method, path).Observe(time.Since(start).Seconds())
+		return err
+	})
+}
+
+// statusResponseWriter is an http.ResponseWriter recording the status code of
+// the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements the http.Flusher interface, which streaming handlers such
+// as the stack logs rely on.
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -1,6 +1,14 @@
 package router
 
-import "github.com/docker/docker/api/server/router"
+import (
+	"context"
+	"net/http"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/server/router"
+
+	"github.com/docker/stacks/pkg/types"
+)
 
 type stacksRouter struct {
 	backend Backend
@@ -28,6 +36,41 @@ func (sr *stacksRouter) initRoutes() {
 		router.NewGetRoute("/stacks/{id}", sr.getStack),
 		router.NewDeleteRoute("/stacks/{id}", sr.removeStack),
 		router.NewPostRoute("/stacks/{id}", sr.updateStack),
+		router.NewRoute("PATCH", "/stacks/{id}", sr.patchStack),
+		router.NewGetRoute("/stacks/{id}/wait", sr.waitStack),
+		router.NewGetRoute("/stacks/{id}/logs", sr.getStackLogs),
+		router.NewPostRoute("/stacks/{id}/redeploy", sr.redeployStack),
+		router.NewPostRoute("/stacks/{id}/pause", sr.pauseStack),
+		router.NewPostRoute("/stacks/{id}/resume", sr.resumeStack),
+		router.NewPostRoute("/stacks/{id}/services/{name}/scale", sr.scaleStackService),
+		router.NewPostRoute("/stacks/{id}/services/{name}/image", sr.setStackServiceImage),
 		router.NewPostRoute("/parsecompose", sr.parseComposeInput),
 	}
+	for i, route := range sr.routes {
+		sr.routes[i] = instrumentRoute(variablesErrorRoute(route))
+	}
+}
+
+// variablesErrorResponse is the error response of a VariablesError. Along
+// with the message of the error, it lists the variables, so that the clients
+// can show all of them with their locations.
+type variablesErrorResponse struct {
+	Message string `json:"message"`
+	*types.VariablesError
+}
+
+// variablesErrorRoute wraps the handler of a route, writing the
+// VariablesError it returns as a variablesErrorResponse.
+func variablesErrorRoute(route router.Route) router.Route {
+	handler := route.Handler()
+	return router.NewRoute(route.Method(), route.Path(), func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		err := handler(ctx, w, r, vars)
+		if variablesErr, ok := err.(*types.VariablesError); ok {
+			return httputils.WriteJSON(w, http.StatusBadRequest, variablesErrorResponse{
+				Message:        variablesErr.Error(),
+				VariablesError: variablesErr,
+			})
+		}
+		return err
+	})
 }
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/server/httputils"
+	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/errdefs"
 	"github.com/sirupsen/logrus"
 
@@ -23,7 +26,12 @@ func (sr *stacksRouter) getStacks(_ context.Context, w http.ResponseWriter, _ *h
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, stacks)
+	redacted := make([]types.Stack, len(stacks))
+	for i, stack := range stacks {
+		redacted[i] = stack.Redacted()
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, redacted)
 }
 
 func (sr *stacksRouter) createStack(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
@@ -34,12 +42,19 @@ func (sr *stacksRouter) createStack(_ context.Context, w http.ResponseWriter, r
 		}
 		return errdefs.InvalidParameter(err)
 	}
+	stackCreate.Spec.MarkSensitive(r.URL.Query()["sensitive"])
+
+	warnings, err := sr.stackWarnings(r, stackCreate.Spec)
+	if err != nil {
+		return err
+	}
 
 	resp, err := sr.backend.CreateStack(stackCreate)
 	if err != nil {
 		logrus.Errorf("Error creating stack: %s", err)
 		return err
 	}
+	resp.Warnings = warnings
 
 	return httputils.WriteJSON(w, http.StatusCreated, resp)
 }
@@ -51,7 +66,7 @@ func (sr *stacksRouter) getStack(_ context.Context, w http.ResponseWriter, _ *ht
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, stack)
+	return httputils.WriteJSON(w, http.StatusOK, stack.Redacted())
 }
 
 func (sr *stacksRouter) removeStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
@@ -65,7 +80,7 @@ func (sr *stacksRouter) removeStack(_ context.Context, w http.ResponseWriter, _
 	return nil
 }
 
-func (sr *stacksRouter) updateStack(_ context.Context, _ http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (sr *stacksRouter) updateStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var stackSpec types.StackSpec
 	if err := json.NewDecoder(r.Body).Decode(&stackSpec); err != nil {
 		if err == io.EOF {
@@ -73,6 +88,7 @@ func (sr *stacksRouter) updateStack(_ context.Context, _ http.ResponseWriter, r
 		}
 		return errdefs.InvalidParameter(err)
 	}
+	stackSpec.MarkSensitive(r.URL.Query()["sensitive"])
 
 	rawVersion := r.URL.Query().Get("version")
 	version, err := strconv.ParseUint(rawVersion, 10, 64)
@@ -81,12 +97,204 @@ func (sr *stacksRouter) updateStack(_ context.Context, _ http.ResponseWriter, r
 		return errdefs.InvalidParameter(err)
 	}
 
+	warnings, err := sr.stackWarnings(r, stackSpec)
+	if err != nil {
+		return err
+	}
+
 	err = sr.backend.UpdateStack(vars["id"], stackSpec, version)
 	if err != nil {
 		logrus.Errorf("Error updating stack %s: %s", vars["id"], err)
 		return err
 	}
 
+	return httputils.WriteJSON(w, http.StatusOK, types.StackUpdateResponse{Warnings: warnings})
+}
+
+// stackWarnings returns the warnings about a stack spec, which are returned
+// along with the response of the create and update operations. If the strict
+// query parameter is set, the warnings are returned as an error instead.
+func (sr *stacksRouter) stackWarnings(r *http.Request, spec types.StackSpec) ([]types.Warning, error) {
+	warnings := sr.backend.StackWarnings(spec)
+	if len(warnings) > 0 && httputils.BoolValue(r, "strict") {
+		return nil, &types.WarningsError{Warnings: warnings}
+	}
+	return warnings, nil
+}
+
+func (sr *stacksRouter) patchStack(_ context.Context, _ http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	patch, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	if len(patch) == 0 {
+		return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+	}
+
+	version, err := parseOptionalVersion(r)
+	if err != nil {
+		return err
+	}
+
+	err = sr.backend.PatchStack(vars["id"], patch, version)
+	if err != nil {
+		logrus.Errorf("Error patching stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	return nil
+}
+
+func (sr *stacksRouter) setStackServiceImage(_ context.Context, _ http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	image := r.URL.Query().Get("image")
+	if image == "" {
+		return errdefs.InvalidParameter(errors.New("missing image parameter"))
+	}
+
+	version, err := parseOptionalVersion(r)
+	if err != nil {
+		return err
+	}
+
+	err = sr.backend.SetServiceImage(vars["id"], vars["name"], image, version)
+	if err != nil {
+		logrus.Errorf("Error setting image of service %s of stack %s: %s", vars["name"], vars["id"], err)
+		return err
+	}
+
+	return nil
+}
+
+// parseOptionalVersion parses the version query parameter of a request,
+// which is used as a precondition of partial updates. If the parameter is
+// absent, zero is returned.
+func parseOptionalVersion(r *http.Request) (uint64, error) {
+	rawVersion := r.URL.Query().Get("version")
+	if rawVersion == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.ParseUint(rawVersion, 10, 64)
+	if err != nil {
+		err := fmt.Errorf("invalid stack version '%s': %v", rawVersion, err)
+		return 0, errdefs.InvalidParameter(err)
+	}
+	return version, nil
+}
+
+func (sr *stacksRouter) redeployStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	err := sr.backend.RedeployStack(vars["id"], r.Form["service"])
+	if err != nil {
+		logrus.Errorf("Error redeploying stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *stacksRouter) pauseStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	err := sr.backend.PauseStack(vars["id"])
+	if err != nil {
+		logrus.Errorf("Error pausing stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *stacksRouter) resumeStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	err := sr.backend.ResumeStack(vars["id"])
+	if err != nil {
+		logrus.Errorf("Error resuming stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *stacksRouter) scaleStackService(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	rawReplicas := r.URL.Query().Get("replicas")
+	replicas, err := strconv.ParseUint(rawReplicas, 10, 64)
+	if err != nil {
+		err := fmt.Errorf("invalid number of replicas '%s': %v", rawReplicas, err)
+		return errdefs.InvalidParameter(err)
+	}
+
+	err = sr.backend.ScaleService(vars["id"], vars["name"], replicas)
+	if err != nil {
+		logrus.Errorf("Error scaling service %s of stack %s: %s", vars["name"], vars["id"], err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *stacksRouter) waitStack(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	condition := types.StackWaitCondition(r.URL.Query().Get("condition"))
+	switch condition {
+	case "":
+		condition = types.StackWaitConditionConverged
+	case types.StackWaitConditionConverged, types.StackWaitConditionRunning, types.StackWaitConditionDeleted:
+	default:
+		err := fmt.Errorf("invalid wait condition '%s'", condition)
+		return errdefs.InvalidParameter(err)
+	}
+
+	if rawTimeout := r.URL.Query().Get("timeout"); rawTimeout != "" {
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil {
+			err := fmt.Errorf("invalid timeout '%s': %v", rawTimeout, err)
+			return errdefs.InvalidParameter(err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	status, err := sr.backend.WaitStack(ctx, vars["id"], condition)
+	if err != nil {
+		logrus.Errorf("Error waiting for stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, status)
+}
+
+func (sr *stacksRouter) getStackLogs(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	options := types.StackLogsOptions{
+		Services:   r.Form["service"],
+		Follow:     httputils.BoolValue(r, "follow"),
+		Tail:       r.Form.Get("tail"),
+		Since:      r.Form.Get("since"),
+		Timestamps: httputils.BoolValue(r, "timestamps"),
+	}
+
+	msgs, err := sr.backend.StackLogs(ctx, vars["id"], options)
+	if err != nil {
+		logrus.Errorf("Error getting logs of stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	// The logs of all services are multiplexed with the stdcopy format, as
+	// the services may or may not have a TTY.
+	w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+	httputils.WriteLogStream(ctx, w, msgs, &dockerTypes.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: options.Timestamps,
+	}, true)
 	return nil
 }
 
@@ -102,6 +310,11 @@ func (sr *stacksRouter) parseComposeInput(_ context.Context, w http.ResponseWrit
 	resp, err := sr.backend.ParseComposeInput(input)
 	if err != nil {
 		logrus.Errorf("Error creating stack: %s", err)
+		// the errors of the compose files are returned along with their
+		// location, so that they can be shown in the files.
+		if composeErr, ok := err.(*types.ComposeError); ok {
+			return httputils.WriteJSON(w, http.StatusBadRequest, composeErr)
+		}
 		return err
 	}
 
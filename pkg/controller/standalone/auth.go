@@ -0,0 +1,31 @@
+package standalone
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/errdefs"
+)
+
+// bearerPrefix is the prefix of the Authorization header carrying a bearer
+// token, as defined by RFC 6750.
+const bearerPrefix = "Bearer "
+
+// withBearerAuth wraps an http.Handler, rejecting requests which don't carry
+// the provided bearer token in their Authorization header.
+func withBearerAuth(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, bearerPrefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, bearerPrefix)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="stacks"`)
+			httputils.MakeErrorHandler(errdefs.Unauthorized(errors.New("invalid or missing bearer token")))(w, r)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,82 @@
+package standalone
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+
+	"github.com/docker/stacks/pkg/reconciler"
+)
+
+// readinessCheckTimeout bounds the time taken by each readiness check.
+const readinessCheckTimeout = 5 * time.Second
+
+// readinessCheck is a named check which must pass for the server to be
+// ready.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// readinessResponse is the body of the responses of the readiness endpoint.
+// Checks contains the result of each check, either "ok" or an error.
+type readinessResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// healthzHandler reports that the process is alive.
+func healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok"))
+}
+
+// newReadyzHandler creates a handler reporting whether all of the provided
+// checks pass, with status 200 if they do and 503 otherwise.
+func newReadyzHandler(checks []readinessCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := readinessResponse{
+			Ready:  true,
+			Checks: make(map[string]string, len(checks)),
+		}
+		for _, c := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			err := c.check(ctx)
+			cancel()
+			if err != nil {
+				resp.Ready = false
+				resp.Checks[c.name] = err.Error()
+				continue
+			}
+			resp.Checks[c.name] = "ok"
+		}
+
+		status := http.StatusOK
+		if !resp.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		httputils.WriteJSON(w, status, resp)
+	})
+}
+
+// newDebugReconcilerHandler creates a handler dumping the state of the
+// reconciler.
+func newDebugReconcilerHandler(m *reconciler.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		httputils.WriteJSON(w, http.StatusOK, m.State())
+	})
+}
+
+// eventStreamCheck checks that the reconciler is subscribed to the event
+// stream.
+func eventStreamCheck(m *reconciler.Manager) func(context.Context) error {
+	return func(context.Context) error {
+		if !m.EventStreamSubscribed() {
+			return errors.New("not subscribed to the event stream")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package standalone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyzHandler(t *testing.T) {
+	require := require.New(t)
+
+	var engineErr error
+	handler := newReadyzHandler([]readinessCheck{
+		{
+			name: "engine",
+			check: func(context.Context) error {
+				return engineErr
+			},
+		},
+		{
+			name: "store",
+			check: func(context.Context) error {
+				return nil
+			},
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(http.StatusOK, rec.Code)
+
+	var resp readinessResponse
+	require.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	require.True(resp.Ready)
+	require.Equal(map[string]string{"engine": "ok", "store": "ok"}, resp.Checks)
+
+	engineErr = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(http.StatusServiceUnavailable, rec.Code)
+
+	resp = readinessResponse{}
+	require.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	require.False(resp.Ready)
+	require.Equal(map[string]string{"engine": "connection refused", "store": "ok"}, resp.Checks)
+}
@@ -1,42 +1,128 @@
 package standalone
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/docker/docker/api/server/httputils"
 	"github.com/docker/docker/api/server/router"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-connections/tlsconfig"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/controller/backend"
 	stacksRouter "github.com/docker/stacks/pkg/controller/router"
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/reconciler"
+	"github.com/docker/stacks/pkg/store"
 )
 
+// defaultShutdownTimeout is the time in-flight requests are given to
+// complete when the server shuts down, if ServerOptions.ShutdownTimeout is
+// not set.
+const defaultShutdownTimeout = 30 * time.Second
+
 // ServerOptions is the set of options required for the creation of a
 // standalone.Server instance.
 type ServerOptions struct {
 	Debug            bool
 	DockerSocketPath string
-	ServerPort       int
+
+	// DockerHost, if set, is the address of the Docker engine, such as
+	// tcp://10.0.0.1:2376, and takes precedence over DockerSocketPath.
+	DockerHost string
+	// DockerTLSCAFile, DockerTLSCertFile and DockerTLSKeyFile configure TLS
+	// for the connection to the Docker engine. If none of them is set, TLS
+	// is not used.
+	DockerTLSCAFile   string
+	DockerTLSCertFile string
+	DockerTLSKeyFile  string
+	// DockerAPIVersion pins the version of the API used to talk to the
+	// Docker engine. If it is not set, the version is negotiated with the
+	// engine.
+	DockerAPIVersion string
+
+	// ServerAddress is the address on which the server listens for TCP
+	// connections. It defaults to 0.0.0.0.
+	ServerAddress string
+	// ServerPort is the port on which the server listens for TCP
+	// connections. If it is 0, the server doesn't listen on TCP.
+	ServerPort int
+	// UnixSocketPath, if set, is the path of a unix socket on which the
+	// server listens, in addition to its TCP port.
+	UnixSocketPath string
+
+	// TLS enables TLS on the TCP listener, using the TLSCertFile and
+	// TLSKeyFile key pair.
+	TLS         bool
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSVerify enables TLS and requires clients to present a certificate
+	// signed by the CA in TLSCAFile, like the --tlsverify option of the
+	// Docker daemon.
+	TLSVerify bool
+	TLSCAFile string
+
+	// AuthToken, if set, is the bearer token clients need to present in
+	// their Authorization header.
+	AuthToken string
+
+	// EncryptionKey is the AES key, 16, 24 or 32 bytes long, with which the
+	// stacks holding sensitive values are encrypted in the store. If it is
+	// not set, a random key is generated, as the stacks are only kept in
+	// memory.
+	EncryptionKey []byte
+
+	// ShutdownTimeout is the time in-flight requests are given to complete
+	// when the server is shut down.
+	ShutdownTimeout time.Duration
+
+	// ReconcileWorkers is the number of objects the reconciler reconciles
+	// concurrently. Objects of the same stack are reconciled one at a time.
+	ReconcileWorkers int
+}
+
+// newStackStore returns an in-memory store of stacks, which encrypts the
+// stacks holding sensitive values with the provided key, or else with a
+// random key.
+func newStackStore(key []byte) (*store.StackStore, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("unable to generate encryption key: %s", err)
+		}
+	}
+	return store.NewWithOptions(store.NewMemoryClient(), store.Options{EncryptionKey: key})
 }
 
 // Server initializes and runs a standalone http Server that serves the Stacks
-// API, and sets up the Stacks reconciler. A docker API client, accessible as a
-// unix socket via the DockerSocketPath option, provides access to the
-// downstream set of Swarmkit required for the reconciler.
+// API, and sets up the Stacks reconciler. A docker API client, connected to
+// the DockerHost option or to the unix socket at DockerSocketPath, provides
+// access to the downstream set of Swarmkit required for the reconciler. The server runs
+// until it fails, or receives SIGTERM or SIGINT, upon which it shuts down
+// gracefully.
 func Server(opts ServerOptions) error {
 	if opts.Debug {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
-	// Create an unauthenticated docker client
-	dclient, err := client.NewClient(fmt.Sprintf("unix://%s", opts.DockerSocketPath), "", nil, nil)
+	dclient, err := newDockerClient(opts)
 	if err != nil {
-		return fmt.Errorf("unable to create docker client for unix socket at %s: %s", opts.DockerSocketPath, err)
+		return err
 	}
 
 	// Create a shim for the SwarmResourceBackend interface using the docker client.
@@ -45,8 +131,12 @@ func Server(opts ServerOptions) error {
 	swarmResourceBackend := interfaces.NewSwarmAPIClientShim(dclient)
 
 	// Create the underlying storage for stacks and swarmstacks as an
-	// in-memory store.
-	stackStore := interfaces.NewFakeStackStore()
+	// in-memory store, in which the stacks holding sensitive values are
+	// encrypted.
+	stackStore, err := newStackStore(opts.EncryptionKey)
+	if err != nil {
+		return err
+	}
 
 	// Create a Stacks API Backend, which includes the API handling logic.
 	stacksBackend := backend.NewDefaultStacksBackend(stackStore, swarmResourceBackend)
@@ -55,33 +145,241 @@ func Server(opts ServerOptions) error {
 	backendClient := interfaces.NewBackendAPIClientShim(dclient, stacksBackend)
 
 	// Create the reconciler manager
-	reconcilerManager := reconciler.New(backendClient)
+	reconcilerManager := reconciler.NewWithOptions(backendClient, reconciler.Options{
+		Workers: opts.ReconcileWorkers,
+	})
 
 	// Create a Stacks API Router, which includes basic HTTP handlers
 	// for the Stacks APIs. This is wired up against the backendClient
 	// so that the API can trigger stack events.
 	r := stacksRouter.NewRouter(backendClient)
 
-	errChan := make(chan error)
+	// Collect the metrics of the Stacks API and of the reconciler, to be
+	// served on /metrics.
+	registry := prometheus.NewRegistry()
+	if err := stacksRouter.RegisterMetrics(registry); err != nil {
+		return err
+	}
+	if err := reconcilerManager.RegisterMetrics(registry); err != nil {
+		return err
+	}
+
+	apiHandler := registerRoutes(r, map[string]http.Handler{
+		"/metrics":          promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+		"/debug/reconciler": newDebugReconcilerHandler(reconcilerManager),
+	})
+	if opts.AuthToken != "" {
+		apiHandler = withBearerAuth(opts.AuthToken, apiHandler)
+	}
+
+	// The health and readiness endpoints are used by orchestrators to probe
+	// the server, and don't require authentication.
+	handler := http.NewServeMux()
+	handler.HandleFunc("/healthz", healthzHandler)
+	handler.Handle("/readyz", newReadyzHandler([]readinessCheck{
+		{
+			name: "engine",
+			check: func(ctx context.Context) error {
+				_, err := dclient.Ping(ctx)
+				return err
+			},
+		},
+		{
+			name: "store",
+			check: func(context.Context) error {
+				_, err := stacksBackend.ListStacks()
+				return err
+			},
+		},
+		{
+			name:  "events",
+			check: eventStreamCheck(reconcilerManager),
+		},
+	}))
+	handler.Handle("/", apiHandler)
+
+	listeners, err := newListeners(opts)
+	if err != nil {
+		return err
+	}
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", opts.ServerPort),
-		Handler: registerRoutes(r),
+		Handler: handler,
 	}
 
+	// The channels are buffered, so that the goroutines can exit once we
+	// stop listening to them.
+	serverErrChan := make(chan error, len(listeners))
+	reconcilerErrChan := make(chan error, 1)
+
 	// Launch the reconciler in a goroutine
 	go func() {
 		logrus.Infof("Starting Swarm Stacks reconciler")
-		errChan <- reconcilerManager.Run()
+		reconcilerErrChan <- reconcilerManager.Run()
 	}()
 
-	// Launch the HTTP server in a goroutine
-	go func() {
-		logrus.Infof("Running standalone Stacks API server")
-		errChan <- server.ListenAndServe()
-	}()
+	// Launch the HTTP server in a goroutine per listener
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			logrus.Infof("Running standalone Stacks API server on %s", l.Addr())
+			serverErrChan <- server.Serve(l)
+		}(l)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	var (
+		runErr            error
+		reconcilerStopped bool
+	)
+	select {
+	case sig := <-signals:
+		logrus.Infof("Received %s, shutting down", sig)
+	case runErr = <-serverErrChan:
+		logrus.Errorf("Stacks API server failed: %s", runErr)
+	case runErr = <-reconcilerErrChan:
+		reconcilerStopped = true
+		logrus.Errorf("Swarm Stacks reconciler stopped: %v", runErr)
+	}
+
+	timeout := opts.ShutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop accepting new requests, and wait for in-flight requests to
+	// complete. Requests which are still running after the timeout, such as
+	// followed log streams, are cut off.
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.Warnf("Stacks API server did not shut down gracefully: %s", err)
+		server.Close()
+	}
+
+	reconcilerManager.Stop()
+	if !reconcilerStopped {
+		select {
+		case err := <-reconcilerErrChan:
+			if err != nil {
+				logrus.Warnf("Swarm Stacks reconciler stopped with error: %s", err)
+			}
+		case <-ctx.Done():
+			logrus.Warnf("Swarm Stacks reconciler did not stop in time")
+		}
+	}
 
-	return <-errChan
+	return runErr
+}
+
+// dockerNegotiationTimeout is the time given to the Docker engine to answer
+// the API version negotiation.
+const dockerNegotiationTimeout = 10 * time.Second
+
+// newDockerClient creates the docker API client used to access the Docker
+// engine, from the options of the server.
+func newDockerClient(opts ServerOptions) (*client.Client, error) {
+	host := opts.DockerHost
+	if host == "" {
+		host = fmt.Sprintf("unix://%s", opts.DockerSocketPath)
+	}
+
+	clientOpts := []func(*client.Client) error{
+		client.WithHost(host),
+	}
+	if opts.DockerTLSCAFile != "" || opts.DockerTLSCertFile != "" || opts.DockerTLSKeyFile != "" {
+		clientOpts = append(clientOpts, client.WithTLSClientConfig(opts.DockerTLSCAFile, opts.DockerTLSCertFile, opts.DockerTLSKeyFile))
+	}
+	if opts.DockerAPIVersion != "" {
+		clientOpts = append(clientOpts, client.WithVersion(opts.DockerAPIVersion))
+	}
+
+	dclient, err := client.NewClientWithOpts(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create docker client for %s: %s", host, err)
+	}
+
+	if opts.DockerAPIVersion == "" {
+		// Negotiation failures are ignored, in which case the default
+		// version of the client is used.
+		ctx, cancel := context.WithTimeout(context.Background(), dockerNegotiationTimeout)
+		dclient.NegotiateAPIVersion(ctx)
+		cancel()
+		logrus.Infof("Using Docker API version %s", dclient.ClientVersion())
+	}
+
+	return dclient, nil
+}
+
+// newListeners creates the listeners of the server from its options.
+func newListeners(opts ServerOptions) ([]net.Listener, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+	closeListeners := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	if opts.ServerPort != 0 {
+		address := opts.ServerAddress
+		if address == "" {
+			address = "0.0.0.0"
+		}
+		l, err := sockets.NewTCPSocket(net.JoinHostPort(address, strconv.Itoa(opts.ServerPort)), tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on port %d: %s", opts.ServerPort, err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	if opts.UnixSocketPath != "" {
+		l, err := sockets.NewUnixSocket(opts.UnixSocketPath, os.Getegid())
+		if err != nil {
+			closeListeners()
+			return nil, fmt.Errorf("unable to listen on unix socket %s: %s", opts.UnixSocketPath, err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no port or unix socket to listen on")
+	}
+
+	return listeners, nil
+}
+
+// newTLSConfig creates the TLS configuration of the TCP listener of the
+// server, or returns nil if TLS is not enabled.
+func newTLSConfig(opts ServerOptions) (*tls.Config, error) {
+	if !opts.TLS && !opts.TLSVerify {
+		return nil, nil
+	}
+
+	options := tlsconfig.Options{
+		CertFile: opts.TLSCertFile,
+		KeyFile:  opts.TLSKeyFile,
+	}
+	if opts.TLSVerify {
+		if opts.TLSCAFile == "" {
+			return nil, fmt.Errorf("a CA certificate is required to verify clients")
+		}
+		options.CAFile = opts.TLSCAFile
+		options.ClientAuth = tls.RequireAndVerifyClientCert
+		options.ExclusiveRootPools = true
+	}
+
+	tlsConfig, err := tlsconfig.Server(options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure TLS: %s", err)
+	}
+	return tlsConfig, nil
 }
 
 // versionMatcher defines a variable matcher to be parsed by the router
@@ -90,8 +388,11 @@ const versionMatcher = "/v{version:[0-9.]+}"
 
 // Implementation loosely based on
 // https://github.com/moby/moby/blob/master/api/server/server.go#L171-L198
-func registerRoutes(r router.Router) http.Handler {
+func registerRoutes(r router.Router, extraHandlers map[string]http.Handler) http.Handler {
 	m := mux.NewRouter()
+	for path, handler := range extraHandlers {
+		m.Path(path).Methods("GET").Handler(handler)
+	}
 	for _, r := range r.Routes() {
 		f := makeHTTPHandler(r.Handler())
 		m.Path(versionMatcher + r.Path()).Methods(r.Method()).Handler(f)
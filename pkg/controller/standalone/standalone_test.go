@@ -0,0 +1,37 @@
+package standalone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestNewStackStore(t *testing.T) {
+	stack := types.Stack{
+		Spec: types.StackSpec{
+			Properties:     map[string]types.Property{"PASSWORD": {Sensitive: true}},
+			PropertyValues: []string{"PASSWORD=s3cret"},
+		},
+	}
+
+	// without a key, the stacks are encrypted with a random key.
+	s, err := newStackStore(nil)
+	require.NoError(t, err)
+	id, err := s.AddStack(stack, interfaces.SwarmStack{})
+	require.NoError(t, err)
+	got, err := s.GetStack(id)
+	require.NoError(t, err)
+	require.Equal(t, stack.Spec.PropertyValues, got.Spec.PropertyValues)
+
+	s, err = newStackStore([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	_, err = s.AddStack(stack, interfaces.SwarmStack{})
+	require.NoError(t, err)
+
+	_, err = newStackStore([]byte("short"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid encryption key")
+}
@@ -0,0 +1,346 @@
+package drift
+
+// Utility routines to detect the changes made to the resources of a stack
+// outside of the Stacks API.
+//
+// The reconciler labels each service it creates or updates with the hash of
+// the spec it applied. A service whose spec differs from the spec of its
+// stack, but which still carries the hash of that spec, has thus been changed
+// since the reconciler last applied it: it has drifted. A service carrying
+// the hash of another spec has merely not been updated to the latest spec of
+// its stack yet.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Field names of the service specs, as reported in types.ResourceDrift.
+const (
+	FieldImage      = "image"
+	FieldEntrypoint = "entrypoint"
+	FieldCommand    = "command"
+	FieldEnv        = "env"
+	FieldReplicas   = "replicas"
+	FieldLabels     = "labels"
+)
+
+// field is a field of a service spec which is compared on its own.
+type field struct {
+	name string
+	// get returns the value of the field in a spec.
+	get func(swarm.ServiceSpec) interface{}
+	// clear resets the field in a copy of a spec, so that the rest of the
+	// spec can be compared without it.
+	clear func(*swarm.ServiceSpec)
+}
+
+// fields are the fields of service specs reported individually. The changes to
+// the rest of the spec are reported per section of the spec.
+var fields = []field{
+	{
+		name: FieldImage,
+		get: func(spec swarm.ServiceSpec) interface{} {
+			if spec.TaskTemplate.ContainerSpec == nil {
+				return ""
+			}
+			return spec.TaskTemplate.ContainerSpec.Image
+		},
+		clear: func(spec *swarm.ServiceSpec) {
+			if spec.TaskTemplate.ContainerSpec != nil {
+				spec.TaskTemplate.ContainerSpec.Image = ""
+			}
+		},
+	},
+	{
+		name: FieldEntrypoint,
+		get: func(spec swarm.ServiceSpec) interface{} {
+			if spec.TaskTemplate.ContainerSpec == nil {
+				return []string(nil)
+			}
+			return spec.TaskTemplate.ContainerSpec.Command
+		},
+		clear: func(spec *swarm.ServiceSpec) {
+			if spec.TaskTemplate.ContainerSpec != nil {
+				spec.TaskTemplate.ContainerSpec.Command = nil
+			}
+		},
+	},
+	{
+		name: FieldCommand,
+		get: func(spec swarm.ServiceSpec) interface{} {
+			if spec.TaskTemplate.ContainerSpec == nil {
+				return []string(nil)
+			}
+			return spec.TaskTemplate.ContainerSpec.Args
+		},
+		clear: func(spec *swarm.ServiceSpec) {
+			if spec.TaskTemplate.ContainerSpec != nil {
+				spec.TaskTemplate.ContainerSpec.Args = nil
+			}
+		},
+	},
+	{
+		name: FieldEnv,
+		get: func(spec swarm.ServiceSpec) interface{} {
+			if spec.TaskTemplate.ContainerSpec == nil {
+				return []string(nil)
+			}
+			return spec.TaskTemplate.ContainerSpec.Env
+		},
+		clear: func(spec *swarm.ServiceSpec) {
+			if spec.TaskTemplate.ContainerSpec != nil {
+				spec.TaskTemplate.ContainerSpec.Env = nil
+			}
+		},
+	},
+	{
+		name: FieldReplicas,
+		get: func(spec swarm.ServiceSpec) interface{} {
+			if spec.Mode.Replicated == nil || spec.Mode.Replicated.Replicas == nil {
+				return nil
+			}
+			return *spec.Mode.Replicated.Replicas
+		},
+		clear: func(spec *swarm.ServiceSpec) {
+			if spec.Mode.Replicated != nil {
+				spec.Mode.Replicated = &swarm.ReplicatedService{}
+			}
+		},
+	},
+	{
+		name: FieldLabels,
+		get: func(spec swarm.ServiceSpec) interface{} {
+			return userLabels(spec.Annotations.Labels)
+		},
+		clear: func(spec *swarm.ServiceSpec) {
+			spec.Annotations.Labels = nil
+		},
+	},
+}
+
+// sections are the sections of service specs whose changes are reported as a
+// whole, once the fields have been cleared.
+var sections = []field{
+	{
+		name: "container_spec",
+		get: func(spec swarm.ServiceSpec) interface{} {
+			return spec.TaskTemplate.ContainerSpec
+		},
+	},
+	{
+		name: "task_template",
+		get: func(spec swarm.ServiceSpec) interface{} {
+			template := spec.TaskTemplate
+			template.ContainerSpec = nil
+			return template
+		},
+	},
+	{
+		name: "mode",
+		get: func(spec swarm.ServiceSpec) interface{} {
+			return spec.Mode
+		},
+	},
+	{
+		name: "update_config",
+		get: func(spec swarm.ServiceSpec) interface{} {
+			return spec.UpdateConfig
+		},
+	},
+	{
+		name: "rollback_config",
+		get: func(spec swarm.ServiceSpec) interface{} {
+			return spec.RollbackConfig
+		},
+	},
+	{
+		name: "endpoint_spec",
+		get: func(spec swarm.ServiceSpec) interface{} {
+			return spec.EndpointSpec
+		},
+	},
+}
+
+// adoptable are the fields whose changes can be folded back into the stack
+// spec.
+var adoptable = map[string]bool{
+	FieldImage:      true,
+	FieldEntrypoint: true,
+	FieldCommand:    true,
+	FieldEnv:        true,
+	FieldReplicas:   true,
+}
+
+// ServiceSpec returns the spec a service of the provided stack must have, given
+// the spec stored in the stack. The stored spec is labeled with the stack ID,
+// and with its hash.
+func ServiceSpec(stackID string, spec swarm.ServiceSpec) swarm.ServiceSpec {
+	labels := make(map[string]string, len(spec.Annotations.Labels)+2)
+	for k, v := range spec.Annotations.Labels {
+		labels[k] = v
+	}
+	labels[interfaces.StackLabel] = stackID
+	delete(labels, interfaces.SpecHashLabel)
+	spec.Annotations.Labels = labels
+
+	labels[interfaces.SpecHashLabel] = hash(spec)
+	return spec
+}
+
+// HasPendingSecrets returns true if a service spec refers to secrets without
+// an ID. These are the secrets the reconciler creates for the secret
+// properties of the stack, which are referred to by name in the stack.
+func HasPendingSecrets(spec swarm.ServiceSpec) bool {
+	if spec.TaskTemplate.ContainerSpec == nil {
+		return false
+	}
+	for _, ref := range spec.TaskTemplate.ContainerSpec.Secrets {
+		if ref.SecretID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSecretIDs returns a copy of a service spec whose references to secrets
+// without an ID are given the IDs of the secrets of the same names, keyed by
+// name in ids. The references to secrets missing from ids are left as they
+// are. It must be applied before ServiceSpec, as the IDs are part of the
+// hash of the spec.
+func WithSecretIDs(spec swarm.ServiceSpec, ids map[string]string) swarm.ServiceSpec {
+	if !HasPendingSecrets(spec) {
+		return spec
+	}
+	containerSpec := *spec.TaskTemplate.ContainerSpec
+	containerSpec.Secrets = make([]*swarm.SecretReference, 0, len(spec.TaskTemplate.ContainerSpec.Secrets))
+	for _, ref := range spec.TaskTemplate.ContainerSpec.Secrets {
+		if id, ok := ids[ref.SecretName]; ok && ref.SecretID == "" {
+			resolved := *ref
+			resolved.SecretID = id
+			ref = &resolved
+		}
+		containerSpec.Secrets = append(containerSpec.Secrets, ref)
+	}
+	spec.TaskTemplate.ContainerSpec = &containerSpec
+	return spec
+}
+
+// hash returns the hash of a service spec without its hash label.
+func hash(spec swarm.ServiceSpec) string {
+	// json.Marshal sorts the keys of maps, so the encoding is stable.
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		// a ServiceSpec always has a valid JSON encoding.
+		panic(fmt.Sprintf("unable to encode service spec: %s", err))
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Drifted returns true if the current spec of a service differs from its
+// desired spec, as returned by ServiceSpec, although it was last applied with
+// that desired spec.
+func Drifted(desired, current swarm.ServiceSpec) bool {
+	if reflect.DeepEqual(desired, current) {
+		return false
+	}
+	return current.Annotations.Labels[interfaces.SpecHashLabel] == desired.Annotations.Labels[interfaces.SpecHashLabel]
+}
+
+// ServiceChanges returns the fields of the current spec of a service which
+// differ from its desired spec.
+func ServiceChanges(desired, current swarm.ServiceSpec) []types.ResourceDrift {
+	var changes []types.ResourceDrift
+	add := func(name string, d, c interface{}) {
+		if reflect.DeepEqual(d, c) {
+			return
+		}
+		changes = append(changes, types.ResourceDrift{
+			Kind:    events.ServiceEventType,
+			Name:    desired.Annotations.Name,
+			Field:   name,
+			Current: format(c),
+			Desired: format(d),
+		})
+	}
+
+	for _, f := range fields {
+		add(f.name, f.get(desired), f.get(current))
+	}
+
+	// the other changes are reported by section, without the fields above,
+	// which have already been reported.
+	desired, current = clearFields(desired), clearFields(current)
+	for _, s := range sections {
+		add(s.name, s.get(desired), s.get(current))
+	}
+	return changes
+}
+
+// Adoptable returns true if the changes to the provided field can be folded
+// back into the stack spec.
+func Adoptable(field string) bool {
+	return adoptable[field]
+}
+
+// clearFields returns a copy of a spec without the fields reported
+// individually.
+func clearFields(spec swarm.ServiceSpec) swarm.ServiceSpec {
+	if spec.TaskTemplate.ContainerSpec != nil {
+		containerSpec := *spec.TaskTemplate.ContainerSpec
+		spec.TaskTemplate.ContainerSpec = &containerSpec
+	}
+	for _, f := range fields {
+		f.clear(&spec)
+	}
+	return spec
+}
+
+// userLabels returns the labels of a service, without those set by the
+// reconciler.
+func userLabels(labels map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range labels {
+		if k == interfaces.StackLabel || k == interfaces.SpecHashLabel {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// format returns the representation of a field value in a types.ResourceDrift.
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	case map[string]string:
+		pairs := make([]string, 0, len(v))
+		for k, val := range v {
+			pairs = append(pairs, k+"="+val)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, " ")
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,127 @@
+package drift
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func testServiceSpec() swarm.ServiceSpec {
+	replicas := uint64(2)
+	return swarm.ServiceSpec{
+		Annotations: swarm.Annotations{
+			Name:   "stack_web",
+			Labels: map[string]string{"com.docker.stack.namespace": "stack"},
+		},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: "nginx:1.15",
+				Env:   []string{"A=1"},
+			},
+		},
+		Mode: swarm.ServiceMode{
+			Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+		},
+	}
+}
+
+func TestServiceSpec(t *testing.T) {
+	spec := testServiceSpec()
+	desired := ServiceSpec("stackID", spec)
+
+	assert.Check(t, is.Equal("stackID", desired.Annotations.Labels[interfaces.StackLabel]))
+	assert.Check(t, desired.Annotations.Labels[interfaces.SpecHashLabel] != "")
+	// the stored spec is not modified
+	assert.Check(t, is.Len(spec.Annotations.Labels, 1))
+
+	// the desired spec is stable, even if the spec already has a hash
+	assert.Check(t, is.DeepEqual(desired, ServiceSpec("stackID", desired)))
+
+	other := testServiceSpec()
+	other.TaskTemplate.ContainerSpec.Image = "nginx:1.16"
+	assert.Check(t, desired.Annotations.Labels[interfaces.SpecHashLabel] != ServiceSpec("stackID", other).Annotations.Labels[interfaces.SpecHashLabel])
+}
+
+func TestWithSecretIDs(t *testing.T) {
+	spec := testServiceSpec()
+	assert.Check(t, !HasPendingSecrets(spec))
+	spec.TaskTemplate.ContainerSpec.Secrets = []*swarm.SecretReference{
+		{SecretName: "external", SecretID: "externalID"},
+		{SecretName: "stack_property_password_abc"},
+		{SecretName: "stack_property_token_def"},
+	}
+	assert.Check(t, HasPendingSecrets(spec))
+
+	resolved := WithSecretIDs(spec, map[string]string{
+		"external":                    "otherID",
+		"stack_property_password_abc": "passwordID",
+	})
+	assert.Check(t, is.DeepEqual([]*swarm.SecretReference{
+		{SecretName: "external", SecretID: "externalID"},
+		{SecretName: "stack_property_password_abc", SecretID: "passwordID"},
+		{SecretName: "stack_property_token_def"},
+	}, resolved.TaskTemplate.ContainerSpec.Secrets))
+	// the secret not created yet is still pending
+	assert.Check(t, HasPendingSecrets(resolved))
+	// the stored spec is not modified
+	assert.Check(t, is.Equal("", spec.TaskTemplate.ContainerSpec.Secrets[1].SecretID))
+}
+
+func TestDrifted(t *testing.T) {
+	desired := ServiceSpec("stackID", testServiceSpec())
+	assert.Check(t, !Drifted(desired, desired))
+
+	// the service was changed after the spec was applied
+	current := ServiceSpec("stackID", testServiceSpec())
+	current.TaskTemplate.ContainerSpec.Image = "nginx:1.16"
+	assert.Check(t, Drifted(desired, current))
+
+	// the service hasn't been updated to a new spec of the stack yet
+	updated := testServiceSpec()
+	updated.TaskTemplate.ContainerSpec.Image = "nginx:1.17"
+	assert.Check(t, !Drifted(ServiceSpec("stackID", updated), current))
+}
+
+func TestServiceChanges(t *testing.T) {
+	desired := ServiceSpec("stackID", testServiceSpec())
+
+	current := ServiceSpec("stackID", testServiceSpec())
+	replicas := uint64(5)
+	current.Mode.Replicated.Replicas = &replicas
+	current.TaskTemplate.ContainerSpec.Image = "nginx:1.16"
+	current.TaskTemplate.ContainerSpec.User = "nobody"
+	current.Annotations.Labels["owner"] = "ops"
+
+	changes := ServiceChanges(desired, current)
+	assert.Check(t, is.DeepEqual([]types.ResourceDrift{
+		{Kind: "service", Name: "stack_web", Field: FieldImage, Current: "nginx:1.16", Desired: "nginx:1.15"},
+		{Kind: "service", Name: "stack_web", Field: FieldReplicas, Current: "5", Desired: "2"},
+		{
+			Kind:    "service",
+			Name:    "stack_web",
+			Field:   FieldLabels,
+			Current: "com.docker.stack.namespace=stack owner=ops",
+			Desired: "com.docker.stack.namespace=stack",
+		},
+		{
+			Kind:    "service",
+			Name:    "stack_web",
+			Field:   "container_spec",
+			Current: `{"User":"nobody"}`,
+			Desired: `{}`,
+		},
+	}, changes))
+
+	// the specs themselves are not modified
+	assert.Check(t, is.Equal("nginx:1.16", current.TaskTemplate.ContainerSpec.Image))
+	assert.Check(t, is.Equal(uint64(5), *current.Mode.Replicated.Replicas))
+
+	assert.Check(t, Adoptable(FieldImage))
+	assert.Check(t, !Adoptable(FieldLabels))
+}
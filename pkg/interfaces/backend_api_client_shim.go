@@ -9,6 +9,7 @@ import (
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 
@@ -128,6 +129,103 @@ func (c *BackendAPIClientShim) UpdateStack(id string, spec types.StackSpec, vers
 	return err
 }
 
+// PatchStack applies a merge patch to a stack.
+func (c *BackendAPIClientShim) PatchStack(id string, patch []byte, version uint64) error {
+	err := c.StacksBackend.PatchStack(id, patch, version)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// SetServiceImage sets the image of a service of a stack.
+func (c *BackendAPIClientShim) SetServiceImage(id string, service string, image string, version uint64) error {
+	err := c.StacksBackend.SetServiceImage(id, service, image, version)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// ScaleService scales a service of a stack.
+func (c *BackendAPIClientShim) ScaleService(id string, service string, replicas uint64) error {
+	err := c.StacksBackend.ScaleService(id, service, replicas)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// RedeployStack forces the services of a stack to be redeployed.
+func (c *BackendAPIClientShim) RedeployStack(id string, services []string) error {
+	err := c.StacksBackend.RedeployStack(id, services)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// PauseStack pauses the reconciliation of a stack.
+func (c *BackendAPIClientShim) PauseStack(id string) error {
+	err := c.StacksBackend.PauseStack(id)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// ResumeStack resumes the reconciliation of a stack.
+func (c *BackendAPIClientShim) ResumeStack(id string) error {
+	err := c.StacksBackend.ResumeStack(id)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// AdoptServiceSpec folds the changes made to a service back into the spec of
+// its stack.
+func (c *BackendAPIClientShim) AdoptServiceSpec(id string, spec swarm.ServiceSpec) error {
+	err := c.StacksBackend.AdoptServiceSpec(id, spec)
+	if err != nil {
+		return err
+	}
+
+	c.emitStackUpdate(id)
+	return nil
+}
+
+// emitStackUpdate asynchronously emits an update event for a stack.
+func (c *BackendAPIClientShim) emitStackUpdate(id string) {
+	c.LogStackEvent(id, "update", nil)
+}
+
+// LogStackEvent asynchronously emits an event for a stack.
+func (c *BackendAPIClientShim) LogStackEvent(id, action string, attributes map[string]string) {
+	go func() {
+		c.stackEvents <- events.Message{
+			Type:   StackEventType,
+			Action: action,
+			Actor: events.Actor{
+				ID:         id,
+				Attributes: attributes,
+			},
+		}
+	}()
+}
+
 // DeleteStack deletes a stack.
 func (c *BackendAPIClientShim) DeleteStack(id string) error {
 	err := c.StacksBackend.DeleteStack(id)
@@ -135,7 +233,9 @@ func (c *BackendAPIClientShim) DeleteStack(id string) error {
 		c.stackEvents <- events.Message{
 			Type:   "stack",
 			Action: "delete",
-			ID:     id,
+			Actor: events.Actor{
+				ID: id,
+			},
 		}
 	}()
 	return err
@@ -1,10 +1,12 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 
 	"github.com/docker/docker/api/server/router/network"
 	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/backend"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
@@ -20,13 +22,24 @@ type StacksBackend interface {
 	ListStacks() ([]types.Stack, error)
 	UpdateStack(id string, spec types.StackSpec, version uint64) error
 	DeleteStack(id string) error
+	PatchStack(id string, patch []byte, version uint64) error
+	SetServiceImage(id string, service string, image string, version uint64) error
+	ScaleService(id string, service string, replicas uint64) error
+	RedeployStack(id string, services []string) error
+	PauseStack(id string) error
+	ResumeStack(id string) error
+	WaitStack(ctx context.Context, id string, condition types.StackWaitCondition) (types.StackStatus, error)
+	StackLogs(ctx context.Context, id string, options types.StackLogsOptions) (<-chan *backend.LogMessage, error)
 
 	// The following operations are only used by the Reconciler and not
 	// exposed via the Stacks API.
 	GetSwarmStack(id string) (SwarmStack, error)
 	ListSwarmStacks() ([]SwarmStack, error)
+	GetStackStatus(id string) (types.StackStatus, error)
+	AdoptServiceSpec(id string, spec swarm.ServiceSpec) error
 
 	ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error)
+	StackWarnings(spec types.StackSpec) []types.Warning
 }
 
 // SwarmResourceBackend is a subset of the swarm.Backend interface,
@@ -47,6 +60,7 @@ type SwarmResourceBackend interface {
 	CreateService(swarm.ServiceSpec, string, bool) (*dockerTypes.ServiceCreateResponse, error)
 	UpdateService(string, uint64, swarm.ServiceSpec, dockerTypes.ServiceUpdateOptions, bool) (*dockerTypes.ServiceUpdateResponse, error)
 	RemoveService(string) error
+	ServiceLogs(context.Context, *backend.LogSelector, *dockerTypes.ContainerLogsOptions) (<-chan *backend.LogMessage, error)
 	GetTasks(dockerTypes.TaskListOptions) ([]swarm.Task, error)
 	GetTask(string) (swarm.Task, error)
 	GetSecrets(opts dockerTypes.SecretListOptions) ([]swarm.Secret, error)
@@ -75,6 +89,10 @@ type BackendClient interface {
 	// system.Backend interface.
 	SubscribeToEvents(since, until time.Time, ef filters.Args) ([]events.Message, chan interface{})
 	UnsubscribeFromEvents(chan interface{})
+
+	// LogStackEvent publishes an event of type StackEventType to the event
+	// stream.
+	LogStackEvent(id, action string, attributes map[string]string)
 }
 
 // StackStore defines an interface to an arbitrary store which is able
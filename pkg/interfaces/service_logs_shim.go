@@ -0,0 +1,195 @@
+package interfaces
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ServiceLogs returns the logs of the services and tasks in the selector,
+// multiplexed into a single channel of log messages. The channel is closed
+// once all log streams have ended, or the context is done.
+func (c *SwarmResourceAPIClientShim) ServiceLogs(ctx context.Context, selector *backend.LogSelector, config *dockerTypes.ContainerLogsOptions) (<-chan *backend.LogMessage, error) {
+	// The timestamps and details of each line are required to fill in the
+	// LogMessage, so request them regardless of what the caller asked for.
+	options := *config
+	options.Timestamps = true
+	options.Details = true
+
+	var streams []logStream
+	for _, serviceID := range selector.Services {
+		svc, _, err := c.dclient.ServiceInspectWithRaw(ctx, serviceID, dockerTypes.ServiceInspectOptions{})
+		if err != nil {
+			closeLogStreams(streams)
+			if client.IsErrNotFound(err) {
+				return nil, errdefs.NotFound(err)
+			}
+			return nil, err
+		}
+		rc, err := c.dclient.ServiceLogs(ctx, svc.ID, options)
+		if err != nil {
+			closeLogStreams(streams)
+			return nil, err
+		}
+		containerSpec := svc.Spec.TaskTemplate.ContainerSpec
+		streams = append(streams, logStream{
+			rc:  rc,
+			tty: containerSpec != nil && containerSpec.TTY,
+		})
+	}
+
+	for _, taskID := range selector.Tasks {
+		task, _, err := c.dclient.TaskInspectWithRaw(ctx, taskID)
+		if err != nil {
+			closeLogStreams(streams)
+			if client.IsErrNotFound(err) {
+				return nil, errdefs.NotFound(err)
+			}
+			return nil, err
+		}
+		rc, err := c.dclient.TaskLogs(ctx, task.ID, options)
+		if err != nil {
+			closeLogStreams(streams)
+			return nil, err
+		}
+		containerSpec := task.Spec.ContainerSpec
+		streams = append(streams, logStream{
+			rc:  rc,
+			tty: containerSpec != nil && containerSpec.TTY,
+		})
+	}
+
+	msgs := make(chan *backend.LogMessage)
+	var wg sync.WaitGroup
+	for _, stream := range streams {
+		wg.Add(1)
+		go func(stream logStream) {
+			defer wg.Done()
+			defer stream.rc.Close()
+			stream.copyTo(ctx, msgs)
+		}(stream)
+	}
+
+	go func() {
+		wg.Wait()
+		close(msgs)
+	}()
+
+	return msgs, nil
+}
+
+// logStream is a single log stream returned by the Docker API.
+type logStream struct {
+	rc io.ReadCloser
+	// tty indicates that the stream is a raw stream, instead of a stream
+	// multiplexed with stdcopy.
+	tty bool
+}
+
+func closeLogStreams(streams []logStream) {
+	for _, stream := range streams {
+		stream.rc.Close()
+	}
+}
+
+// copyTo parses the log lines of the stream into log messages, and sends them
+// to the provided channel until the stream ends or the context is done.
+func (s logStream) copyTo(ctx context.Context, msgs chan<- *backend.LogMessage) {
+	stdout := &logLineWriter{ctx: ctx, source: "stdout", msgs: msgs}
+	stderr := &logLineWriter{ctx: ctx, source: "stderr", msgs: msgs}
+
+	var err error
+	if s.tty {
+		_, err = io.Copy(stdout, s.rc)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, s.rc)
+	}
+
+	if err != nil && ctx.Err() == nil {
+		select {
+		case msgs <- &backend.LogMessage{Err: err}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// logLineWriter is an io.Writer which parses each line written to it into a
+// backend.LogMessage. Lines are expected to be prefixed with a timestamp and
+// the log details, which is the format produced by the Docker API when both
+// the timestamps and details options are set.
+type logLineWriter struct {
+	ctx    context.Context
+	source string
+	msgs   chan<- *backend.LogMessage
+	buf    bytes.Buffer
+}
+
+// Write implements the io.Writer interface.
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		// A line which is not terminated by a newline has not been fully
+		// written yet, so leave it in the buffer.
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf.Next(i + 1)
+
+		select {
+		case w.msgs <- parseLogLine(w.source, line[:i]):
+		case <-w.ctx.Done():
+			return 0, w.ctx.Err()
+		}
+	}
+}
+
+// parseLogLine parses a log line of the form
+// "<timestamp> <key>=<value>,<key>=<value> <line>".
+func parseLogLine(source string, line []byte) *backend.LogMessage {
+	msg := &backend.LogMessage{
+		Source: source,
+	}
+
+	parts := strings.SplitN(string(line), " ", 3)
+	if len(parts) != 3 {
+		msg.Line = append(append([]byte{}, line...), '\n')
+		return msg
+	}
+
+	// A line which doesn't start with a timestamp is not in the expected
+	// format, so pass it on verbatim.
+	timestamp, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		msg.Line = append(append([]byte{}, line...), '\n')
+		return msg
+	}
+	msg.Timestamp = timestamp
+	for _, pair := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			continue
+		}
+		value, err := url.QueryUnescape(kv[1])
+		if err != nil {
+			continue
+		}
+		msg.Attrs = append(msg.Attrs, backend.LogAttr{Key: key, Value: value})
+	}
+	msg.Line = []byte(parts[2] + "\n")
+	return msg
+}
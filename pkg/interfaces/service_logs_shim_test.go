@@ -0,0 +1,43 @@
+package interfaces
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLineWriter(t *testing.T) {
+	require := require.New(t)
+
+	msgs := make(chan *backend.LogMessage, 2)
+	w := &logLineWriter{
+		ctx:    context.Background(),
+		source: "stdout",
+		msgs:   msgs,
+	}
+
+	// Lines are only parsed once they have been fully written
+	_, err := w.Write([]byte("2019-01-02T03:04:05.000000006Z com.docker.swarm.node.id=node1,com.docker.swarm.task.id=task%3D1 hello "))
+	require.NoError(err)
+	require.Len(msgs, 0)
+
+	_, err = w.Write([]byte("world\nnot a log line\n"))
+	require.NoError(err)
+	require.Len(msgs, 2)
+
+	msg := <-msgs
+	require.Equal("stdout", msg.Source)
+	require.Equal("hello world\n", string(msg.Line))
+	require.Equal(time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC), msg.Timestamp)
+	require.Equal([]backend.LogAttr{
+		{Key: "com.docker.swarm.node.id", Value: "node1"},
+		{Key: "com.docker.swarm.task.id", Value: "task=1"},
+	}, msg.Attrs)
+
+	msg = <-msgs
+	require.Equal("not a log line\n", string(msg.Line))
+	require.Empty(msg.Attrs)
+}
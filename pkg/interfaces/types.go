@@ -3,6 +3,8 @@ package interfaces
 import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
+
+	stacktypes "github.com/docker/stacks/pkg/types"
 )
 
 const (
@@ -10,6 +12,15 @@ const (
 	StackEventType = "stack"
 	// StackLabel is a label on objects indicating the stack that it belongs to
 	StackLabel = "com.docker.stacks.stack_id"
+	// SpecHashLabel is a label on objects indicating the hash of the spec
+	// they were last created or updated with by the reconciler
+	SpecHashLabel = "com.docker.stacks.spec_hash"
+	// PropertyLabel is a label on the secrets materializing the values of the
+	// secret properties of a stack, indicating the name of their property
+	PropertyLabel = "com.docker.stacks.property"
+	// StackDriftAction is the value of Action in an events.Message for
+	// stacks, reporting a resource which has drifted from the stack spec
+	StackDriftAction = "drift"
 )
 
 // SwarmStack represents a Stack with all of its elements converted to Engine
@@ -35,6 +46,25 @@ type SwarmStackSpec struct {
 	Networks map[string]types.NetworkCreate
 	Secrets  []swarm.SecretSpec
 	Configs  []swarm.ConfigSpec
+	// ForceUpdates is a map of service name -> the number of times the
+	// service has been redeployed. It is kept in the SwarmStackSpec so that
+	// the TaskTemplate.ForceUpdate of the services survives updates of the
+	// stack, which regenerate the service specs.
+	ForceUpdates map[string]uint64
+	// Dependencies is a map of service name -> the names of the services it
+	// depends on. Services are sorted so that they come after the services
+	// they depend on.
+	Dependencies map[string][]string
+	// WaitForDependencies is true if services must only be rolled out once
+	// the services they depend on are running.
+	WaitForDependencies bool
 	// there is no "Volumes" in a SwarmStackSpec -- Swarm has no concept of
 	// volumes
+
+	// DriftPolicy is the policy applied by the reconciler to the resources
+	// of the stack which have drifted from the spec.
+	DriftPolicy stacktypes.DriftPolicy
+	// Paused is true if the reconciliation of the stack is paused. It is
+	// kept in the SwarmStackSpec so that it survives updates of the stack.
+	Paused bool
 }
@@ -0,0 +1,88 @@
+package mergepatch
+
+// Utility routines to apply JSON merge patches, as defined by RFC 7386, to
+// StackSpecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Apply applies a JSON merge patch to a JSON document, and returns the
+// patched document.
+func Apply(doc []byte, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %s", err)
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %s", err)
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+// ApplyStackSpec applies a JSON merge patch to the JSON representation of a
+// StackSpec, and returns the patched StackSpec. The original StackSpec is not
+// modified.
+func ApplyStackSpec(spec types.StackSpec, patch []byte) (types.StackSpec, error) {
+	doc, err := json.Marshal(spec)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+
+	patched, err := Apply(doc, patch)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+
+	var result types.StackSpec
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return types.StackSpec{}, fmt.Errorf("patched stack spec is invalid: %s", err)
+	}
+	return result, nil
+}
+
+// decode decodes a JSON value, retaining numbers as json.Number so that
+// integers which don't fit in a float64 survive the round trip.
+func decode(data []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// merge implements the MergePatch function of RFC 7386. Objects are merged
+// recursively, null values remove the corresponding member of the target, and
+// any other value, including arrays, replaces the target wholesale.
+func merge(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = merge(targetObj[k], v)
+	}
+	return targetObj
+}
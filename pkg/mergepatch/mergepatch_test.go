@@ -0,0 +1,66 @@
+package mergepatch
+
+import (
+	"testing"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestApply(t *testing.T) {
+	// Test cases from the appendix of RFC 7386
+	for _, tc := range []struct {
+		doc, patch, result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// Large integers are not rounded through float64
+		{`{"a":18446744073709551615}`, `{"b":1}`, `{"a":18446744073709551615,"b":1}`},
+	} {
+		result, err := Apply([]byte(tc.doc), []byte(tc.patch))
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(string(result), tc.result), "doc %s, patch %s", tc.doc, tc.patch)
+	}
+
+	_, err := Apply([]byte(`{}`), []byte(`{"a":`))
+	assert.ErrorContains(t, err, "invalid merge patch")
+}
+
+func TestApplyStackSpec(t *testing.T) {
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			{
+				Name:  "web",
+				Image: "nginx:1.14",
+			},
+		},
+		PropertyValues: []string{"A=1"},
+	}
+
+	patched, err := ApplyStackSpec(spec, []byte(`{"property_values":null}`))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(patched.PropertyValues, 0))
+	assert.Check(t, is.Equal(patched.Services[0].Image, "nginx:1.14"))
+
+	// The original spec is not modified
+	assert.Check(t, is.Len(spec.PropertyValues, 1))
+
+	_, err = ApplyStackSpec(spec, []byte(`{"services":"web"}`))
+	assert.ErrorContains(t, err, "patched stack spec is invalid")
+}
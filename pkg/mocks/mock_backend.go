@@ -5,7 +5,9 @@
 package mocks
 
 import (
+	context "context"
 	types "github.com/docker/docker/api/types"
+	backend "github.com/docker/docker/api/types/backend"
 	events "github.com/docker/docker/api/types/events"
 	filters "github.com/docker/docker/api/types/filters"
 	swarm "github.com/docker/docker/api/types/swarm"
@@ -39,6 +41,20 @@ func (m *MockBackendClient) EXPECT() *MockBackendClientMockRecorder {
 	return m.recorder
 }
 
+// AdoptServiceSpec mocks base method
+func (m *MockBackendClient) AdoptServiceSpec(arg0 string, arg1 swarm.ServiceSpec) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdoptServiceSpec", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdoptServiceSpec indicates an expected call of AdoptServiceSpec
+func (mr *MockBackendClientMockRecorder) AdoptServiceSpec(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdoptServiceSpec", reflect.TypeOf((*MockBackendClient)(nil).AdoptServiceSpec), arg0, arg1)
+}
+
 // CreateConfig mocks base method
 func (m *MockBackendClient) CreateConfig(arg0 swarm.ConfigSpec) (string, error) {
 	m.ctrl.T.Helper()
@@ -293,6 +309,21 @@ func (mr *MockBackendClientMockRecorder) GetStack(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStack", reflect.TypeOf((*MockBackendClient)(nil).GetStack), arg0)
 }
 
+// GetStackStatus mocks base method
+func (m *MockBackendClient) GetStackStatus(arg0 string) (types0.StackStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStackStatus", arg0)
+	ret0, _ := ret[0].(types0.StackStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStackStatus indicates an expected call of GetStackStatus
+func (mr *MockBackendClientMockRecorder) GetStackStatus(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStackStatus", reflect.TypeOf((*MockBackendClient)(nil).GetStackStatus), arg0)
+}
+
 // GetSwarmStack mocks base method
 func (m *MockBackendClient) GetSwarmStack(arg0 string) (interfaces.SwarmStack, error) {
 	m.ctrl.T.Helper()
@@ -382,6 +413,18 @@ func (mr *MockBackendClientMockRecorder) ListSwarmStacks() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSwarmStacks", reflect.TypeOf((*MockBackendClient)(nil).ListSwarmStacks))
 }
 
+// LogStackEvent mocks base method
+func (m *MockBackendClient) LogStackEvent(arg0, arg1 string, arg2 map[string]string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "LogStackEvent", arg0, arg1, arg2)
+}
+
+// LogStackEvent indicates an expected call of LogStackEvent
+func (mr *MockBackendClientMockRecorder) LogStackEvent(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogStackEvent", reflect.TypeOf((*MockBackendClient)(nil).LogStackEvent), arg0, arg1, arg2)
+}
+
 // ParseComposeInput mocks base method
 func (m *MockBackendClient) ParseComposeInput(arg0 types0.ComposeInput) (*types0.StackCreate, error) {
 	m.ctrl.T.Helper()
@@ -397,6 +440,48 @@ func (mr *MockBackendClientMockRecorder) ParseComposeInput(arg0 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseComposeInput", reflect.TypeOf((*MockBackendClient)(nil).ParseComposeInput), arg0)
 }
 
+// PatchStack mocks base method
+func (m *MockBackendClient) PatchStack(arg0 string, arg1 []byte, arg2 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchStack", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchStack indicates an expected call of PatchStack
+func (mr *MockBackendClientMockRecorder) PatchStack(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchStack", reflect.TypeOf((*MockBackendClient)(nil).PatchStack), arg0, arg1, arg2)
+}
+
+// PauseStack mocks base method
+func (m *MockBackendClient) PauseStack(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseStack", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PauseStack indicates an expected call of PauseStack
+func (mr *MockBackendClientMockRecorder) PauseStack(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseStack", reflect.TypeOf((*MockBackendClient)(nil).PauseStack), arg0)
+}
+
+// RedeployStack mocks base method
+func (m *MockBackendClient) RedeployStack(arg0 string, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeployStack", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedeployStack indicates an expected call of RedeployStack
+func (mr *MockBackendClientMockRecorder) RedeployStack(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeployStack", reflect.TypeOf((*MockBackendClient)(nil).RedeployStack), arg0, arg1)
+}
+
 // RemoveConfig mocks base method
 func (m *MockBackendClient) RemoveConfig(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -453,6 +538,92 @@ func (mr *MockBackendClientMockRecorder) RemoveService(arg0 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveService", reflect.TypeOf((*MockBackendClient)(nil).RemoveService), arg0)
 }
 
+// ResumeStack mocks base method
+func (m *MockBackendClient) ResumeStack(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeStack", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeStack indicates an expected call of ResumeStack
+func (mr *MockBackendClientMockRecorder) ResumeStack(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeStack", reflect.TypeOf((*MockBackendClient)(nil).ResumeStack), arg0)
+}
+
+// ScaleService mocks base method
+func (m *MockBackendClient) ScaleService(arg0, arg1 string, arg2 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScaleService", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScaleService indicates an expected call of ScaleService
+func (mr *MockBackendClientMockRecorder) ScaleService(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScaleService", reflect.TypeOf((*MockBackendClient)(nil).ScaleService), arg0, arg1, arg2)
+}
+
+// ServiceLogs mocks base method
+func (m *MockBackendClient) ServiceLogs(arg0 context.Context, arg1 *backend.LogSelector, arg2 *types.ContainerLogsOptions) (<-chan *backend.LogMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServiceLogs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan *backend.LogMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServiceLogs indicates an expected call of ServiceLogs
+func (mr *MockBackendClientMockRecorder) ServiceLogs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServiceLogs", reflect.TypeOf((*MockBackendClient)(nil).ServiceLogs), arg0, arg1, arg2)
+}
+
+// SetServiceImage mocks base method
+func (m *MockBackendClient) SetServiceImage(arg0, arg1, arg2 string, arg3 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetServiceImage", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetServiceImage indicates an expected call of SetServiceImage
+func (mr *MockBackendClientMockRecorder) SetServiceImage(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetServiceImage", reflect.TypeOf((*MockBackendClient)(nil).SetServiceImage), arg0, arg1, arg2, arg3)
+}
+
+// StackLogs mocks base method
+func (m *MockBackendClient) StackLogs(arg0 context.Context, arg1 string, arg2 types0.StackLogsOptions) (<-chan *backend.LogMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StackLogs", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan *backend.LogMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StackLogs indicates an expected call of StackLogs
+func (mr *MockBackendClientMockRecorder) StackLogs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StackLogs", reflect.TypeOf((*MockBackendClient)(nil).StackLogs), arg0, arg1, arg2)
+}
+
+// StackWarnings mocks base method
+func (m *MockBackendClient) StackWarnings(arg0 types0.StackSpec) []types0.Warning {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StackWarnings", arg0)
+	ret0, _ := ret[0].([]types0.Warning)
+	return ret0
+}
+
+// StackWarnings indicates an expected call of StackWarnings
+func (mr *MockBackendClientMockRecorder) StackWarnings(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StackWarnings", reflect.TypeOf((*MockBackendClient)(nil).StackWarnings), arg0)
+}
+
 // SubscribeToEvents mocks base method
 func (m *MockBackendClient) SubscribeToEvents(arg0, arg1 time.Time, arg2 filters.Args) ([]events.Message, chan interface{}) {
 	m.ctrl.T.Helper()
@@ -536,3 +707,18 @@ func (mr *MockBackendClientMockRecorder) UpdateStack(arg0, arg1, arg2 interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStack", reflect.TypeOf((*MockBackendClient)(nil).UpdateStack), arg0, arg1, arg2)
 }
+
+// WaitStack mocks base method
+func (m *MockBackendClient) WaitStack(arg0 context.Context, arg1 string, arg2 types0.StackWaitCondition) (types0.StackStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitStack", arg0, arg1, arg2)
+	ret0, _ := ret[0].(types0.StackStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WaitStack indicates an expected call of WaitStack
+func (mr *MockBackendClientMockRecorder) WaitStack(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitStack", reflect.TypeOf((*MockBackendClient)(nil).WaitStack), arg0, arg1, arg2)
+}
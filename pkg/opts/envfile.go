@@ -1,6 +1,7 @@
 package opts
 
 import (
+	"bytes"
 	"os"
 )
 
@@ -20,3 +21,10 @@ import (
 func ParseEnvFile(filename string) ([]string, error) {
 	return parseKeyValueFile(filename, os.LookupEnv)
 }
+
+// ParseEnvFileContent parses the content of the named env file, like
+// ParseEnvFile. Variables without a value are dropped, as there is no
+// environment to take their value from.
+func ParseEnvFileContent(filename string, content []byte) ([]string, error) {
+	return parseKeyValues(filename, bytes.NewReader(content), nil)
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"unicode"
@@ -28,8 +29,14 @@ func parseKeyValueFile(filename string, emptyFn func(string) (string, bool)) ([]
 	}
 	defer fh.Close()
 
+	return parseKeyValues(filename, fh, emptyFn)
+}
+
+// parseKeyValues parses the lines read from r, reporting errors in the named
+// file.
+func parseKeyValues(filename string, r io.Reader, emptyFn func(string) (string, bool)) ([]string, error) {
 	lines := []string{}
-	scanner := bufio.NewScanner(fh)
+	scanner := bufio.NewScanner(r)
 	currentLine := 0
 	utf8bom := []byte{0xEF, 0xBB, 0xBF}
 	for scanner.Scan() {
@@ -0,0 +1,342 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/interfaces"
+)
+
+// kinds are the kinds of objects held by the cache.
+var kinds = []string{
+	interfaces.StackEventType,
+	events.ServiceEventType,
+	events.NetworkEventType,
+	events.SecretEventType,
+	events.ConfigEventType,
+}
+
+// Client is the subset of interfaces.BackendClient methods needed to fill the
+// Cache.
+type Client interface {
+	ListSwarmStacks() ([]interfaces.SwarmStack, error)
+	GetSwarmStack(string) (interfaces.SwarmStack, error)
+	GetServices(dockerTypes.ServiceListOptions) ([]swarm.Service, error)
+	GetService(string, bool) (swarm.Service, error)
+	GetNetworks(filters.Args) ([]dockerTypes.NetworkResource, error)
+	GetNetwork(string) (dockerTypes.NetworkResource, error)
+	GetSecrets(dockerTypes.SecretListOptions) ([]swarm.Secret, error)
+	GetSecret(string) (swarm.Secret, error)
+	GetConfigs(dockerTypes.ConfigListOptions) ([]swarm.Config, error)
+	GetConfig(string) (swarm.Config, error)
+}
+
+// Cache is an in-memory cache of the stacks, and of the services, networks,
+// secrets and configs which carry a stack label. Objects are indexed by ID,
+// name and stack ID.
+//
+// The Cache is primed by Sync, which lists all of the objects, and kept up to
+// date by calling Refresh with the objects of each event. Sync should also be
+// called periodically: it then counts the objects which were out of sync, and
+// thus missed by the event stream.
+//
+// Cache implements the dispatcher.StackResolver interface.
+type Cache struct {
+	cli Client
+
+	mu     sync.RWMutex
+	stores map[string]*store
+	// unowned contains the objects known not to belong to any stack, keyed
+	// by kind and ID, so that they don't need to be fetched again each time
+	// they are resolved.
+	unowned map[string]struct{}
+
+	synced          bool
+	lastSync        time.Time
+	inconsistencies map[string]int
+}
+
+// New creates a new, empty Cache which is filled using the provided Client.
+func New(cli Client) *Cache {
+	c := &Cache{
+		cli:             cli,
+		stores:          map[string]*store{},
+		unowned:         map[string]struct{}{},
+		inconsistencies: map[string]int{},
+	}
+	for _, kind := range kinds {
+		c.stores[kind] = newStore()
+	}
+	return c
+}
+
+// Sync lists all of the objects, and replaces the content of the cache with
+// them. Unless this is the first Sync, the number of objects which were out
+// of sync is recorded for each kind, and logged. They may include objects
+// which changed while they were being listed.
+func (c *Cache) Sync() error {
+	stores := map[string]*store{}
+	for _, kind := range kinds {
+		entries, err := c.list(kind)
+		if err != nil {
+			return fmt.Errorf("unable to list objects of kind %s: %s", kind, err)
+		}
+		s := newStore()
+		for _, e := range entries {
+			s.put(e)
+		}
+		stores[kind] = s
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced {
+		for _, kind := range kinds {
+			n := diff(c.stores[kind], stores[kind])
+			c.inconsistencies[kind] = n
+			if n > 0 {
+				logrus.Warnf("%d objects of kind %s were out of sync in the cache", n, kind)
+				inconsistenciesTotal.WithLabelValues(kind).Add(float64(n))
+			}
+		}
+	}
+	c.stores = stores
+	c.unowned = map[string]struct{}{}
+	c.synced = true
+	c.lastSync = time.Now()
+	return nil
+}
+
+// Synced returns true once the cache has been synced.
+func (c *Cache) Synced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// Refresh fetches an object again, and updates the cache with it. It returns
+// the stack the object belongs to, or an empty string if it belongs to no
+// stack or no longer exists. If the object cannot be fetched, the cached
+// stack of the object is returned.
+func (c *Cache) Refresh(kind, id string) string {
+	e, ok, err := c.fetch(kind, id)
+	if err != nil {
+		logrus.Debugf("unable to refresh %s %s in the cache: %s", kind, id, err)
+		return c.cachedStackOf(kind, id)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, known := c.stores[kind]
+	if !known {
+		return ""
+	}
+	if !ok {
+		s.delete(id)
+		c.unowned[kind+"/"+id] = struct{}{}
+		return ""
+	}
+	delete(c.unowned, kind+"/"+id)
+	s.put(e)
+	return e.stack
+}
+
+// Forget removes an object, by ID or name, from the cache once it has been
+// removed.
+func (c *Cache) Forget(kind, idOrName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.unowned, kind+"/"+idOrName)
+	s, ok := c.stores[kind]
+	if !ok {
+		return
+	}
+	if e, ok := s.get(idOrName); ok {
+		s.delete(e.id)
+	}
+}
+
+// StackOf returns the stack an object belongs to, fetching the object if it
+// isn't cached. It implements the dispatcher.StackResolver interface.
+func (c *Cache) StackOf(kind, id string) string {
+	// stacks belong to themselves
+	if kind == interfaces.StackEventType {
+		return id
+	}
+	c.mu.RLock()
+	_, unowned := c.unowned[kind+"/"+id]
+	e, ok := entry{}, false
+	if s, known := c.stores[kind]; known {
+		e, ok = s.get(id)
+	}
+	c.mu.RUnlock()
+	switch {
+	case unowned:
+		return ""
+	case ok:
+		return e.stack
+	default:
+		return c.Refresh(kind, id)
+	}
+}
+
+func (c *Cache) cachedStackOf(kind, id string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if s, ok := c.stores[kind]; ok {
+		if e, ok := s.get(id); ok {
+			return e.stack
+		}
+	}
+	return ""
+}
+
+// stackLabelFilter filters the objects which carry a stack label.
+func stackLabelFilter() filters.Args {
+	return filters.NewArgs(filters.Arg("label", interfaces.StackLabel))
+}
+
+// list lists all of the objects of a kind which belong to a stack.
+func (c *Cache) list(kind string) ([]entry, error) {
+	var entries []entry
+	switch kind {
+	case interfaces.StackEventType:
+		stacks, err := c.cli.ListSwarmStacks()
+		if err != nil {
+			return nil, err
+		}
+		for _, stack := range stacks {
+			entries = append(entries, stackEntry(stack))
+		}
+	case events.ServiceEventType:
+		services, err := c.cli.GetServices(dockerTypes.ServiceListOptions{Filters: stackLabelFilter()})
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			entries = append(entries, serviceEntry(service))
+		}
+	case events.NetworkEventType:
+		networks, err := c.cli.GetNetworks(stackLabelFilter())
+		if err != nil {
+			return nil, err
+		}
+		for _, network := range networks {
+			entries = append(entries, networkEntry(network))
+		}
+	case events.SecretEventType:
+		secrets, err := c.cli.GetSecrets(dockerTypes.SecretListOptions{Filters: stackLabelFilter()})
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets {
+			entries = append(entries, secretEntry(secret))
+		}
+	case events.ConfigEventType:
+		configs, err := c.cli.GetConfigs(dockerTypes.ConfigListOptions{Filters: stackLabelFilter()})
+		if err != nil {
+			return nil, err
+		}
+		for _, config := range configs {
+			entries = append(entries, configEntry(config))
+		}
+	}
+	return entries, nil
+}
+
+// fetch fetches an object. It returns false if the object doesn't exist, or
+// doesn't belong to a stack.
+func (c *Cache) fetch(kind, id string) (entry, bool, error) {
+	var (
+		e   entry
+		err error
+	)
+	switch kind {
+	case interfaces.StackEventType:
+		var stack interfaces.SwarmStack
+		stack, err = c.cli.GetSwarmStack(id)
+		e = stackEntry(stack)
+	case events.ServiceEventType:
+		var service swarm.Service
+		service, err = c.cli.GetService(id, false)
+		e = serviceEntry(service)
+	case events.NetworkEventType:
+		var network dockerTypes.NetworkResource
+		network, err = c.cli.GetNetwork(id)
+		e = networkEntry(network)
+	case events.SecretEventType:
+		var secret swarm.Secret
+		secret, err = c.cli.GetSecret(id)
+		e = secretEntry(secret)
+	case events.ConfigEventType:
+		var config swarm.Config
+		config, err = c.cli.GetConfig(id)
+		e = configEntry(config)
+	default:
+		return e, false, nil
+	}
+	switch {
+	case errdefs.IsNotFound(err):
+		return e, false, nil
+	case err != nil:
+		return e, false, err
+	}
+	return e, e.stack != "", nil
+}
+
+func stackEntry(stack interfaces.SwarmStack) entry {
+	return entry{
+		id:      stack.ID,
+		name:    stack.Spec.Annotations.Name,
+		stack:   stack.ID,
+		version: stack.Meta.Version.Index,
+		object:  stack,
+	}
+}
+
+func serviceEntry(service swarm.Service) entry {
+	return entry{
+		id:      service.ID,
+		name:    service.Spec.Annotations.Name,
+		stack:   service.Spec.Annotations.Labels[interfaces.StackLabel],
+		version: service.Meta.Version.Index,
+		object:  service,
+	}
+}
+
+func networkEntry(network dockerTypes.NetworkResource) entry {
+	return entry{
+		id:     network.ID,
+		name:   network.Name,
+		stack:  network.Labels[interfaces.StackLabel],
+		object: network,
+	}
+}
+
+func secretEntry(secret swarm.Secret) entry {
+	return entry{
+		id:      secret.ID,
+		name:    secret.Spec.Annotations.Name,
+		stack:   secret.Spec.Annotations.Labels[interfaces.StackLabel],
+		version: secret.Meta.Version.Index,
+		object:  secret,
+	}
+}
+
+func configEntry(config swarm.Config) entry {
+	return entry{
+		id:      config.ID,
+		name:    config.Spec.Annotations.Name,
+		stack:   config.Spec.Annotations.Labels[interfaces.StackLabel],
+		version: config.Meta.Version.Index,
+		object:  config,
+	}
+}
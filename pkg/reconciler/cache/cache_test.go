@@ -0,0 +1,239 @@
+package cache
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+)
+
+func labeledService(id, name, stack string, version uint64) swarm.Service {
+	service := swarm.Service{
+		ID: id,
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: name},
+		},
+	}
+	service.Meta.Version.Index = version
+	if stack != "" {
+		service.Spec.Annotations.Labels = map[string]string{interfaces.StackLabel: stack}
+	}
+	return service
+}
+
+var _ = Describe("Cache", func() {
+	var (
+		ctrl       *gomock.Controller
+		mockClient *mocks.MockBackendClient
+		c          *Cache
+
+		stack    interfaces.SwarmStack
+		services []swarm.Service
+	)
+
+	// expectSync sets up the list calls of a Sync
+	expectSync := func() {
+		mockClient.EXPECT().ListSwarmStacks().Return([]interfaces.SwarmStack{stack}, nil)
+		mockClient.EXPECT().GetServices(dockerTypes.ServiceListOptions{
+			Filters: filters.NewArgs(filters.Arg("label", interfaces.StackLabel)),
+		}).Return(services, nil)
+		mockClient.EXPECT().GetNetworks(gomock.Any()).Return(nil, nil)
+		mockClient.EXPECT().GetSecrets(gomock.Any()).Return(nil, nil)
+		mockClient.EXPECT().GetConfigs(gomock.Any()).Return(nil, nil)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockBackendClient(ctrl)
+		c = New(mockClient)
+
+		stack = interfaces.SwarmStack{
+			ID: "stack1",
+			Spec: interfaces.SwarmStackSpec{
+				Annotations: swarm.Annotations{Name: "stackname"},
+			},
+		}
+		services = []swarm.Service{
+			labeledService("service1", "web", "stack1", 1),
+			labeledService("service2", "db", "stack1", 1),
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should index the synced objects by ID, name and stack", func() {
+		expectSync()
+		Expect(c.Sync()).To(Succeed())
+		Expect(c.Synced()).To(BeTrue())
+
+		cached, ok := c.GetSwarmStack("stackname")
+		Expect(ok).To(BeTrue())
+		Expect(cached).To(Equal(stack))
+
+		service, ok := c.GetService("db")
+		Expect(ok).To(BeTrue())
+		Expect(service.ID).To(Equal("service2"))
+
+		Expect(c.ListServices("stack1")).To(ConsistOf(services))
+		Expect(c.ListServices("stack2")).To(BeEmpty())
+		Expect(c.StackOf(events.ServiceEventType, "service1")).To(Equal("stack1"))
+	})
+
+	It("should refresh objects from events", func() {
+		expectSync()
+		Expect(c.Sync()).To(Succeed())
+
+		updated := labeledService("service1", "web", "stack1", 2)
+		mockClient.EXPECT().GetService("service1", false).Return(updated, nil)
+		Expect(c.Refresh(events.ServiceEventType, "service1")).To(Equal("stack1"))
+		service, ok := c.GetService("web")
+		Expect(ok).To(BeTrue())
+		Expect(service).To(Equal(updated))
+
+		// the stack label was removed from the service
+		mockClient.EXPECT().GetService("service2", false).Return(labeledService("service2", "db", "", 2), nil)
+		Expect(c.Refresh(events.ServiceEventType, "service2")).To(BeEmpty())
+		_, ok = c.GetService("service2")
+		Expect(ok).To(BeFalse())
+		// it is now known not to belong to a stack, and isn't fetched again
+		Expect(c.StackOf(events.ServiceEventType, "service2")).To(BeEmpty())
+
+		mockClient.EXPECT().GetService("service1", false).Return(swarm.Service{}, errdefs.NotFound(errors.New("not found")))
+		Expect(c.Refresh(events.ServiceEventType, "service1")).To(BeEmpty())
+		Expect(c.ListServices("stack1")).To(BeEmpty())
+	})
+
+	It("should keep the cached object if it can't be refreshed", func() {
+		expectSync()
+		Expect(c.Sync()).To(Succeed())
+
+		mockClient.EXPECT().GetService("service1", false).Return(swarm.Service{}, errors.New("unavailable"))
+		Expect(c.Refresh(events.ServiceEventType, "service1")).To(Equal("stack1"))
+		Expect(c.ListServices("stack1")).To(HaveLen(2))
+	})
+
+	It("should return copies of the cached objects", func() {
+		expectSync()
+		Expect(c.Sync()).To(Succeed())
+
+		service, ok := c.GetService("service1")
+		Expect(ok).To(BeTrue())
+		service.Spec.Labels[interfaces.StackLabel] = "stack2"
+		listed := c.ListServices("stack1")
+		Expect(listed).To(ConsistOf(services))
+		listed[0].Spec.Labels["modified"] = "true"
+
+		Expect(c.ListServices("stack1")).To(ConsistOf(services))
+		Expect(c.ListServices("stack2")).To(BeEmpty())
+		Expect(c.StackOf(events.ServiceEventType, "service1")).To(Equal("stack1"))
+	})
+
+	It("should count the objects out of sync on resync", func() {
+		expectSync()
+		Expect(c.Sync()).To(Succeed())
+		Expect(c.Stats().Inconsistencies[events.ServiceEventType]).To(Equal(0))
+
+		// service1 was updated, service2 removed and service3 created
+		// without the cache being notified
+		services = []swarm.Service{
+			labeledService("service1", "web", "stack1", 2),
+			labeledService("service3", "cache", "stack1", 1),
+		}
+		expectSync()
+		Expect(c.Sync()).To(Succeed())
+
+		stats := c.Stats()
+		Expect(stats.Inconsistencies[events.ServiceEventType]).To(Equal(3))
+		Expect(stats.Inconsistencies[interfaces.StackEventType]).To(Equal(0))
+		Expect(stats.Objects[events.ServiceEventType]).To(Equal(2))
+	})
+
+	Describe("reconciler client", func() {
+		It("should read from the cache, and fall back to the client", func() {
+			expectSync()
+			Expect(c.Sync()).To(Succeed())
+			cli := NewReconcilerClient(c, mockClient)
+
+			service, err := cli.GetService("web", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(service.ID).To(Equal("service1"))
+
+			listed, err := cli.GetServices(dockerTypes.ServiceListOptions{
+				Filters: filters.NewArgs(filters.Arg("label", interfaces.StackLabel+"=stack1")),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed).To(ConsistOf(services))
+
+			// a service which isn't cached is read from the cluster
+			mockClient.EXPECT().GetService("other", false).Return(labeledService("service4", "other", "", 1), nil)
+			mockClient.EXPECT().GetService("service4", false).Return(labeledService("service4", "other", "", 1), nil)
+			service, err = cli.GetService("other", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(service.ID).To(Equal("service4"))
+		})
+
+		It("should update the cache with the services it writes", func() {
+			expectSync()
+			Expect(c.Sync()).To(Succeed())
+			cli := NewReconcilerClient(c, mockClient)
+
+			created := labeledService("service3", "cache", "stack1", 1)
+			mockClient.EXPECT().CreateService(created.Spec, "", false).Return(&dockerTypes.ServiceCreateResponse{ID: "service3"}, nil)
+			mockClient.EXPECT().GetService("service3", false).Return(created, nil)
+			_, err := cli.CreateService(created.Spec, "", false)
+			Expect(err).ToNot(HaveOccurred())
+			service, ok := c.GetService("cache")
+			Expect(ok).To(BeTrue())
+			Expect(service).To(Equal(created))
+
+			mockClient.EXPECT().RemoveService("service1").Return(nil)
+			Expect(cli.RemoveService("service1")).To(Succeed())
+			_, ok = c.GetService("web")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should update the cache with the secrets it writes", func() {
+			expectSync()
+			Expect(c.Sync()).To(Succeed())
+			cli := NewReconcilerClient(c, mockClient)
+
+			created := swarm.Secret{
+				ID: "secret1",
+				Spec: swarm.SecretSpec{
+					Annotations: swarm.Annotations{
+						Name:   "stackname_password",
+						Labels: map[string]string{interfaces.StackLabel: "stack1"},
+					},
+				},
+			}
+			mockClient.EXPECT().CreateSecret(created.Spec).Return("secret1", nil)
+			mockClient.EXPECT().GetSecret("secret1").Return(created, nil)
+			_, err := cli.CreateSecret(created.Spec)
+			Expect(err).ToNot(HaveOccurred())
+
+			byStack := dockerTypes.SecretListOptions{
+				Filters: filters.NewArgs(filters.Arg("label", interfaces.StackLabel+"=stack1")),
+			}
+			listed, err := cli.GetSecrets(byStack)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed).To(Equal([]swarm.Secret{created}))
+
+			mockClient.EXPECT().RemoveSecret("secret1").Return(nil)
+			Expect(cli.RemoveSecret("secret1")).To(Succeed())
+			listed, err = cli.GetSecrets(byStack)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/reconciler"
+)
+
+// reconcilerClient is a reconciler.Client which reads stacks, services and
+// secrets from a Cache, falling back to the underlying client for the objects
+// which aren't cached, and updates the Cache with the objects it writes.
+type reconcilerClient struct {
+	cache *Cache
+	cli   reconciler.Client
+}
+
+// NewReconcilerClient creates a reconciler.Client which reads from the
+// provided Cache, and writes through the provided Client.
+func NewReconcilerClient(cache *Cache, cli reconciler.Client) reconciler.Client {
+	return &reconcilerClient{
+		cache: cache,
+		cli:   cli,
+	}
+}
+
+func (c *reconcilerClient) GetSwarmStack(id string) (interfaces.SwarmStack, error) {
+	if stack, ok := c.cache.GetSwarmStack(id); ok {
+		return stack, nil
+	}
+	stack, err := c.cli.GetSwarmStack(id)
+	if err == nil {
+		c.cache.Refresh(interfaces.StackEventType, stack.ID)
+	}
+	return stack, err
+}
+
+func (c *reconcilerClient) AdoptServiceSpec(id string, spec swarm.ServiceSpec) error {
+	err := c.cli.AdoptServiceSpec(id, spec)
+	if err == nil {
+		c.cache.Refresh(interfaces.StackEventType, id)
+	}
+	return err
+}
+
+func (c *reconcilerClient) LogStackEvent(id, action string, attributes map[string]string) {
+	c.cli.LogStackEvent(id, action, attributes)
+}
+
+func (c *reconcilerClient) GetServices(opts dockerTypes.ServiceListOptions) ([]swarm.Service, error) {
+	// only the listing of the services of a stack is served from the cache,
+	// and only once it has been filled.
+	if stack, ok := c.cachedStack(opts.Filters); ok {
+		return c.cache.ListServices(stack), nil
+	}
+	return c.cli.GetServices(opts)
+}
+
+// cachedStack returns the ID of the stack whose objects are listed with the
+// provided filters, if they only filter on the stack label and the listing
+// can be served from the cache.
+func (c *reconcilerClient) cachedStack(args filters.Args) (string, bool) {
+	labels := args.Get("label")
+	if c.cache.Synced() && args.Len() == 1 && len(labels) == 1 {
+		if stack := strings.TrimPrefix(labels[0], interfaces.StackLabel+"="); stack != labels[0] {
+			return stack, true
+		}
+	}
+	return "", false
+}
+
+func (c *reconcilerClient) GetService(idOrName string, insertDefaults bool) (swarm.Service, error) {
+	if !insertDefaults {
+		if service, ok := c.cache.GetService(idOrName); ok {
+			return service, nil
+		}
+	}
+	service, err := c.cli.GetService(idOrName, insertDefaults)
+	if err == nil && !insertDefaults {
+		c.cache.Refresh(events.ServiceEventType, service.ID)
+	}
+	return service, err
+}
+
+func (c *reconcilerClient) CreateService(spec swarm.ServiceSpec, encodedAuth string, queryRegistry bool) (*dockerTypes.ServiceCreateResponse, error) {
+	resp, err := c.cli.CreateService(spec, encodedAuth, queryRegistry)
+	if err == nil {
+		c.cache.Refresh(events.ServiceEventType, resp.ID)
+	}
+	return resp, err
+}
+
+func (c *reconcilerClient) UpdateService(id string, version uint64, spec swarm.ServiceSpec, opts dockerTypes.ServiceUpdateOptions, queryRegistry bool) (*dockerTypes.ServiceUpdateResponse, error) {
+	resp, err := c.cli.UpdateService(id, version, spec, opts, queryRegistry)
+	if err == nil {
+		c.cache.Refresh(events.ServiceEventType, id)
+	}
+	return resp, err
+}
+
+func (c *reconcilerClient) RemoveService(id string) error {
+	err := c.cli.RemoveService(id)
+	if err == nil {
+		c.cache.Forget(events.ServiceEventType, id)
+	}
+	return err
+}
+
+func (c *reconcilerClient) GetTasks(opts dockerTypes.TaskListOptions) ([]swarm.Task, error) {
+	return c.cli.GetTasks(opts)
+}
+
+func (c *reconcilerClient) GetSecrets(opts dockerTypes.SecretListOptions) ([]swarm.Secret, error) {
+	if stack, ok := c.cachedStack(opts.Filters); ok {
+		return c.cache.ListSecrets(stack), nil
+	}
+	return c.cli.GetSecrets(opts)
+}
+
+func (c *reconcilerClient) CreateSecret(spec swarm.SecretSpec) (string, error) {
+	id, err := c.cli.CreateSecret(spec)
+	if err == nil {
+		c.cache.Refresh(events.SecretEventType, id)
+	}
+	return id, err
+}
+
+func (c *reconcilerClient) RemoveSecret(id string) error {
+	err := c.cli.RemoveSecret(id)
+	if err == nil {
+		c.cache.Forget(events.SecretEventType, id)
+	}
+	return err
+}
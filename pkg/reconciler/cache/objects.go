@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/interfaces"
+)
+
+// get returns a copy of the object of a kind with the provided ID or name.
+func (c *Cache) get(kind, idOrName string) (interface{}, bool) {
+	c.mu.RLock()
+	e, ok := c.stores[kind].get(idOrName)
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return copyObject(e.object), true
+}
+
+// listByStack returns copies of the objects of a kind belonging to a stack.
+func (c *Cache) listByStack(kind, stack string) []interface{} {
+	c.mu.RLock()
+	entries := c.stores[kind].listByStack(stack)
+	c.mu.RUnlock()
+	result := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, copyObject(e.object))
+	}
+	return result
+}
+
+// copyObject returns a deep copy of a cached object, so that the objects
+// returned by the cache don't share their maps, slices and pointers with the
+// cache, which is read and written concurrently by the workers of the
+// reconciler. The objects are API objects, and are thus copied through their
+// JSON form.
+func copyObject(object interface{}) interface{} {
+	data, err := json.Marshal(object)
+	if err != nil {
+		panic(fmt.Sprintf("unable to copy cached %T: %s", object, err))
+	}
+	copied := reflect.New(reflect.TypeOf(object))
+	if err := json.Unmarshal(data, copied.Interface()); err != nil {
+		panic(fmt.Sprintf("unable to copy cached %T: %s", object, err))
+	}
+	return copied.Elem().Interface()
+}
+
+// GetSwarmStack returns the stack with the provided ID or name, if cached.
+func (c *Cache) GetSwarmStack(idOrName string) (interfaces.SwarmStack, bool) {
+	object, ok := c.get(interfaces.StackEventType, idOrName)
+	if !ok {
+		return interfaces.SwarmStack{}, false
+	}
+	return object.(interfaces.SwarmStack), true
+}
+
+// GetService returns the service with the provided ID or name, if cached.
+func (c *Cache) GetService(idOrName string) (swarm.Service, bool) {
+	object, ok := c.get(events.ServiceEventType, idOrName)
+	if !ok {
+		return swarm.Service{}, false
+	}
+	return object.(swarm.Service), true
+}
+
+// ListServices returns the cached services belonging to a stack.
+func (c *Cache) ListServices(stack string) []swarm.Service {
+	objects := c.listByStack(events.ServiceEventType, stack)
+	services := make([]swarm.Service, 0, len(objects))
+	for _, object := range objects {
+		services = append(services, object.(swarm.Service))
+	}
+	return services
+}
+
+// GetNetwork returns the network with the provided ID or name, if cached.
+func (c *Cache) GetNetwork(idOrName string) (dockerTypes.NetworkResource, bool) {
+	object, ok := c.get(events.NetworkEventType, idOrName)
+	if !ok {
+		return dockerTypes.NetworkResource{}, false
+	}
+	return object.(dockerTypes.NetworkResource), true
+}
+
+// ListNetworks returns the cached networks belonging to a stack.
+func (c *Cache) ListNetworks(stack string) []dockerTypes.NetworkResource {
+	objects := c.listByStack(events.NetworkEventType, stack)
+	networks := make([]dockerTypes.NetworkResource, 0, len(objects))
+	for _, object := range objects {
+		networks = append(networks, object.(dockerTypes.NetworkResource))
+	}
+	return networks
+}
+
+// GetSecret returns the secret with the provided ID or name, if cached.
+func (c *Cache) GetSecret(idOrName string) (swarm.Secret, bool) {
+	object, ok := c.get(events.SecretEventType, idOrName)
+	if !ok {
+		return swarm.Secret{}, false
+	}
+	return object.(swarm.Secret), true
+}
+
+// ListSecrets returns the cached secrets belonging to a stack.
+func (c *Cache) ListSecrets(stack string) []swarm.Secret {
+	objects := c.listByStack(events.SecretEventType, stack)
+	secrets := make([]swarm.Secret, 0, len(objects))
+	for _, object := range objects {
+		secrets = append(secrets, object.(swarm.Secret))
+	}
+	return secrets
+}
+
+// GetConfig returns the config with the provided ID or name, if cached.
+func (c *Cache) GetConfig(idOrName string) (swarm.Config, bool) {
+	object, ok := c.get(events.ConfigEventType, idOrName)
+	if !ok {
+		return swarm.Config{}, false
+	}
+	return object.(swarm.Config), true
+}
+
+// ListConfigs returns the cached configs belonging to a stack.
+func (c *Cache) ListConfigs(stack string) []swarm.Config {
+	objects := c.listByStack(events.ConfigEventType, stack)
+	configs := make([]swarm.Config, 0, len(objects))
+	for _, object := range objects {
+		configs = append(configs, object.(swarm.Config))
+	}
+	return configs
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var inconsistenciesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stacks_cache_resync_inconsistencies_total",
+	Help: "Number of cached objects found out of sync by periodic resyncs, by kind.",
+}, []string{"kind"})
+
+// RegisterMetrics registers the metrics of the cache in the provided
+// registerer.
+func RegisterMetrics(r prometheus.Registerer) error {
+	return r.Register(inconsistenciesTotal)
+}
+
+// Stats describes the content of the Cache, for debugging purposes.
+type Stats struct {
+	Synced   bool      `json:"synced"`
+	LastSync time.Time `json:"last_sync"`
+	// Objects is the number of cached objects, by kind.
+	Objects map[string]int `json:"objects"`
+	// Inconsistencies is the number of objects found out of sync by the
+	// last Sync, by kind.
+	Inconsistencies map[string]int `json:"inconsistencies"`
+}
+
+// Stats returns statistics about the content of the Cache.
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stats := Stats{
+		Synced:          c.synced,
+		LastSync:        c.lastSync,
+		Objects:         map[string]int{},
+		Inconsistencies: map[string]int{},
+	}
+	for _, kind := range kinds {
+		stats.Objects[kind] = len(c.stores[kind].entries)
+		stats.Inconsistencies[kind] = c.inconsistencies[kind]
+	}
+	return stats
+}
@@ -0,0 +1,99 @@
+package cache
+
+// entry is an object in a store, along with the keys it is indexed by.
+type entry struct {
+	id    string
+	name  string
+	stack string
+	// version is the version of the object, used to detect objects which
+	// the event stream failed to update. It is 0 for objects without a
+	// version, such as networks.
+	version uint64
+	object  interface{}
+}
+
+// store holds the objects of one kind, indexed by ID, name and stack ID.
+// It is not thread-safe, the Cache holds the lock.
+type store struct {
+	entries map[string]entry
+	byName  map[string]string
+	byStack map[string]map[string]struct{}
+}
+
+func newStore() *store {
+	return &store{
+		entries: map[string]entry{},
+		byName:  map[string]string{},
+		byStack: map[string]map[string]struct{}{},
+	}
+}
+
+func (s *store) put(e entry) {
+	s.delete(e.id)
+	s.entries[e.id] = e
+	if e.name != "" {
+		s.byName[e.name] = e.id
+	}
+	ids, ok := s.byStack[e.stack]
+	if !ok {
+		ids = map[string]struct{}{}
+		s.byStack[e.stack] = ids
+	}
+	ids[e.id] = struct{}{}
+}
+
+func (s *store) delete(id string) {
+	e, ok := s.entries[id]
+	if !ok {
+		return
+	}
+	delete(s.entries, id)
+	if s.byName[e.name] == id {
+		delete(s.byName, e.name)
+	}
+	if ids, ok := s.byStack[e.stack]; ok {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(s.byStack, e.stack)
+		}
+	}
+}
+
+// get returns the object with the provided ID or, failing that, name.
+func (s *store) get(idOrName string) (entry, bool) {
+	if e, ok := s.entries[idOrName]; ok {
+		return e, true
+	}
+	if id, ok := s.byName[idOrName]; ok {
+		return s.entries[id], true
+	}
+	return entry{}, false
+}
+
+// listByStack returns the objects belonging to the provided stack.
+func (s *store) listByStack(stack string) []entry {
+	ids := s.byStack[stack]
+	result := make([]entry, 0, len(ids))
+	for id := range ids {
+		result = append(result, s.entries[id])
+	}
+	return result
+}
+
+// diff returns the number of objects which differ between two stores: the
+// objects present in only one of them, and those whose versions differ.
+func diff(a, b *store) int {
+	n := 0
+	for id, e := range a.entries {
+		other, ok := b.entries[id]
+		if !ok || other.version != e.version {
+			n++
+		}
+	}
+	for id := range b.entries {
+		if _, ok := a.entries[id]; !ok {
+			n++
+		}
+	}
+	return n
+}
@@ -2,6 +2,7 @@ package dispatcher
 
 import (
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types/events"
 	"github.com/sirupsen/logrus"
@@ -13,8 +14,26 @@ import (
 
 const (
 	noMoreObjects = "none left"
+
+	// defaultWorkers is the number of workers reconciling objects when
+	// Options.Workers is not set.
+	defaultWorkers = 1
 )
 
+// kindOrder is the order in which the objects of a stack are reconciled:
+// Stack, Network, Secret, Config, and finally Service. Stacks must come
+// first, because every other object type will depend on the latest stack, and
+// Services must come last because they depend on the other object types. The
+// middle 3 object types, Network, Secret, and Config, could be done in any
+// order, but it's simpler to just assign them an order
+var kindOrder = []string{
+	interfaces.StackEventType,
+	events.NetworkEventType,
+	events.SecretEventType,
+	events.ConfigEventType,
+	events.ServiceEventType,
+}
+
 // Dispatcher is the object that decides when to call the reconciler and with
 // what objects. It exists separately from the Reconciler so that we can
 // decouple the channel-driven logic of choosing events to reconcile from the
@@ -23,47 +42,123 @@ type Dispatcher interface {
 	notifier.ObjectChangeNotifier
 
 	HandleEvents(chan interface{}) error
+
+	// State returns a snapshot of the internal state of the dispatcher, for
+	// debugging purposes.
+	State() State
+}
+
+// Options configures a Dispatcher.
+type Options struct {
+	// Workers is the number of objects reconciled concurrently. Objects of
+	// the same stack are never reconciled concurrently. Defaults to 1.
+	Workers int
+	// Resolver finds the stack each object belongs to. If nil, all objects
+	// are considered to belong to the same stack, and are thus reconciled
+	// one at a time.
+	Resolver StackResolver
+}
+
+// objectQueue is the set of objects of one stack waiting to be reconciled.
+// at first glance, we might want to put all objects into a map[string]string,
+// where the key is the ID and the value is the kind. however, we have to
+// reconcile objects in order: stacks, then networks, configs, and secrets,
+// and finally services. the queue is thus a set of object IDs per kind.
+type objectQueue map[string]map[string]struct{}
+
+func newObjectQueue() objectQueue {
+	q := make(objectQueue, len(kindOrder))
+	for _, kind := range kindOrder {
+		q[kind] = map[string]struct{}{}
+	}
+	return q
+}
+
+func (q objectQueue) empty() bool {
+	for _, ids := range q {
+		if len(ids) > 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // dispatcher implements the Dispatcher interface
 type dispatcher struct {
 	mu sync.Mutex
+	// cond is broadcast whenever workers may be able to pick an object, or
+	// must stop. It uses mu as its lock.
+	cond *sync.Cond
 
-	r reconciler.Reconciler
+	r        reconciler.Reconciler
+	resolver StackResolver
+	workers  int
 
 	// currently, the reconciler package only works with Stacks. The dispatcher
 	// will be updated to handle more object types as the Reconciler implements
 	// functionality for them.
 
-	// pendingStacks (and the similar pending maps) are sets of object IDs. at
-	// first glance, we might want to put all objects into a map[string]string,
-	// where the key is the ID and the value is the kind. however, we have to
-	// reconcile objects in order: stacks, then networks, configs, and secrets,
-	// and finally services.
-	pendingStacks   map[string]struct{}
-	pendingNetworks map[string]struct{}
-	pendingSecrets  map[string]struct{}
-	pendingConfigs  map[string]struct{}
-	pendingServices map[string]struct{}
+	// queues contains the objects waiting to be reconciled, keyed by the ID
+	// of their stack, and busy the stacks which have an object being
+	// reconciled by a worker.
+	queues map[string]objectQueue
+	busy   map[string]struct{}
+
+	// reading is true while HandleEvents reads a batch of events. Workers
+	// don't pick objects while reading, so that a batch is reconciled in
+	// order. stopped is true once HandleEvents is exiting.
+	reading bool
+	stopped bool
+
+	// retries contains the objects whose last reconciliation failed, keyed
+	// by kind and ID, and results the last reconcile results, oldest first.
+	retries map[string]*RetryState
+	results []ReconcileResult
+
+	// delayed contains the timers of the objects which will be queued again
+	// once the delay requested by the reconciler has elapsed.
+	delayed map[delayedObject]*time.Timer
+}
+
+// delayedObject is an object waiting to be queued again.
+type delayedObject struct {
+	stack, kind, id string
 }
 
 // New creates and returns the default Dispatcher object, which will
-// work on the provided Reconciler
+// work on the provided Reconciler one object at a time
 func New(r reconciler.Reconciler, register notifier.Register) Dispatcher {
-	return newDispatcher(r, register)
+	return newDispatcherWithOptions(r, register, Options{})
+}
+
+// NewWithOptions creates and returns a Dispatcher object, which will work on
+// the provided Reconciler with the provided options.
+func NewWithOptions(r reconciler.Reconciler, register notifier.Register, opts Options) Dispatcher {
+	return newDispatcherWithOptions(r, register, opts)
 }
 
 // newDispatcher is the private method that creates a new dispatcher object. It
 // exists separately for testing purposes.
 func newDispatcher(r reconciler.Reconciler, register notifier.Register) *dispatcher {
+	return newDispatcherWithOptions(r, register, Options{})
+}
+
+func newDispatcherWithOptions(r reconciler.Reconciler, register notifier.Register, opts Options) *dispatcher {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
 	m := &dispatcher{
-		r:               r,
-		pendingStacks:   map[string]struct{}{},
-		pendingNetworks: map[string]struct{}{},
-		pendingSecrets:  map[string]struct{}{},
-		pendingConfigs:  map[string]struct{}{},
-		pendingServices: map[string]struct{}{},
+		r:        r,
+		resolver: opts.Resolver,
+		workers:  workers,
+		queues:   map[string]objectQueue{},
+		busy:     map[string]struct{}{},
+		retries:  map[string]*RetryState{},
+		delayed:  map[delayedObject]*time.Timer{},
 	}
+	m.cond = sync.NewCond(&m.mu)
+	m.updatePendingObjects()
 	register.Register(m)
 	return m
 }
@@ -71,27 +166,44 @@ func newDispatcher(r reconciler.Reconciler, register notifier.Register) *dispatc
 // Notify tells the dispatcher to call the reconciler with this object at some
 // point in the future
 func (d *dispatcher) Notify(kind, id string) {
+	// resolving the stack may require API calls, so it's done before taking
+	// the lock.
+	stack := ""
+	if d.resolver != nil {
+		stack = d.resolver.StackOf(kind, id)
+	}
+	d.enqueue(stack, kind, id)
+}
+
+// enqueue adds an object to the queue of its stack, and wakes up the workers.
+func (d *dispatcher) enqueue(stack, kind, id string) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	// TODO(dperny) implement
-	switch kind {
-	case interfaces.StackEventType:
-		d.pendingStacks[id] = struct{}{}
-	case events.NetworkEventType:
-		d.pendingNetworks[id] = struct{}{}
-	case events.SecretEventType:
-		d.pendingSecrets[id] = struct{}{}
-	case events.ConfigEventType:
-		d.pendingConfigs[id] = struct{}{}
-	case events.ServiceEventType:
-		d.pendingServices[id] = struct{}{}
+	d.enqueueLocked(stack, kind, id)
+}
+
+// enqueueLocked adds an object to the queue of its stack, and wakes up the
+// workers. It must be called with the lock held.
+func (d *dispatcher) enqueueLocked(stack, kind, id string) {
+	q, ok := d.queues[stack]
+	if !ok {
+		q = newObjectQueue()
+	}
+	ids, ok := q[kind]
+	if !ok {
+		// TODO(dperny) implement other kinds
+		return
 	}
+	ids[id] = struct{}{}
+	d.queues[stack] = q
+	d.updatePendingObjects()
+	d.cond.Broadcast()
 }
 
 // HandleEvents takes a channel that issues events, and processes those events
 // by handing them off to the Reconciler. It exits when the provided channel is
-// closed. This occurs immediately, and no further calls to the reconciler will
-// subsequently be made.
+// closed. This occurs immediately after the reconciliations in progress
+// complete, and no further calls to the reconciler will subsequently be made.
 //
 // The channel for eventC is nominally of type interface{}, but the returned
 // objects must all be of type events.Messages. The odd type of eventC is a
@@ -113,15 +225,45 @@ func (d *dispatcher) HandleEvents(eventC chan interface{}) error {
 	// |                |   channel read     |                |
 	// | wait for read  |------------------->| reading events |<-+
 	// |________________|                    |________________|  |
-	//         ^                               |   ^   |         | channel read
-	//         |               channel blocked |   |   +---------+
-	//         |                               |   |
-	//         |                               |   | Some objects left
-	//         |                    ___________V___|_______
-	//         |                   |                       |
-	//         +-------------------|  Reconcile one object |
-	//           no objects left   |_______________________|
+	//         ^                                       |         | channel read
+	//         |               channel blocked         +---------+
+	//         +---------------------------------------+
 	//
+	// While waiting for a read, the workers reconcile the pending objects,
+	// one at a time per stack. They pause while events are being read, so
+	// that each batch of events is reconciled in order.
+
+	d.mu.Lock()
+	d.reading = true
+	d.stopped = false
+	d.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work()
+		}()
+	}
+	// whenever we return, stop the workers and wait for the reconciliations
+	// in progress to complete. the delayed objects are queued right away, so
+	// that they are reconciled if the dispatcher is started again.
+	defer func() {
+		d.mu.Lock()
+		d.stopped = true
+		d.cond.Broadcast()
+		d.mu.Unlock()
+		wg.Wait()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for obj, timer := range d.delayed {
+			timer.Stop()
+			delete(d.delayed, obj)
+			d.enqueueLocked(obj.stack, obj.kind, obj.id)
+		}
+	}()
 
 	// the whole thing  goes in a for loop
 	for {
@@ -131,6 +273,7 @@ func (d *dispatcher) HandleEvents(eventC chan interface{}) error {
 			// if the channel is closed, return
 			return nil
 		}
+		d.setReading(true)
 		d.resolveMessage(ev)
 
 		// next state: reading events
@@ -145,70 +288,163 @@ func (d *dispatcher) HandleEvents(eventC chan interface{}) error {
 				}
 				d.resolveMessage(ev)
 			default:
-				// when the channel is no longer ready, process an event
-				kind, id := d.pickObject()
-				if kind == noMoreObjects {
-					// if there are no more objects in the queue, go back to
-					// waiting for an event
-					break readingEvents
-				}
-				// next state: reconcile the object. if it fails, add it back
-				// to the set of objects.
-				if err := d.r.Reconcile(kind, id); err != nil {
-					// TODO(dperny): if a given object always fails, we'll stay
-					// in this state forever, looping again and again.
-					logrus.Error(err)
-					d.Notify(kind, id)
+				// when the channel is no longer ready, go back to waiting
+				// for an event, and let the workers process the objects.
+				break readingEvents
+			}
+		}
+		d.setReading(false)
+	}
+}
+
+// setReading sets whether HandleEvents is reading a batch of events, waking
+// up the workers when it is done.
+func (d *dispatcher) setReading(reading bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reading = reading
+	if !reading {
+		d.cond.Broadcast()
+	}
+}
+
+// work is the loop of a worker. It picks objects and reconciles them until
+// HandleEvents stops.
+func (d *dispatcher) work() {
+	for {
+		d.mu.Lock()
+		var stack, kind, id string
+		for {
+			if d.stopped {
+				d.mu.Unlock()
+				return
+			}
+			if !d.reading {
+				stack, kind, id = d.pickObject()
+				if kind != noMoreObjects {
+					break
 				}
 			}
+			d.cond.Wait()
+		}
+		d.busy[stack] = struct{}{}
+		d.mu.Unlock()
+
+		// reconcile the object. if it fails, add it back to the queue of
+		// its stack. if it isn't ready to be reconciled, add it back once
+		// the delay requested by the reconciler has elapsed.
+		if err := d.reconcile(kind, id); err != nil {
+			reconcileRetriesTotal.WithLabelValues(kind).Inc()
+			if notReady, ok := err.(*reconciler.NotReadyError); ok {
+				logrus.Debugf("%s %s is not ready to be reconciled: %s", kind, id, err)
+				d.enqueueAfter(stack, kind, id, notReady.RetryAfter)
+			} else {
+				// TODO(dperny): if a given object always fails, we'll stay
+				// in this state forever, looping again and again.
+				logrus.Error(err)
+				d.enqueue(stack, kind, id)
+			}
+		}
+
+		d.mu.Lock()
+		delete(d.busy, stack)
+		if q, ok := d.queues[stack]; ok && q.empty() {
+			delete(d.queues, stack)
+		}
+		// the other workers may be waiting for this stack.
+		d.cond.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+// enqueueAfter adds an object to the queue of its stack once the provided
+// delay has elapsed. If the dispatcher is stopped in the meantime, the object
+// is queued right away.
+func (d *dispatcher) enqueueAfter(stack, kind, id string, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	obj := delayedObject{stack: stack, kind: kind, id: id}
+	if d.stopped {
+		d.enqueueLocked(stack, kind, id)
+		return
+	}
+	if _, ok := d.delayed[obj]; ok {
+		return
+	}
+	d.delayed[obj] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if _, ok := d.delayed[obj]; !ok {
+			// the dispatcher stopped, and has queued the object already
+			return
 		}
+		delete(d.delayed, obj)
+		d.enqueueLocked(stack, kind, id)
+	})
+}
+
+// reconcile calls the reconciler with the provided object, recording metrics
+// and the result of the reconciliation.
+func (d *dispatcher) reconcile(kind, id string) error {
+	start := time.Now()
+	err := d.r.Reconcile(kind, id)
+	duration := time.Since(start)
+
+	result := ReconcileResult{
+		Kind:     kind,
+		ID:       id,
+		Time:     start,
+		Duration: duration,
 	}
+	reconcileDuration.WithLabelValues(kind).Observe(duration.Seconds())
+	reconcilesTotal.WithLabelValues(kind).Inc()
+	if err != nil {
+		reconcileErrorsTotal.WithLabelValues(kind).Inc()
+		result.Error = err.Error()
+	}
+	d.recordResult(result)
+	return err
 }
 
 // resolveMessage is a method that figures out what kind of event this is and
-// puts it into the correct map
+// puts it into the correct map. If the actor of the event carries the stack
+// label, as the events forwarded by the Manager do, the stack isn't resolved
+// again, so that no API call is made while the workers are paused.
 func (d *dispatcher) resolveMessage(ev interface{}) {
 	// naked type cast. If this isn't events.Message, then the program will
 	// panic. This is the desired behavior.
 	msg := ev.(events.Message)
-	// and then just call Notify, it's the same code anyway.
+	if stack, ok := msg.Actor.Attributes[interfaces.StackLabel]; ok {
+		d.enqueue(stack, msg.Type, msg.Actor.ID)
+		return
+	}
+	// otherwise just call Notify, it's the same code anyway.
 	d.Notify(msg.Type, msg.Actor.ID)
 }
 
-// pickObject selects and returns the next object to be processed. It returns
-// the object event type and the object ID. If no objects remain, it will
-// return noMoreObjects as the kind
+// pickObject selects, removes from its queue and returns the next object to
+// be processed. It returns the stack of the object, the object event type and
+// the object ID. If no objects remain, it will return noMoreObjects as the
+// kind. It must be called with the lock held.
 //
-// pickObjects picks objects in a specific order: Stack, Network, Secret,
-// Config, and finally Service. Stacks must come first, because every other
-// object type will depend on the latest stack, and Services must come last
-// because they depend on the other object types. The middle 3 object types,
-// Network, Secret, and Config, could be done in any order, but it's simpler
-// to just assign them an order
-func (d *dispatcher) pickObject() (string, string) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	for stack := range d.pendingStacks {
-		// it should be safe to delete from a map we're iterating over.
-		// especially considering we're not iterating any further.
-		delete(d.pendingStacks, stack)
-		return interfaces.StackEventType, stack
-	}
-	for nw := range d.pendingNetworks {
-		delete(d.pendingNetworks, nw)
-		return events.NetworkEventType, nw
-	}
-	for secret := range d.pendingSecrets {
-		delete(d.pendingSecrets, secret)
-		return events.SecretEventType, secret
-	}
-	for config := range d.pendingConfigs {
-		delete(d.pendingConfigs, config)
-		return events.ConfigEventType, config
-	}
-	for service := range d.pendingServices {
-		delete(d.pendingServices, service)
-		return events.ServiceEventType, service
+// pickObject picks objects in the order defined by kindOrder, among the
+// stacks which have no object being reconciled. Objects of a stack are thus
+// reconciled one at a time, in order.
+func (d *dispatcher) pickObject() (string, string, string) {
+	defer d.updatePendingObjects()
+	for _, kind := range kindOrder {
+		for stack, q := range d.queues {
+			if _, ok := d.busy[stack]; ok {
+				continue
+			}
+			for id := range q[kind] {
+				// it should be safe to delete from a map we're iterating
+				// over. especially considering we're not iterating any
+				// further.
+				delete(q[kind], id)
+				return stack, kind, id
+			}
+		}
 	}
-	return noMoreObjects, ""
+	return "", noMoreObjects, ""
 }
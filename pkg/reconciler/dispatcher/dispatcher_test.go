@@ -1,6 +1,10 @@
 package dispatcher
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -8,11 +12,13 @@ import (
 
 	"github.com/docker/stacks/pkg/mocks"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/docker/docker/api/types/events"
 
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/reconciler/reconciler"
 )
 
 type fakeRegisterFunc func(notifier.ObjectChangeNotifier)
@@ -53,6 +59,13 @@ func (i *idMatcher) Matches(x interface{}) bool {
 	return true
 }
 
+// fakeResolver is a StackResolver implemented by a function
+type fakeResolver func(kind, id string) string
+
+func (f fakeResolver) StackOf(kind, id string) string {
+	return f(kind, id)
+}
+
 func (i *idMatcher) String() string {
 	return "is one of the specified IDs (only once)"
 }
@@ -257,6 +270,270 @@ var _ = Describe("Dispatcher", func() {
 		})
 	})
 
+	Describe("reconciling with several workers", func() {
+		var (
+			d      *dispatcher
+			eventC chan interface{}
+		)
+
+		BeforeEach(func() {
+			// objects belong to the stack named by the first letter of
+			// their ID
+			resolver := fakeResolver(func(_, id string) string {
+				return id[:1]
+			})
+			d = newDispatcherWithOptions(mockReconciler, reg, Options{
+				Workers:  4,
+				Resolver: resolver,
+			})
+			eventC = make(chan interface{}, 32)
+		})
+
+		It("should reconcile objects of different stacks concurrently", func() {
+			bReconciled := make(chan struct{})
+			mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "a").Do(func(_, _ string) {
+				// this blocks forever unless stack b is reconciled while
+				// stack a is being reconciled
+				<-bReconciled
+			}).Return(nil)
+			mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "b").Do(func(_, _ string) {
+				close(bReconciled)
+			}).Return(nil)
+
+			eventC <- events.Message{Type: interfaces.StackEventType, Actor: events.Actor{ID: "a"}}
+			eventC <- events.Message{Type: interfaces.StackEventType, Actor: events.Actor{ID: "b"}}
+
+			time.AfterFunc(time.Second, func() {
+				close(eventC)
+			})
+			Expect(d.HandleEvents(eventC)).ToNot(HaveOccurred())
+		})
+
+		It("should reconcile objects of the same stack one at a time, in order", func() {
+			var (
+				mu         sync.Mutex
+				inProgress int
+				order      []string
+			)
+			mockReconciler.EXPECT().Reconcile(gomock.Any(), gomock.Any()).Do(func(kind, id string) {
+				mu.Lock()
+				inProgress++
+				Expect(inProgress).To(Equal(1))
+				order = append(order, id)
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				inProgress--
+				mu.Unlock()
+			}).Return(nil).Times(4)
+
+			eventC <- events.Message{Type: events.ServiceEventType, Actor: events.Actor{ID: "a-service"}}
+			eventC <- events.Message{Type: events.ConfigEventType, Actor: events.Actor{ID: "a-config"}}
+			eventC <- events.Message{Type: events.NetworkEventType, Actor: events.Actor{ID: "a-network"}}
+			eventC <- events.Message{Type: interfaces.StackEventType, Actor: events.Actor{ID: "a"}}
+
+			time.AfterFunc(time.Second, func() {
+				close(eventC)
+			})
+			Expect(d.HandleEvents(eventC)).ToNot(HaveOccurred())
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(order).To(Equal([]string{"a", "a-network", "a-config", "a-service"}))
+		})
+
+		It("should not resolve the stack of events carrying the stack label", func() {
+			d = newDispatcherWithOptions(mockReconciler, reg, Options{
+				Resolver: fakeResolver(func(kind, id string) string {
+					Fail("the stack of " + id + " was resolved")
+					return ""
+				}),
+			})
+			mockReconciler.EXPECT().Reconcile(events.ServiceEventType, "service1").Return(nil)
+
+			eventC <- events.Message{
+				Type: events.ServiceEventType,
+				Actor: events.Actor{
+					ID:         "service1",
+					Attributes: map[string]string{interfaces.StackLabel: "a"},
+				},
+			}
+			time.AfterFunc(100*time.Millisecond, func() {
+				close(eventC)
+			})
+			Expect(d.HandleEvents(eventC)).ToNot(HaveOccurred())
+		})
+
+		It("should not call the reconciler once HandleEvents has returned", func() {
+			mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "a").Do(func(_, _ string) {
+				close(eventC)
+				time.Sleep(10 * time.Millisecond)
+			}).Return(nil)
+
+			eventC <- events.Message{Type: interfaces.StackEventType, Actor: events.Actor{ID: "a"}}
+			Expect(d.HandleEvents(eventC)).ToNot(HaveOccurred())
+
+			// the reconciler mock fails the test if it is called again
+			d.Notify(interfaces.StackEventType, "b")
+			time.Sleep(10 * time.Millisecond)
+		})
+	})
+
+	Describe("reconciling objects which are not ready", func() {
+		var (
+			d *dispatcher
+
+			mu    sync.Mutex
+			calls []string
+		)
+
+		record := func(kind, id string) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, id)
+		}
+		recorded := func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string{}, calls...)
+		}
+		stackEvent := func(id string) events.Message {
+			return events.Message{Type: interfaces.StackEventType, Actor: events.Actor{ID: id}}
+		}
+
+		BeforeEach(func() {
+			calls = nil
+			d = newDispatcherWithOptions(mockReconciler, reg, Options{
+				Resolver: fakeResolver(func(kind, id string) string { return id }),
+			})
+		})
+
+		It("should reconcile them again once the delay has elapsed, without blocking the worker", func() {
+			gomock.InOrder(
+				mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack1").Do(record).Return(
+					&reconciler.NotReadyError{Reason: "not ready", RetryAfter: 200 * time.Millisecond},
+				),
+				mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack2").Do(record).Return(nil),
+				mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack1").Do(record).Return(nil),
+			)
+
+			eventC := make(chan interface{})
+			done := make(chan error)
+			go func() {
+				done <- d.HandleEvents(eventC)
+			}()
+
+			eventC <- stackEvent("stack1")
+			Eventually(recorded).Should(Equal([]string{"stack1"}))
+			eventC <- stackEvent("stack2")
+			Eventually(recorded).Should(Equal([]string{"stack1", "stack2"}))
+			Eventually(recorded).Should(Equal([]string{"stack1", "stack2", "stack1"}))
+
+			close(eventC)
+			Expect(<-done).To(Succeed())
+		})
+
+		It("should queue them right away when it stops", func() {
+			mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack1").Do(record).Return(
+				&reconciler.NotReadyError{Reason: "not ready", RetryAfter: time.Hour},
+			)
+
+			eventC := make(chan interface{})
+			done := make(chan error)
+			go func() {
+				done <- d.HandleEvents(eventC)
+			}()
+
+			eventC <- stackEvent("stack1")
+			Eventually(recorded).Should(Equal([]string{"stack1"}))
+			close(eventC)
+			Expect(<-done).To(Succeed())
+
+			Expect(d.State().Pending[interfaces.StackEventType]).To(Equal([]string{"stack1"}))
+		})
+	})
+
+	Describe("reporting metrics", func() {
+		var (
+			d *dispatcher
+		)
+
+		// pending returns the value of the pendingObjects gauge for the
+		// provided kind
+		pending := func(kind string) float64 {
+			return testutil.ToFloat64(pendingObjects.WithLabelValues(kind))
+		}
+
+		BeforeEach(func() {
+			d = newDispatcher(mockReconciler, reg)
+		})
+
+		It("should report the number of pending objects per kind", func() {
+			Expect(pending(interfaces.StackEventType)).To(Equal(0.0))
+
+			d.Notify(interfaces.StackEventType, "stack1")
+			d.Notify(interfaces.StackEventType, "stack2")
+			d.Notify(events.ServiceEventType, "service1")
+			Expect(pending(interfaces.StackEventType)).To(Equal(2.0))
+			Expect(pending(events.ServiceEventType)).To(Equal(1.0))
+			Expect(pending(events.NetworkEventType)).To(Equal(0.0))
+
+			_, kind, _ := d.pickObject()
+			Expect(kind).To(Equal(interfaces.StackEventType))
+			Expect(pending(interfaces.StackEventType)).To(Equal(1.0))
+		})
+	})
+
+	Describe("reporting its state", func() {
+		var (
+			d *dispatcher
+		)
+
+		BeforeEach(func() {
+			d = newDispatcher(mockReconciler, reg)
+		})
+
+		It("should report pending objects, retries and recent results", func() {
+			gomock.InOrder(
+				mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack1").Return(errors.New("failed")),
+				mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack1").Return(errors.New("failed again")),
+				mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, "stack2").Return(nil),
+			)
+
+			d.Notify(events.ServiceEventType, "service1")
+			Expect(d.reconcile(interfaces.StackEventType, "stack1")).To(HaveOccurred())
+			Expect(d.reconcile(interfaces.StackEventType, "stack1")).To(HaveOccurred())
+			Expect(d.reconcile(interfaces.StackEventType, "stack2")).ToNot(HaveOccurred())
+
+			state := d.State()
+			Expect(state.Pending[events.ServiceEventType]).To(Equal([]string{"service1"}))
+			Expect(state.Pending[interfaces.StackEventType]).To(BeEmpty())
+
+			Expect(state.Retrying).To(HaveLen(1))
+			Expect(state.Retrying[0].ID).To(Equal("stack1"))
+			Expect(state.Retrying[0].Failures).To(Equal(2))
+			Expect(state.Retrying[0].LastError).To(Equal("failed again"))
+
+			Expect(state.RecentResults).To(HaveLen(3))
+			Expect(state.RecentResults[0].ID).To(Equal("stack2"))
+			Expect(state.RecentResults[0].Error).To(BeEmpty())
+			Expect(state.RecentResults[2].Error).To(Equal("failed"))
+		})
+
+		It("should only keep the most recent results", func() {
+			mockReconciler.EXPECT().Reconcile(interfaces.StackEventType, gomock.Any()).Return(nil).AnyTimes()
+			for i := 0; i < maxRecentResults+5; i++ {
+				d.reconcile(interfaces.StackEventType, fmt.Sprintf("stack%d", i))
+			}
+
+			state := d.State()
+			Expect(state.RecentResults).To(HaveLen(maxRecentResults))
+			Expect(state.RecentResults[0].ID).To(Equal(fmt.Sprintf("stack%d", maxRecentResults+4)))
+		})
+	})
+
 	AfterEach(func() {
 		mockCtrl.Finish()
 	})
@@ -0,0 +1,57 @@
+package dispatcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pendingObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "stacks_dispatcher_pending_objects",
+		Help: "Number of objects waiting to be reconciled, by kind.",
+	}, []string{"kind"})
+	reconcilesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_reconciles_total",
+		Help: "Number of objects reconciled, by kind.",
+	}, []string{"kind"})
+	reconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_reconcile_errors_total",
+		Help: "Number of objects which failed to reconcile, by kind.",
+	}, []string{"kind"})
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "stacks_reconcile_duration_seconds",
+		Help: "Time taken to reconcile an object, by kind.",
+	}, []string{"kind"})
+	reconcileRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_reconcile_retries_total",
+		Help: "Number of objects queued again after failing to reconcile, by kind.",
+	}, []string{"kind"})
+)
+
+// RegisterMetrics registers the metrics of the dispatcher in the provided
+// registerer.
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		pendingObjects,
+		reconcilesTotal,
+		reconcileErrorsTotal,
+		reconcileDuration,
+		reconcileRetriesTotal,
+	} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updatePendingObjects sets the pendingObjects gauge from the sizes of the
+// queues. It must be called with the lock held.
+func (d *dispatcher) updatePendingObjects() {
+	for _, kind := range kindOrder {
+		pending := 0
+		for _, q := range d.queues {
+			pending += len(q[kind])
+		}
+		pendingObjects.WithLabelValues(kind).Set(float64(pending))
+	}
+}
@@ -0,0 +1,47 @@
+package dispatcher
+
+import (
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/reconciler"
+)
+
+// StackResolver finds the stack an object belongs to, so that the objects of
+// a stack can be reconciled one at a time.
+type StackResolver interface {
+	// StackOf returns the ID of the stack the object belongs to, or an
+	// empty string if it belongs to no stack or cannot be found.
+	StackOf(kind, id string) string
+}
+
+// labelStackResolver is a StackResolver which finds the stack of objects from
+// their stack label.
+type labelStackResolver struct {
+	cli reconciler.Client
+}
+
+// NewLabelStackResolver creates a StackResolver which finds the stack of
+// objects from their stack label, using the provided Client.
+func NewLabelStackResolver(cli reconciler.Client) StackResolver {
+	return &labelStackResolver{cli: cli}
+}
+
+func (r *labelStackResolver) StackOf(kind, id string) string {
+	switch kind {
+	case interfaces.StackEventType:
+		return id
+	case events.ServiceEventType:
+		// deleted services can't be resolved anymore. they are reconciled
+		// along with the other objects of no stack.
+		service, err := r.cli.GetService(id, false)
+		if err != nil {
+			return ""
+		}
+		return service.Spec.Annotations.Labels[interfaces.StackLabel]
+	default:
+		// TODO(dperny): the reconciler only handles stacks and services
+		// right now.
+		return ""
+	}
+}
@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/golang/mock/gomock"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+)
+
+var _ = Describe("labelStackResolver", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		mockClient *mocks.MockBackendClient
+		resolver   StackResolver
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockBackendClient(mockCtrl)
+		resolver = NewLabelStackResolver(mockClient)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("should resolve stacks to themselves", func() {
+		Expect(resolver.StackOf(interfaces.StackEventType, "stack1")).To(Equal("stack1"))
+	})
+
+	It("should resolve services from their stack label", func() {
+		mockClient.EXPECT().GetService("service1", false).Return(swarm.Service{
+			Spec: swarm.ServiceSpec{
+				Annotations: swarm.Annotations{
+					Labels: map[string]string{interfaces.StackLabel: "stack1"},
+				},
+			},
+		}, nil)
+		mockClient.EXPECT().GetService("service2", false).Return(swarm.Service{}, nil)
+		mockClient.EXPECT().GetService("service3", false).Return(swarm.Service{}, errors.New("not found"))
+
+		Expect(resolver.StackOf(events.ServiceEventType, "service1")).To(Equal("stack1"))
+		Expect(resolver.StackOf(events.ServiceEventType, "service2")).To(BeEmpty())
+		Expect(resolver.StackOf(events.ServiceEventType, "service3")).To(BeEmpty())
+	})
+})
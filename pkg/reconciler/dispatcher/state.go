@@ -0,0 +1,116 @@
+package dispatcher
+
+import (
+	"sort"
+	"time"
+)
+
+// maxRecentResults is the number of reconcile results kept by the dispatcher
+// for debugging purposes.
+const maxRecentResults = 50
+
+// State is a snapshot of the internal state of the dispatcher, exposed for
+// debugging purposes.
+type State struct {
+	// Pending contains the IDs of the objects waiting to be reconciled,
+	// keyed by kind.
+	Pending map[string][]string `json:"pending"`
+	// Busy contains the IDs of the stacks which have an object being
+	// reconciled. Objects which belong to no stack are grouped under an
+	// empty ID.
+	Busy []string `json:"busy"`
+	// Retrying contains the objects whose last reconciliation failed. Failed
+	// objects are queued again immediately, without backing off.
+	Retrying []RetryState `json:"retrying"`
+	// RecentResults contains the results of the last reconciliations, most
+	// recent first.
+	RecentResults []ReconcileResult `json:"recent_results"`
+}
+
+// RetryState describes an object which failed to reconcile.
+type RetryState struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	// Failures is the number of consecutive failed reconciliations.
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error"`
+	LastAttempt time.Time `json:"last_attempt"`
+}
+
+// ReconcileResult is the result of the reconciliation of an object.
+type ReconcileResult struct {
+	Kind     string        `json:"kind"`
+	ID       string        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// State returns a snapshot of the internal state of the dispatcher.
+func (d *dispatcher) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := State{
+		Pending:       map[string][]string{},
+		Busy:          setToSlice(d.busy),
+		Retrying:      []RetryState{},
+		RecentResults: make([]ReconcileResult, 0, len(d.results)),
+	}
+	for _, kind := range kindOrder {
+		pending := map[string]struct{}{}
+		for _, q := range d.queues {
+			for id := range q[kind] {
+				pending[id] = struct{}{}
+			}
+		}
+		state.Pending[kind] = setToSlice(pending)
+	}
+	for _, retry := range d.retries {
+		state.Retrying = append(state.Retrying, *retry)
+	}
+	sort.Slice(state.Retrying, func(i, j int) bool {
+		if state.Retrying[i].Kind != state.Retrying[j].Kind {
+			return state.Retrying[i].Kind < state.Retrying[j].Kind
+		}
+		return state.Retrying[i].ID < state.Retrying[j].ID
+	})
+	for i := len(d.results) - 1; i >= 0; i-- {
+		state.RecentResults = append(state.RecentResults, d.results[i])
+	}
+	return state
+}
+
+// recordResult records the result of the reconciliation of an object.
+func (d *dispatcher) recordResult(result ReconcileResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.results) == maxRecentResults {
+		d.results = append(d.results[:0], d.results[1:]...)
+	}
+	d.results = append(d.results, result)
+
+	key := result.Kind + "/" + result.ID
+	if result.Error == "" {
+		delete(d.retries, key)
+		return
+	}
+	retry, ok := d.retries[key]
+	if !ok {
+		retry = &RetryState{Kind: result.Kind, ID: result.ID}
+		d.retries[key] = retry
+	}
+	retry.Failures++
+	retry.LastError = result.Error
+	retry.LastAttempt = result.Time
+}
+
+func setToSlice(set map[string]struct{}) []string {
+	result := make([]string, 0, len(set))
+	for id := range set {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,123 @@
+package reconciler
+
+import (
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/docker/stacks/pkg/interfaces"
+)
+
+const (
+	// swarmServiceIDLabel is the label swarm sets on the containers of tasks
+	// with the ID of their service.
+	swarmServiceIDLabel = "com.docker.swarm.service.id"
+
+	// swarmScope is the scope of the events of swarm objects.
+	swarmScope = "swarm"
+)
+
+// eventCoalescer coalesces the events of each object, keeping the last event
+// of each object in the order objects were first seen.
+type eventCoalescer struct {
+	order  []string
+	events map[string]events.Message
+}
+
+func newEventCoalescer() *eventCoalescer {
+	return &eventCoalescer{
+		events: map[string]events.Message{},
+	}
+}
+
+// add adds an event, replacing the previous event of the same object.
+func (c *eventCoalescer) add(msg events.Message) {
+	key := msg.Type + "/" + msg.Actor.ID
+	if _, ok := c.events[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.events[key] = msg
+}
+
+// drain returns the coalesced events, and resets the coalescer.
+func (c *eventCoalescer) drain() []events.Message {
+	result := make([]events.Message, 0, len(c.order))
+	for _, key := range c.order {
+		result = append(result, c.events[key])
+	}
+	c.order = nil
+	c.events = map[string]events.Message{}
+	return result
+}
+
+// objectIndex keeps track of the objects of the events, and of the stacks
+// they belong to. It is implemented by the cache.Cache.
+type objectIndex interface {
+	// StackOf returns the stack the object belongs to.
+	StackOf(kind, id string) string
+	// Refresh updates the object, and returns the stack it belongs to.
+	Refresh(kind, id string) string
+	// Forget forgets an object which has been removed.
+	Forget(kind, id string)
+}
+
+// eventFilter decides which events are dispatched to the reconciler, and
+// which stacks need their status refreshed following an event. It keeps the
+// objectIndex up to date with the events.
+type eventFilter struct {
+	index objectIndex
+}
+
+// filter returns whether the event must be dispatched to the reconciler, and
+// the stack whose status may have changed following the event, if any.
+//
+// Events of objects which don't belong to a stack are not dispatched. Task
+// events, i.e. events of the containers of swarm tasks, are never dispatched
+// and only cause the status of their stack to be refreshed.
+func (f *eventFilter) filter(msg events.Message) (bool, string) {
+	switch msg.Type {
+	case interfaces.StackEventType:
+		if msg.Action == interfaces.StackDriftAction {
+			// drift is reported by the reconciler itself.
+			return false, msg.Actor.ID
+		}
+		f.index.Refresh(msg.Type, msg.Actor.ID)
+		return true, msg.Actor.ID
+	case events.ContainerEventType:
+		serviceID, ok := msg.Actor.Attributes[swarmServiceIDLabel]
+		if !ok {
+			return false, ""
+		}
+		return false, f.index.StackOf(events.ServiceEventType, serviceID)
+	case events.ServiceEventType, events.NetworkEventType, events.SecretEventType, events.ConfigEventType:
+		if msg.Scope != swarmScope {
+			return false, ""
+		}
+		var stack string
+		if msg.Action == "remove" {
+			// the object can't be resolved anymore, rely on the cache.
+			stack = f.index.StackOf(msg.Type, msg.Actor.ID)
+			f.index.Forget(msg.Type, msg.Actor.ID)
+		} else {
+			// the labels of the object may have changed.
+			stack = f.index.Refresh(msg.Type, msg.Actor.ID)
+		}
+		if stack == "" {
+			return false, ""
+		}
+		return true, stack
+	default:
+		return false, ""
+	}
+}
+
+// withStack returns a copy of an event carrying the stack of its object in
+// the stack label of its actor, so that the dispatcher doesn't need to
+// resolve the stack again.
+func withStack(msg events.Message, stack string) events.Message {
+	attributes := make(map[string]string, len(msg.Actor.Attributes)+1)
+	for k, v := range msg.Actor.Attributes {
+		attributes[k] = v
+	}
+	attributes[interfaces.StackLabel] = stack
+	msg.Actor.Attributes = attributes
+	return msg
+}
@@ -0,0 +1,222 @@
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/reconciler/cache"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func serviceEvent(action, id string) events.Message {
+	return events.Message{
+		Type:   events.ServiceEventType,
+		Scope:  swarmScope,
+		Action: action,
+		Actor:  events.Actor{ID: id},
+	}
+}
+
+func stackService(id, stack string) swarm.Service {
+	return swarm.Service{
+		ID: id,
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Labels: map[string]string{interfaces.StackLabel: stack},
+			},
+		},
+	}
+}
+
+var _ = Describe("eventCoalescer", func() {
+	It("should keep the last event of each object, in order of first appearance", func() {
+		c := newEventCoalescer()
+		c.add(serviceEvent("create", "service1"))
+		c.add(serviceEvent("create", "service2"))
+		c.add(serviceEvent("update", "service1"))
+		c.add(events.Message{Type: interfaces.StackEventType, Actor: events.Actor{ID: "service1"}})
+
+		Expect(c.drain()).To(Equal([]events.Message{
+			serviceEvent("update", "service1"),
+			serviceEvent("create", "service2"),
+			{Type: interfaces.StackEventType, Actor: events.Actor{ID: "service1"}},
+		}))
+		Expect(c.drain()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("withStack", func() {
+	It("should add the stack label to a copy of the event", func() {
+		msg := serviceEvent("update", "service1")
+		msg.Actor.Attributes = map[string]string{"name": "web"}
+
+		Expect(withStack(msg, "stack1").Actor.Attributes).To(Equal(map[string]string{
+			"name":                "web",
+			interfaces.StackLabel: "stack1",
+		}))
+		Expect(msg.Actor.Attributes).To(Equal(map[string]string{"name": "web"}))
+	})
+})
+
+var _ = Describe("eventFilter", func() {
+	var (
+		ctrl       *gomock.Controller
+		mockClient *mocks.MockBackendClient
+		f          *eventFilter
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockBackendClient(ctrl)
+		f = &eventFilter{
+			index: cache.New(mockClient),
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should dispatch stack events", func() {
+		mockClient.EXPECT().GetSwarmStack("stack1").Return(interfaces.SwarmStack{ID: "stack1"}, nil)
+		dispatch, stack := f.filter(events.Message{
+			Type:  interfaces.StackEventType,
+			Actor: events.Actor{ID: "stack1"},
+		})
+		Expect(dispatch).To(BeTrue())
+		Expect(stack).To(Equal("stack1"))
+	})
+
+	It("should only refresh the status of the stack of drift events", func() {
+		dispatch, stack := f.filter(events.Message{
+			Type:   interfaces.StackEventType,
+			Action: interfaces.StackDriftAction,
+			Actor:  events.Actor{ID: "stack1"},
+		})
+		Expect(dispatch).To(BeFalse())
+		Expect(stack).To(Equal("stack1"))
+	})
+
+	It("should only dispatch the events of services belonging to a stack", func() {
+		mockClient.EXPECT().GetService("service1", false).Return(stackService("service1", "stack1"), nil)
+		mockClient.EXPECT().GetService("service2", false).Return(swarm.Service{}, nil)
+
+		dispatch, stack := f.filter(serviceEvent("update", "service1"))
+		Expect(dispatch).To(BeTrue())
+		Expect(stack).To(Equal("stack1"))
+
+		dispatch, stack = f.filter(serviceEvent("update", "service2"))
+		Expect(dispatch).To(BeFalse())
+		Expect(stack).To(BeEmpty())
+	})
+
+	It("should dispatch the removal of services belonging to a stack", func() {
+		mockClient.EXPECT().GetService("service1", false).Return(stackService("service1", "stack1"), nil)
+		dispatch, _ := f.filter(serviceEvent("create", "service1"))
+		Expect(dispatch).To(BeTrue())
+
+		// the removed service can't be inspected anymore
+		dispatch, stack := f.filter(serviceEvent("remove", "service1"))
+		Expect(dispatch).To(BeTrue())
+		Expect(stack).To(Equal("stack1"))
+	})
+
+	It("should not dispatch events of local objects", func() {
+		dispatch, stack := f.filter(events.Message{
+			Type:  events.NetworkEventType,
+			Scope: "local",
+			Actor: events.Actor{ID: "network1"},
+		})
+		Expect(dispatch).To(BeFalse())
+		Expect(stack).To(BeEmpty())
+	})
+
+	It("should only refresh the status of the stack of task events", func() {
+		mockClient.EXPECT().GetService("service1", false).Return(stackService("service1", "stack1"), nil)
+
+		dispatch, stack := f.filter(events.Message{
+			Type:   events.ContainerEventType,
+			Action: "die",
+			Actor: events.Actor{
+				ID:         "container1",
+				Attributes: map[string]string{swarmServiceIDLabel: "service1"},
+			},
+		})
+		Expect(dispatch).To(BeFalse())
+		Expect(stack).To(Equal("stack1"))
+
+		// containers which aren't tasks are ignored
+		dispatch, stack = f.filter(events.Message{
+			Type:  events.ContainerEventType,
+			Actor: events.Actor{ID: "container2"},
+		})
+		Expect(dispatch).To(BeFalse())
+		Expect(stack).To(BeEmpty())
+	})
+})
+
+var _ = Describe("statusCache", func() {
+	var (
+		ctrl       *gomock.Controller
+		mockClient *mocks.MockBackendClient
+		c          *statusCache
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockBackendClient(ctrl)
+		c = newStatusCache(mockClient)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("should compute missing statuses and cache them", func() {
+		mockClient.EXPECT().GetStackStatus("stack1").Return(types.StackStatus{Phase: types.StackPhaseRunning}, nil)
+		mockClient.EXPECT().GetStackStatus("stack2").Return(types.StackStatus{}, errors.New("unavailable"))
+
+		Expect(c.phases([]string{"stack1", "stack2"})).To(Equal(map[string]string{
+			"stack1": types.StackPhaseRunning,
+			"stack2": stackPhaseUnknown,
+		}))
+
+		// stack1 is cached, stack2 is computed again
+		mockClient.EXPECT().GetStackStatus("stack2").Return(types.StackStatus{Phase: types.StackPhasePending}, nil)
+		Expect(c.phases([]string{"stack1", "stack2"})).To(Equal(map[string]string{
+			"stack1": types.StackPhaseRunning,
+			"stack2": types.StackPhasePending,
+		}))
+	})
+
+	It("should refresh requested statuses in the background", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		go c.run(stop)
+
+		refreshed := make(chan struct{})
+		mockClient.EXPECT().GetStackStatus("stack1").Return(
+			types.StackStatus{Phase: types.StackPhaseConverged}, nil,
+		).Do(func(string) { close(refreshed) })
+		c.requestRefresh("stack1")
+		Eventually(refreshed).Should(BeClosed())
+
+		Eventually(func() map[string]string {
+			return c.phases([]string{"stack1"})
+		}).Should(Equal(map[string]string{"stack1": types.StackPhaseConverged}))
+	})
+
+	It("should drop the statuses of deleted stacks", func() {
+		mockClient.EXPECT().GetStackStatus("stack1").Return(types.StackStatus{}, errdefs.NotFound(errors.New("not found")))
+		Expect(c.phases([]string{"stack1"})).To(Equal(map[string]string{"stack1": stackPhaseUnknown}))
+		Expect(c.statuses).To(BeEmpty())
+	})
+})
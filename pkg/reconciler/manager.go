@@ -7,8 +7,10 @@ import (
 
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/cache"
 	"github.com/docker/stacks/pkg/reconciler/dispatcher"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
 	"github.com/docker/stacks/pkg/reconciler/reconciler"
@@ -17,6 +19,14 @@ import (
 const (
 	// eventsChanBufferDepth defines the size of the channel buffer for events
 	eventsChanBufferDepth = 30
+
+	// defaultEventDebounce is the window during which the events of an
+	// object are coalesced, if Options.EventDebounce is not set.
+	defaultEventDebounce = 100 * time.Millisecond
+
+	// defaultResyncInterval is the interval at which the cache is synced
+	// with the cluster, if Options.ResyncInterval is not set.
+	defaultResyncInterval = 5 * time.Minute
 )
 
 // Manager is the main entrypoint for the reconciler package; users of
@@ -38,6 +48,19 @@ type Manager struct {
 	d dispatcher.Dispatcher
 	r reconciler.Reconciler
 
+	// cache holds the objects the reconciler reads. It is kept up to date
+	// from the events, and is also used to find the stacks of objects.
+	cache    *cache.Cache
+	statuses *statusCache
+	debounce time.Duration
+	resync   time.Duration
+
+	// leader and subscriptions are accessed atomically. leader is 1 while
+	// the Manager runs on the leader, and subscriptions is the number of
+	// active event stream subscriptions.
+	leader        int32
+	subscriptions int32
+
 	nodeID string
 	// notifyCluster is used to signal from JoinCluster and LeaveCluster. It
 	// will only ever be read from in one place, we can use a channel instead
@@ -45,12 +68,44 @@ type Manager struct {
 	notifyCluster chan struct{}
 }
 
+// Options configures a Manager.
+type Options struct {
+	// Workers is the number of objects reconciled concurrently. Objects of
+	// the same stack are always reconciled one at a time. Defaults to 1.
+	Workers int
+	// EventDebounce is the window during which the events of an object are
+	// coalesced before being dispatched. Defaults to 100ms.
+	EventDebounce time.Duration
+	// ResyncInterval is the interval at which the cache is synced with the
+	// cluster, in case the event stream missed any changes. Defaults to 5
+	// minutes.
+	ResyncInterval time.Duration
+}
+
 // New creates a new Manager, the main entrypoint for the reconciler package,
 // along with all of the dependent types
 func New(client interfaces.BackendClient) *Manager {
+	return NewWithOptions(client, Options{})
+}
+
+// NewWithOptions creates a new Manager configured with the provided options,
+// along with all of the dependent types
+func NewWithOptions(client interfaces.BackendClient, opts Options) *Manager {
+	debounce := opts.EventDebounce
+	if debounce <= 0 {
+		debounce = defaultEventDebounce
+	}
+	resync := opts.ResyncInterval
+	if resync <= 0 {
+		resync = defaultResyncInterval
+	}
 	m := &Manager{
-		client: client,
-		stop:   make(chan struct{}),
+		client:   client,
+		stop:     make(chan struct{}),
+		cache:    cache.New(client),
+		statuses: newStatusCache(client),
+		debounce: debounce,
+		resync:   resync,
 		// notifyCluster is buffered to 1. This means that we can leave a
 		// notification in the buffer for the reader to get at any time. When
 		// we try to write to the channel, we should do so in a select. If the
@@ -62,8 +117,11 @@ func New(client interfaces.BackendClient) *Manager {
 	// create a new Dispatcher and Reconciler, with a NotificationForwarder to
 	// put between them
 	n := notifier.NewNotificationForwarder()
-	m.r = reconciler.New(n, m.client)
-	m.d = dispatcher.New(m.r, n)
+	m.r = reconciler.New(n, cache.NewReconcilerClient(m.cache, m.client))
+	m.d = dispatcher.NewWithOptions(m.r, n, dispatcher.Options{
+		Workers:  opts.Workers,
+		Resolver: m.cache,
+	})
 	return m
 }
 
@@ -89,7 +147,9 @@ func (m *Manager) Run() error {
 		// closed
 		for {
 			m.waitReady()
+			m.setLeader(true)
 			err = m.run()
+			m.setLeader(false)
 			select {
 			case <-m.stop:
 				return
@@ -141,8 +201,8 @@ func (m *Manager) Stop() {
 func (m *Manager) waitReady() {
 	// set up a watch for node events
 	f := filters.NewArgs(filters.Arg("type", events.NodeEventType))
-	_, eventC := m.client.SubscribeToEvents(time.Time{}, time.Time{}, f)
-	defer m.client.UnsubscribeFromEvents(eventC)
+	eventC, unsubscribe := m.subscribe(f)
+	defer unsubscribe()
 	for {
 		select {
 		case <-m.notifyCluster:
@@ -162,6 +222,7 @@ func (m *Manager) waitReady() {
 			// the result of that shouldn't be catastrophic, and anyway
 			// standalone isn't a supported production use case.
 			if !ok {
+				eventStreamReconnectsTotal.Inc()
 				return
 			}
 			if m.checkNodeEventLeadership(ev) {
@@ -205,14 +266,23 @@ func (m *Manager) run() error {
 	// couple of Time arguments, but we won't use them right now. Instead,
 	// we'll pass a raw time.Time, which is the zero-value. Additionally, to
 	// hopefully restrict the firehose a bit, we'll filter events based on
-	// scope.
-	f := filters.NewArgs(filters.Arg("scope", "swarm"))
-	// throw away the first return value, it'll be an empty list anyway and we
-	// don't need it.
-	_, eventC := m.client.SubscribeToEvents(time.Time{}, time.Time{}, f)
+	// type. Container events are needed for the tasks running on this node,
+	// the others are filtered out by the eventFilter.
+	f := filters.NewArgs(
+		filters.Arg("type", interfaces.StackEventType),
+		filters.Arg("type", events.ServiceEventType),
+		filters.Arg("type", events.NetworkEventType),
+		filters.Arg("type", events.SecretEventType),
+		filters.Arg("type", events.ConfigEventType),
+		filters.Arg("type", events.NodeEventType),
+		filters.Arg("type", events.ContainerEventType),
+	)
+	// subscribe throws away the list of past events, it'll be empty anyway
+	// and we don't need it.
+	eventC, unsubscribe := m.subscribe(f)
 	// make sure we unsubscribe from events when we're done. I think if we
 	// don't do this, the channel may leak?
-	defer m.client.UnsubscribeFromEvents(eventC)
+	defer unsubscribe()
 
 	// now, we want to make sure that the events channel is buffered, for the
 	// benefit of the Dispatcher. The dispatcher is designed such that it
@@ -236,12 +306,53 @@ func (m *Manager) run() error {
 	// could have multiple dispatchers and reconcilers, but that's an idea for
 	// another day.
 	var wg sync.WaitGroup
+
+	// now that we're the leader, prime the cache. If this fails, the
+	// reconciler falls back to reading from the cluster until the next
+	// resync.
+	if err := m.cache.Sync(); err != nil {
+		logrus.Errorf("unable to sync the cache: %s", err)
+	}
+
+	// the statuses of stacks are refreshed, and the cache is resynced, in
+	// the background for as long as we run.
+	statusStop := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		m.statuses.run(statusStop)
+	}()
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(m.resync)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.cache.Sync(); err != nil {
+					logrus.Errorf("unable to resync the cache: %s", err)
+				}
+			case <-statusStop:
+				return
+			}
+		}
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		// every case where we return from this function should result in the
 		// dispatcherChan being closed, so just stick it in a defer.
 		defer close(dispatcherChan)
+		defer close(statusStop)
+
+		// events are coalesced for the debounce window following the first
+		// event of a batch, and then filtered and forwarded to the
+		// dispatcher. flush is nil while there is no batch.
+		coalescer := newEventCoalescer()
+		filter := &eventFilter{index: m.cache}
+		var flush <-chan time.Time
+
 		for {
 			select {
 			case ev, ok := <-eventC:
@@ -250,21 +361,45 @@ func (m *Manager) run() error {
 					// without asking for it to be shutdown? right now, this
 					// case isn't handled well. We should probably start a new
 					// event channel, though.
+					eventStreamReconnectsTotal.Inc()
 					return
 				}
-				// check if we're currently the leader. if we're not, we should
-				// return, closing the dispatcher
-				if !m.checkNodeEventLeadership(ev) {
-					return
+				msg, ok := ev.(events.Message)
+				if !ok {
+					continue
 				}
-				// even though dispatcherChan is buffered, we don't want to
-				// block on a send. If something happens and dispatcherChan
-				// gets full, we need to be able to bail out of attempting to
-				// send to it.
-				select {
-				case dispatcherChan <- ev:
-				case <-m.stop:
-					return
+				// node events are only used to check if we're still the
+				// leader. if we're not, we should return, closing the
+				// dispatcher
+				if msg.Type == events.NodeEventType {
+					if msg.Actor.ID == m.nodeID && !m.checkLeadership() {
+						return
+					}
+					continue
+				}
+				coalescer.add(msg)
+				if flush == nil {
+					flush = time.After(m.debounce)
+				}
+			case <-flush:
+				flush = nil
+				for _, msg := range coalescer.drain() {
+					dispatch, stack := filter.filter(msg)
+					if stack != "" {
+						m.statuses.requestRefresh(stack)
+					}
+					if !dispatch {
+						continue
+					}
+					// even though dispatcherChan is buffered, we don't want
+					// to block on a send. If something happens and
+					// dispatcherChan gets full, we need to be able to bail
+					// out of attempting to send to it.
+					select {
+					case dispatcherChan <- withStack(msg, stack):
+					case <-m.stop:
+						return
+					}
 				}
 			case <-m.notifyCluster:
 				if !m.checkLeadership() {
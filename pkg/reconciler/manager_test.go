@@ -7,13 +7,16 @@ import (
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 
 	// strictly speaking, we don't have to use errdefs here -- no error
 	// checking actually occurs. However, to future-proof the tests, in case
 	// something happens later, we should return errdefs errors
 	"github.com/docker/docker/errdefs"
 
+	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
 )
 
 var _ = Describe("reconciler.Manager", func() {
@@ -84,4 +87,32 @@ var _ = Describe("reconciler.Manager", func() {
 			})
 		})
 	})
+
+	Describe("RegisterMetrics", func() {
+		It("should only list the stacks when the metrics are gathered", func() {
+			registry := prometheus.NewRegistry()
+			// the mock fails the test if ListSwarmStacks is called while
+			// registering
+			Expect(m.RegisterMetrics(registry)).To(Succeed())
+
+			mockClient.EXPECT().ListSwarmStacks().Return([]interfaces.SwarmStack{}, nil)
+			families, err := registry.Gather()
+			Expect(err).ToNot(HaveOccurred())
+
+			phases := map[string]float64{}
+			for _, family := range families {
+				if family.GetName() != "stacks_phase" {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					phases[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+				}
+			}
+			Expect(phases).To(Equal(map[string]float64{
+				types.StackPhasePending:   0,
+				types.StackPhaseRunning:   0,
+				types.StackPhaseConverged: 0,
+			}))
+		})
+	})
 })
@@ -0,0 +1,88 @@
+package reconciler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/reconciler/cache"
+	"github.com/docker/stacks/pkg/reconciler/dispatcher"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// stackPhaseUnknown is the phase reported for stacks whose status cannot be
+// computed.
+const stackPhaseUnknown = "unknown"
+
+var (
+	leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "stacks_reconciler_leader",
+		Help: "Whether the reconciler is running on the swarm leader (1) or waiting for leadership (0).",
+	})
+	eventStreamReconnectsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stacks_reconciler_event_stream_reconnects_total",
+		Help: "Number of times the event stream was lost and subscribed to again.",
+	})
+	stackPhaseDesc = prometheus.NewDesc(
+		"stacks_phase",
+		"Number of stacks, by phase.",
+		[]string{"phase"}, nil,
+	)
+)
+
+// RegisterMetrics registers the metrics of the reconciler, including those of
+// its dispatcher and cache and the number of stacks per phase, in the provided
+// registerer.
+func (m *Manager) RegisterMetrics(r prometheus.Registerer) error {
+	if err := dispatcher.RegisterMetrics(r); err != nil {
+		return err
+	}
+	if err := cache.RegisterMetrics(r); err != nil {
+		return err
+	}
+	for _, c := range []prometheus.Collector{
+		leader,
+		eventStreamReconnectsTotal,
+		stackPhaseCollector{m},
+	} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stackPhaseCollector is a prometheus.Collector reporting the number of
+// stacks per phase. The stacks are only listed when the metrics are
+// collected.
+type stackPhaseCollector struct {
+	m *Manager
+}
+
+func (c stackPhaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stackPhaseDesc
+}
+
+func (c stackPhaseCollector) Collect(ch chan<- prometheus.Metric) {
+	for phase, count := range c.m.countStacksByPhase() {
+		ch <- prometheus.MustNewConstMetric(stackPhaseDesc, prometheus.GaugeValue, count, phase)
+	}
+}
+
+// countStacksByPhase returns the number of stacks in each phase.
+func (m *Manager) countStacksByPhase() map[string]float64 {
+	counts := map[string]float64{
+		types.StackPhasePending:   0,
+		types.StackPhaseRunning:   0,
+		types.StackPhaseConverged: 0,
+	}
+
+	phases, err := m.stackPhases()
+	if err != nil {
+		logrus.Errorf("unable to list stacks for metrics: %s", err)
+		return counts
+	}
+	for _, phase := range phases {
+		counts[phase]++
+	}
+	return counts
+}
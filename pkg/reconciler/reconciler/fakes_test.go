@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/errdefs"
@@ -35,6 +36,17 @@ type fakeReconcilerClient struct {
 
 	services       map[string]*swarm.Service
 	servicesByName map[string]string
+
+	// adopted contains the service specs adopted into each stack, keyed by
+	// stack ID, and stackEvents the stack events logged.
+	adopted     map[string][]swarm.ServiceSpec
+	stackEvents []events.Message
+
+	// tasks maps service id -> tasks of the service
+	tasks map[string][]swarm.Task
+
+	secrets       map[string]*swarm.Secret
+	secretsByName map[string]string
 }
 
 // error definitions to reuse
@@ -50,6 +62,10 @@ func newFakeReconcilerClient() *fakeReconcilerClient {
 		stacksByName:   map[string]string{},
 		services:       map[string]*swarm.Service{},
 		servicesByName: map[string]string{},
+		adopted:        map[string][]swarm.ServiceSpec{},
+		tasks:          map[string][]swarm.Task{},
+		secrets:        map[string]*swarm.Secret{},
+		secretsByName:  map[string]string{},
 	}
 }
 
@@ -73,6 +89,37 @@ func (f *fakeReconcilerClient) GetSwarmStack(idOrName string) (interfaces.SwarmS
 	return *stack, nil
 }
 
+// AdoptServiceSpec records the service spec adopted into a stack. It does not
+// update the stack.
+func (f *fakeReconcilerClient) AdoptServiceSpec(stackID string, spec swarm.ServiceSpec) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stack, ok := f.stacks[stackID]
+	if !ok {
+		return notFound
+	}
+	// if you add the "makemeforbidden" label to a stack, the changes to its
+	// services cannot be adopted
+	if _, ok := stack.Spec.Annotations.Labels["makemeforbidden"]; ok {
+		return errdefs.Forbidden(errors.New("the changes cannot be adopted"))
+	}
+	f.adopted[stackID] = append(f.adopted[stackID], spec)
+	return nil
+}
+
+// LogStackEvent records a stack event.
+func (f *fakeReconcilerClient) LogStackEvent(id, action string, attributes map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stackEvents = append(f.stackEvents, events.Message{
+		Type:   interfaces.StackEventType,
+		Action: action,
+		Actor:  events.Actor{ID: id, Attributes: attributes},
+	})
+}
+
 // GetServices implements the GetServices method of the BackendClient,
 // returning a list of services. It only supports 1 kind of filter, which is
 // a filter for stack ID.
@@ -204,6 +251,94 @@ func (f *fakeReconcilerClient) RemoveService(idOrName string) error {
 	return nil
 }
 
+// GetTasks returns the tasks of the service provided with the "service" filter.
+// Other filters are ignored.
+func (f *fakeReconcilerClient) GetTasks(opts dockerTypes.TaskListOptions) ([]swarm.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var tasks []swarm.Task
+	for _, serviceID := range opts.Filters.Get("service") {
+		tasks = append(tasks, f.tasks[serviceID]...)
+	}
+	return tasks, nil
+}
+
+// GetSecrets returns a list of secrets. Like GetServices, it only supports a
+// filter for stack ID.
+func (f *fakeReconcilerClient) GetSecrets(opts dockerTypes.SecretListOptions) ([]swarm.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var (
+		stackID   string
+		hasFilter bool
+	)
+	if opts.Filters.Len() != 0 {
+		var ok bool
+		stackID, ok = getStackIDFromLabelFilter(opts.Filters)
+		if !ok {
+			return nil, invalidArg
+		}
+		hasFilter = true
+	}
+
+	secrets := []swarm.Secret{}
+	for _, secret := range f.secrets {
+		if hasFilter && secret.Spec.Labels[interfaces.StackLabel] != stackID {
+			continue
+		}
+		secrets = append(secrets, *secret)
+	}
+	return secrets, nil
+}
+
+// CreateSecret creates a secret, failing if a secret of the same name exists.
+func (f *fakeReconcilerClient) CreateSecret(spec swarm.SecretSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.secretsByName[spec.Name]; ok {
+		return "", invalidArg
+	}
+
+	secret := &swarm.Secret{
+		ID:   f.newID("secret"),
+		Spec: spec,
+	}
+	f.secretsByName[spec.Name] = secret.ID
+	f.secrets[secret.ID] = secret
+	return secret.ID, nil
+}
+
+// RemoveSecret removes a secret. Like in swarm, secrets which services refer
+// to can't be removed.
+func (f *fakeReconcilerClient) RemoveSecret(idOrName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := resolveID(f.secretsByName, idOrName)
+	secret, ok := f.secrets[id]
+	if !ok {
+		return notFound
+	}
+
+	for _, service := range f.services {
+		if service.Spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		for _, ref := range service.Spec.TaskTemplate.ContainerSpec.Secrets {
+			if ref.SecretID == secret.ID {
+				return invalidArg
+			}
+		}
+	}
+
+	delete(f.secrets, secret.ID)
+	delete(f.secretsByName, secret.Spec.Name)
+	return nil
+}
+
 // resolveID takes a value that might be an ID or and figures out which it is,
 // returning the ID
 func resolveID(namesToIds map[string]string, key string) string {
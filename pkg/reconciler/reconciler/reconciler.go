@@ -3,6 +3,8 @@ package reconciler
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
@@ -11,8 +13,23 @@ import (
 	"github.com/docker/docker/errdefs"
 	"github.com/sirupsen/logrus"
 
+	"github.com/docker/stacks/pkg/drift"
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+var (
+	// dependencyPollInterval is the interval at which the services a service
+	// depends on are checked while waiting for them to be ready, and
+	// dependencyTimeout the time after which the wait is reported as timed
+	// out. They are variables so that tests can shorten them.
+	dependencyPollInterval = time.Second
+	dependencyTimeout      = 2 * time.Minute
+
+	// removalPollInterval is the interval at which a deleted stack is
+	// reconciled again while waiting for its services to be removed.
+	removalPollInterval = time.Second
 )
 
 // Client is the subset of interfaces.BackendClient methods needed to
@@ -20,6 +37,8 @@ import (
 type Client interface {
 	// stack methods
 	GetSwarmStack(string) (interfaces.SwarmStack, error)
+	AdoptServiceSpec(string, swarm.ServiceSpec) error
+	LogStackEvent(string, string, map[string]string)
 
 	// service methods
 	GetServices(dockerTypes.ServiceListOptions) ([]swarm.Service, error)
@@ -28,6 +47,14 @@ type Client interface {
 	UpdateService(string, uint64, swarm.ServiceSpec, dockerTypes.ServiceUpdateOptions, bool) (*dockerTypes.ServiceUpdateResponse, error)
 	RemoveService(string) error
 
+	// task methods
+	GetTasks(dockerTypes.TaskListOptions) ([]swarm.Task, error)
+
+	// secret methods
+	GetSecrets(dockerTypes.SecretListOptions) ([]swarm.Secret, error)
+	CreateSecret(swarm.SecretSpec) (string, error)
+	RemoveSecret(string) error
+
 	// TODO(dperny): there's a lot more where this came from, but these are the
 	// parts we need to make this part go
 }
@@ -59,6 +86,26 @@ type Reconciler interface {
 type reconciler struct {
 	notify notifier.ObjectChangeNotifier
 	cli    Client
+
+	// mu protects reported, which contains the drift last reported for each
+	// service, keyed by service ID, so that the same drift is only reported
+	// once, and waiting, which contains the time since which the services
+	// waited for have not been ready, keyed by stack ID and service name.
+	mu       sync.Mutex
+	reported map[string]string
+	waiting  map[string]map[string]time.Time
+}
+
+// NotReadyError is returned by Reconcile when an object cannot be reconciled
+// yet, e.g. because the services a service depends on are not ready. The
+// object should be reconciled again after RetryAfter.
+type NotReadyError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *NotReadyError) Error() string {
+	return e.Reason
 }
 
 // New creates a new Reconciler object, which uses the provided
@@ -71,8 +118,10 @@ func New(notify notifier.ObjectChangeNotifier, cli Client) Reconciler {
 // raw object, for use internally, instead of the interface as used externally.
 func newReconciler(notify notifier.ObjectChangeNotifier, cli Client) *reconciler {
 	r := &reconciler{
-		notify: notify,
-		cli:    cli,
+		notify:   notify,
+		cli:      cli,
+		reported: map[string]string{},
+		waiting:  map[string]map[string]time.Time{},
 	}
 	return r
 }
@@ -102,28 +151,169 @@ func (r *reconciler) reconcileStack(id string) error {
 		return err
 	}
 
+	// the services of a paused stack are left as they are. the whole stack
+	// is reconciled again once it is resumed.
+	if stack.Spec.Paused {
+		logrus.Debugf("Stack %s is paused, not reconciling it", id)
+		return nil
+	}
+
+	// the secrets of the secret properties must exist before the services
+	// referring to them are created or updated.
+	secretIDs, err := r.createPropertySecrets(stack)
+	if err != nil {
+		return err
+	}
+
+	// the services are sorted so that they come after the services they
+	// depend on, so they are rolled out in that order. the services which
+	// are up to date are left alone.
+	ready := map[string]bool{}
 	for _, spec := range stack.Spec.Services {
+		desired := drift.ServiceSpec(stack.ID, drift.WithSecretIDs(spec, secretIDs))
 		// try getting the service to see if it already exists
 		service, err := r.cli.GetService(spec.Annotations.Name, false)
-		// if it doesn't exist create it now
-		if errdefs.IsNotFound(err) {
+		exists := err == nil
+		if err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+		if exists && reflect.DeepEqual(desired, service.Spec) {
+			continue
+		}
+
+		if stack.Spec.WaitForDependencies {
+			if err := r.waitForDependencies(stack, spec.Annotations.Name, ready); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case !exists:
+			// if it doesn't exist create it now
 			// TODO(dperny): second 2 arguments?
-			// TODO(dperny): we don't cache service data right now, but we
-			// might want to do so later
-			logrus.Debugf("Unable to find existing service, creating service with spec %+v", spec)
-			_, err := r.cli.CreateService(spec, "", false)
-			if err != nil {
+			logrus.Debugf("Unable to find existing service, creating service with spec %+v", desired)
+			if _, err := r.cli.CreateService(desired, "", false); err != nil {
+				return err
+			}
+		case service.Spec.Annotations.Labels[interfaces.StackLabel] == "":
+			// services created before they were labeled with their stack
+			// can't be reconciled on their own, so update them now.
+			if err := r.updateService(service, desired); err != nil {
+				return err
+			}
+		default:
+			if err := r.reconcileService(service.ID); err != nil {
 				return err
 			}
-		} else if err != nil {
+		}
+	}
+
+	// the services removed from the stack, including while it was paused,
+	// are removed when they are reconciled.
+	if err := r.notifyRemovedServices(stack); err != nil {
+		return err
+	}
+	return r.removePropertySecrets(stack)
+}
+
+// createPropertySecrets creates the secrets of the secret properties of a
+// stack which don't exist yet, labeled with the stack ID, and returns the
+// IDs of the secrets of the stack, keyed by name.
+func (r *reconciler) createPropertySecrets(stack interfaces.SwarmStack) (map[string]string, error) {
+	ids, err := r.stackSecretIDs(stack.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range stack.Spec.Secrets {
+		if _, ok := spec.Labels[interfaces.PropertyLabel]; !ok {
+			continue
+		}
+		if _, ok := ids[spec.Name]; ok {
+			continue
+		}
+		labels := make(map[string]string, len(spec.Labels)+1)
+		for k, v := range spec.Labels {
+			labels[k] = v
+		}
+		labels[interfaces.StackLabel] = stack.ID
+		spec.Labels = labels
+		id, err := r.cli.CreateSecret(spec)
+		if err != nil {
+			return nil, err
+		}
+		ids[spec.Name] = id
+	}
+	return ids, nil
+}
+
+// removePropertySecrets removes the secrets labeled with the ID of a stack
+// which are no longer part of its spec, such as the secrets of the previous
+// values of its secret properties. The secrets the services of the stack
+// still refer to, e.g. because their drift is only reported, are kept until
+// the stack is reconciled again.
+func (r *reconciler) removePropertySecrets(stack interfaces.SwarmStack) error {
+	keep := map[string]struct{}{}
+	for _, spec := range stack.Spec.Secrets {
+		keep[spec.Name] = struct{}{}
+	}
+	services, err := r.cli.GetServices(dockerTypes.ServiceListOptions{Filters: stackLabelFilter(stack.ID)})
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		if service.Spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		for _, ref := range service.Spec.TaskTemplate.ContainerSpec.Secrets {
+			keep[ref.SecretName] = struct{}{}
+		}
+	}
+
+	secrets, err := r.cli.GetSecrets(dockerTypes.SecretListOptions{Filters: stackLabelFilter(stack.ID)})
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		if _, ok := keep[secret.Spec.Name]; ok {
+			continue
+		}
+		if err := r.cli.RemoveSecret(secret.ID); err != nil {
 			return err
-		} else {
-			// if the service already exists, it should be reconciled after
-			// this, so notify
-			r.notify.Notify("service", service.ID)
 		}
 	}
+	return nil
+}
+
+// stackSecretIDs returns the IDs of the secrets labeled with the ID of a
+// stack, keyed by name.
+func (r *reconciler) stackSecretIDs(stackID string) (map[string]string, error) {
+	secrets, err := r.cli.GetSecrets(dockerTypes.SecretListOptions{Filters: stackLabelFilter(stackID)})
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		ids[secret.Spec.Name] = secret.ID
+	}
+	return ids, nil
+}
 
+// notifyRemovedServices notifies the services labeled for a stack which are
+// no longer part of its spec, so that they are reconciled.
+func (r *reconciler) notifyRemovedServices(stack interfaces.SwarmStack) error {
+	names := map[string]struct{}{}
+	for _, spec := range stack.Spec.Services {
+		names[spec.Annotations.Name] = struct{}{}
+	}
+	services, err := r.cli.GetServices(dockerTypes.ServiceListOptions{Filters: stackLabelFilter(stack.ID)})
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		if _, ok := names[service.Spec.Annotations.Name]; !ok {
+			r.notify.Notify(events.ServiceEventType, service.ID)
+		}
+	}
 	return nil
 }
 
@@ -149,9 +339,8 @@ func (r *reconciler) reconcileService(id string) error {
 		return nil
 	}
 
-	// now, get the stack itself.
-	// TODO(dperny): we may want to cache stacks so we don't have to do this
-	// lookup every time
+	// now, get the stack itself. the Manager provides a Client which serves
+	// this lookup from its cache.
 	stack, err := r.cli.GetSwarmStack(stackID)
 	// if the stack has been deleted, then the service must follow with it.
 	if errdefs.IsNotFound(err) {
@@ -177,30 +366,246 @@ func (r *reconciler) reconcileService(id string) error {
 		}
 	}
 
-	// if there is no matching service spec, then we need to delete the service
+	// if there is no matching service spec, then we need to delete the service,
+	// unless the stack is paused.
 	if !found {
+		if stack.Spec.Paused {
+			return nil
+		}
 		return r.cli.RemoveService(id)
 	}
 
+	// the secrets of the secret properties are referred to by name in the
+	// stack, and by ID in the service. they are created here too, as the
+	// service may be reconciled before its stack.
+	if drift.HasPendingSecrets(expectedSpec) {
+		secretIDs, err := r.stackSecretIDs(stack.ID)
+		if !stack.Spec.Paused {
+			secretIDs, err = r.createPropertySecrets(stack)
+		}
+		if err != nil {
+			return err
+		}
+		expectedSpec = drift.WithSecretIDs(expectedSpec, secretIDs)
+	}
+
 	// finally, check if the service is already the same
 	// TODO(dperny): is reflect.DeepEqual really the best way to do this?
-	if !reflect.DeepEqual(expectedSpec, service.Spec) {
-		// the response from UpdateService is irrelevant
-		_, err := r.cli.UpdateService(
-			id,
-			service.Meta.Version.Index,
-			expectedSpec,
-			dockerTypes.ServiceUpdateOptions{},
-			false,
-		)
-		return err
+	desired := drift.ServiceSpec(stack.ID, expectedSpec)
+	if reflect.DeepEqual(desired, service.Spec) {
+		// if it is. then there is nothing to do
+		r.clearDrift(id)
+		return nil
 	}
 
-	// if it is. then there is nothing to do
+	// if the service was changed since we last updated it, it has drifted
+	// from the stack, and the policy of the stack decides what to do.
+	// otherwise, the stack has been updated since.
+	if drift.Drifted(desired, service.Spec) {
+		changes := drift.ServiceChanges(desired, service.Spec)
+		policy := stack.Spec.DriftPolicy
+		r.reportDrift(stack.ID, id, policy, changes)
+		// the drift of the services of a paused stack is still reported,
+		// but it is only acted upon once the stack is resumed.
+		if stack.Spec.Paused {
+			return nil
+		}
+		switch policy {
+		case types.DriftPolicyReportOnly:
+			return nil
+		case types.DriftPolicyAdopt:
+			if adoptable(changes) {
+				// the service is updated when the stack is reconciled
+				// following the adoption. the changes which can't be
+				// adopted are reverted then.
+				err := r.cli.AdoptServiceSpec(stack.ID, service.Spec)
+				if !errdefs.IsForbidden(err) {
+					return err
+				}
+				// the changes are to values holding variables, which
+				// would be lost if they were adopted. the drift is only
+				// reported, and the service is left as it is.
+				logrus.Warnf("Unable to adopt the changes to service %s of stack %s: %s", id, stack.ID, err)
+				return nil
+			}
+		}
+	}
+
+	if stack.Spec.Paused {
+		return nil
+	}
+
+	r.clearDrift(id)
+	return r.updateService(service, desired)
+}
+
+// waitForDependencies checks that the services the provided service depends
+// on are ready. ready contains the services already known to be ready, and is
+// updated with the services checked. A NotReadyError is returned if a
+// dependency is not ready yet, so that the stack is reconciled again later
+// rather than blocking the caller.
+func (r *reconciler) waitForDependencies(stack interfaces.SwarmStack, name string, ready map[string]bool) error {
+	for _, dependency := range stack.Spec.Dependencies[name] {
+		if ready[dependency] {
+			continue
+		}
+		if err := r.checkDependency(stack.ID, dependency); err != nil {
+			logrus.Debugf("Waiting for service %s to be ready before rolling out service %s", dependency, name)
+			return err
+		}
+		ready[dependency] = true
+	}
 	return nil
 }
 
+// checkDependency returns a NotReadyError if a service which services of the
+// provided stack depend on is not ready. Once the service has not been
+// ready for dependencyTimeout, the error reports that waiting for it timed
+// out, but the stack is still reconciled again later.
+func (r *reconciler) checkDependency(stackID, name string) error {
+	ready, err := r.serviceReady(name)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ready {
+		delete(r.waiting[stackID], name)
+		if len(r.waiting[stackID]) == 0 {
+			delete(r.waiting, stackID)
+		}
+		return nil
+	}
+
+	if r.waiting[stackID] == nil {
+		r.waiting[stackID] = map[string]time.Time{}
+	}
+	since, ok := r.waiting[stackID][name]
+	if !ok {
+		since = time.Now()
+		r.waiting[stackID][name] = since
+	}
+	reason := fmt.Sprintf("waiting for service %s to be ready", name)
+	if time.Since(since) > dependencyTimeout {
+		reason = fmt.Sprintf("timed out waiting for service %s to be ready", name)
+	}
+	return &NotReadyError{Reason: reason, RetryAfter: dependencyPollInterval}
+}
+
+// serviceReady returns true if a service is running all of its desired tasks,
+// and is not being updated. Tasks with a healthcheck are only running once
+// they are healthy.
+func (r *reconciler) serviceReady(name string) (bool, error) {
+	service, err := r.cli.GetService(name, false)
+	if err != nil {
+		return false, err
+	}
+	if service.UpdateStatus != nil {
+		switch service.UpdateStatus.State {
+		case swarm.UpdateStateCompleted, swarm.UpdateStateRollbackCompleted:
+		default:
+			return false, nil
+		}
+	}
+
+	tasks, err := r.cli.GetTasks(dockerTypes.TaskListOptions{
+		Filters: filters.NewArgs(filters.Arg("service", service.ID)),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var desired, running uint64
+	for _, task := range tasks {
+		if task.DesiredState != swarm.TaskStateRunning {
+			continue
+		}
+		desired++
+		if task.Status.State == swarm.TaskStateRunning {
+			running++
+		}
+	}
+	// the tasks of replicated services may not all have been created yet.
+	if replicated := service.Spec.Mode.Replicated; replicated != nil {
+		desired = 1
+		if replicated.Replicas != nil {
+			desired = *replicated.Replicas
+		}
+	}
+	return running >= desired, nil
+}
+
+// updateService updates a service to the provided spec.
+func (r *reconciler) updateService(service swarm.Service, spec swarm.ServiceSpec) error {
+	// the response from UpdateService is irrelevant
+	_, err := r.cli.UpdateService(
+		service.ID,
+		service.Meta.Version.Index,
+		spec,
+		dockerTypes.ServiceUpdateOptions{},
+		false,
+	)
+	return err
+}
+
+// reportDrift logs the changes made to a service of a stack outside of the
+// Stacks API, and publishes an event for each of them. The same changes are
+// only reported once.
+func (r *reconciler) reportDrift(stackID, serviceID string, policy types.DriftPolicy, changes []types.ResourceDrift) {
+	if policy == "" {
+		policy = types.DriftPolicyRevert
+	}
+	key := fmt.Sprintf("%s %+v", policy, changes)
+
+	r.mu.Lock()
+	reported := r.reported[serviceID] == key
+	r.reported[serviceID] = key
+	r.mu.Unlock()
+	if reported {
+		return
+	}
+
+	for _, change := range changes {
+		logrus.Warnf(
+			"%s %s of stack %s has drifted: %s is %q instead of %q (policy %s)",
+			change.Kind, change.Name, stackID, change.Field, change.Current, change.Desired, policy,
+		)
+		r.cli.LogStackEvent(stackID, interfaces.StackDriftAction, map[string]string{
+			"kind":    change.Kind,
+			"name":    change.Name,
+			"field":   change.Field,
+			"current": change.Current,
+			"desired": change.Desired,
+			"policy":  string(policy),
+		})
+	}
+}
+
+// clearDrift forgets the drift reported for a service, once it has been
+// resolved.
+func (r *reconciler) clearDrift(serviceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.reported, serviceID)
+}
+
+// adoptable returns true if any of the changes can be folded back into the
+// stack spec.
+func adoptable(changes []types.ResourceDrift) bool {
+	for _, change := range changes {
+		if drift.Adoptable(change.Field) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *reconciler) deleteStack(id string) error {
+	r.mu.Lock()
+	delete(r.waiting, id)
+	r.mu.Unlock()
+
 	// it doesn't matter if the stack is actually deleted or not, so we don't
 	// have to get it from the backend. If it isn't deleted, the services will
 	// not be deleted when we reconcile them in a bit.
@@ -213,10 +618,30 @@ func (r *reconciler) deleteStack(id string) error {
 	for _, service := range services {
 		r.notify.Notify("service", service.ID)
 	}
+
+	// the secrets of the stack can only be removed once no service refers
+	// to them anymore, so the stack is reconciled again until its services
+	// are removed.
+	secrets, err := r.cli.GetSecrets(dockerTypes.SecretListOptions{Filters: stackLabelFilter(id)})
+	if err != nil || len(secrets) == 0 {
+		return err
+	}
+	if len(services) > 0 {
+		return &NotReadyError{
+			Reason:     fmt.Sprintf("waiting for the services of stack %s to be removed", id),
+			RetryAfter: removalPollInterval,
+		}
+	}
+	for _, secret := range secrets {
+		if err := r.cli.RemoveSecret(secret.ID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (r *reconciler) handleDeletedService(id string) error {
+	r.clearDrift(id)
 	// TODO(dperny): implement
 	// TODO(dperny): events can contain labels, and so the initial event may
 	// contain a label with the stack ID. If that were the case, we could
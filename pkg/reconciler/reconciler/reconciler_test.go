@@ -1,6 +1,8 @@
 package reconciler
 
 import (
+	"time"
+
 	// Ginkgo uses the dot-import for its packages. This may seem strange, but
 	// the tests flow much better without having to qualify all of the Ginkgo
 	// imports with package names.
@@ -15,7 +17,9 @@ import (
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/swarm"
 
+	"github.com/docker/stacks/pkg/drift"
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
 )
 
 const (
@@ -38,9 +42,20 @@ func (f *fakeObjectChangeNotifier) Notify(kind, id string) {
 	f.objects = append(f.objects, obj{kind, id})
 }
 
+// desiredSpec returns the spec the reconciler gives to a service of the stack,
+// given its spec in the stack.
+func desiredSpec(spec swarm.ServiceSpec) swarm.ServiceSpec {
+	return drift.ServiceSpec(stackID, spec)
+}
+
 // ConsistOfServices is a matcher that verifies that a map of services contains
-// only services whose specs match the provided specs.
-func ConsistOfServices(specs []swarm.ServiceSpec) GomegaMatcher {
+// only services whose specs match the specs the reconciler gives to the
+// services of the stack with the provided specs.
+func ConsistOfServices(stackSpecs []swarm.ServiceSpec) GomegaMatcher {
+	specs := make([]swarm.ServiceSpec, 0, len(stackSpecs))
+	for _, spec := range stackSpecs {
+		specs = append(specs, desiredSpec(spec))
+	}
 	// quick function to convert the map to a slice of ServiceSpecs
 	serviceSpecs := func(f *fakeReconcilerClient) []swarm.ServiceSpec {
 		specs := make([]swarm.ServiceSpec, 0, len(f.services))
@@ -193,6 +208,40 @@ var _ = Describe("Reconciler", func() {
 			})
 		})
 
+		When("the stack is paused", func() {
+			BeforeEach(func() {
+				stackFixture.Spec.Paused = true
+			})
+			It("should not create any of the objects defined within", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(ConsistOfServices([]swarm.ServiceSpec{}))
+			})
+		})
+
+		When("a service was removed from the stack while it was paused", func() {
+			var (
+				// serviceID is the ID of the service removed from the stack
+				serviceID string
+			)
+
+			BeforeEach(func() {
+				// the service was left as it is while the stack was paused,
+				// and the stack has been resumed since.
+				resp, _ := f.CreateService(desiredSpec(swarm.ServiceSpec{
+					Annotations: swarm.Annotations{Name: "removed-name"},
+				}), "", false)
+				serviceID = resp.ID
+			})
+
+			It("should notify the removed service, so that it is removed", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(notifier.objects).To(ConsistOf(obj{events.ServiceEventType, serviceID}))
+
+				Expect(r.Reconcile(events.ServiceEventType, serviceID)).To(Succeed())
+				Expect(f).To(ConsistOfServices(stackFixture.Spec.Services))
+			})
+		})
+
 		When("a stack does not exist to be retrieved by the client", func() {
 			BeforeEach(func() {
 				// Actually no instead remove the stack
@@ -237,12 +286,14 @@ var _ = Describe("Reconciler", func() {
 			)
 
 			BeforeEach(func() {
-				resp, _ := f.CreateService(stackFixture.Spec.Services[0], "", false)
+				resp, _ := f.CreateService(desiredSpec(stackFixture.Spec.Services[0]), "", false)
 				serviceID = resp.ID
 			})
 
-			It("should notify the ObjectChangeNotifier that the service resources should be reconciled", func() {
-				Expect(notifier.objects).To(ConsistOf(obj{"service", serviceID}))
+			It("should leave the up to date service alone", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f.services[serviceID].Meta.Version.Index).To(Equal(uint64(1)))
+				Expect(notifier.objects).To(BeEmpty())
 			})
 
 			It("should still create all of the other service", func() {
@@ -250,6 +301,200 @@ var _ = Describe("Reconciler", func() {
 			})
 		})
 
+		When("a service for a stack is out of date", func() {
+			var (
+				serviceID string
+			)
+
+			BeforeEach(func() {
+				spec := stackFixture.Spec.Services[0]
+				spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{Image: "old"}
+				resp, _ := f.CreateService(desiredSpec(spec), "", false)
+				serviceID = resp.ID
+			})
+
+			It("should update the service", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(ConsistOfServices(stackFixture.Spec.Services))
+				Expect(f.services[serviceID].Meta.Version.Index).To(Equal(uint64(2)))
+			})
+		})
+
+		When("the services of a stack wait for their dependencies", func() {
+			var (
+				// dependencyID is the ID of the service the second service
+				// depends on, which already exists.
+				dependencyID string
+				replicas     uint64
+			)
+
+			BeforeEach(func() {
+				stackFixture.Spec.WaitForDependencies = true
+				stackFixture.Spec.Dependencies = map[string][]string{
+					"service2-name": {"service1-name"},
+				}
+				replicas = 2
+				stackFixture.Spec.Services[0].Mode = swarm.ServiceMode{
+					Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+				}
+				resp, _ := f.CreateService(desiredSpec(stackFixture.Spec.Services[0]), "", false)
+				dependencyID = resp.ID
+
+				dependencyTimeout = 50 * time.Millisecond
+				dependencyPollInterval = 10 * time.Millisecond
+			})
+
+			AfterEach(func() {
+				dependencyTimeout = 2 * time.Minute
+				dependencyPollInterval = time.Second
+			})
+
+			When("the tasks of the dependency are running", func() {
+				BeforeEach(func() {
+					running := swarm.Task{
+						DesiredState: swarm.TaskStateRunning,
+						Status:       swarm.TaskStatus{State: swarm.TaskStateRunning},
+					}
+					f.tasks[dependencyID] = []swarm.Task{running, running}
+				})
+				It("should create the dependent service", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(f).To(ConsistOfServices(stackFixture.Spec.Services))
+				})
+			})
+
+			When("the tasks of the dependency are not all running", func() {
+				BeforeEach(func() {
+					f.tasks[dependencyID] = []swarm.Task{
+						{
+							DesiredState: swarm.TaskStateRunning,
+							Status:       swarm.TaskStatus{State: swarm.TaskStateRunning},
+						},
+						{
+							// tasks with a healthcheck are starting until
+							// they are healthy
+							DesiredState: swarm.TaskStateRunning,
+							Status:       swarm.TaskStatus{State: swarm.TaskStateStarting},
+						},
+					}
+				})
+				It("should not create the dependent service", func() {
+					Expect(err).To(HaveOccurred())
+					_, ok := f.servicesByName["service2-name"]
+					Expect(ok).To(BeFalse())
+				})
+				It("should ask to be reconciled again later rather than wait", func() {
+					Expect(err).To(BeAssignableToTypeOf(&NotReadyError{}))
+					Expect(err.(*NotReadyError).RetryAfter).To(Equal(dependencyPollInterval))
+					Expect(err.Error()).To(Equal("waiting for service service1-name to be ready"))
+				})
+				It("should report that waiting timed out after dependencyTimeout", func() {
+					time.Sleep(dependencyTimeout)
+					err = r.Reconcile(interfaces.StackEventType, stackID)
+					Expect(err).To(BeAssignableToTypeOf(&NotReadyError{}))
+					Expect(err.Error()).To(Equal("timed out waiting for service service1-name to be ready"))
+				})
+			})
+		})
+
+		When("a service for a stack exists without a stack label", func() {
+			var (
+				serviceID string
+			)
+
+			BeforeEach(func() {
+				spec := stackFixture.Spec.Services[0]
+				spec.Annotations.Labels = map[string]string{}
+				resp, _ := f.CreateService(spec, "", false)
+				serviceID = resp.ID
+			})
+
+			It("should update the service to label it", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(ConsistOfServices(stackFixture.Spec.Services))
+				Expect(f.services[serviceID].Meta.Version.Index).To(Equal(uint64(2)))
+				Expect(notifier.objects).To(BeEmpty())
+			})
+		})
+
+		When("the stack has secret properties", func() {
+			// propertySecret returns the spec of the secret of the secret
+			// property PASSWORD, and makes service1 refer to it.
+			propertySecret := func(name, value string) swarm.SecretSpec {
+				stackFixture.Spec.Services[0].TaskTemplate.ContainerSpec = &swarm.ContainerSpec{
+					Secrets: []*swarm.SecretReference{{SecretName: name}},
+				}
+				return swarm.SecretSpec{
+					Annotations: swarm.Annotations{
+						Name:   name,
+						Labels: map[string]string{interfaces.PropertyLabel: "PASSWORD"},
+					},
+					Data: []byte(value),
+				}
+			}
+			// secretRefs returns the secrets service1 refers to.
+			secretRefs := func() []*swarm.SecretReference {
+				service := f.services[f.servicesByName["service1-name"]]
+				return service.Spec.TaskTemplate.ContainerSpec.Secrets
+			}
+
+			BeforeEach(func() {
+				stackFixture.Spec.Secrets = []swarm.SecretSpec{propertySecret("stack_password_1", "s3cret")}
+			})
+
+			It("should create the secrets labeled with the stack, and refer to them by ID", func() {
+				Expect(err).ToNot(HaveOccurred())
+				secretID := f.secretsByName["stack_password_1"]
+				Expect(f.secrets[secretID].Spec.Labels).To(Equal(map[string]string{
+					interfaces.PropertyLabel: "PASSWORD",
+					interfaces.StackLabel:    stackID,
+				}))
+				Expect(f.secrets[secretID].Spec.Data).To(Equal([]byte("s3cret")))
+				Expect(secretRefs()).To(Equal([]*swarm.SecretReference{
+					{SecretName: "stack_password_1", SecretID: secretID},
+				}))
+				// the stack spec is not modified
+				Expect(stackFixture.Spec.Secrets[0].Labels).ToNot(HaveKey(interfaces.StackLabel))
+			})
+
+			It("should leave the services alone once they are up to date", func() {
+				version := f.services[f.servicesByName["service1-name"]].Meta.Version.Index
+				Expect(r.Reconcile(interfaces.StackEventType, stackID)).To(Succeed())
+				Expect(f.services[f.servicesByName["service1-name"]].Meta.Version.Index).To(Equal(version))
+				Expect(f.secrets).To(HaveLen(1))
+			})
+
+			It("should replace the secret of a property whose value changed", func() {
+				stackFixture.Spec.Secrets = []swarm.SecretSpec{propertySecret("stack_password_2", "n3wer")}
+				Expect(r.Reconcile(interfaces.StackEventType, stackID)).To(Succeed())
+
+				secretID := f.secretsByName["stack_password_2"]
+				Expect(secretRefs()).To(Equal([]*swarm.SecretReference{
+					{SecretName: "stack_password_2", SecretID: secretID},
+				}))
+				Expect(f.secrets).To(HaveLen(1))
+				Expect(f.secrets[secretID].Spec.Data).To(Equal([]byte("n3wer")))
+			})
+
+			It("should remove the secrets once the services of the deleted stack are removed", func() {
+				delete(f.stacks, stackID)
+				delete(f.stacksByName, stackName)
+
+				err := r.Reconcile(interfaces.StackEventType, stackID)
+				Expect(err).To(BeAssignableToTypeOf(&NotReadyError{}))
+				Expect(err.(*NotReadyError).RetryAfter).To(Equal(removalPollInterval))
+				Expect(f.secrets).To(HaveLen(1))
+
+				for _, notified := range notifier.objects {
+					Expect(r.Reconcile(notified.kind, notified.id)).To(Succeed())
+				}
+				Expect(f.services).To(BeEmpty())
+
+				Expect(r.Reconcile(interfaces.StackEventType, stackID)).To(Succeed())
+				Expect(f.secrets).To(BeEmpty())
+			})
+		})
+
 	})
 
 	Describe("deleting a stack", func() {
@@ -392,6 +637,7 @@ var _ = Describe("Reconciler", func() {
 						stackFixture.Spec.Services = append(stackFixture.Spec.Services, spec)
 						f.stacks[stackFixture.ID] = stackFixture
 						f.stacksByName[stackFixture.Spec.Annotations.Name] = stackFixture.ID
+						f.services[id].Spec = desiredSpec(spec)
 					})
 					It("should return no error", func() {
 						Expect(err).To(BeNil())
@@ -406,6 +652,133 @@ var _ = Describe("Reconciler", func() {
 			})
 		})
 
+		When("a service has drifted from the stack", func() {
+			var (
+				id   string
+				err  error
+				spec swarm.ServiceSpec
+			)
+
+			BeforeEach(func() {
+				spec = swarm.ServiceSpec{
+					Annotations: swarm.Annotations{
+						Name:   "foo",
+						Labels: map[string]string{interfaces.StackLabel: stackID},
+					},
+					TaskTemplate: swarm.TaskSpec{
+						ContainerSpec: &swarm.ContainerSpec{Image: "nginx:1.15"},
+					},
+				}
+				stackFixture.Spec.Services = append(stackFixture.Spec.Services, spec)
+				f.stacks[stackFixture.ID] = stackFixture
+				f.stacksByName[stackFixture.Spec.Annotations.Name] = stackFixture.ID
+
+				// the service is created by the reconciler, and then changed
+				// by hand
+				drifted := desiredSpec(spec)
+				drifted.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{Image: "nginx:1.16"}
+				resp, createErr := f.CreateService(drifted, "", false)
+				Expect(createErr).ToNot(HaveOccurred())
+				id = resp.ID
+			})
+
+			JustBeforeEach(func() {
+				err = r.Reconcile(events.ServiceEventType, id)
+			})
+
+			It("should report the drift", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f.stackEvents).To(ConsistOf(events.Message{
+					Type:   interfaces.StackEventType,
+					Action: interfaces.StackDriftAction,
+					Actor: events.Actor{
+						ID: stackID,
+						Attributes: map[string]string{
+							"kind":    "service",
+							"name":    "foo",
+							"field":   drift.FieldImage,
+							"current": "nginx:1.16",
+							"desired": "nginx:1.15",
+							"policy":  string(types.DriftPolicyRevert),
+						},
+					},
+				}))
+			})
+
+			It("should report the same drift only once", func() {
+				Expect(r.Reconcile(events.ServiceEventType, id)).To(Succeed())
+				Expect(f.stackEvents).To(HaveLen(1))
+			})
+
+			It("should revert the service by default", func() {
+				Expect(f).To(ConsistOfServices(stackFixture.Spec.Services))
+			})
+
+			When("the stack is paused", func() {
+				BeforeEach(func() {
+					stackFixture.Spec.Paused = true
+				})
+				It("should report the drift, and leave the service as it is", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(f.stackEvents).To(HaveLen(1))
+					Expect(f.services[id].Meta.Version.Index).To(Equal(uint64(1)))
+				})
+				It("should not remove the service if it was removed from the stack", func() {
+					stackFixture.Spec.Services = nil
+					Expect(r.Reconcile(events.ServiceEventType, id)).To(Succeed())
+					Expect(f.services).To(HaveKey(id))
+				})
+			})
+
+			When("the stack only reports drift", func() {
+				BeforeEach(func() {
+					stackFixture.Spec.DriftPolicy = types.DriftPolicyReportOnly
+				})
+				It("should report the drift, and leave the service as it is", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(f.stackEvents).To(HaveLen(1))
+					Expect(f.services[id].Meta.Version.Index).To(Equal(uint64(1)))
+				})
+			})
+
+			When("the stack adopts drift", func() {
+				BeforeEach(func() {
+					stackFixture.Spec.DriftPolicy = types.DriftPolicyAdopt
+				})
+				It("should adopt the spec of the service into the stack", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(f.adopted[stackID]).To(ConsistOf(f.services[id].Spec))
+					Expect(f.services[id].Meta.Version.Index).To(Equal(uint64(1)))
+				})
+
+				When("the changes cannot be adopted", func() {
+					BeforeEach(func() {
+						f.services[id].Spec = desiredSpec(spec)
+						f.services[id].Spec.TaskTemplate.Placement = &swarm.Placement{
+							Constraints: []string{"node.role==manager"},
+						}
+					})
+					It("should revert the service", func() {
+						Expect(err).ToNot(HaveOccurred())
+						Expect(f.adopted).To(BeEmpty())
+						Expect(f).To(ConsistOfServices(stackFixture.Spec.Services))
+					})
+				})
+
+				When("the changes are to values holding variables", func() {
+					BeforeEach(func() {
+						stackFixture.Spec.Annotations.Labels = map[string]string{"makemeforbidden": ""}
+					})
+					It("should report the drift, and leave the service as it is", func() {
+						Expect(err).ToNot(HaveOccurred())
+						Expect(f.adopted).To(BeEmpty())
+						Expect(f.stackEvents).To(HaveLen(1))
+						Expect(f.services[id].Meta.Version.Index).To(Equal(uint64(1)))
+					})
+				})
+			})
+		})
+
 		PWhen("a service is deleted", func() {
 			// TODO(dperny): we can't handle this case yet.
 			It("should notify the ObjectChangeNotifier that the stack should be reconciled", func() {
@@ -0,0 +1,89 @@
+package reconciler
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/reconciler/cache"
+	"github.com/docker/stacks/pkg/reconciler/dispatcher"
+)
+
+// State is a snapshot of the state of the reconciler, exposed for debugging
+// purposes.
+type State struct {
+	// Leader is true while the reconciler runs on the swarm leader and
+	// processes events.
+	Leader bool `json:"leader"`
+	// EventStreamSubscribed is true while the Manager holds a subscription
+	// to the event stream, either waiting for leadership or processing
+	// events.
+	EventStreamSubscribed bool `json:"event_stream_subscribed"`
+	// StackPhases contains the phase of each stack, keyed by stack ID.
+	StackPhases map[string]string `json:"stack_phases"`
+	Cache       cache.Stats       `json:"cache"`
+	Dispatcher  dispatcher.State  `json:"dispatcher"`
+}
+
+// State returns a snapshot of the state of the reconciler.
+func (m *Manager) State() State {
+	phases, err := m.stackPhases()
+	if err != nil {
+		logrus.Errorf("unable to list stacks: %s", err)
+	}
+	return State{
+		Leader:                m.IsLeader(),
+		EventStreamSubscribed: m.EventStreamSubscribed(),
+		StackPhases:           phases,
+		Cache:                 m.cache.Stats(),
+		Dispatcher:            m.d.State(),
+	}
+}
+
+// stackPhases returns the phase of each stack, keyed by stack ID.
+func (m *Manager) stackPhases() (map[string]string, error) {
+	stacks, err := m.client.ListSwarmStacks()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(stacks))
+	for _, stack := range stacks {
+		ids = append(ids, stack.ID)
+	}
+	return m.statuses.phases(ids), nil
+}
+
+// IsLeader returns true if the reconciler is currently running on the swarm
+// leader.
+func (m *Manager) IsLeader() bool {
+	return atomic.LoadInt32(&m.leader) == 1
+}
+
+// EventStreamSubscribed returns true if the Manager is currently subscribed
+// to the event stream.
+func (m *Manager) EventStreamSubscribed() bool {
+	return atomic.LoadInt32(&m.subscriptions) > 0
+}
+
+func (m *Manager) setLeader(isLeader bool) {
+	if isLeader {
+		atomic.StoreInt32(&m.leader, 1)
+		leader.Set(1)
+	} else {
+		atomic.StoreInt32(&m.leader, 0)
+		leader.Set(0)
+	}
+}
+
+// subscribe subscribes to the event stream, keeping track of the number of
+// active subscriptions. The returned function unsubscribes.
+func (m *Manager) subscribe(f filters.Args) (chan interface{}, func()) {
+	_, eventC := m.client.SubscribeToEvents(time.Time{}, time.Time{}, f)
+	atomic.AddInt32(&m.subscriptions, 1)
+	return eventC, func() {
+		m.client.UnsubscribeFromEvents(eventC)
+		atomic.AddInt32(&m.subscriptions, -1)
+	}
+}
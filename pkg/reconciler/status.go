@@ -0,0 +1,127 @@
+package reconciler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// statusMaxAge is the age after which a cached stack status is computed
+// again when read, as not every change of status is notified by an event.
+const statusMaxAge = 30 * time.Second
+
+// cachedStatus is a stack status along with the time it was computed.
+type cachedStatus struct {
+	status  types.StackStatus
+	updated time.Time
+}
+
+// statusCache caches the status of stacks. Statuses are refreshed in the
+// background when requested, typically following task events, so that
+// computing them doesn't hold up the processing of events.
+type statusCache struct {
+	client interfaces.StacksBackend
+
+	mu       sync.Mutex
+	statuses map[string]cachedStatus
+	pending  map[string]struct{}
+	// refreshC signals that refreshes are pending. It is buffered to 1, so
+	// that requests are coalesced.
+	refreshC chan struct{}
+}
+
+func newStatusCache(client interfaces.StacksBackend) *statusCache {
+	return &statusCache{
+		client:   client,
+		statuses: map[string]cachedStatus{},
+		pending:  map[string]struct{}{},
+		refreshC: make(chan struct{}, 1),
+	}
+}
+
+// requestRefresh requests the status of a stack to be refreshed by run.
+func (c *statusCache) requestRefresh(stack string) {
+	c.mu.Lock()
+	c.pending[stack] = struct{}{}
+	c.mu.Unlock()
+	select {
+	case c.refreshC <- struct{}{}:
+	default:
+	}
+}
+
+// run refreshes the statuses requested with requestRefresh, until stop is
+// closed.
+func (c *statusCache) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-c.refreshC:
+			c.mu.Lock()
+			pending := c.pending
+			c.pending = map[string]struct{}{}
+			c.mu.Unlock()
+			for stack := range pending {
+				c.refresh(stack)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// refresh computes the status of a stack, and caches it.
+func (c *statusCache) refresh(stack string) (types.StackStatus, bool) {
+	status, err := c.client.GetStackStatus(stack)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case errdefs.IsNotFound(err):
+		delete(c.statuses, stack)
+		return status, false
+	case err != nil:
+		logrus.Debugf("unable to compute the status of stack %s: %s", stack, err)
+		return status, false
+	}
+	c.statuses[stack] = cachedStatus{status: status, updated: time.Now()}
+	return status, true
+}
+
+// phases returns the phase of each of the provided stacks, from the cache if
+// the status of the stack is fresh enough. Stacks whose status can't be
+// computed have the unknown phase. Other stacks are dropped from the cache.
+func (c *statusCache) phases(stacks []string) map[string]string {
+	listed := make(map[string]struct{}, len(stacks))
+	for _, stack := range stacks {
+		listed[stack] = struct{}{}
+	}
+	c.mu.Lock()
+	for stack := range c.statuses {
+		if _, ok := listed[stack]; !ok {
+			delete(c.statuses, stack)
+		}
+	}
+	c.mu.Unlock()
+
+	phases := make(map[string]string, len(stacks))
+	for _, stack := range stacks {
+		c.mu.Lock()
+		cached, ok := c.statuses[stack]
+		c.mu.Unlock()
+		if ok && time.Since(cached.updated) < statusMaxAge {
+			phases[stack] = cached.status.Phase
+			continue
+		}
+		status, ok := c.refresh(stack)
+		if !ok {
+			phases[stack] = stackPhaseUnknown
+			continue
+		}
+		phases[stack] = status.Phase
+	}
+	return phases
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/docker/docker/errdefs"
@@ -158,7 +159,26 @@ func (s *StacksRouter) StackList(ctx context.Context, options types.StackListOpt
 
 // StackUpdate identifies which backend an existing stack is located at, and
 // calls the update operation of that backend.
-func (s *StacksRouter) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error {
+func (s *StacksRouter) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) (types.StackUpdateResponse, error) {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return types.StackUpdateResponse{}, err
+		}
+		return types.StackUpdateResponse{}, fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return types.StackUpdateResponse{}, fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackUpdate(ctx, id, version, spec, options)
+}
+
+// StackPatch identifies which backend an existing stack is located at, and
+// calls the patch operation of that backend.
+func (s *StacksRouter) StackPatch(ctx context.Context, id string, patch []byte, options types.StackPatchOptions) error {
 	stackPair, err := s.getStack(ctx, id)
 	if err != nil {
 		if errdefs.IsNotFound(err) {
@@ -172,7 +192,159 @@ func (s *StacksRouter) StackUpdate(ctx context.Context, id string, version types
 		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
 	}
 
-	return backend.StackUpdate(ctx, id, version, spec, options)
+	return backend.StackPatch(ctx, id, patch, options)
+}
+
+// StackSetImage identifies which backend an existing stack is located at, and
+// calls the set image operation of that backend.
+func (s *StacksRouter) StackSetImage(ctx context.Context, id string, service string, image string, options types.StackPatchOptions) error {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackSetImage(ctx, id, service, image, options)
+}
+
+// StackRedeploy identifies which backend an existing stack is located at,
+// and calls the redeploy operation of that backend.
+func (s *StacksRouter) StackRedeploy(ctx context.Context, id string, options types.StackRedeployOptions) error {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackRedeploy(ctx, id, options)
+}
+
+// StackPause identifies which backend an existing stack is located at, and
+// calls the pause operation of that backend.
+func (s *StacksRouter) StackPause(ctx context.Context, id string) error {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackPause(ctx, id)
+}
+
+// StackResume identifies which backend an existing stack is located at, and
+// calls the resume operation of that backend.
+func (s *StacksRouter) StackResume(ctx context.Context, id string) error {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackResume(ctx, id)
+}
+
+// StackScale identifies which backend an existing stack is located at, and
+// calls the scale operation of that backend.
+func (s *StacksRouter) StackScale(ctx context.Context, id string, service string, options types.StackScaleOptions) error {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackScale(ctx, id, service, options)
+}
+
+// StackWait identifies which backend an existing stack is located at, and
+// calls the wait operation of that backend. Waiting for a stack which cannot
+// be found in any backend to be deleted waits on all of the backends, as the
+// objects of the stack may still be being removed.
+func (s *StacksRouter) StackWait(ctx context.Context, id string, options types.StackWaitOptions) (types.StackStatus, error) {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			if options.Condition == types.StackWaitConditionDeleted {
+				return s.waitDeleted(ctx, id, options)
+			}
+			return types.StackStatus{}, err
+		}
+		return types.StackStatus{}, fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return types.StackStatus{}, fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackWait(ctx, id, options)
+}
+
+// waitDeleted waits for a stack which can't be found in any backend to be
+// deleted from all of the backends.
+func (s *StacksRouter) waitDeleted(ctx context.Context, id string, options types.StackWaitOptions) (types.StackStatus, error) {
+	status := types.StackStatus{Phase: types.StackPhaseDeleted}
+	for _, backend := range s.backends {
+		var err error
+		status, err = backend.StackWait(ctx, id, options)
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// StackLogs identifies which backend an existing stack is located at, and
+// calls the logs operation of that backend.
+func (s *StacksRouter) StackLogs(ctx context.Context, id string, options types.StackLogsOptions) (io.ReadCloser, error) {
+	stackPair, err := s.getStack(ctx, id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	backend, ok := s.backends[stackPair.fromBackend]
+	if !ok {
+		return nil, fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+	}
+
+	return backend.StackLogs(ctx, id, options)
 }
 
 // StackDelete deletes a stack from all backends. StackDelete should be
@@ -43,7 +43,7 @@ func TestUpdateNotFound(t *testing.T) {
 	router := NewStacksRouter()
 	swarmBackend := fake.NewStackClient()
 	router.RegisterBackend(types.OrchestratorSwarm, swarmBackend)
-	err := router.StackUpdate(context.Background(), "nosuchid", types.Version{}, types.StackSpec{}, types.StackUpdateOptions{})
+	_, err := router.StackUpdate(context.Background(), "nosuchid", types.Version{}, types.StackSpec{}, types.StackUpdateOptions{})
 	require.Error(t, err)
 	require.True(t, errdefs.IsNotFound(err))
 }
@@ -75,10 +75,11 @@ func TestRouterMultipleBackendsUpdate(t *testing.T) {
 	newSpec := stack.Spec
 	newSpec.Services[0].Image = "newimage"
 
-	require.NoError(router.StackUpdate(ctx, swarmResp.ID, stack.Version, newSpec, types.StackUpdateOptions{}))
+	_, err = router.StackUpdate(ctx, swarmResp.ID, stack.Version, newSpec, types.StackUpdateOptions{})
+	require.NoError(err)
 
 	// A second update over the same version should trigger an "update out of sequence" error
-	err = router.StackUpdate(ctx, swarmResp.ID, stack.Version, newSpec, types.StackUpdateOptions{})
+	_, err = router.StackUpdate(ctx, swarmResp.ID, stack.Version, newSpec, types.StackUpdateOptions{})
 	require.Error(err)
 	require.Contains(err.Error(), "update out of sequence")
 
@@ -178,6 +179,44 @@ func TestRouterMultipleBackendsCRD(t *testing.T) {
 	require.Empty(stack)
 }
 
+// waitRecordingClient is a fake StackAPIClient recording the stacks waited
+// for.
+type waitRecordingClient struct {
+	*fake.StackClient
+	waited []string
+}
+
+func (c *waitRecordingClient) StackWait(ctx context.Context, id string, options types.StackWaitOptions) (types.StackStatus, error) {
+	c.waited = append(c.waited, id)
+	return c.StackClient.StackWait(ctx, id, options)
+}
+
+func TestRouterWaitDeleted(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	router := NewStacksRouter()
+	swarmBackend := &waitRecordingClient{StackClient: fake.NewStackClient()}
+	kubeBackend := &waitRecordingClient{StackClient: fake.NewStackClient(fake.WithStartingID(5000))}
+	router.RegisterBackend(types.OrchestratorSwarm, swarmBackend)
+	router.RegisterBackend(types.OrchestratorKubernetes, kubeBackend)
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	require.NoError(router.StackDelete(ctx, resp.ID))
+
+	// The objects of the deleted stack may still be being removed, so the
+	// backends are waited on even though the stack can't be found anymore.
+	status, err := router.StackWait(ctx, resp.ID, types.StackWaitOptions{Condition: types.StackWaitConditionDeleted})
+	require.NoError(err)
+	require.Equal(types.StackPhaseDeleted, status.Phase)
+	require.Equal([]string{resp.ID}, swarmBackend.waited)
+	require.Equal([]string{resp.ID}, kubeBackend.waited)
+
+	_, err = router.StackWait(ctx, resp.ID, types.StackWaitOptions{Condition: types.StackWaitConditionConverged})
+	require.True(errdefs.IsNotFound(err))
+}
+
 func requireMatchesCreate(t *testing.T, stack types.Stack, create types.StackCreate, id string) {
 	require.True(t, reflect.DeepEqual(stack.Spec, create.Spec))
 	require.Equal(t, stack.Metadata.Name, create.Metadata.Name)
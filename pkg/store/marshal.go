@@ -18,20 +18,43 @@ import (
 type CombinedStack struct {
 	Stack      *types.Stack
 	SwarmStack *interfaces.SwarmStack
+	// Sealed, if set, holds the encrypted JSON form of the Stack and
+	// SwarmStack of a stack with sensitive properties or secret data, in
+	// place of Stack and SwarmStack.
+	Sealed []byte `json:",omitempty"`
 }
 
+// errPendingStack is returned when unmarshalling a stack which is being
+// created, whose resource doesn't hold the stack yet.
+var errPendingStack = errors.New("stack is being created")
+
 func init() {
 	typeurl.Register(&CombinedStack{}, "github.com/docker/stacks/CombinedStack")
 }
 
 // MarshalStacks takes a Stack objects and marshals it into a protocol buffer
 // Any message. Under the hood, this relies on marshaling the objects to JSON.
+// Stacks with sensitive properties or secret data can't be marshalled without
+// a key, see MarshalSealedStacks.
 func MarshalStacks(stack *types.Stack, swarmStack *interfaces.SwarmStack) (*gogotypes.Any, error) {
+	return MarshalSealedStacks(stack, swarmStack, nil)
+}
+
+// MarshalSealedStacks is MarshalStacks, except that a stack with sensitive
+// properties or secret data is encrypted with the provided AES key. Such a
+// stack is bound to its ID, which must thus be set.
+func MarshalSealedStacks(stack *types.Stack, swarmStack *interfaces.SwarmStack, key []byte) (*gogotypes.Any, error) {
 	// we should first combine the stack and the swarmStack into one object, so
 	// they can be marshalled together.
 	combinedStack := &CombinedStack{Stack: stack, SwarmStack: swarmStack}
+	if stack != nil && stack.Spec.IsSensitive() {
+		sealed, err := seal(combinedStack, stack.ID, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "error sealing stack with sensitive values")
+		}
+		combinedStack = &CombinedStack{Sealed: sealed}
+	}
 	return typeurl.MarshalAny(combinedStack)
-
 }
 
 // UnmarshalStacks does the MarshalStacks operation in reverse -- takes a proto
@@ -41,6 +64,12 @@ func MarshalStacks(stack *types.Stack, swarmStack *interfaces.SwarmStack) (*gogo
 // Stack (Meta, Version, and ID) that are derrived from the values assigned by
 // swarmkit and contained in the Resource
 func UnmarshalStacks(resource *api.Resource) (*types.Stack, *interfaces.SwarmStack, error) {
+	return UnmarshalSealedStacks(resource, nil)
+}
+
+// UnmarshalSealedStacks is UnmarshalStacks, except that a stack encrypted by
+// MarshalSealedStacks is decrypted with the provided AES key.
+func UnmarshalSealedStacks(resource *api.Resource, key []byte) (*types.Stack, *interfaces.SwarmStack, error) {
 	iface, err := typeurl.UnmarshalAny(resource.Payload)
 	if err != nil {
 		return nil, nil, err
@@ -49,6 +78,16 @@ func UnmarshalStacks(resource *api.Resource) (*types.Stack, *interfaces.SwarmSta
 	// CombinedStack object, the program will panic. This is fine, because if
 	// such a thing were to occur, it would be panic-worthy.
 	combinedStack := iface.(*CombinedStack)
+	if combinedStack.Sealed != nil {
+		combinedStack, err = unseal(combinedStack.Sealed, resource.ID, key)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error unsealing stack %s", resource.ID)
+		}
+	}
+
+	if combinedStack.Stack == nil || combinedStack.SwarmStack == nil {
+		return nil, nil, errPendingStack
+	}
 
 	combinedStack.Stack.ID = resource.ID
 	combinedStack.Stack.Version = types.Version{Index: resource.Meta.Version.Index}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"encoding/base64"
 	"testing"
 
 	"github.com/docker/docker/api/types/swarm"
@@ -94,3 +95,75 @@ func TestMarshalUnmarshal(t *testing.T) {
 	assert.Equal(t, stack, unstack)
 	assert.Equal(t, swarmStack, unswarm)
 }
+
+// TestMarshalUnmarshalSealed tests that the stacks with sensitive properties
+// are only marshalled encrypted, and can be unmarshalled with the same key.
+func TestMarshalUnmarshalSealed(t *testing.T) {
+	stack := &types.Stack{
+		Spec: types.StackSpec{
+			Properties: map[string]types.Property{
+				"PASSWORD": {Sensitive: true},
+			},
+			PropertyValues: []string{"PASSWORD=s3cret"},
+		},
+	}
+	swarmStack := &interfaces.SwarmStack{}
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	_, err := MarshalStacks(stack, swarmStack)
+	require.Error(t, err)
+
+	// the sealed stacks are bound to their ID.
+	_, err = MarshalSealedStacks(stack, swarmStack, key)
+	require.Error(t, err)
+	stack.ID = "someID"
+
+	msg, err := MarshalSealedStacks(stack, swarmStack, key)
+	require.NoError(t, err)
+	assert.NotContains(t, string(msg.Value), "s3cret")
+
+	resource := &api.Resource{
+		ID: "someID",
+		Meta: api.Meta{
+			CreatedAt: gogotypes.TimestampNow(),
+			UpdatedAt: gogotypes.TimestampNow(),
+		},
+		Payload: msg,
+	}
+	_, _, err = UnmarshalStacks(resource)
+	require.Error(t, err)
+	_, _, err = UnmarshalSealedStacks(resource, []byte("fedcba9876543210fedcba9876543210"))
+	require.Error(t, err)
+
+	unstack, _, err := UnmarshalSealedStacks(resource, key)
+	require.NoError(t, err)
+	assert.Equal(t, stack.Spec, unstack.Spec)
+
+	// the sealed stack can't be swapped into the resource of another stack.
+	resource.ID = "otherID"
+	_, _, err = UnmarshalSealedStacks(resource, key)
+	require.Error(t, err)
+}
+
+// TestMarshalSealedSecretData tests that the stacks whose secrets hold the
+// content of their file are only marshalled encrypted.
+func TestMarshalSealedSecretData(t *testing.T) {
+	stack := &types.Stack{
+		Spec: types.StackSpec{
+			Secrets: map[string]composetypes.SecretConfig{
+				"password": {File: "./password.txt", Data: []byte("s3cret")},
+			},
+		},
+	}
+	swarmStack := &interfaces.SwarmStack{}
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	_, err := MarshalStacks(stack, swarmStack)
+	require.Error(t, err)
+
+	stack.ID = "someID"
+	msg, err := MarshalSealedStacks(stack, swarmStack, key)
+	require.NoError(t, err)
+	assert.NotContains(t, string(msg.Value), "s3cret")
+	assert.NotContains(t, string(msg.Value), base64.StdEncoding.EncodeToString([]byte("s3cret")))
+}
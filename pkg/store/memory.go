@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/errdefs"
+	swarmapi "github.com/docker/swarmkit/api"
+	gogotypes "github.com/gogo/protobuf/types"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// MemoryClient is a ResourcesClient keeping the resources in memory, for the
+// standalone server, which has no access to the store of swarmkit. Like in
+// swarmkit, the resources are versioned, and an update of a resource is
+// rejected if it is not based on the current version of the resource.
+type MemoryClient struct {
+	mu        sync.Mutex
+	resources map[string]*swarmapi.Resource
+	order     []string
+	nextID    int
+	index     uint64
+}
+
+// NewMemoryClient creates a new MemoryClient without any resource.
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		resources: make(map[string]*swarmapi.Resource),
+		// Don't start from ID 0, to catch any uninitialized types.
+		nextID: 1,
+	}
+}
+
+// CreateExtension does nothing, as the kinds of the resources are not
+// checked.
+func (c *MemoryClient) CreateExtension(ctx context.Context, in *swarmapi.CreateExtensionRequest, opts ...grpc.CallOption) (*swarmapi.CreateExtensionResponse, error) {
+	return &swarmapi.CreateExtensionResponse{}, nil
+}
+
+// CreateResource creates a new resource.
+func (c *MemoryClient) CreateResource(ctx context.Context, in *swarmapi.CreateResourceRequest, opts ...grpc.CallOption) (*swarmapi.CreateResourceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := gogotypes.TimestampNow()
+	c.index++
+	resource := &swarmapi.Resource{
+		ID: fmt.Sprintf("%d", c.nextID),
+		Meta: swarmapi.Meta{
+			Version:   swarmapi.Version{Index: c.index},
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		Kind:    in.Kind,
+		Payload: in.Payload,
+	}
+	if in.Annotations != nil {
+		resource.Annotations = *in.Annotations
+	}
+	c.nextID++
+
+	c.resources[resource.ID] = resource
+	c.order = append(c.order, resource.ID)
+	return &swarmapi.CreateResourceResponse{Resource: resource.Copy()}, nil
+}
+
+// GetResource returns a resource by ID.
+func (c *MemoryClient) GetResource(ctx context.Context, in *swarmapi.GetResourceRequest, opts ...grpc.CallOption) (*swarmapi.GetResourceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resource, ok := c.resources[in.ResourceID]
+	if !ok {
+		return nil, errdefs.NotFound(errors.Errorf("resource %s not found", in.ResourceID))
+	}
+	return &swarmapi.GetResourceResponse{Resource: resource.Copy()}, nil
+}
+
+// UpdateResource updates the payload, and the annotations if they are set,
+// of the version of a resource.
+func (c *MemoryClient) UpdateResource(ctx context.Context, in *swarmapi.UpdateResourceRequest, opts ...grpc.CallOption) (*swarmapi.UpdateResourceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resource, ok := c.resources[in.ResourceID]
+	if !ok {
+		return nil, errdefs.NotFound(errors.Errorf("resource %s not found", in.ResourceID))
+	}
+	if in.ResourceVersion == nil || in.ResourceVersion.Index != resource.Meta.Version.Index {
+		return nil, errors.New("update out of sequence")
+	}
+
+	resource = resource.Copy()
+	c.index++
+	resource.Meta.Version.Index = c.index
+	resource.Meta.UpdatedAt = gogotypes.TimestampNow()
+	if in.Annotations != nil {
+		resource.Annotations = *in.Annotations
+	}
+	if in.Payload != nil {
+		resource.Payload = in.Payload
+	}
+
+	c.resources[resource.ID] = resource
+	return &swarmapi.UpdateResourceResponse{Resource: resource.Copy()}, nil
+}
+
+// ListResources lists the resources, in the order they were created. Only
+// the Kind filter is supported.
+func (c *MemoryClient) ListResources(ctx context.Context, in *swarmapi.ListResourcesRequest, opts ...grpc.CallOption) (*swarmapi.ListResourcesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resources := []*swarmapi.Resource{}
+	for _, id := range c.order {
+		resource := c.resources[id]
+		if in.Filters != nil && in.Filters.Kind != "" && in.Filters.Kind != resource.Kind {
+			continue
+		}
+		resources = append(resources, resource.Copy())
+	}
+	return &swarmapi.ListResourcesResponse{Resources: resources}, nil
+}
+
+// RemoveResource removes a resource by ID.
+func (c *MemoryClient) RemoveResource(ctx context.Context, in *swarmapi.RemoveResourceRequest, opts ...grpc.CallOption) (*swarmapi.RemoveResourceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.resources[in.ResourceID]; !ok {
+		return nil, errdefs.NotFound(errors.Errorf("resource %s not found", in.ResourceID))
+	}
+	delete(c.resources, in.ResourceID)
+	for i, id := range c.order {
+		if id == in.ResourceID {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	return &swarmapi.RemoveResourceResponse{}, nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+	swarmapi "github.com/docker/swarmkit/api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// TestMemoryClient tests a StackStore keeping its stacks in a MemoryClient,
+// as the standalone server does.
+func TestMemoryClient(t *testing.T) {
+	require := require.New(t)
+	client := NewMemoryClient()
+	s, err := NewWithOptions(client, Options{EncryptionKey: bytes.Repeat([]byte("k"), 32)})
+	require.NoError(err)
+
+	stack := types.Stack{
+		Metadata: types.Metadata{Name: "teststack"},
+		Spec: types.StackSpec{
+			Properties:     map[string]types.Property{"PASSWORD": {Sensitive: true}},
+			PropertyValues: []string{"PASSWORD=s3cret"},
+		},
+	}
+	id, err := s.AddStack(stack, interfaces.SwarmStack{})
+	require.NoError(err)
+
+	// the stacks with sensitive values are kept sealed.
+	resp, err := client.GetResource(context.TODO(), &swarmapi.GetResourceRequest{ResourceID: id})
+	require.NoError(err)
+	require.NotContains(string(resp.Resource.Payload.Value), "s3cret")
+
+	got, err := s.GetStack(id)
+	require.NoError(err)
+	require.Equal(id, got.ID)
+	require.Equal(stack.Spec.PropertyValues, got.Spec.PropertyValues)
+
+	// the updates must be based on the current version of the stack.
+	spec := got.Spec
+	spec.PropertyValues = []string{"PASSWORD=n3w"}
+	require.NoError(s.UpdateStack(id, spec, interfaces.SwarmStackSpec{}, got.Version.Index))
+	err = s.UpdateStack(id, spec, interfaces.SwarmStackSpec{}, got.Version.Index)
+	require.EqualError(err, "update out of sequence")
+
+	stacks, err := s.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal([]string{"PASSWORD=n3w"}, stacks[0].Spec.PropertyValues)
+	require.True(stacks[0].Version.Index > got.Version.Index)
+
+	require.NoError(s.DeleteStack(id))
+	_, err = s.GetStack(id)
+	require.True(errdefs.IsNotFound(err))
+	stacks, err = s.ListStacks()
+	require.NoError(err)
+	require.Empty(stacks)
+}
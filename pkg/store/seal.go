@@ -0,0 +1,70 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// newAEAD returns the AES-GCM cipher of a key, which must be 16, 24 or 32
+// bytes long.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, errors.New("no encryption key is configured")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the JSON form of a CombinedStack. The random nonce of the
+// encryption prefixes the result. The ID of the stack is authenticated along
+// with it, so that the result can't be unsealed as the stack of another ID.
+func seal(combinedStack *CombinedStack, id string, key []byte) ([]byte, error) {
+	if id == "" {
+		return nil, errors.New("a stack without ID can't be sealed")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(combinedStack)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(id)), nil
+}
+
+// unseal decrypts a CombinedStack encrypted by seal with the same ID.
+func unseal(sealed []byte, id string, key []byte) (*CombinedStack, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed stack is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+	var combinedStack CombinedStack
+	if err := json.Unmarshal(plaintext, &combinedStack); err != nil {
+		return nil, err
+	}
+	if combinedStack.Stack == nil || combinedStack.SwarmStack == nil {
+		return nil, errors.New("sealed stack is incomplete")
+	}
+	return &combinedStack, nil
+}
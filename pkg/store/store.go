@@ -3,7 +3,9 @@ package store
 import (
 	"context"
 
+	"github.com/containerd/typeurl"
 	swarmapi "github.com/docker/swarmkit/api"
+	gogotypes "github.com/gogo/protobuf/types"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 
@@ -31,6 +33,15 @@ type ResourcesClient interface {
 // swarmkit object store.
 type StackStore struct {
 	client ResourcesClient
+	key    []byte
+}
+
+// Options are the options of a StackStore.
+type Options struct {
+	// EncryptionKey is the AES key, 16, 24 or 32 bytes long, with which the
+	// stacks with sensitive properties or secret data are encrypted. Without
+	// a key, such stacks can't be stored.
+	EncryptionKey []byte
 }
 
 // New creates a new StackStore using the provided client.
@@ -40,11 +51,36 @@ func New(client ResourcesClient) *StackStore {
 	}
 }
 
+// NewWithOptions creates a new StackStore using the provided client and
+// options.
+func NewWithOptions(client ResourcesClient, opts Options) (*StackStore, error) {
+	if len(opts.EncryptionKey) > 0 {
+		if _, err := newAEAD(opts.EncryptionKey); err != nil {
+			return nil, errors.Wrap(err, "invalid encryption key")
+		}
+	}
+	return &StackStore{
+		client: client,
+		key:    opts.EncryptionKey,
+	}, nil
+}
+
 // AddStack creates a new Stack object in the swarmkit data store. It returns
 // the ID of the new object if successful, or an error otherwise.
 func (s *StackStore) AddStack(st types.Stack, sst interfaces.SwarmStack) (string, error) {
+	// The stacks with sensitive values are sealed along with their ID, which
+	// is only known once their resource is created: the resource is first
+	// created without a stack, and then updated with the sealed stack.
+	pending := st.Spec.IsSensitive()
+
 	// first, marshal the stacks to a proto message
-	any, err := MarshalStacks(&st, &sst)
+	var any *gogotypes.Any
+	var err error
+	if pending {
+		any, err = typeurl.MarshalAny(&CombinedStack{})
+	} else {
+		any, err = MarshalSealedStacks(&st, &sst, s.key)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -68,7 +104,33 @@ func (s *StackStore) AddStack(st types.Stack, sst interfaces.SwarmStack) (string
 	if err != nil {
 		return "", err
 	}
-	return resp.Resource.ID, nil
+	if !pending {
+		return resp.Resource.ID, nil
+	}
+
+	st.ID = resp.Resource.ID
+	if err := s.sealPending(resp.Resource, &st, &sst); err != nil {
+		// the stack is not left pending.
+		s.client.RemoveResource(context.TODO(), &swarmapi.RemoveResourceRequest{ResourceID: st.ID})
+		return "", err
+	}
+	return st.ID, nil
+}
+
+// sealPending updates the resource of a pending stack with the sealed stack.
+func (s *StackStore) sealPending(resource *swarmapi.Resource, st *types.Stack, sst *interfaces.SwarmStack) error {
+	any, err := MarshalSealedStacks(st, sst, s.key)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.UpdateResource(context.TODO(),
+		&swarmapi.UpdateResourceRequest{
+			ResourceID:      resource.ID,
+			ResourceVersion: &resource.Meta.Version,
+			Payload:         any,
+		},
+	)
+	return err
 }
 
 // UpdateStack updates an existing Stack object
@@ -83,7 +145,7 @@ func (s *StackStore) UpdateStack(id string, st types.StackSpec, sst interfaces.S
 
 	resource := resp.Resource
 	// unmarshal the contents
-	stack, swarmStack, err := UnmarshalStacks(resource)
+	stack, swarmStack, err := UnmarshalSealedStacks(resource, s.key)
 	if err != nil {
 		return err
 	}
@@ -93,7 +155,7 @@ func (s *StackStore) UpdateStack(id string, st types.StackSpec, sst interfaces.S
 	swarmStack.Spec = sst
 
 	// marshal it all back
-	any, err := MarshalStacks(stack, swarmStack)
+	any, err := MarshalSealedStacks(stack, swarmStack, s.key)
 	if err != nil {
 		return err
 	}
@@ -131,7 +193,7 @@ func (s *StackStore) GetStack(id string) (types.Stack, error) {
 	resource := resp.Resource
 
 	// now, we have to get the stack out of the resource object
-	stack, _, err := UnmarshalStacks(resource)
+	stack, _, err := UnmarshalSealedStacks(resource, s.key)
 	if err != nil {
 		return types.Stack{}, err
 	}
@@ -152,7 +214,7 @@ func (s *StackStore) GetSwarmStack(id string) (interfaces.SwarmStack, error) {
 		return interfaces.SwarmStack{}, err
 	}
 	resource := resp.Resource
-	_, swarmStack, err := UnmarshalStacks(resource)
+	_, swarmStack, err := UnmarshalSealedStacks(resource, s.key)
 	if err != nil {
 		return interfaces.SwarmStack{}, err
 	}
@@ -179,7 +241,10 @@ func (s *StackStore) ListStacks() ([]types.Stack, error) {
 	// unmarshal and pack up all of the stack objects
 	stacks := make([]types.Stack, 0, len(resp.Resources))
 	for _, resource := range resp.Resources {
-		stack, _, err := UnmarshalStacks(resource)
+		stack, _, err := UnmarshalSealedStacks(resource, s.key)
+		if err == errPendingStack {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -203,7 +268,10 @@ func (s *StackStore) ListSwarmStacks() ([]interfaces.SwarmStack, error) {
 	}
 	stacks := make([]interfaces.SwarmStack, 0, len(resp.Resources))
 	for _, resource := range resp.Resources {
-		_, stack, err := UnmarshalStacks(resource)
+		_, stack, err := UnmarshalSealedStacks(resource, s.key)
+		if err == errPendingStack {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -6,11 +6,14 @@ package substitution
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/docker/stacks/pkg/compose/interpolation"
 	"github.com/docker/stacks/pkg/compose/loader"
 	"github.com/docker/stacks/pkg/compose/template"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/types"
 )
 
@@ -22,11 +25,16 @@ func DoSubstitution(spec types.StackSpec) (types.StackSpec, error) {
 	// Start with a naive implementation based on round-tripping to json
 	var finalSpec types.StackSpec
 
+	if err := validateVariables(spec); err != nil {
+		return finalSpec, err
+	}
+
 	// TODO There may be additional corner cases where
 	// the structure changes (not a simple string replacement)
 	// Those are handled by custom conversion routines here
 	// before performing the generic json round-trip conversion
 	for _, sub := range []func(spec *types.StackSpec) error{
+		doSecretSubstitutions,
 		doPortSubstitutions,
 		doVolumeSubstitutions,
 	} {
@@ -47,7 +55,18 @@ func DoSubstitution(spec types.StackSpec) (types.StackSpec, error) {
 	}
 
 	err = json.Unmarshal([]byte(specPostJSON), &finalSpec)
-	return finalSpec, err
+	if err != nil {
+		return finalSpec, err
+	}
+
+	// The values deferred until their variables are substituted can now be
+	// decoded into their properties.
+	for i := range finalSpec.Services {
+		if err := loader.ResolveDeferred(&finalSpec.Services[i]); err != nil {
+			return finalSpec, err
+		}
+	}
+	return finalSpec, nil
 }
 
 // Wrap the template.Substitute function to automatically lookup
@@ -68,6 +87,210 @@ func doSubstitute(data string, spec *types.StackSpec) (string, error) {
 		})
 }
 
+// validateVariables returns a VariablesError listing at once the property
+// values of a spec which don't satisfy the declarations of the properties,
+// and the required variables missing a value, along with the paths of the
+// values referring to them.
+func validateVariables(spec types.StackSpec) error {
+	values := map[string]string{}
+	for _, keyval := range spec.PropertyValues {
+		split := strings.SplitN(keyval, "=", 2)
+		if len(split) == 2 {
+			values[split[0]] = split[1]
+		}
+	}
+
+	errs := validateProperties(spec, values)
+	missing, err := missingVariables(spec, values)
+	if err != nil {
+		return err
+	}
+	errs = append(errs, missing...)
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Variable < errs[j].Variable })
+	return &types.VariablesError{Variables: errs}
+}
+
+// validateProperties returns the errors of the property values of a spec
+// which don't satisfy the declarations of the properties.
+func validateProperties(spec types.StackSpec, values map[string]string) []types.VariableError {
+	var errs []types.VariableError
+	for name, property := range spec.Properties {
+		value := values[name]
+		if value == "" {
+			if property.Required {
+				errs = append(errs, types.VariableError{
+					Variable: name,
+					Message:  fmt.Sprintf("property %s is required", name),
+				})
+			}
+			continue
+		}
+		if err := property.Validate(value); err != nil {
+			errs = append(errs, types.VariableError{
+				Variable: name,
+				Message:  fmt.Sprintf("property %s: %s", name, err),
+			})
+		}
+	}
+	return errs
+}
+
+// missingVariables returns the errors of the required variables of a spec,
+// e.g. "${FOO:?message}", missing a value. The services are keyed by name in
+// the paths of the values referring to them.
+func missingVariables(spec types.StackSpec, values map[string]string) ([]types.VariableError, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var dict map[string]interface{}
+	if err := json.Unmarshal(raw, &dict); err != nil {
+		return nil, err
+	}
+	// the descriptions and regexes of the properties aren't substituted.
+	delete(dict, "properties")
+	delete(dict, "property_values")
+	if services, ok := dict["services"].([]interface{}); ok {
+		byName := make(map[string]interface{}, len(services))
+		for _, service := range services {
+			if service, ok := service.(map[string]interface{}); ok {
+				name, _ := service["name"].(string)
+				byName[name] = service
+			}
+		}
+		dict["services"] = byName
+	}
+
+	_, err = interpolation.Interpolate(dict, interpolation.Options{
+		LookupValue: func(key string) (string, bool) {
+			value, found := values[key]
+			return value, found
+		},
+	})
+	var pathErrs []*interpolation.PathError
+	switch err := err.(type) {
+	case *interpolation.PathError:
+		pathErrs = []*interpolation.PathError{err}
+	case interpolation.Errors:
+		pathErrs = err
+	}
+
+	// the other errors are reported by the substitution itself.
+	var missing loader.MissingVariables
+	missing.Add(pathErrs, func(err *interpolation.PathError) types.VariableLocation {
+		return types.VariableLocation{Path: err.Path()}
+	})
+	return missing.Errors(), nil
+}
+
+// propertySecretPrefix prefixes the names of the secrets materializing the
+// values of the secret properties.
+const propertySecretPrefix = "property_"
+
+// doSecretSubstitutions materializes the values of the secret properties as
+// secrets, rather than substituting them inline, where they are the whole
+// values of environment variables: the variable FOO=${PASSWORD} is replaced
+// with the variable FOO_FILE, holding the path of the secret
+// property_password. The other uses of the secret properties are substituted
+// inline.
+func doSecretSubstitutions(spec *types.StackSpec) error {
+	values := spec.SensitiveValues()
+	for name := range values {
+		if !spec.Properties[name].Secret {
+			delete(values, name)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	// The services and secrets are copied, so that the original spec is
+	// not modified.
+	secrets := make(map[string]composetypes.SecretConfig, len(spec.Secrets))
+	for name, secret := range spec.Secrets {
+		secrets[name] = secret
+	}
+	services := make(composetypes.Services, len(spec.Services))
+	for si, service := range spec.Services {
+		services[si] = service
+
+		keys := make([]string, 0, len(service.Environment))
+		for key := range service.Environment {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var environment composetypes.MappingWithEquals
+		referenced := map[string]bool{}
+		for _, key := range keys {
+			name, ok := propertyVariable(service.Environment[key], values)
+			if !ok {
+				continue
+			}
+			if environment == nil {
+				environment = make(composetypes.MappingWithEquals, len(service.Environment))
+				for k, v := range service.Environment {
+					environment[k] = v
+				}
+			}
+
+			secretName := propertySecretPrefix + strings.ToLower(name)
+			if _, exists := spec.Secrets[secretName]; exists {
+				return fmt.Errorf("service %s: secret %s of property %s is already defined", service.Name, secretName, name)
+			}
+			secrets[secretName] = composetypes.SecretConfig{
+				Labels: composetypes.Labels{interfaces.PropertyLabel: name},
+				Data:   []byte(values[name]),
+			}
+
+			path := "/run/secrets/" + secretName
+			delete(environment, key)
+			environment[key+"_FILE"] = &path
+			if !referenced[secretName] {
+				referenced[secretName] = true
+				services[si].Secrets = append(services[si].Secrets[:len(services[si].Secrets):len(services[si].Secrets)],
+					composetypes.ServiceSecretConfig{Source: secretName})
+			}
+		}
+		if environment != nil {
+			services[si].Environment = environment
+		}
+	}
+	spec.Services = services
+	spec.Secrets = secrets
+	return nil
+}
+
+// propertyVariable returns the name of the property, among these of the
+// provided values, whose variable is the whole value of an environment
+// variable, if any.
+func propertyVariable(value *string, values map[string]string) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+	matches := template.DefaultPattern.FindStringSubmatch(*value)
+	if matches == nil || matches[0] != *value {
+		return "", false
+	}
+	groups := map[string]string{}
+	for i, name := range template.DefaultPattern.SubexpNames() {
+		if name != "" {
+			groups[name] = matches[i]
+		}
+	}
+	name := groups["named"]
+	if name == "" {
+		name = groups["braced"]
+	}
+	if _, ok := values[name]; !ok {
+		return "", false
+	}
+	return name, true
+}
+
 func doPortSubstitutions(spec *types.StackSpec) error {
 	for si, service := range spec.Services {
 		for pi, port := range service.Ports {
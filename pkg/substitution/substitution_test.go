@@ -1,9 +1,12 @@
 package substitution
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/types"
 
 	"gotest.tools/assert"
@@ -74,3 +77,172 @@ func TestDoVolumeSubstitutions(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Check(t, is.DeepEqual(outspec.Services[0].Volumes, expectedVolumes))
 }
+
+func TestDoDeferredSubstitutions(t *testing.T) {
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			composetypes.ServiceConfig{
+				Name: "web",
+				Deferred: map[string]string{
+					"deploy.replicas":                "${REPLICAS}",
+					"deploy.resources.limits.memory": "${MEMORY}",
+					"healthcheck.interval":           "${INTERVAL}",
+					"read_only":                      "${READ_ONLY}",
+					"ulimits.nofile.soft":            "${NOFILE}",
+				},
+			},
+		},
+		PropertyValues: []string{
+			"REPLICAS=3",
+			"MEMORY=1G",
+			"INTERVAL=10s",
+			"READ_ONLY=yes",
+			"NOFILE=1024",
+		},
+	}
+	outspec, err := DoSubstitution(spec)
+	assert.NilError(t, err)
+	service := outspec.Services[0]
+	replicas := uint64(3)
+	interval := composetypes.Duration(10 * time.Second)
+	assert.Check(t, is.DeepEqual(&replicas, service.Deploy.Replicas))
+	assert.Check(t, is.Equal(composetypes.UnitBytes(1<<30), service.Deploy.Resources.Limits.MemoryBytes))
+	assert.Check(t, is.DeepEqual(&interval, service.HealthCheck.Interval))
+	assert.Check(t, service.ReadOnly)
+	assert.Check(t, is.Equal(1024, service.Ulimits["nofile"].Soft))
+	assert.Check(t, is.Len(service.Deferred, 0))
+
+	// the original spec is left alone
+	assert.Check(t, is.Len(spec.Services[0].Deferred, 5))
+	assert.Check(t, is.Nil(spec.Services[0].Deploy.Replicas))
+}
+
+func TestDoDeferredSubstitutionsInvalidValue(t *testing.T) {
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			composetypes.ServiceConfig{
+				Name:     "web",
+				Deferred: map[string]string{"deploy.replicas": "${REPLICAS}"},
+			},
+		},
+		PropertyValues: []string{"REPLICAS=many"},
+	}
+	_, err := DoSubstitution(spec)
+	assert.Check(t, is.Error(err, `service web: deploy.replicas: invalid value "many"`))
+}
+
+func TestDoSubstitutionValidatesProperties(t *testing.T) {
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			composetypes.ServiceConfig{Name: "web", Image: "busybox:${TAG}"},
+		},
+		Properties: map[string]types.Property{
+			"TAG":      {Regex: "[0-9.]+"},
+			"REPLICAS": {Type: types.PropertyTypeInt, Required: true},
+			"PORT":     {Type: types.PropertyTypePort},
+			"ENV":      {Enum: []string{"dev", "prod"}},
+			"DEBUG":    {Type: types.PropertyTypeBool},
+		},
+		PropertyValues: []string{"TAG=latest", "PORT=80000", "ENV=dev", "DEBUG"},
+	}
+	_, err := DoSubstitution(spec)
+	assert.Check(t, is.Error(err, "stack has 3 invalid variable(s):\n"+
+		`property PORT: "80000" is not a valid port`+"\n"+
+		"property REPLICAS is required\n"+
+		`property TAG: "latest" does not match [0-9.]+`))
+
+	// the values of the sensitive properties are not quoted.
+	spec.Properties["TAG"] = types.Property{Regex: "[0-9.]+", Sensitive: true}
+	_, err = DoSubstitution(spec)
+	assert.Check(t, is.ErrorContains(err, "property TAG: the value does not match [0-9.]+"))
+	assert.Check(t, !strings.Contains(err.Error(), "latest"))
+
+	spec.PropertyValues = []string{"TAG=1.2", "REPLICAS=3", "PORT=8080", "ENV=prod", "DEBUG=no"}
+	outspec, err := DoSubstitution(spec)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("busybox:1.2", outspec.Services[0].Image))
+}
+
+func TestDoSubstitutionMaterializesSecretProperties(t *testing.T) {
+	password := "${PASSWORD}"
+	url := "postgres://admin:${PASSWORD}@db"
+	user := "$USER"
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			composetypes.ServiceConfig{
+				Name: "web",
+				Environment: composetypes.MappingWithEquals{
+					"DB_PASSWORD": &password,
+					"DB_URL":      &url,
+					"DB_USER":     &user,
+				},
+			},
+		},
+		Properties: map[string]types.Property{
+			"PASSWORD": {Sensitive: true},
+		},
+		PropertyValues: []string{"PASSWORD=s3cret", "USER=admin"},
+	}
+
+	// the values of the sensitive properties are substituted inline, unless
+	// they are materialized as secrets.
+	outspec, err := DoSubstitution(spec)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("s3cret", *outspec.Services[0].Environment["DB_PASSWORD"]))
+	assert.Check(t, is.Len(outspec.Secrets, 0))
+
+	spec.Properties["PASSWORD"] = types.Property{Sensitive: true, Secret: true}
+	outspec, err = DoSubstitution(spec)
+	assert.NilError(t, err)
+
+	environment := outspec.Services[0].Environment
+	assert.Check(t, is.Len(environment, 3))
+	assert.Check(t, is.Equal("/run/secrets/property_password", *environment["DB_PASSWORD_FILE"]))
+	assert.Check(t, is.Equal("postgres://admin:s3cret@db", *environment["DB_URL"]))
+	assert.Check(t, is.Equal("admin", *environment["DB_USER"]))
+	assert.Check(t, is.DeepEqual([]composetypes.ServiceSecretConfig{{Source: "property_password"}}, outspec.Services[0].Secrets))
+	assert.Check(t, is.DeepEqual(composetypes.SecretConfig{
+		Labels: composetypes.Labels{interfaces.PropertyLabel: "PASSWORD"},
+		Data:   []byte("s3cret"),
+	}, outspec.Secrets["property_password"]))
+
+	// the original spec is not modified.
+	assert.Check(t, is.Equal(password, *spec.Services[0].Environment["DB_PASSWORD"]))
+	assert.Check(t, is.Len(spec.Services[0].Secrets, 0))
+	assert.Check(t, is.Len(spec.Secrets, 0))
+}
+
+func TestDoSubstitutionReportsAllMissingVariables(t *testing.T) {
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			composetypes.ServiceConfig{Name: "web", Image: "${REGISTRY:?}/web:${TAG:?the tag is required}"},
+			composetypes.ServiceConfig{Name: "worker", Image: "${REGISTRY:?}/worker:latest"},
+		},
+		Properties: map[string]types.Property{
+			"DEBUG": {Type: types.PropertyTypeBool},
+		},
+		PropertyValues: []string{"DEBUG=maybe"},
+	}
+
+	_, err := DoSubstitution(spec)
+	assert.Assert(t, is.ErrorType(err, &types.VariablesError{}))
+	assert.Check(t, is.DeepEqual([]types.VariableError{
+		{
+			Variable: "DEBUG",
+			Message:  `property DEBUG: "maybe" is not a valid bool`,
+		},
+		{
+			Variable: "REGISTRY",
+			Message:  "required variable REGISTRY is missing a value",
+			Locations: []types.VariableLocation{
+				{Path: "services.web.image"},
+				{Path: "services.worker.image"},
+			},
+		},
+		{
+			Variable:  "TAG",
+			Message:   "required variable TAG is missing a value: the tag is required",
+			Locations: []types.VariableLocation{{Path: "services.web.image"}},
+		},
+	}, err.(*types.VariablesError).Variables))
+}
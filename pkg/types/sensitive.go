@@ -0,0 +1,194 @@
+package types
+
+import (
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/types"
+)
+
+// RedactedValue replaces the values of the sensitive properties of a stack in
+// the API responses. A stack updated with this value keeps its current value.
+const RedactedValue = "<redacted>"
+
+// MarkSensitive marks properties of a stack sensitive, declaring them if
+// needed.
+func (s *StackSpec) MarkSensitive(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	properties := make(map[string]Property, len(s.Properties)+len(names))
+	for name, property := range s.Properties {
+		properties[name] = property
+	}
+	for _, name := range names {
+		property := properties[name]
+		property.Sensitive = true
+		properties[name] = property
+	}
+	s.Properties = properties
+}
+
+// HasSensitiveProperties returns whether a stack has sensitive properties.
+func (s StackSpec) HasSensitiveProperties() bool {
+	for _, property := range s.Properties {
+		if property.Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSecretData returns whether secrets of a stack hold the content of their
+// file.
+func (s StackSpec) HasSecretData() bool {
+	for _, secret := range s.Secrets {
+		if secret.Data != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSensitive returns whether a stack holds values which must be redacted
+// from the API responses and encrypted at rest: the values of its sensitive
+// properties, or the content of its secrets.
+func (s StackSpec) IsSensitive() bool {
+	return s.HasSensitiveProperties() || s.HasSecretData()
+}
+
+// SensitiveValues returns the values of the sensitive properties of a stack,
+// including their defaults, keyed by property name.
+func (s StackSpec) SensitiveValues() map[string]string {
+	values := map[string]string{}
+	for name, property := range s.Properties {
+		if property.Sensitive && property.Default != nil && *property.Default != "" {
+			values[name] = *property.Default
+		}
+	}
+	for _, keyval := range s.PropertyValues {
+		split := strings.SplitN(keyval, "=", 2)
+		if len(split) == 2 && split[1] != "" && s.Properties[split[0]].Sensitive {
+			values[split[0]] = split[1]
+		}
+	}
+	return values
+}
+
+// Redacted returns a copy of a stack spec whose sensitive property values and
+// defaults are replaced with RedactedValue, and whose secrets are stripped of
+// the content of their file.
+func (s StackSpec) Redacted() StackSpec {
+	if s.HasSecretData() {
+		secrets := make(map[string]types.SecretConfig, len(s.Secrets))
+		for name, secret := range s.Secrets {
+			secret.Data = nil
+			secrets[name] = secret
+		}
+		s.Secrets = secrets
+	}
+	if !s.HasSensitiveProperties() {
+		return s
+	}
+
+	properties := make(map[string]Property, len(s.Properties))
+	for name, property := range s.Properties {
+		if property.Sensitive && property.Default != nil {
+			redacted := RedactedValue
+			property.Default = &redacted
+		}
+		properties[name] = property
+	}
+	s.Properties = properties
+
+	values := make([]string, len(s.PropertyValues))
+	for i, keyval := range s.PropertyValues {
+		split := strings.SplitN(keyval, "=", 2)
+		if len(split) == 2 && properties[split[0]].Sensitive {
+			keyval = split[0] + "=" + RedactedValue
+		}
+		values[i] = keyval
+	}
+	s.PropertyValues = values
+	return s
+}
+
+// RedactStatus returns a copy of the status of a stack in which the values of
+// the sensitive properties of the stack are replaced with RedactedValue.
+func (s StackSpec) RedactStatus(status StackStatus) StackStatus {
+	values := s.SensitiveValues()
+	if len(values) == 0 {
+		return status
+	}
+	oldnew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldnew = append(oldnew, value, RedactedValue)
+	}
+	replacer := strings.NewReplacer(oldnew...)
+
+	status.Message = replacer.Replace(status.Message)
+	if status.Drift != nil {
+		drift := make([]ResourceDrift, len(status.Drift))
+		for i, d := range status.Drift {
+			d.Current = replacer.Replace(d.Current)
+			d.Desired = replacer.Replace(d.Desired)
+			drift[i] = d
+		}
+		status.Drift = drift
+	}
+	return status
+}
+
+// Redacted returns a copy of a stack whose sensitive property values are
+// replaced with RedactedValue, in its spec as well as in its status.
+func (s Stack) Redacted() Stack {
+	s.Status = s.Spec.RedactStatus(s.Status)
+	s.Spec = s.Spec.Redacted()
+	return s
+}
+
+// WithSensitiveValues returns a copy of a stack spec whose sensitive property
+// values and defaults set to RedactedValue are replaced with their values in
+// a previous version of the stack spec, so that a stack returned by the API
+// can be updated without providing its sensitive values again. Likewise, the
+// secrets without content are given the content of the secret of the same
+// name and file in the previous version.
+func (s StackSpec) WithSensitiveValues(previous StackSpec) StackSpec {
+	if previous.HasSecretData() {
+		secrets := make(map[string]types.SecretConfig, len(s.Secrets))
+		for name, secret := range s.Secrets {
+			if old, ok := previous.Secrets[name]; ok && secret.Data == nil && secret.File != "" && secret.File == old.File {
+				secret.Data = old.Data
+			}
+			secrets[name] = secret
+		}
+		s.Secrets = secrets
+	}
+	if !s.HasSensitiveProperties() {
+		return s
+	}
+	previousValues := previous.SensitiveValues()
+
+	properties := make(map[string]Property, len(s.Properties))
+	for name, property := range s.Properties {
+		if property.Sensitive && property.Default != nil && *property.Default == RedactedValue {
+			if old, ok := previous.Properties[name]; ok && old.Default != nil {
+				property.Default = old.Default
+			}
+		}
+		properties[name] = property
+	}
+	s.Properties = properties
+
+	values := make([]string, len(s.PropertyValues))
+	for i, keyval := range s.PropertyValues {
+		split := strings.SplitN(keyval, "=", 2)
+		if len(split) == 2 && split[1] == RedactedValue && properties[split[0]].Sensitive {
+			if old, ok := previousValues[split[0]]; ok {
+				keyval = split[0] + "=" + old
+			}
+		}
+		values[i] = keyval
+	}
+	s.PropertyValues = values
+	return s
+}
@@ -1,6 +1,12 @@
 package types
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/stacks/pkg/compose/types"
 )
@@ -13,6 +19,8 @@ type Stack struct {
 	StackResources StackResources     `json:"stack_resources"`
 	Orchestrator   OrchestratorChoice `json:"orchestrator"`
 	Status         StackStatus        `json:"stack_status"`
+	// Paused is true if the reconciliation of the stack is paused.
+	Paused bool `json:"paused,omitempty"`
 
 	// TODO - temporary (not in swagger)
 	ID string
@@ -21,11 +29,23 @@ type Stack struct {
 // StackCreateOptions is input to the Create operation for a Stack
 type StackCreateOptions struct {
 	EncodedRegistryAuth string
+	// Strict makes the operation fail if the stack has warnings, instead of
+	// returning them along with the response.
+	Strict bool
+	// SensitiveProperties are the names of the properties whose values are
+	// sensitive, in addition to these declared sensitive by the stack.
+	SensitiveProperties []string
 }
 
 // StackUpdateOptions is input to the Update operation for a Stack
 type StackUpdateOptions struct {
 	EncodedRegistryAuth string
+	// Strict makes the operation fail if the stack has warnings, instead of
+	// returning them along with the response.
+	Strict bool
+	// SensitiveProperties are the names of the properties whose values are
+	// sensitive, in addition to these declared sensitive by the stack.
+	SensitiveProperties []string
 }
 
 // StackListOptions is input to the List operation for a Stack
@@ -33,6 +53,64 @@ type StackListOptions struct {
 	Filters filters.Args
 }
 
+// StackWaitOptions is input to the Wait operation for a Stack
+type StackWaitOptions struct {
+	// Condition is the condition to wait for. If empty, the Wait operation
+	// waits for the stack to be converged.
+	Condition StackWaitCondition
+	// Timeout is the maximum amount of time to wait for. A zero value means
+	// no timeout.
+	Timeout time.Duration
+}
+
+// StackPatchOptions is input to the Patch and SetImage operations for a Stack
+type StackPatchOptions struct {
+	// Version, if set, is the version the Stack must be at for the
+	// operation to be applied.
+	Version *Version
+}
+
+// StackRedeployOptions is input to the Redeploy operation for a Stack
+type StackRedeployOptions struct {
+	// Services is the list of names of services in the stack which should
+	// be redeployed. If empty, all services are redeployed.
+	Services []string
+}
+
+// StackScaleOptions is input to the Scale operation for a service of a Stack
+type StackScaleOptions struct {
+	// Replicas is the desired number of replicas of the service.
+	Replicas uint64
+}
+
+// StackLogsOptions is input to the Logs operation for a Stack
+type StackLogsOptions struct {
+	// Services is the list of names of services in the stack whose logs
+	// should be returned. If empty, the logs of all services are returned.
+	Services   []string
+	Follow     bool
+	Tail       string
+	Since      string
+	Timestamps bool
+}
+
+// StackWaitCondition is a condition that a Wait operation blocks on.
+type StackWaitCondition string
+
+const (
+	// StackWaitConditionConverged waits until every service of the stack is
+	// running its desired tasks on the latest spec of the stack.
+	StackWaitConditionConverged StackWaitCondition = "converged"
+
+	// StackWaitConditionRunning waits until every service of the stack is
+	// running its desired tasks, regardless of the spec they run.
+	StackWaitConditionRunning StackWaitCondition = "running"
+
+	// StackWaitConditionDeleted waits until the stack and all of its
+	// services have been removed.
+	StackWaitConditionDeleted StackWaitCondition = "deleted"
+)
+
 // Version represents the internal object version.
 type Version struct {
 	Index uint64 `json:",omitempty"`
@@ -43,6 +121,9 @@ type StackCreate struct {
 	Metadata
 	Spec         StackSpec          `json:"spec"`
 	Orchestrator OrchestratorChoice `json:"orchestrator"`
+	// Warnings are the warnings about the compose files the StackCreate
+	// was parsed from. They are ignored by the Create operation.
+	Warnings []Warning `json:"warnings,omitempty"`
 }
 
 // Metadata contains metadata for a Stack.
@@ -65,8 +146,145 @@ type StackSpec struct {
 	StackImage     string                           `json:"stack_image,omitempty"`
 	PropertyValues []string                         `json:"property_values,omitempty"`
 	Collection     string                           `json:"collection,omitempty"`
+	// DriftPolicy is the policy applied to the resources of the stack which
+	// are changed outside of the Stacks API. Defaults to DriftPolicyRevert.
+	DriftPolicy DriftPolicy `json:"drift_policy,omitempty"`
+	// WaitForDependencies makes the services of the stack roll out only once
+	// the services they depend on are running, and healthy if they have a
+	// healthcheck.
+	WaitForDependencies bool `json:"wait_for_dependencies,omitempty"`
+	// Properties are the declarations of the properties of the stack, keyed
+	// by name, from the x-properties extension of the compose files. The
+	// PropertyValues are validated against them.
+	Properties map[string]Property `json:"properties,omitempty"`
+}
+
+// PropertyType is the type of the value of a property.
+type PropertyType string
+
+const (
+	// PropertyTypeString is the type of the properties whose value can be
+	// any string. It is the default.
+	PropertyTypeString PropertyType = "string"
+
+	// PropertyTypeInt is the type of the properties whose value is an
+	// integer.
+	PropertyTypeInt PropertyType = "int"
+
+	// PropertyTypeBool is the type of the properties whose value is a
+	// boolean, such as "true" or "no".
+	PropertyTypeBool PropertyType = "bool"
+
+	// PropertyTypePort is the type of the properties whose value is a port
+	// number, from 1 to 65535.
+	PropertyTypePort PropertyType = "port"
+
+	// PropertyTypeDuration is the type of the properties whose value is a
+	// duration, such as "10s".
+	PropertyTypeDuration PropertyType = "duration"
+)
+
+// Property is the declaration of a property of a stack, i.e. of a variable of
+// its compose files.
+type Property struct {
+	Description string       `json:"description,omitempty"`
+	Type        PropertyType `json:"type,omitempty"`
+	// Required makes the creation and update of the stack fail if the
+	// property has no value.
+	Required bool `json:"required,omitempty"`
+	// Default is the default value of the property, if any.
+	Default *string `json:"default,omitempty"`
+	// Enum, if not empty, lists the values the property can have.
+	Enum []string `json:"enum,omitempty"`
+	// Regex, if set, is a regular expression the whole value of the
+	// property must match.
+	Regex string `json:"regex,omitempty"`
+	// Sensitive values, such as passwords, are encrypted in the store and
+	// redacted in the API responses.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// Secret materializes the value of a sensitive property as a swarm
+	// secret: the environment variables whose whole value is the variable
+	// of the property, e.g. DB_PASSWORD=${PASSWORD}, are replaced with
+	// variables suffixed with _FILE, e.g. DB_PASSWORD_FILE, holding the path
+	// of the secret in the containers.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// Validate returns an error if a value doesn't satisfy the declaration of a
+// property. The errors quote the value, unless the property is sensitive.
+func (p Property) Validate(value string) error {
+	quoted := fmt.Sprintf("%q", value)
+	if p.Sensitive {
+		quoted = "the value"
+	}
+
+	var err error
+	switch p.Type {
+	case "", PropertyTypeString:
+	case PropertyTypeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case PropertyTypeBool:
+		switch strings.ToLower(value) {
+		case "y", "yes", "true", "on", "n", "no", "false", "off":
+		default:
+			err = fmt.Errorf("invalid boolean")
+		}
+	case PropertyTypePort:
+		var port uint64
+		port, err = strconv.ParseUint(value, 10, 16)
+		if err == nil && port == 0 {
+			err = fmt.Errorf("invalid port")
+		}
+	case PropertyTypeDuration:
+		_, err = time.ParseDuration(value)
+	default:
+		return fmt.Errorf("unknown type %s", p.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%s is not a valid %s", quoted, p.Type)
+	}
+
+	if len(p.Enum) > 0 {
+		found := false
+		for _, allowed := range p.Enum {
+			found = found || allowed == value
+		}
+		if !found {
+			return fmt.Errorf("%s is not one of %s", quoted, strings.Join(p.Enum, ", "))
+		}
+	}
+
+	if p.Regex != "" {
+		re, err := regexp.Compile("^(?:" + p.Regex + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex %s: %s", p.Regex, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%s does not match %s", quoted, p.Regex)
+		}
+	}
+	return nil
 }
 
+// DriftPolicy defines how the resources of a stack which have drifted from the
+// stack spec, i.e. which were changed outside of the Stacks API, are handled.
+type DriftPolicy string
+
+const (
+	// DriftPolicyRevert reverts the resources which have drifted to the
+	// stack spec, after reporting the drift.
+	DriftPolicyRevert DriftPolicy = "revert"
+
+	// DriftPolicyReportOnly reports the drift, and leaves the resources
+	// as they are.
+	DriftPolicyReportOnly DriftPolicy = "report-only"
+
+	// DriftPolicyAdopt reports the drift, and folds the changes back into
+	// the stack spec. Changes which cannot be represented in the stack spec
+	// are reverted.
+	DriftPolicyAdopt DriftPolicy = "adopt"
+)
+
 // StackResources links to the running instances of the StackSpec
 type StackResources struct {
 	Services map[string]StackResource `json:"services,omitempty"`
@@ -95,9 +313,45 @@ type StackStatus struct {
 	// ServicesStatus contains the last known status of the service
 	// The service name is the key in the map.
 	ServicesStatus map[string]ServiceStatus `json:"services_status"`
-	LastUpdated    string                   `json:"last_updated"`
+	// Drift contains the fields of the resources of the stack which were
+	// changed outside of the Stacks API, and which differ from the spec.
+	Drift       []ResourceDrift `json:"drift,omitempty"`
+	LastUpdated string          `json:"last_updated"`
+}
+
+// ResourceDrift describes a field of a resource of a stack which differs from
+// the stack spec, following a change made outside of the Stacks API.
+type ResourceDrift struct {
+	// Kind is the kind of the resource, e.g. "service".
+	Kind string `json:"kind"`
+	// Name is the name of the resource.
+	Name string `json:"name"`
+	// Field is the field of the resource which has changed.
+	Field string `json:"field"`
+	// Current is the current value of the field.
+	Current string `json:"current"`
+	// Desired is the value of the field defined by the stack spec.
+	Desired string `json:"desired"`
 }
 
+const (
+	// StackPhasePending is the phase of a stack with services that are
+	// missing or not running all of their desired tasks.
+	StackPhasePending = "pending"
+
+	// StackPhaseRunning is the phase of a stack whose services are running
+	// all of their desired tasks, but are not yet on the latest stack spec.
+	StackPhaseRunning = "running"
+
+	// StackPhaseConverged is the phase of a stack whose services are running
+	// all of their desired tasks on the latest stack spec.
+	StackPhaseConverged = "converged"
+
+	// StackPhaseDeleted is the phase of a stack which has been removed along
+	// with all of its services.
+	StackPhaseDeleted = "deleted"
+)
+
 // ServiceStatus represents the latest known status of a service
 type ServiceStatus struct {
 	// DesiredTasks represents the expected number of running tasks
@@ -141,10 +395,176 @@ const (
 // ComposeInput carries one or more compose files for parsing by the server
 type ComposeInput struct {
 	ComposeFiles []string `json:"compose_files"`
+	// Filenames are the names of the compose files, if known, used in the
+	// errors about the compose files.
+	Filenames []string `json:"filenames,omitempty"`
+	// Files carries the other files of the project referenced by the compose
+	// files, such as the .env file, env files and the files of secrets and
+	// configs, keyed by their slash separated path relative to the project
+	// directory.
+	Files map[string][]byte `json:"files,omitempty"`
+	// Profiles are the profiles enabled for the services of the compose
+	// files. The services with profiles are left out of the stack, unless
+	// one of their profiles is enabled.
+	Profiles []string `json:"profiles,omitempty"`
+	// Strict makes the parsing fail if the compose files have warnings,
+	// instead of returning them along with the StackCreate.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// ComposeError is an error of one of the compose files of a ComposeInput,
+// located in the file when possible. It is returned by the parsecompose
+// operation.
+type ComposeError struct {
+	// FileIndex is the index of the file in ComposeInput.ComposeFiles.
+	FileIndex int `json:"file_index"`
+	// File is the name of the file, if known.
+	File string `json:"file,omitempty"`
+	// Line and Column are the position in the file of the value the error
+	// is about, starting at 1, or 0 if unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Path is the dotted path of the value the error is about, if known,
+	// e.g. "services.web.ports.0".
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ComposeError) Error() string {
+	location := e.File
+	if location == "" {
+		location = fmt.Sprintf("compose file %d", e.FileIndex+1)
+	}
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Line)
+	}
+	if e.Column > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Column)
+	}
+	return location + ": " + e.Message
 }
 
 // StackCreateResponse is the response type of the Create Stack
 // operation.
 type StackCreateResponse struct {
-	ID string
+	ID       string
+	Warnings []Warning `json:",omitempty"`
+}
+
+// StackUpdateResponse is the response type of the Update Stack
+// operation.
+type StackUpdateResponse struct {
+	Warnings []Warning `json:",omitempty"`
+}
+
+// WarningSeverity is the severity of a Warning.
+type WarningSeverity string
+
+const (
+	// WarningSeverityWarning is the severity of the warnings about
+	// properties which are ignored, so that the stack may not behave as
+	// intended.
+	WarningSeverityWarning WarningSeverity = "warning"
+
+	// WarningSeverityInfo is the severity of the warnings about properties
+	// which are ignored, without impacting the behaviour of the stack.
+	WarningSeverityInfo WarningSeverity = "info"
+)
+
+// Warning is a warning about a property of a stack, such as a property of
+// the compose files which isn't supported and is ignored.
+type Warning struct {
+	// Property is the dotted path of the property, e.g.
+	// "services.web.cap_add".
+	Property string `json:"property"`
+	// Service is the name of the service the property is set on, if any.
+	Service  string          `json:"service,omitempty"`
+	Message  string          `json:"message"`
+	Severity WarningSeverity `json:"severity"`
+}
+
+// WarningsError is the error of the operations in strict mode when the
+// stack has warnings.
+type WarningsError struct {
+	Warnings []Warning
+}
+
+func (e *WarningsError) Error() string {
+	messages := make([]string, 0, len(e.Warnings))
+	for _, warning := range e.Warnings {
+		messages = append(messages, fmt.Sprintf("%s: %s", warning.Property, warning.Message))
+	}
+	return fmt.Sprintf("stack has %d warning(s):\n%s", len(e.Warnings), strings.Join(messages, "\n"))
+}
+
+// InvalidParameter marks the error as an invalid parameter error, see
+// github.com/docker/docker/errdefs.
+func (e *WarningsError) InvalidParameter() {}
+
+// VariablesError is the error of the stacks whose variables are missing a
+// value or have an invalid value. It lists all of them at once.
+type VariablesError struct {
+	Variables []VariableError `json:"variables"`
+}
+
+func (e *VariablesError) Error() string {
+	messages := make([]string, 0, len(e.Variables))
+	for _, variable := range e.Variables {
+		messages = append(messages, variable.String())
+	}
+	return fmt.Sprintf("stack has %d invalid variable(s):\n%s", len(e.Variables), strings.Join(messages, "\n"))
+}
+
+// InvalidParameter marks the error as an invalid parameter error, see
+// github.com/docker/docker/errdefs.
+func (e *VariablesError) InvalidParameter() {}
+
+// VariableError is a variable missing a value, or with an invalid value.
+type VariableError struct {
+	// Variable is the name of the variable, if known.
+	Variable string `json:"variable,omitempty"`
+	Message  string `json:"message"`
+	// Locations are the values referring to the variable.
+	Locations []VariableLocation `json:"locations,omitempty"`
+}
+
+func (e VariableError) String() string {
+	if len(e.Locations) == 0 {
+		return e.Message
+	}
+	locations := make([]string, 0, len(e.Locations))
+	for _, location := range e.Locations {
+		locations = append(locations, location.String())
+	}
+	return fmt.Sprintf("%s (at %s)", e.Message, strings.Join(locations, ", "))
+}
+
+// VariableLocation is the location of a value referring to a variable, in a
+// compose file when known.
+type VariableLocation struct {
+	// FileIndex is the index of the file in ComposeInput.ComposeFiles.
+	FileIndex int `json:"file_index,omitempty"`
+	// File is the name of the file, if known.
+	File string `json:"file,omitempty"`
+	// Line and Column are the position in the file of the value, starting
+	// at 1, or 0 if unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Path is the dotted path of the value, e.g. "services.web.image".
+	Path string `json:"path"`
+}
+
+func (l VariableLocation) String() string {
+	if l.Line == 0 {
+		return l.Path
+	}
+	location := l.File
+	if location == "" {
+		location = fmt.Sprintf("compose file %d", l.FileIndex+1)
+	}
+	location = fmt.Sprintf("%s:%d", location, l.Line)
+	if l.Column > 0 {
+		location = fmt.Sprintf("%s:%d", location, l.Column)
+	}
+	return fmt.Sprintf("%s %s", location, l.Path)
 }